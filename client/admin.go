@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cooljeffrey/petstore/model"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CreateWebhook subscribes webhook to events, returning it with its id and secret.
+func (c *Client) CreateWebhook(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error) {
+	r, err := newRequest(http.MethodPost, "/webhooks").json(webhook)
+	if err != nil {
+		return nil, err
+	}
+	var created *model.Webhook
+	_, err = c.do(ctx, r, &created)
+	return created, err
+}
+
+// FindWebhooks lists all webhooks.
+func (c *Client) FindWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/webhooks"), &webhooks)
+	return webhooks, err
+}
+
+// GetWebhook fetches a webhook by id.
+func (c *Client) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	var webhook *model.Webhook
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/webhooks/"+formatID(id)), &webhook)
+	return webhook, err
+}
+
+// DeleteWebhook unsubscribes a webhook.
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, "/webhooks/"+formatID(id)), nil)
+	return err
+}
+
+// FindDeliveries lists deliveries of a webhook in status, or in any status when it is empty.
+func (c *Client) FindDeliveries(ctx context.Context, webhookID int64, status string) ([]*model.Delivery, error) {
+	r := newRequest(http.MethodGet, "/webhooks/"+formatID(webhookID)+"/deliveries")
+	if status != "" {
+		r.query.Set("status", status)
+	}
+	var deliveries []*model.Delivery
+	_, err := c.do(ctx, r, &deliveries)
+	return deliveries, err
+}
+
+// RetryDelivery sends a failed delivery of a webhook again.
+func (c *Client) RetryDelivery(ctx context.Context, webhookID int64, deliveryID string) (*model.Delivery, error) {
+	path := "/webhooks/" + formatID(webhookID) + "/deliveries/" + url.PathEscape(deliveryID) + "/retry"
+	var delivery *model.Delivery
+	_, err := c.do(ctx, newRequest(http.MethodPost, path), &delivery)
+	return delivery, err
+}
+
+// FindAuditEvents finds one page of audit events matching filter, with the total count of matches.
+func (c *Client) FindAuditEvents(ctx context.Context, filter model.AuditFilter) ([]*model.AuditEvent, int64, error) {
+	r := newRequest(http.MethodGet, "/admin/audit")
+	if filter.Entity != "" {
+		r.query.Set("entity", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		r.query.Set("entityId", formatID(filter.EntityID))
+	}
+	if filter.Actor != "" {
+		r.query.Set("actor", filter.Actor)
+	}
+	setTime(r.query, "from", filter.From)
+	setTime(r.query, "to", filter.To)
+	setPage(r.query, filter.Offset, filter.Limit)
+	var events []*model.AuditEvent
+	header, err := c.do(ctx, r, &events)
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, totalCount(header), nil
+}
+
+// CacheStats reports how well the cache of pets and users is doing.
+func (c *Client) CacheStats(ctx context.Context) (*model.CacheStats, error) {
+	var stats *model.CacheStats
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/admin/cache"), &stats)
+	return stats, err
+}
+
+// GraphQLErrors are the errors of a GraphQL query, some of its fields possibly resolved nonetheless.
+type GraphQLErrors []GraphQLError
+
+// GraphQLError is an error resolving a GraphQL query, Path telling the field failed.
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return "petstore: graphql: " + strings.Join(messages, "; ")
+}
+
+// GraphQL runs query with variables, decoding its data into out unless it is nil. Data is decoded even when the
+// query fails in part, the error being GraphQLErrors.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	r, err := newRequest(http.MethodPost, "/graphql").json(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Data   json.RawMessage `json:"data"`
+		Errors GraphQLErrors   `json:"errors"`
+	}
+	if _, err = c.do(ctx, r, &res); err != nil {
+		return err
+	}
+	if out != nil && len(res.Data) > 0 {
+		if err = json.Unmarshal(res.Data, out); err != nil {
+			return err
+		}
+	}
+	if len(res.Errors) > 0 {
+		return res.Errors
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+// Package client is a Go client of the petstore REST API, decoding responses into the model types.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy tells how often and how soon idempotent calls failing with a network error, 429, 502, 503 or 504
+// are tried again. Delays double from BaseDelay up to MaxDelay, with jitter, unless the server asks for a
+// longer one with Retry-After.
+type RetryPolicy struct {
+	// Attempts in all, 1 to never retry
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// delay returns how long to wait before retrying after attempt, counted from 1.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay << uint(attempt-1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// Auth authenticates requests of a client.
+type Auth interface {
+	Authenticate(req *http.Request)
+}
+
+// AuthFunc is an Auth calling itself.
+type AuthFunc func(req *http.Request)
+
+func (f AuthFunc) Authenticate(req *http.Request) {
+	f(req)
+}
+
+// APIKey authenticates with token in api_key header, a session token or the admin api key.
+func APIKey(token string) Auth {
+	return AuthFunc(func(req *http.Request) {
+		req.Header.Set("api_key", token)
+	})
+}
+
+// Bearer authenticates with a session token in Authorization header.
+func Bearer(token string) Auth {
+	return AuthFunc(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	})
+}
+
+// Client calls the petstore API at BaseURL, the URL the /v2 routes are under.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Auth of requests, anonymous when nil
+	Auth  Auth
+	Retry RetryPolicy
+}
+
+// New returns a client of the API at baseURL, eg. http://localhost:8080/v2, authenticating with auth unless it
+// is nil.
+func New(baseURL string, auth Auth) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		Auth:       auth,
+		Retry:      DefaultRetryPolicy,
+	}
+}
+
+// WithAuth returns a copy of the client authenticating with auth.
+func (c *Client) WithAuth(auth Auth) *Client {
+	copied := *c
+	copied.Auth = auth
+	return &copied
+}
+
+// Error is the error response of a failed call, Message being the status text when the response has no body.
+type Error struct {
+	StatusCode int
+	Type       string
+	Message    string
+	// Items failed of a batch call
+	Items []model.BatchItemError
+	// Delay asked for by the server before calling again
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("petstore: %d %s", e.StatusCode, e.Message)
+}
+
+func hasStatus(err error, code int) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == code
+}
+
+// IsNotFound tells whether err reports a document not found.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized tells whether err reports a call without valid credentials.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden tells whether err reports a call the caller has no permission for.
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// IsPreconditionFailed tells whether err reports a document changed since the version given.
+func IsPreconditionFailed(err error) bool {
+	return hasStatus(err, http.StatusPreconditionFailed)
+}
+
+// IsConflict tells whether err reports a change conflicting with the state of a document.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsTooManyRequests tells whether err reports a call throttled by the server, see Error.RetryAfter.
+func IsTooManyRequests(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// request is a call to the API.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        []byte
+	contentType string
+}
+
+func newRequest(method, path string) *request {
+	return &request{method: method, path: path, query: url.Values{}, header: http.Header{}}
+}
+
+// json sets body to v encoded in JSON.
+func (r *request) json(v interface{}) (*request, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	r.body, r.contentType = b, "application/json"
+	return r, nil
+}
+
+// form sets body to values form encoded.
+func (r *request) form(values url.Values) *request {
+	r.body, r.contentType = []byte(values.Encode()), "application/x-www-form-urlencoded"
+	return r
+}
+
+// ifMatch makes the call conditional on the document being at version, unless it is 0.
+func (r *request) ifMatch(version int64) *request {
+	if version != 0 {
+		r.header.Set("If-Match", etag(version))
+	}
+	return r
+}
+
+func (r *request) idempotent() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// do makes the call, retrying it as the retry policy allows, and decodes the JSON response into out unless it is
+// nil. It returns the response headers.
+func (c *Client) do(ctx context.Context, r *request, out interface{}) (http.Header, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := c.send(ctx, r)
+		if err == nil && res.StatusCode < 400 {
+			defer res.Body.Close()
+			if out != nil && res.StatusCode != http.StatusNoContent {
+				if err = json.NewDecoder(res.Body).Decode(out); err == io.EOF {
+					err = nil
+				}
+			}
+			return res.Header, err
+		}
+		if err == nil {
+			err = decodeError(res)
+			res.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt >= c.Retry.MaxAttempts || !r.idempotent() || !retryable(err) {
+			return nil, err
+		}
+		delay := c.Retry.delay(attempt)
+		if e, ok := err.(*Error); ok && e.RetryAfter > delay {
+			delay = e.RetryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt of the call.
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	u := c.BaseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequest(r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if c.Auth != nil {
+		c.Auth.Authenticate(req)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+// retryable tells whether err may go away when trying again.
+func retryable(err error) bool {
+	e, ok := err.(*Error)
+	if !ok {
+		// network error
+		return true
+	}
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// decodeError reads the ErrResponse in the body of res, if any.
+func decodeError(res *http.Response) error {
+	var body struct {
+		model.ErrResponse
+		Items []model.BatchItemError `json:"items"`
+	}
+	e := &Error{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+	if b, err := ioutil.ReadAll(res.Body); err == nil && json.Unmarshal(b, &body) == nil && body.Message != "" {
+		e.Type, e.Message, e.Items = body.Type, body.Message, body.Items
+	}
+	if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s > 0 {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+	return e
+}
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// version reads the version of a document from the ETag header, 0 when there is none.
+func version(header http.Header) int64 {
+	v, _ := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header.Get("ETag"), "W/"), `"`), 10, 64)
+	return v
+}
+
+// totalCount reads the X-Total-Count header of listings.
+func totalCount(header http.Header) int64 {
+	n, _ := strconv.ParseInt(header.Get("X-Total-Count"), 10, 64)
+	return n
+}
+
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,190 @@
+package client
+
+// This is to test the client against the real router, with services faked in memory
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/cooljeffrey/petstore/service"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const adminKey = "secret"
+
+// testPets keeps pets in a map, other PetService methods are not implemented.
+type testPets struct {
+	service.PetService
+	pets map[int64]*model.Pet
+}
+
+func (s *testPets) AddPet(ctx context.Context, pet *model.Pet) error {
+	pet.Version = 1
+	s.pets[pet.ID] = pet
+	return nil
+}
+
+func (s *testPets) FindPetByID(ctx context.Context, id int64) (*model.Pet, error) {
+	if pet, ok := s.pets[id]; ok {
+		return pet, nil
+	}
+	return nil, model.ErrNotFound
+}
+
+func (s *testPets) UpdatePet(ctx context.Context, pet *model.Pet) error {
+	existing, ok := s.pets[pet.ID]
+	if !ok {
+		return model.ErrNotFound
+	}
+	if pet.Version != 0 && pet.Version != existing.Version {
+		return model.ErrVersionMismatch
+	}
+	pet.Version = existing.Version + 1
+	s.pets[pet.ID] = pet
+	return nil
+}
+
+// testUsers authenticates tokens named after users, other UserService methods are not implemented.
+type testUsers struct {
+	service.UserService
+	users map[string]*model.User
+}
+
+func (s *testUsers) Authenticate(ctx context.Context, token string) (*service.Principal, error) {
+	if u, ok := s.users[token]; ok {
+		return &service.Principal{UserID: u.ID, Username: u.Username, Role: u.Role}, nil
+	}
+	return nil, service.ErrInvalidToken
+}
+
+func (s *testUsers) Login(ctx context.Context, username, password string) (*model.Session, error) {
+	u, ok := s.users[username]
+	if !ok || password != "password" {
+		return nil, service.ErrInvalidCredentials
+	}
+	return model.NewSession(username, u, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)), nil
+}
+
+func (s *testUsers) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	if u, ok := s.users[username]; ok {
+		return u, nil
+	}
+	return nil, model.ErrNotFound
+}
+
+func newTestServer(handler func(http.Handler) http.Handler) *httptest.Server {
+	services := &service.Services{
+		PetService: &testPets{pets: map[int64]*model.Pet{}},
+		UserService: &testUsers{users: map[string]*model.User{
+			"alice": {ID: 1, Username: "alice", Role: model.RoleCustomer},
+		}},
+	}
+	var h http.Handler = service.SetupRoutes(services, service.Options{AdminAPIKey: adminKey}, log.NewNopLogger())
+	if handler != nil {
+		h = handler(h)
+	}
+	return httptest.NewServer(h)
+}
+
+func TestClientPets(t *testing.T) {
+	server := newTestServer(nil)
+	defer server.Close()
+	ctx := context.Background()
+	admin := New(server.URL+"/v2", APIKey(adminKey))
+
+	assert.NoError(t, admin.AddPet(ctx, &model.Pet{ID: 1, Name: "Tom", Status: "available"}))
+	pet, err := admin.GetPet(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tom", pet.Name)
+
+	pet.Name = "Thomas"
+	assert.NoError(t, admin.UpdatePet(ctx, pet))
+	assert.Equal(t, int64(2), pet.Version)
+	stale := &model.Pet{ID: 1, Name: "Tommy", Version: 1}
+	assert.True(t, IsPreconditionFailed(admin.UpdatePet(ctx, stale)))
+
+	_, err = admin.GetPet(ctx, 9)
+	assert.True(t, IsNotFound(err))
+
+	// pets are managed by staff only
+	err = New(server.URL+"/v2", nil).AddPet(ctx, &model.Pet{ID: 2, Name: "Spike"})
+	assert.True(t, IsUnauthorized(err))
+	err = admin.WithAuth(Bearer("alice")).AddPet(ctx, &model.Pet{ID: 2, Name: "Spike"})
+	assert.True(t, IsForbidden(err))
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, "permission denied", err.(*Error).Message)
+	}
+}
+
+func TestClientLogin(t *testing.T) {
+	server := newTestServer(nil)
+	defer server.Close()
+	ctx := context.Background()
+	c := New(server.URL+"/v2", nil)
+
+	token, expiresAt, err := c.Login(ctx, "alice", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", token)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), expiresAt.UTC())
+
+	user, err := c.WithAuth(Bearer(token)).GetUser(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), user.ID)
+
+	_, _, err = c.Login(ctx, "alice", "wrong")
+	assert.True(t, IsUnauthorized(err))
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls, failures int32
+	server := newTestServer(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	defer server.Close()
+	ctx := context.Background()
+	c := New(server.URL+"/v2", APIKey(adminKey))
+	c.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	// idempotent calls are tried again
+	atomic.StoreInt32(&failures, 2)
+	_, err := c.GetPet(ctx, 1)
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	atomic.StoreInt32(&failures, 3)
+	_, err = c.GetPet(ctx, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, err.(*Error).StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// others are not
+	atomic.StoreInt32(&calls, 0)
+	atomic.StoreInt32(&failures, 1)
+	err = c.AddPet(ctx, &model.Pet{ID: 1, Name: "Tom"})
+	assert.Equal(t, http.StatusServiceUnavailable, err.(*Error).StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// waiting to try again stops with the context
+	atomic.StoreInt32(&calls, 0)
+	atomic.StoreInt32(&failures, 100)
+	c.Retry.BaseDelay, c.Retry.MaxDelay = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.GetPet(ctx, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
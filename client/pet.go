@@ -0,0 +1,216 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/cooljeffrey/petstore/model"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// PatchOperation is an operation of a JSON Patch, Value being encoded in JSON.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// FindPetsByStatus finds pets of any of statuses.
+func (c *Client) FindPetsByStatus(ctx context.Context, statuses ...string) ([]*model.Pet, error) {
+	r := newRequest(http.MethodGet, "/pet/findByStatus")
+	r.query.Set("status", strings.Join(statuses, ","))
+	var pets []*model.Pet
+	_, err := c.do(ctx, r, &pets)
+	return pets, err
+}
+
+// SearchPets finds one page of pets matching search, most relevant first, with the total count of matches.
+func (c *Client) SearchPets(ctx context.Context, search model.PetSearch) ([]*model.PetSearchResult, int64, error) {
+	r := newRequest(http.MethodGet, "/pet/search")
+	r.query.Set("q", search.Query)
+	if len(search.Statuses) > 0 {
+		r.query.Set("status", strings.Join(search.Statuses, ","))
+	}
+	if search.Fuzzy {
+		r.query.Set("fuzzy", "true")
+	}
+	setPage(r.query, search.Offset, search.Limit)
+	var results []*model.PetSearchResult
+	header, err := c.do(ctx, r, &results)
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, totalCount(header), nil
+}
+
+// AddPet creates pet.
+func (c *Client) AddPet(ctx context.Context, pet *model.Pet) error {
+	r, err := newRequest(http.MethodPost, "/pet").json(pet)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, r, nil)
+	return err
+}
+
+// AddPets creates pets, all or none of them when atomic and each on its own otherwise. The results tell what
+// happened to each pet, and are returned along with the *Error of an atomic batch refused.
+func (c *Client) AddPets(ctx context.Context, pets []*model.Pet, atomic bool) ([]model.BatchItemResult, error) {
+	r, err := newRequest(http.MethodPost, "/pet/batch").json(pets)
+	if err != nil {
+		return nil, err
+	}
+	r.query.Set("mode", "partial")
+	if atomic {
+		r.query.Set("mode", "atomic")
+	}
+	// refused batches answer 400 with the results, not an error response
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var results []model.BatchItemResult
+	if res.StatusCode == http.StatusBadRequest && strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		var body bytes.Buffer
+		if _, err = body.ReadFrom(res.Body); err != nil {
+			return nil, err
+		}
+		if json.Unmarshal(body.Bytes(), &results) == nil {
+			e := &Error{StatusCode: res.StatusCode, Message: "batch refused"}
+			for _, item := range results {
+				if item.Status == model.BatchItemFailed {
+					e.Items = append(e.Items, model.BatchItemError{Index: item.Index, ID: item.ID, Message: item.Error})
+				}
+			}
+			return results, e
+		}
+		res.Body = readCloser{bytes.NewReader(body.Bytes()), res.Body}
+	}
+	if res.StatusCode >= 400 {
+		return nil, decodeError(res)
+	}
+	return results, json.NewDecoder(res.Body).Decode(&results)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// UpdatePet replaces pet, only if it is at pet.Version unless it is 0, and sets pet.Version to the new version.
+func (c *Client) UpdatePet(ctx context.Context, pet *model.Pet) error {
+	r, err := newRequest(http.MethodPut, "/pet").json(pet)
+	if err != nil {
+		return err
+	}
+	header, err := c.do(ctx, r.ifMatch(pet.Version), nil)
+	if err != nil {
+		return err
+	}
+	pet.Version = version(header)
+	return nil
+}
+
+// GetPet fetches pet by id.
+func (c *Client) GetPet(ctx context.Context, id int64) (*model.Pet, error) {
+	var pet *model.Pet
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/pet/"+formatID(id)), &pet)
+	return pet, err
+}
+
+// UpdatePetWithForm updates name and status of a pet, leaving those empty as they are, only if it is at version
+// unless it is 0.
+func (c *Client) UpdatePetWithForm(ctx context.Context, id int64, version int64, name, status string) error {
+	form := url.Values{}
+	if name != "" {
+		form.Set("name", name)
+	}
+	if status != "" {
+		form.Set("status", status)
+	}
+	r := newRequest(http.MethodPost, "/pet/"+formatID(id)).form(form).ifMatch(version)
+	_, err := c.do(ctx, r, nil)
+	return err
+}
+
+// PatchPet applies a JSON merge patch to a pet, only if it is at version unless it is 0, and returns the patched
+// pet. Patch is encoded in JSON, a map or a struct with omitempty fields.
+func (c *Client) PatchPet(ctx context.Context, id int64, version int64, patch interface{}) (*model.Pet, error) {
+	var pet *model.Pet
+	return pet, c.patch(ctx, "/pet/"+formatID(id), version, patch, contentTypeMergePatch, &pet)
+}
+
+// JSONPatchPet applies JSON patch operations to a pet, only if it is at version unless it is 0, and returns the
+// patched pet.
+func (c *Client) JSONPatchPet(ctx context.Context, id int64, version int64, ops []PatchOperation) (*model.Pet, error) {
+	var pet *model.Pet
+	return pet, c.patch(ctx, "/pet/"+formatID(id), version, ops, contentTypeJSONPatch, &pet)
+}
+
+func (c *Client) patch(ctx context.Context, path string, version int64, patch interface{}, contentType string, out interface{}) error {
+	r, err := newRequest(http.MethodPatch, path).json(patch)
+	if err != nil {
+		return err
+	}
+	r.contentType = contentType
+	_, err = c.do(ctx, r.ifMatch(version), out)
+	return err
+}
+
+// DeletePet deletes a pet, for good when hard.
+func (c *Client) DeletePet(ctx context.Context, id int64, hard bool) error {
+	r := newRequest(http.MethodDelete, "/pet/"+formatID(id))
+	if hard {
+		r.query.Set("hard", "true")
+	}
+	_, err := c.do(ctx, r, nil)
+	return err
+}
+
+// RestorePet undoes the deletion of a pet.
+func (c *Client) RestorePet(ctx context.Context, id int64) (*model.Pet, error) {
+	var pet *model.Pet
+	_, err := c.do(ctx, newRequest(http.MethodPost, "/pet/"+formatID(id)+"/restore"), &pet)
+	return pet, err
+}
+
+// UploadImage adds the image read from file to the photos of a pet, filename telling its type by extension.
+func (c *Client) UploadImage(ctx context.Context, id int64, filename string, file io.Reader) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(part, file); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	r := newRequest(http.MethodPost, "/pet/"+formatID(id)+"/uploadImage")
+	r.body, r.contentType = body.Bytes(), w.FormDataContentType()
+	_, err = c.do(ctx, r, nil)
+	return err
+}
+
+// setPage sets offset and limit query parameters, unless they are 0.
+func setPage(query url.Values, offset, limit int64) {
+	if offset != 0 {
+		query.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.FormatInt(limit, 10))
+	}
+}
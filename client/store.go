@@ -0,0 +1,183 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/cooljeffrey/petstore/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetInventory counts pets by status.
+func (c *Client) GetInventory(ctx context.Context) (map[string]int64, error) {
+	var counts map[string]int64
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/store/inventory"), &counts)
+	return counts, err
+}
+
+// GetStockValue sums the prices of pets available by currency.
+func (c *Client) GetStockValue(ctx context.Context) (model.StockValue, error) {
+	r := newRequest(http.MethodGet, "/store/inventory")
+	r.query.Set("report", "value")
+	var value model.StockValue
+	_, err := c.do(ctx, r, &value)
+	return value, err
+}
+
+// PlaceOrder places order for the caller, or for order.UserID when the caller manages orders.
+func (c *Client) PlaceOrder(ctx context.Context, order *model.Order) (*model.Order, error) {
+	r, err := newRequest(http.MethodPost, "/store/order").json(order)
+	if err != nil {
+		return nil, err
+	}
+	var placed *model.Order
+	_, err = c.do(ctx, r, &placed)
+	return placed, err
+}
+
+// StreamEvent is an event of the store stream, either a pet status change or the inventory after it.
+type StreamEvent struct {
+	// ID to resume the stream after, 0 for the inventory sent before any change
+	ID   int64
+	Type string
+	// Pet status change, unless Type is inventory
+	Event *model.Event
+	// Pet counts by status when Type is inventory
+	Inventory map[string]int64
+}
+
+// StreamEvents calls fn with events of the store stream, from the inventory after lastEventID and the changes
+// missed since then when it is not 0, until ctx is done, fn fails or the server ends the stream. It returns the
+// error of fn, or nil when the stream ends, resuming being up to the caller with the last ID seen.
+func (c *Client) StreamEvents(ctx context.Context, lastEventID int64, fn func(StreamEvent) error) error {
+	r := newRequest(http.MethodGet, "/store/events")
+	r.header.Set("Accept", "text/event-stream")
+	if lastEventID > 0 {
+		r.header.Set("Last-Event-ID", formatID(lastEventID))
+	}
+	res, err := c.send(ctx, r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return decodeError(res)
+	}
+	var e StreamEvent
+	var data []byte
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data == nil {
+				continue
+			}
+			if e.Type == "inventory" {
+				err = json.Unmarshal(data, &e.Inventory)
+			} else {
+				err = json.Unmarshal(data, &e.Event)
+			}
+			if err == nil {
+				err = fn(e)
+			}
+			if err != nil {
+				return err
+			}
+			e, data = StreamEvent{}, nil
+		case strings.HasPrefix(line, ":"):
+			// heartbeat
+		case strings.HasPrefix(line, "id: "):
+			e.ID, _ = strconv.ParseInt(line[len("id: "):], 10, 64)
+		case strings.HasPrefix(line, "event: "):
+			e.Type = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, line[len("data: "):]...)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+// FindOrders finds one page of orders matching filter, or of the user called username unless it is empty, with
+// the total count of matches.
+func (c *Client) FindOrders(ctx context.Context, filter model.OrderFilter, username string) ([]*model.Order, int64, error) {
+	r := newRequest(http.MethodGet, "/store/order")
+	setOrderFilter(r.query, filter)
+	if username != "" {
+		r.query.Set("username", username)
+	}
+	return c.findOrders(ctx, r)
+}
+
+func (c *Client) findOrders(ctx context.Context, r *request) ([]*model.Order, int64, error) {
+	var orders []*model.Order
+	header, err := c.do(ctx, r, &orders)
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, totalCount(header), nil
+}
+
+func setOrderFilter(query url.Values, filter model.OrderFilter) {
+	if filter.UserID != 0 {
+		query.Set("userId", formatID(filter.UserID))
+	}
+	if filter.PetID != 0 {
+		query.Set("petId", formatID(filter.PetID))
+	}
+	if len(filter.Statuses) > 0 {
+		query.Set("status", strings.Join(filter.Statuses, ","))
+	}
+	setTime(query, "shipDateFrom", filter.ShipDateFrom)
+	setTime(query, "shipDateTo", filter.ShipDateTo)
+	setPage(query, filter.Offset, filter.Limit)
+}
+
+// setTime sets a query parameter to t in RFC 3339, unless t is zero.
+func setTime(query url.Values, name string, t time.Time) {
+	if !t.IsZero() {
+		query.Set(name, t.Format(time.RFC3339))
+	}
+}
+
+// GetOrder fetches an order by id, one of the caller unless the caller manages orders.
+func (c *Client) GetOrder(ctx context.Context, id int64) (*model.Order, error) {
+	var order *model.Order
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/store/order/"+formatID(id)), &order)
+	return order, err
+}
+
+// UpdateOrderStatus moves an order to status, only if it is at version unless it is 0, and returns the order.
+func (c *Client) UpdateOrderStatus(ctx context.Context, id int64, version int64, status string) (*model.Order, error) {
+	r := newRequest(http.MethodPost, "/store/order/"+formatID(id)).form(url.Values{"status": {status}}).ifMatch(version)
+	var order *model.Order
+	_, err := c.do(ctx, r, &order)
+	return order, err
+}
+
+// DeleteOrder deletes an order, for good when hard.
+func (c *Client) DeleteOrder(ctx context.Context, id int64, hard bool) error {
+	r := newRequest(http.MethodDelete, "/store/order/"+formatID(id))
+	if hard {
+		r.query.Set("hard", "true")
+	}
+	_, err := c.do(ctx, r, nil)
+	return err
+}
+
+// RestoreOrder undoes the deletion of an order.
+func (c *Client) RestoreOrder(ctx context.Context, id int64) (*model.Order, error) {
+	var order *model.Order
+	_, err := c.do(ctx, newRequest(http.MethodPost, "/store/order/"+formatID(id)+"/restore"), &order)
+	return order, err
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CreateUser signs up user, or creates it with any role when the caller manages users.
+func (c *Client) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
+	r, err := newRequest(http.MethodPost, "/user").json(user)
+	if err != nil {
+		return nil, err
+	}
+	var created *model.User
+	_, err = c.do(ctx, r, &created)
+	return created, err
+}
+
+// CreateUsersWithArray creates users, all or none of them. Error.Items tells which users were refused.
+func (c *Client) CreateUsersWithArray(ctx context.Context, users []*model.User) ([]*model.User, error) {
+	return c.createUsers(ctx, "/user/createWithArray", users)
+}
+
+// CreateUsersWithList creates users, all or none of them. Error.Items tells which users were refused.
+func (c *Client) CreateUsersWithList(ctx context.Context, users []*model.User) ([]*model.User, error) {
+	return c.createUsers(ctx, "/user/createWithList", users)
+}
+
+func (c *Client) createUsers(ctx context.Context, path string, users []*model.User) ([]*model.User, error) {
+	r, err := newRequest(http.MethodPost, path).json(users)
+	if err != nil {
+		return nil, err
+	}
+	var created []*model.User
+	_, err = c.do(ctx, r, &created)
+	return created, err
+}
+
+// Login opens a session of the user called username, returning its token, to authenticate with APIKey or Bearer,
+// and when it expires.
+func (c *Client) Login(ctx context.Context, username, password string) (string, time.Time, error) {
+	r, err := newRequest(http.MethodPost, "/user/login").json(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var token string
+	header, err := c.do(ctx, r, &token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt, _ := time.Parse(time.RFC3339, header.Get("X-Expires-After"))
+	return token, expiresAt, nil
+}
+
+// Logout closes the session the client authenticates with.
+func (c *Client) Logout(ctx context.Context) error {
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/user/logout"), nil)
+	return err
+}
+
+// GetUser fetches the user called username.
+func (c *Client) GetUser(ctx context.Context, username string) (*model.User, error) {
+	var user *model.User
+	_, err := c.do(ctx, newRequest(http.MethodGet, userPath(username)), &user)
+	return user, err
+}
+
+// UpdateUser replaces the user called username, only if it is at user.Version unless it is 0, and sets
+// user.Version to the new version.
+func (c *Client) UpdateUser(ctx context.Context, username string, user *model.User) error {
+	r, err := newRequest(http.MethodPut, userPath(username)).json(user)
+	if err != nil {
+		return err
+	}
+	header, err := c.do(ctx, r.ifMatch(user.Version), nil)
+	if err != nil {
+		return err
+	}
+	user.Version = version(header)
+	return nil
+}
+
+// PatchUser applies a JSON merge patch to the user called username, only if it is at version unless it is 0, and
+// returns the patched user.
+func (c *Client) PatchUser(ctx context.Context, username string, version int64, patch interface{}) (*model.User, error) {
+	var user *model.User
+	return user, c.patch(ctx, userPath(username), version, patch, contentTypeMergePatch, &user)
+}
+
+// JSONPatchUser applies JSON patch operations to the user called username, only if it is at version unless it
+// is 0, and returns the patched user.
+func (c *Client) JSONPatchUser(ctx context.Context, username string, version int64, ops []PatchOperation) (*model.User, error) {
+	var user *model.User
+	return user, c.patch(ctx, userPath(username), version, ops, contentTypeJSONPatch, &user)
+}
+
+// DeleteUser deletes the user called username, for good when hard.
+func (c *Client) DeleteUser(ctx context.Context, username string, hard bool) error {
+	r := newRequest(http.MethodDelete, userPath(username))
+	if hard {
+		r.query.Set("hard", "true")
+	}
+	_, err := c.do(ctx, r, nil)
+	return err
+}
+
+// RestoreUser undoes the deletion of the user called username.
+func (c *Client) RestoreUser(ctx context.Context, username string) (*model.User, error) {
+	var user *model.User
+	_, err := c.do(ctx, newRequest(http.MethodPost, userPath(username)+"/restore"), &user)
+	return user, err
+}
+
+// FindUserOrders finds one page of orders of the user called username matching filter, whose UserID is ignored,
+// with the total count of matches.
+func (c *Client) FindUserOrders(ctx context.Context, username string, filter model.OrderFilter) ([]*model.Order, int64, error) {
+	filter.UserID = 0
+	r := newRequest(http.MethodGet, userPath(username)+"/orders")
+	setOrderFilter(r.query, filter)
+	return c.findOrders(ctx, r)
+}
+
+// UnlockUser lets the user called username log in again after too many failed attempts.
+func (c *Client) UnlockUser(ctx context.Context, username string) error {
+	_, err := c.do(ctx, newRequest(http.MethodPost, userPath(username)+"/unlock"), nil)
+	return err
+}
+
+func userPath(username string) string {
+	return "/user/" + url.PathEscape(username)
+}
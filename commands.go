@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/cooljeffrey/petstore/conformance"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/cooljeffrey/petstore/model/fixtures"
+	"github.com/cooljeffrey/petstore/transfer"
+	"github.com/go-kit/kit/log"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// command runs a mode of petstore other than serving the API, given the arguments following its name.
+type command func(storage model.Storage, args []string, logger log.Logger) error
+
+var commands = map[string]command{
+	"export":  exportCommand,
+	"import":  importCommand,
+	"seed":    seedCommand,
+	"migrate": migrateCommand,
+	"backup":  backupCommand,
+}
+
+// remoteCommand runs a mode of petstore working against a running server, without storage.
+type remoteCommand func(args []string, logger log.Logger) error
+
+var remoteCommands = map[string]remoteCommand{
+	"conformance": conformanceCommand,
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Export collections to one <collection>.<format> file each
+func exportCommand(storage model.Storage, args []string, logger log.Logger) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var (
+		format = fs.String(
+			"format",
+			string(transfer.FormatNDJSON),
+			"ndjson or csv")
+		dir = fs.String(
+			"dir",
+			".",
+			"folder to write <collection>.<format> files to, - to write a single collection to stdout")
+		collections = fs.String(
+			"collections",
+			strings.Join(transfer.Collections, ","),
+			"comma separated collections to export")
+	)
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags] export [export flags]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	names := splitList(*collections)
+	if *dir == "-" && len(names) != 1 {
+		return fmt.Errorf("exactly one collection can be exported to stdout")
+	}
+
+	for _, collection := range names {
+		var w io.Writer = os.Stdout
+		if *dir != "-" {
+			f, err := os.Create(filepath.Join(*dir, collection+"."+*format))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := transfer.Export(storage, collection, transfer.Format(*format), w)
+		if err != nil {
+			return fmt.Errorf("export %s: %v", collection, err)
+		}
+		_ = logger.Log("collection", collection, "exported", n)
+	}
+	return nil
+}
+
+// Import collections from one <collection>.<format> file each
+func importCommand(storage model.Storage, args []string, logger log.Logger) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var (
+		format = fs.String(
+			"format",
+			string(transfer.FormatNDJSON),
+			"ndjson or csv")
+		dir = fs.String(
+			"dir",
+			".",
+			"folder to read <collection>.<format> files from, - to read a single collection from stdin")
+		collections = fs.String(
+			"collections",
+			strings.Join(transfer.Collections, ","),
+			"comma separated collections to import, missing files are skipped")
+		conflict = fs.String(
+			"conflict",
+			string(model.ConflictSkip),
+			"what to do with documents whose id exists, upsert or skip")
+		dryRun = fs.Bool(
+			"dry-run",
+			false,
+			"only report what would be imported")
+	)
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags] import [import flags]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	names := splitList(*collections)
+	if *dir == "-" && len(names) != 1 {
+		return fmt.Errorf("exactly one collection can be imported from stdin")
+	}
+
+	var failed int64
+	for _, collection := range names {
+		var r io.Reader = os.Stdin
+		if *dir != "-" {
+			f, err := os.Open(filepath.Join(*dir, collection+"."+*format))
+			if os.IsNotExist(err) {
+				_ = logger.Log("collection", collection, "skipped", "no file")
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		stats, err := transfer.Import(logger, storage, collection, transfer.Format(*format), r,
+			model.ConflictMode(*conflict), *dryRun)
+		if err != nil {
+			return fmt.Errorf("import %s: %v", collection, err)
+		}
+		_ = logger.Log("collection", collection, "dryRun", *dryRun, "read", stats.Read, "inserted", stats.Inserted,
+			"replaced", stats.Replaced, "skipped", stats.Skipped, "failed", stats.Failed)
+		failed += stats.Failed
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d documents failed to import", failed)
+	}
+	return nil
+}
+
+// Seed the database from fixture files and generated documents
+func seedCommand(storage model.Storage, args []string, logger log.Logger) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	var (
+		files = fs.String(
+			"files",
+			"",
+			"comma separated fixture files to load")
+		integration = fs.String(
+			"integration",
+			"",
+			"folder of the integration suite fixtures to load, usually __tests__")
+		seed = fs.Int64(
+			"seed",
+			1,
+			"seed of generated documents, the same seed generates the same documents")
+		pets = fs.Int(
+			"pets",
+			0,
+			"number of pets to generate")
+		users = fs.Int(
+			"users",
+			0,
+			"number of users to generate")
+		orders = fs.Int(
+			"orders",
+			0,
+			"number of orders to generate")
+		conflict = fs.String(
+			"conflict",
+			string(model.ConflictSkip),
+			"what to do with documents whose id exists, upsert or skip")
+	)
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags] seed [seed flags]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	mode := model.ConflictMode(*conflict)
+	if mode != model.ConflictUpsert && mode != model.ConflictSkip {
+		return fmt.Errorf("unknown conflict mode %s", mode)
+	}
+
+	sets := []*fixtures.Set{}
+	if *integration != "" {
+		s, err := fixtures.Integration(*integration)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, s)
+	}
+	if paths := splitList(*files); len(paths) > 0 {
+		s, err := fixtures.ReadFiles(paths...)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, s)
+	}
+	if *pets > 0 || *users > 0 || *orders > 0 {
+		sets = append(sets, fixtures.Generate(*seed, *pets, *users, *orders))
+	}
+	if len(sets) == 0 {
+		fs.Usage()
+		return fmt.Errorf("nothing to seed")
+	}
+
+	n, err := fixtures.Merge(sets...).Load(storage, mode)
+	_ = logger.Log("loaded", n)
+	return err
+}
+
+// Apply, revert or list schema migrations
+func migrateCommand(storage model.Storage, args []string, logger log.Logger) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := fs.Int64(
+		"to",
+		-1,
+		"version to migrate up or down to, defaults to the latest for up and the one before the current for down")
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags] migrate [migrate flags] up|down|status")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	migrator, ok := storage.(model.Migrator)
+	if !ok {
+		return fmt.Errorf("storage has no schema migrations")
+	}
+
+	var (
+		done []model.Migration
+		err  error
+	)
+	switch fs.Arg(0) {
+	case "up":
+		version := *to
+		if version < 0 {
+			version = 0
+		}
+		done, err = migrator.MigrateUp(version)
+	case "down":
+		version := *to
+		if version < 0 {
+			status, err := migrator.MigrationStatus()
+			if err != nil {
+				return err
+			}
+			var applied []int64
+			for _, s := range status {
+				if s.AppliedAt != nil {
+					applied = append(applied, s.Version)
+				}
+			}
+			version = 0
+			if len(applied) > 1 {
+				version = applied[len(applied)-2]
+			}
+		}
+		done, err = migrator.MigrateDown(version)
+	case "status":
+		status, err := migrator.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+		fmt.Fprintf(w, "VERSION\tAPPLIED\tDESCRIPTION\n")
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Unknown {
+				applied += " (unknown)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, applied, s.Description)
+		}
+		return w.Flush()
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate action %q", fs.Arg(0))
+	}
+	for _, m := range done {
+		_ = logger.Log("version", m.Version, "description", m.Description)
+	}
+	return err
+}
+
+// Check responses of a running server against the spec
+// Copy all data to a backup file, where storage supports it
+func backupCommand(storage model.Storage, args []string, logger log.Logger) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags] backup <file>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	backuper, ok := storage.(model.Backuper)
+	if !ok {
+		return fmt.Errorf("storage has no backups")
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("backup file expected")
+	}
+	if err := backuper.Backup(fs.Arg(0)); err != nil {
+		return err
+	}
+	_ = logger.Log("backup", fs.Arg(0))
+	return nil
+}
+
+func conformanceCommand(args []string, logger log.Logger) error {
+	fs := flag.NewFlagSet("conformance", flag.ExitOnError)
+	var (
+		specPath = fs.String(
+			"spec",
+			"schema/petstore.json",
+			"Swagger 2.0 spec to check against")
+		baseURL = fs.String(
+			"url",
+			"",
+			"base URL of the API, defaults to the host and base path of the spec")
+		apiKey = fs.String(
+			"api-key",
+			"",
+			"api_key header value sent with every request, usually the -admin-api-key of the server")
+		timeout = fs.Duration(
+			"timeout",
+			10*time.Second,
+			"timeout of each request")
+	)
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags] conformance [conformance flags]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := os.Open(*specPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	spec, err := conformance.LoadSpec(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *specPath, err)
+	}
+	operations, err := spec.Operations()
+	if err != nil {
+		return err
+	}
+	cases, err := spec.Cases(operations)
+	if err != nil {
+		return err
+	}
+	if *baseURL == "" {
+		*baseURL = spec.BaseURL()
+	}
+	header := http.Header{}
+	if *apiKey != "" {
+		header.Set("api_key", *apiKey)
+	}
+
+	findings, err := spec.Check(context.Background(), &http.Client{Timeout: *timeout}, *baseURL, header, cases)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	fmt.Fprintf(w, "OPERATION\tCASE\tSTATUS\tPROBLEM\n")
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s %s\t%s\t%d\t%s\n", f.Case.Operation.Method, f.Case.Operation.Path, f.Case.Name, f.Status, f.Problem)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	_ = logger.Log("url", *baseURL, "operations", len(operations), "cases", len(cases), "findings", len(findings))
+	if len(findings) > 0 {
+		return fmt.Errorf("%d responses do not conform to %s", len(findings), *specPath)
+	}
+	return nil
+}
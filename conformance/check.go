@@ -0,0 +1,204 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Finding is a response the spec does not declare.
+type Finding struct {
+	Case    *Case
+	Status  int
+	Problem string
+}
+
+// Check makes the requests of cases to the API at baseURL in order, with header added to each, and reports the
+// responses whose status code is not declared by their operation, or whose body does not match the declared schema.
+func (s *Spec) Check(ctx context.Context, client *http.Client, baseURL string, header http.Header, cases []*Case) ([]*Finding, error) {
+	var findings []*Finding
+	for _, c := range cases {
+		status, body, err := send(ctx, client, baseURL, header, c)
+		if err != nil {
+			return findings, fmt.Errorf("%s %s: %s: %v", c.Operation.Method, c.Operation.Path, c.Name, err)
+		}
+		for _, problem := range s.checkResponse(c.Operation, status, body) {
+			findings = append(findings, &Finding{Case: c, Status: status, Problem: problem})
+		}
+	}
+	return findings, nil
+}
+
+// send makes the request of a case, returning the status code and body of its response.
+func send(ctx context.Context, client *http.Client, baseURL string, header http.Header, c *Case) (int, []byte, error) {
+	u := strings.TrimSuffix(baseURL, "/") + c.Path
+	if len(c.Query) > 0 {
+		u += "?" + c.Query.Encode()
+	}
+	req, err := http.NewRequest(c.Operation.Method, u, bytes.NewReader(c.Body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	if c.ContentType != "" {
+		req.Header.Set("Content-Type", c.ContentType)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	return res.StatusCode, body, err
+}
+
+// checkResponse lists what the spec does not declare of a response to op.
+func (s *Spec) checkResponse(op *Operation, status int, body []byte) []string {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if response, ok = op.Responses["default"]; !ok {
+			return []string{fmt.Sprintf("status %d not declared, expecting %s", status, declared(op))}
+		}
+	}
+	if response == nil || response.Schema == nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []string{fmt.Sprintf("body is not JSON: %v", err)}
+	}
+	return s.validate(response.Schema, v, "body")
+}
+
+// declared lists the status codes declared by op.
+func declared(op *Operation) string {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return strings.Join(codes, ", ")
+}
+
+// validate lists where v, found at path, does not match schema.
+func (s *Spec) validate(schema *Schema, v interface{}, path string) []string {
+	schema, err := s.resolve(schema)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	if schema == nil {
+		return nil
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, v) {
+		return []string{fmt.Sprintf("%s: %v is not one of %v", path, v, schema.Enum)}
+	}
+	mismatch := []string{fmt.Sprintf("%s: %s expected, got %s", path, schema.Type, jsonType(v))}
+	switch schema.Type {
+	case "object":
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		var problems []string
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: missing", path, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			if !ok {
+				// properties not declared are allowed, unless additional ones are described
+				property = schema.AdditionalProperties
+			}
+			if property != nil {
+				problems = append(problems, s.validate(property, object[name], path+"."+name)...)
+			}
+		}
+		return problems
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		var problems []string
+		for i, item := range list {
+			problems = append(problems, s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			return mismatch
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return mismatch
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return []string{fmt.Sprintf("%s: %q is not a date-time", path, str)}
+			}
+		}
+	}
+	return nil
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,140 @@
+package conformance
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadPetstore(t *testing.T) *Spec {
+	f, err := os.Open("../schema/petstore.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spec, err := LoadSpec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// findCase finds the case of an operation by name.
+func findCase(cases []*Case, method, path, name string) *Case {
+	for _, c := range cases {
+		if c.Operation.Method == method && c.Operation.Path == path && c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestCases(t *testing.T) {
+	spec := loadPetstore(t)
+	assert.Equal(t, "http://localhost:8080/v2", spec.BaseURL())
+	operations, err := spec.Operations()
+	assert.NoError(t, err)
+	assert.Len(t, operations, 20)
+	assert.Equal(t, "addPet", operations[0].OperationID)
+	assert.Equal(t, http.MethodDelete, operations[len(operations)-1].Method)
+
+	cases, err := spec.Cases(operations)
+	assert.NoError(t, err)
+
+	c := findCase(cases, http.MethodPost, "/pet", ExampleCase)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, "application/json", c.ContentType)
+		assert.JSONEq(t, `{"id":1,"category":{"id":1,"name":"string"},"name":"doggie","photoUrls":["string"],
+			"tags":[{"id":1,"name":"string"}],"status":"available"}`, string(c.Body))
+	}
+	c = findCase(cases, http.MethodPost, "/pet", "missing name in body")
+	if assert.NotNil(t, c) {
+		assert.NotContains(t, string(c.Body), `"name":"doggie"`)
+	}
+	c = findCase(cases, http.MethodPost, "/pet", "missing body")
+	if assert.NotNil(t, c) {
+		assert.Empty(t, c.Body)
+	}
+	c = findCase(cases, http.MethodGet, "/pet/{petId}", "invalid petId")
+	if assert.NotNil(t, c) {
+		assert.Equal(t, "/pet/invalid", c.Path)
+	}
+	c = findCase(cases, http.MethodGet, "/pet/findByStatus", ExampleCase)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, "status=available", c.Query.Encode())
+	}
+	c = findCase(cases, http.MethodGet, "/user/login", "missing password")
+	if assert.NotNil(t, c) {
+		assert.Equal(t, "username=string", c.Query.Encode())
+	}
+	c = findCase(cases, http.MethodPost, "/pet/{petId}", ExampleCase)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, "application/x-www-form-urlencoded", c.ContentType)
+		assert.Equal(t, "name=string&status=string", string(c.Body))
+	}
+	c = findCase(cases, http.MethodPost, "/pet/{petId}/uploadImage", ExampleCase)
+	if assert.NotNil(t, c) {
+		assert.True(t, strings.HasPrefix(c.ContentType, "multipart/form-data; boundary="))
+		assert.Contains(t, string(c.Body), `filename="conformance.jpg"`)
+	}
+	// the optional api_key header is left to the caller
+	c = findCase(cases, http.MethodDelete, "/pet/{petId}", ExampleCase)
+	if assert.NotNil(t, c) {
+		assert.Empty(t, c.Header)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	spec := loadPetstore(t)
+	operations, err := spec.Operations()
+	assert.NoError(t, err)
+	var selected []*Operation
+	for _, op := range operations {
+		if op.OperationID == "getPetById" || op.OperationID == "getInventory" {
+			selected = append(selected, op)
+		}
+	}
+	cases, err := spec.Cases(selected)
+	assert.NoError(t, err)
+
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("api_key"))
+		switch r.URL.Path {
+		case "/v2/pet/1":
+			_, _ = w.Write([]byte(`{"id":1,"name":"doggie","status":"lost","tags":[{"id":1.5}],"version":1}`))
+		case "/v2/pet/invalid":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/v2/store/inventory":
+			_, _ = w.Write([]byte(`{"available":1,"sold":"2"}`))
+		default:
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}))
+	defer server.Close()
+	header := http.Header{}
+	header.Set("api_key", "secret")
+
+	findings, err := spec.Check(context.Background(), server.Client(), server.URL+"/v2", header, cases)
+	assert.NoError(t, err)
+	var problems []string
+	for _, f := range findings {
+		problems = append(problems, f.Case.Operation.OperationID+" "+f.Case.Name+": "+f.Problem)
+	}
+	assert.Equal(t, []string{
+		"getPetById example: body.photoUrls: missing",
+		"getPetById example: body.status: lost is not one of [available pending sold]",
+		"getPetById example: body.tags[0].id: integer expected, got number",
+		"getPetById unknown petId: status 418 not declared, expecting 200, 400, 404",
+		"getInventory example: body.sold: integer expected, got string",
+	}, problems)
+	assert.Equal(t, []string{"secret", "secret", "secret", "secret"}, keys)
+
+	server.Close()
+	_, err = spec.Check(context.Background(), server.Client(), server.URL+"/v2", nil, cases)
+	assert.Error(t, err)
+}
@@ -0,0 +1,303 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ExampleCase names the case of an operation made of the values the spec gives.
+const ExampleCase = "example"
+
+// unknownID is an id no fresh server has
+const unknownID int64 = 987654321
+
+// file is the value of file parameters, the server telling images by extension
+var file = struct {
+	name    string
+	content []byte
+}{"conformance.jpg", []byte("conformance")}
+
+// Case is a request made to an operation of the spec.
+type Case struct {
+	Operation *Operation
+	// Name tells what the request is made of, ExampleCase or what makes it invalid
+	Name        string
+	Path        string
+	Query       url.Values
+	Header      http.Header
+	ContentType string
+	Body        []byte
+}
+
+// Cases generates the requests made to operations. Each operation is requested first with values taken from the
+// examples, defaults and enums of the spec, then once with an invalid or unknown id for each path parameter, once
+// without each required parameter and once without each required field of its body.
+func (s *Spec) Cases(operations []*Operation) ([]*Case, error) {
+	var cases []*Case
+	for _, op := range operations {
+		values := map[*Parameter]interface{}{}
+		for _, p := range op.Parameters {
+			// optional headers are left to the caller, e.g. api_key
+			if p.In == "header" && !p.Required {
+				continue
+			}
+			v, err := s.parameterValue(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s: %v", op.Method, op.Path, p.Name, err)
+			}
+			values[p] = v
+		}
+		c, err := newCase(op, ExampleCase, values)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+
+		add := func(name string, p *Parameter, v interface{}, omit bool) error {
+			changed := map[*Parameter]interface{}{}
+			for k, v := range values {
+				changed[k] = v
+			}
+			if omit {
+				delete(changed, p)
+			} else {
+				changed[p] = v
+			}
+			c, err := newCase(op, name, changed)
+			if err == nil {
+				cases = append(cases, c)
+			}
+			return err
+		}
+		for _, p := range op.Parameters {
+			switch {
+			case p.In == "path" && (p.Type == "integer" || p.Type == "number"):
+				err = add("invalid "+p.Name, p, "invalid", false)
+				if err == nil {
+					err = add("unknown "+p.Name, p, unknownID, false)
+				}
+			case p.In == "path":
+				err = add("unknown "+p.Name, p, "unknown-conformance", false)
+			case p.In == "body" && p.Required:
+				if err = add("missing body", p, nil, true); err != nil {
+					break
+				}
+				// resolved already making the example
+				schema, _ := s.resolve(p.Schema)
+				body, ok := values[p].(map[string]interface{})
+				if schema == nil || !ok {
+					break
+				}
+				for _, field := range schema.Required {
+					without := map[string]interface{}{}
+					for k, v := range body {
+						if k != field {
+							without[k] = v
+						}
+					}
+					if err = add("missing "+field+" in body", p, without, false); err != nil {
+						break
+					}
+				}
+			case p.Required:
+				err = add("missing "+p.Name, p, nil, true)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cases, nil
+}
+
+// newCase makes the request of an operation with values of its parameters, leaving out parameters without value.
+func newCase(op *Operation, name string, values map[*Parameter]interface{}) (*Case, error) {
+	c := &Case{Operation: op, Name: name, Path: op.Path, Query: url.Values{}, Header: http.Header{}}
+	form := url.Values{}
+	var files []*Parameter
+	multipartForm := contains(op.Consumes, "multipart/form-data")
+	for _, p := range op.Parameters {
+		v, ok := values[p]
+		if !ok {
+			continue
+		}
+		switch p.In {
+		case "path":
+			c.Path = strings.Replace(c.Path, "{"+p.Name+"}", url.PathEscape(fmt.Sprint(v)), -1)
+		case "query":
+			addValue(c.Query, p, v)
+		case "header":
+			c.Header.Set(p.Name, fmt.Sprint(v))
+		case "formData":
+			if p.Type == "file" {
+				files = append(files, p)
+				multipartForm = true
+				continue
+			}
+			addValue(form, p, v)
+		case "body":
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s: %v", op.Method, op.Path, p.Name, err)
+			}
+			c.ContentType, c.Body = "application/json", b
+		}
+	}
+	if len(form) == 0 && len(files) == 0 {
+		return c, nil
+	}
+	if !multipartForm {
+		c.ContentType, c.Body = "application/x-www-form-urlencoded", []byte(form.Encode())
+		return c, nil
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range form[k] {
+			if err := w.WriteField(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, p := range files {
+		part, err := w.CreateFormFile(p.Name, file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = part.Write(file.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	c.ContentType, c.Body = w.FormDataContentType(), buf.Bytes()
+	return c, nil
+}
+
+// addValue adds the value of a query or form parameter, formatting arrays as its collection format says.
+func addValue(values url.Values, p *Parameter, v interface{}) {
+	list, ok := v.([]interface{})
+	if !ok {
+		values.Add(p.Name, fmt.Sprint(v))
+		return
+	}
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = fmt.Sprint(item)
+	}
+	switch p.CollectionFormat {
+	case "multi":
+		for _, item := range items {
+			values.Add(p.Name, item)
+		}
+	case "ssv":
+		values.Add(p.Name, strings.Join(items, " "))
+	case "tsv":
+		values.Add(p.Name, strings.Join(items, "\t"))
+	case "pipes":
+		values.Add(p.Name, strings.Join(items, "|"))
+	default:
+		values.Add(p.Name, strings.Join(items, ","))
+	}
+}
+
+// parameterValue is the value a parameter is given in the example case.
+func (s *Spec) parameterValue(p *Parameter) (interface{}, error) {
+	switch p.Type {
+	case "":
+		return s.exampleValue(p.Schema, 0)
+	case "array":
+		item, err := s.exampleValue(p.Items, 0)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{item}, nil
+	case "file":
+		return file.name, nil
+	}
+	return scalarValue(p.Type, p.Format, p.Enum, p.Default), nil
+}
+
+// exampleValue makes up a value of schema from its examples, defaults and enums, down to a few levels deep.
+func (s *Spec) exampleValue(schema *Schema, depth int) (interface{}, error) {
+	schema, err := s.resolve(schema)
+	if err != nil || schema == nil || depth > 8 {
+		return nil, err
+	}
+	if schema.Example != nil {
+		return schema.Example, nil
+	}
+	switch {
+	case schema.Type == "object" || schema.Type == "" && schema.Properties != nil:
+		if schema.Default != nil {
+			return schema.Default, nil
+		}
+		object := map[string]interface{}{}
+		for name, property := range schema.Properties {
+			v, err := s.exampleValue(property, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			if v != nil {
+				object[name] = v
+			}
+		}
+		return object, nil
+	case schema.Type == "array":
+		item, err := s.exampleValue(schema.Items, depth+1)
+		if err != nil || item == nil {
+			return []interface{}{}, err
+		}
+		return []interface{}{item}, nil
+	}
+	return scalarValue(schema.Type, schema.Format, schema.Enum, schema.Default), nil
+}
+
+// scalarValue is the default, else the first of enum, else a value of typ and format.
+func scalarValue(typ, format string, enum []interface{}, def interface{}) interface{} {
+	switch {
+	case def != nil:
+		return def
+	case len(enum) > 0:
+		return enum[0]
+	}
+	switch typ {
+	case "integer":
+		return int64(1)
+	case "number":
+		return 1.5
+	case "boolean":
+		return false
+	case "string":
+		switch format {
+		case "date-time":
+			return "2019-01-01T00:00:00Z"
+		case "date":
+			return "2019-01-01"
+		}
+		return "string"
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+// Package conformance checks an API server against its Swagger 2.0 spec, requesting every operation with values
+// taken from the spec and reporting responses whose status code or body the spec does not declare.
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Spec is the part of a Swagger 2.0 spec needed to request its operations and check their responses.
+type Spec struct {
+	Host        string                                `json:"host"`
+	BasePath    string                                `json:"basePath"`
+	Schemes     []string                              `json:"schemes"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]*Schema                    `json:"definitions"`
+}
+
+// Operation is a method on a path of the spec.
+type Operation struct {
+	Method      string               `json:"-"`
+	Path        string               `json:"-"`
+	OperationID string               `json:"operationId"`
+	Consumes    []string             `json:"consumes"`
+	Parameters  []*Parameter         `json:"parameters"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter of an operation, in path, query, header, formData or body.
+type Parameter struct {
+	Name             string        `json:"name"`
+	In               string        `json:"in"`
+	Required         bool          `json:"required"`
+	Type             string        `json:"type"`
+	Format           string        `json:"format"`
+	Items            *Schema       `json:"items"`
+	CollectionFormat string        `json:"collectionFormat"`
+	Enum             []interface{} `json:"enum"`
+	Default          interface{}   `json:"default"`
+	Schema           *Schema       `json:"schema"`
+}
+
+// Response declared by an operation for a status code, its body described by Schema unless nil.
+type Response struct {
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Schema of a body or of a part of it, possibly a reference to a definition.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	Items                *Schema            `json:"items"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Enum                 []interface{}      `json:"enum"`
+	Default              interface{}        `json:"default"`
+	Example              interface{}        `json:"example"`
+}
+
+// methods are requested in this order, creating what is read and deleted afterwards
+var methods = []string{"post", "put", "patch", "get", "head", "options", "delete"}
+
+// LoadSpec reads a Swagger 2.0 spec in JSON.
+func LoadSpec(r io.Reader) (*Spec, error) {
+	var spec *Spec
+	if err := json.NewDecoder(r).Decode(&spec); err != nil {
+		return nil, err
+	}
+	if spec == nil || len(spec.Paths) == 0 {
+		return nil, fmt.Errorf("spec has no paths")
+	}
+	return spec, nil
+}
+
+// BaseURL is where the spec says the API is served.
+func (s *Spec) BaseURL() string {
+	scheme := "http"
+	if len(s.Schemes) > 0 {
+		scheme = s.Schemes[0]
+	}
+	return scheme + "://" + s.Host + strings.TrimSuffix(s.BasePath, "/")
+}
+
+// Operations lists the operations of the spec, creating ones first, then by path.
+func (s *Spec) Operations() ([]*Operation, error) {
+	var operations []*Operation
+	for _, method := range methods {
+		var paths []string
+		for path, item := range s.Paths {
+			if _, ok := item[method]; ok {
+				paths = append(paths, path)
+			}
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			var op *Operation
+			if err := json.Unmarshal(s.Paths[path][method], &op); err != nil {
+				return nil, fmt.Errorf("%s %s: %v", strings.ToUpper(method), path, err)
+			}
+			op.Method, op.Path = strings.ToUpper(method), path
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+// resolve follows schema to the definition it refers to.
+func (s *Spec) resolve(schema *Schema) (*Schema, error) {
+	for i := 0; schema != nil && schema.Ref != ""; i++ {
+		ref := schema.Ref
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		if name == ref || i > len(s.Definitions) {
+			return nil, fmt.Errorf("unsupported reference %s", ref)
+		}
+		var ok bool
+		if schema, ok = s.Definitions[name]; !ok {
+			return nil, fmt.Errorf("undefined reference %s", ref)
+		}
+	}
+	return schema, nil
+}
@@ -6,13 +6,47 @@ import (
 	"github.com/cooljeffrey/petstore/model"
 	"github.com/cooljeffrey/petstore/service"
 	"github.com/go-kit/kit/log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"text/tabwriter"
+	"time"
 )
 
+// buildTenants builds the services of each tenant of file over its database, and routes requests to them.
+func buildTenants(storage model.Storage, file string, resolution service.TenantResolution,
+	build func(model.Storage, *service.Tenant, log.Logger) (*service.Services, service.Options, error),
+	logger log.Logger) (http.Handler, error) {
+	databases, ok := storage.(model.TenantStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage has no databases for tenants")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tenants, err := service.LoadTenants(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	handlers := map[string]http.Handler{}
+	for _, t := range tenants {
+		tenantLogger := log.WithPrefix(logger, "tenant", t.Name)
+		services, options, err := build(databases.ForDatabase(t.Database), t, tenantLogger)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", t.Name, err)
+		}
+		handlers[t.Name] = service.SetupRoutes(services, options, log.WithPrefix(tenantLogger, "service", "routing"))
+		_ = logger.Log("tenant", t.Name, "database", t.Database)
+	}
+	return service.NewTenantRouter(resolution, handlers)
+}
+
 // Show usage info on command line
 func usageFor(fs *flag.FlagSet, short string) func() {
 	return func() {
@@ -41,6 +75,22 @@ func main() {
 			"http-port",
 			"8080",
 			"HTTP port")
+		grpcPort = fs.String(
+			"grpc-port",
+			"9090",
+			"gRPC port, disabled when empty")
+		graphqlMaxDepth = fs.Int(
+			"graphql-max-depth",
+			service.DefaultGraphQLMaxDepth,
+			"maximum depth of GraphQL queries")
+		graphqlMaxComplexity = fs.Int(
+			"graphql-max-complexity",
+			service.DefaultGraphQLMaxComplexity,
+			"maximum complexity of GraphQL queries, the fields they select counted once per list item")
+		storageKind = fs.String(
+			"storage",
+			"mongo",
+			"storage of documents, mongo, postgres or bolt")
 		mongoUri = fs.String(
 			"mongo-uri",
 			"mongodb://localhost:27017",
@@ -52,6 +102,25 @@ func main() {
 		mongoDbTimeoutSeconds = fs.Int64(
 			"mongo-timeout", 10,
 			"mongo db operatoin timeout in seconds")
+		postgresDsn = fs.String(
+			"postgres-dsn",
+			"postgres://localhost:5432/petstore?sslmode=disable",
+			"postgres connection string")
+		postgresTimeoutSeconds = fs.Int64(
+			"postgres-timeout", 10,
+			"postgres operation timeout in seconds")
+		boltPath = fs.String(
+			"bolt-path",
+			"./petstore.db",
+			"file keeping all documents with bolt storage")
+		boltBackup = fs.String(
+			"bolt-backup",
+			"",
+			"file the bolt storage is backed up to while serving, disabled when empty")
+		boltBackupInterval = fs.Duration(
+			"bolt-backup-interval",
+			time.Hour,
+			"how often the bolt storage is backed up")
 		publicBaseUri = fs.String(
 			"public-uri",
 			"/images",
@@ -60,35 +129,282 @@ func main() {
 			"public-path",
 			"./public",
 			"the folder basing on current working dir")
+		adminApiKey = fs.String(
+			"admin-api-key",
+			"",
+			"api_key header value authenticating as admin, disabled when empty")
+		loginMaxFailures = fs.Int(
+			"login-max-failures",
+			int(model.DefaultLockoutPolicy.MaxFailures),
+			"failed logins before the account is locked, 0 to never lock")
+		loginBackoff = fs.Duration(
+			"login-backoff",
+			model.DefaultLockoutPolicy.BaseDelay,
+			"delay after the first failed login, doubled on each failure")
+		loginMaxBackoff = fs.Duration(
+			"login-max-backoff",
+			model.DefaultLockoutPolicy.MaxDelay,
+			"maximum delay between failed logins")
+		loginLockout = fs.Duration(
+			"login-lockout",
+			model.DefaultLockoutPolicy.LockoutDuration,
+			"how long an account stays locked")
+		sessionTTL = fs.Duration(
+			"session-ttl",
+			time.Hour,
+			"how long a login session token stays valid")
+		loginDisableQuery = fs.Bool(
+			"login-disable-query",
+			false,
+			"reject GET /v2/user/login with credentials in query string")
+		migrate = fs.Bool(
+			"migrate",
+			true,
+			"apply pending schema migrations before serving")
+		deletedRetention = fs.Duration(
+			"deleted-retention",
+			30*24*time.Hour,
+			"how long deleted pets, users and orders are kept before being purged, 0 to keep them")
+		purgeInterval = fs.Duration(
+			"purge-interval",
+			time.Hour,
+			"how often deleted pets, users and orders are purged")
+		webhookInterval = fs.Duration(
+			"webhook-interval",
+			5*time.Second,
+			"how often events are delivered to webhooks, 0 not to deliver them from this process")
+		webhookMaxAttempts = fs.Int(
+			"webhook-max-attempts",
+			service.DefaultDeliveryPolicy.MaxAttempts,
+			"failed deliveries to a webhook before the event is moved to its dead-letter list")
+		cacheSize = fs.Int(
+			"cache-size",
+			10000,
+			"how many pets and users read by id or username are cached, 0 not to cache them")
+		cacheTTL = fs.Duration(
+			"cache-ttl",
+			30*time.Second,
+			"how long pets and users stay cached, bounding how stale changes made by other instances are")
+		rateLimit = fs.Int(
+			"rate-limit",
+			service.DefaultRateLimit,
+			"calls per hour users are told to stay under by X-Rate-Limit")
+		tenantsFile = fs.String(
+			"tenants",
+			"",
+			"JSON file of tenants served, each in a database of its own, a single store in -mongo-dbname when empty")
+		tenantFrom = fs.String(
+			"tenant-from",
+			string(service.TenantFromHeader),
+			"where requests name their tenant, header ( "+service.TenantHeader+" ), subdomain or path")
 	)
-	fs.Usage = usageFor(fs, os.Args[0]+" [flags] <a> <b>")
+	fs.Usage = usageFor(fs, os.Args[0]+" [flags] [serve|export|import|seed|migrate|conformance] [command flags]")
 	err := fs.Parse(os.Args[1:])
 	if err != nil {
 		fs.Usage()
 		os.Exit(1)
 	}
 
+	// serve the API unless another command is given
+	var cmd command
+	if args := fs.Args(); len(args) > 0 && args[0] != "serve" {
+		var ok bool
+		if cmd, ok = commands[args[0]]; !ok && remoteCommands[args[0]] == nil {
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+
 	// init logger
 	var logger log.Logger
 	logger = log.NewJSONLogger(os.Stderr)
 
+	// commands working against a running server need no storage
+	if args := fs.Args(); len(args) > 0 && remoteCommands[args[0]] != nil {
+		err = remoteCommands[args[0]](args[1:], log.WithPrefix(logger, "command", args[0]))
+		if err != nil {
+			_ = logger.Log("err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// init storage
-	storage, err := model.NewMongoStorage(*mongoUri, *mongoDbName, *mongoDbTimeoutSeconds, logger)
+	var storage model.Storage
+	switch *storageKind {
+	case "mongo":
+		storage, err = model.NewMongoStorage(*mongoUri, *mongoDbName, *mongoDbTimeoutSeconds, logger)
+	case "postgres":
+		storage, err = model.NewPostgresStorage(*postgresDsn, *postgresTimeoutSeconds, logger)
+	case "bolt":
+		storage, err = model.NewBoltStorage(*boltPath, logger)
+	default:
+		err = fmt.Errorf("unknown storage %s, mongo, postgres or bolt expected", *storageKind)
+	}
 	if err != nil {
 		_ = logger.Log("err", err)
 		os.Exit(1)
 	}
 
-	// init services
-	services := service.Services{
-		UserService: service.NewUserService(log.WithPrefix(logger, "service", "user"), storage),
-		PetService: service.NewPetService(
-			log.WithPrefix(logger, "service", "pet"), storage, *publicBaseUri, *publicFilePath),
-		StoreService: service.NewStoreService(log.WithPrefix(logger, "service", "store"), storage),
+	if cmd != nil {
+		err = cmd(storage, fs.Args()[1:], log.WithPrefix(logger, "command", fs.Args()[0]))
+		if err != nil {
+			_ = logger.Log("err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// purge, relay and deliver in the background until exit
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// build the services of a store over its storage, settings of its tenant overriding the flags
+	build := func(storage model.Storage, tenant *service.Tenant, logger log.Logger) (*service.Services, service.Options, error) {
+		if migrator, ok := storage.(model.Migrator); ok && *migrate {
+			_, err := migrator.MigrateUp(0)
+			if err != nil {
+				return nil, service.Options{}, err
+			}
+		}
+
+		webhookStore, webhooks := storage.(model.WebhookStore)
+		backuper, backups := storage.(model.Backuper)
+
+		// publish events from change streams where the server has them, and from the services otherwise
+		broker := service.NewBroker()
+		var publisher service.Publisher = broker
+		watcher, watch := storage.(model.EventWatcher)
+		if watch && watcher.CanWatchEvents() {
+			publisher = nil
+		}
+
+		// drop cached documents changed by other instances as soon as told about them
+		cacheWatcher, watchCache := storage.(model.CacheWatcher)
+		watchCache = watchCache && cacheWatcher.CanWatchCachedDocuments()
+
+		// record changes made while serving in the audit log
+		auditLog, audited := storage.(model.AuditLog)
+		if audited {
+			storage = model.NewAuditStorage(storage, auditLog, log.WithPrefix(logger, "storage", "audit"))
+		}
+
+		// cache hot pet and user lookups
+		var cache *model.CacheStorage
+		if *cacheSize > 0 {
+			cache = model.NewCacheStorage(storage, *cacheSize, *cacheTTL)
+			storage = cache
+			if watchCache {
+				go service.RelayInvalidations(log.WithPrefix(logger, "service", "cache"), cacheWatcher, cache, 5*time.Second, stop)
+			} else {
+				_ = logger.Log("msg", "changes of other instances are not watched, cached documents are seen "+
+					"changed once -cache-ttl elapses, set -cache-size 0 when running several instances")
+			}
+		}
+
+		// images of tenants go to a folder of their own unless they say otherwise
+		publicURI, publicPath := *publicBaseUri, *publicFilePath
+		if tenant.Name != "" {
+			publicURI = strings.TrimSuffix(publicURI, "/") + "/" + tenant.Name
+			publicPath = filepath.Join(publicPath, tenant.Name)
+		}
+		if tenant.PublicURI != "" {
+			publicURI = tenant.PublicURI
+		}
+		if tenant.PublicPath != "" {
+			publicPath = tenant.PublicPath
+		}
+		if tenant.Name != "" {
+			if err := os.MkdirAll(publicPath, 0755); err != nil {
+				return nil, service.Options{}, err
+			}
+		}
+
+		// init services
+		services := &service.Services{
+			UserService: service.NewUserService(log.WithPrefix(logger, "service", "user"), storage, model.LockoutPolicy{
+				MaxFailures:     int32(*loginMaxFailures),
+				BaseDelay:       *loginBackoff,
+				MaxDelay:        *loginMaxBackoff,
+				LockoutDuration: *loginLockout,
+			}, *sessionTTL),
+			PetService: service.NewPetService(
+				log.WithPrefix(logger, "service", "pet"), storage, publisher, publicURI, publicPath),
+			StoreService: service.NewStoreService(log.WithPrefix(logger, "service", "store"), storage, publisher),
+			Events:       broker,
+			Cache:        cache,
+		}
+		if webhooks {
+			services.WebhookService = service.NewWebhookService(log.WithPrefix(logger, "service", "webhook"), webhookStore)
+		}
+		if audited {
+			services.AuditService = service.NewAuditService(log.WithPrefix(logger, "service", "audit"), auditLog)
+		}
+
+		// init routes
+		options := service.Options{
+			AdminAPIKey:          *adminApiKey,
+			DisableQueryLogin:    *loginDisableQuery,
+			GraphQLMaxDepth:      *graphqlMaxDepth,
+			GraphQLMaxComplexity: *graphqlMaxComplexity,
+			RateLimit:            *rateLimit,
+			PublicURI:            publicURI,
+			PublicPath:           publicPath,
+		}
+		// tenants have admin keys of their own, never that of the process
+		if tenant.Name != "" {
+			options.AdminAPIKey = tenant.AdminAPIKey
+		}
+		if tenant.RateLimit > 0 {
+			options.RateLimit = tenant.RateLimit
+		}
+
+		// purge deleted documents past retention
+		if *deletedRetention > 0 {
+			purger := service.NewPurger(log.WithPrefix(logger, "service", "purge"), storage, *deletedRetention)
+			go purger.Run(*purgeInterval, stop)
+		}
+
+		// back up to a second file while serving
+		if backups && *boltBackup != "" {
+			runner := service.NewBackupRunner(log.WithPrefix(logger, "service", "backup"), backuper, *boltBackup)
+			go runner.Run(*boltBackupInterval, stop)
+		}
+
+		if publisher == nil {
+			go service.RelayEvents(log.WithPrefix(logger, "service", "events"), storage, watcher, broker, 5*time.Second, stop)
+		}
+
+		// deliver events to webhooks
+		if webhooks && *webhookInterval > 0 {
+			policy := service.DefaultDeliveryPolicy
+			policy.MaxAttempts = *webhookMaxAttempts
+			dispatcher := service.NewDispatcher(log.WithPrefix(logger, "service", "webhook"), storage, webhookStore, policy)
+			go dispatcher.Run(*webhookInterval, stop)
+		}
+		return services, options, nil
 	}
 
-	// init routes
-	r := service.SetupRoutes(&services, log.WithPrefix(logger, "service", "routing"))
+	// serve a single store, or one per tenant each in a database of its own
+	var (
+		r        http.Handler
+		services *service.Services
+		options  service.Options
+	)
+	if *tenantsFile == "" {
+		services, options, err = build(storage, &service.Tenant{}, logger)
+		if err != nil {
+			_ = logger.Log("err", err)
+			os.Exit(1)
+		}
+		r = service.SetupRoutes(services, options, log.WithPrefix(logger, "service", "routing"))
+	} else {
+		r, err = buildTenants(storage, *tenantsFile, service.TenantResolution(*tenantFrom), build, logger)
+		if err != nil {
+			_ = logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
 
 	// format server address
 	addr := fmt.Sprintf("%s:%s", *httpAddr, *httpPort)
@@ -96,7 +412,7 @@ func main() {
 	// catch http server error
 	errs := make(chan error)
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		errs <- fmt.Errorf("%s", <-c)
 	}()
@@ -106,6 +422,21 @@ func main() {
 		_ = logger.Log("transport", "HTTP", "addr", addr)
 		errs <- http.ListenAndServe(addr, r)
 	}()
+	if *grpcPort != "" && services == nil {
+		_ = logger.Log("transport", "gRPC", "msg", "not served to tenants")
+	} else if *grpcPort != "" {
+		grpcAddr := fmt.Sprintf("%s:%s", *httpAddr, *grpcPort)
+		server := service.NewGRPCServer(services, options, log.WithPrefix(logger, "service", "grpc"))
+		go func() {
+			_ = logger.Log("transport", "gRPC", "addr", grpcAddr)
+			lis, err := net.Listen("tcp", grpcAddr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- server.Serve(lis)
+		}()
+	}
 
 	// output (error) event on exit
 	_ = logger.Log("exit", <-errs)
@@ -0,0 +1,421 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Append-only log of changes made to pets, users and orders
+const CollectionAudit string = "audit"
+
+const (
+	AuditCreate  string = "create"
+	AuditUpdate  string = "update"
+	AuditDelete  string = "delete"
+	AuditRestore string = "restore"
+	AuditImport  string = "import"
+	AuditPurge   string = "purge"
+)
+
+const (
+	DefaultAuditPageSize int64 = 50
+	MaxAuditPageSize     int64 = 500
+)
+
+// Actor is who makes changes through a storage, zero valued for anonymous callers and the server itself.
+type Actor struct {
+	UserID    int64  `json:"userId,omitempty" bson:"userId,omitempty"`
+	Username  string `json:"username,omitempty" bson:"username,omitempty"`
+	Role      string `json:"role,omitempty" bson:"role,omitempty"`
+	RequestID string `json:"requestId,omitempty" bson:"requestId,omitempty"`
+}
+
+// AuditChange is the value of a field before and after a change, nil when the field is not set.
+type AuditChange struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before,omitempty" bson:"before,omitempty"`
+	After  interface{} `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// AuditEvent records one change of a document, or the purge of deleted documents of a collection.
+type AuditEvent struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	At       time.Time          `json:"at" bson:"at"`
+	Actor    Actor              `json:"actor" bson:"actor"`
+	Action   string             `json:"action" bson:"action"`
+	Entity   string             `json:"entity" bson:"entity"`
+	EntityID int64              `json:"entityId,omitempty" bson:"entityId,omitempty"`
+	Changes  []AuditChange      `json:"changes,omitempty" bson:"changes,omitempty"`
+	// Number of documents purged
+	Count int64 `json:"count,omitempty" bson:"count,omitempty"`
+}
+
+// AuditFilter selects audit events, zero valued fields don't filter. From is inclusive and To exclusive.
+type AuditFilter struct {
+	Entity   string
+	EntityID int64
+	Actor    string
+	From     time.Time
+	To       time.Time
+	Offset   int64
+	Limit    int64
+}
+
+// PageSize returns Limit bounded by MaxAuditPageSize, DefaultAuditPageSize if not set.
+func (f AuditFilter) PageSize() int64 {
+	if f.Limit <= 0 {
+		return DefaultAuditPageSize
+	}
+	if f.Limit > MaxAuditPageSize {
+		return MaxAuditPageSize
+	}
+	return f.Limit
+}
+
+// AuditLog stores audit events, it is implemented by storages next to their documents.
+type AuditLog interface {
+	// Append event to the log, setting its id
+	AppendAuditEvent(event *AuditEvent) error
+	// Find one page of events matching filter, latest first, with total count of matches
+	FindAuditEvents(filter AuditFilter) ([]*AuditEvent, int64, error)
+}
+
+// ActorStorage is implemented by storages recording who makes changes.
+type ActorStorage interface {
+	Storage
+	// Returns the storage making changes on behalf of actor
+	WithActor(actor Actor) Storage
+}
+
+// AuditStorage is a Storage decorator appending an event to an AuditLog for each change of a pet, user or order
+// made through it. Reads, login attempts and sessions go straight to the decorated storage. Events of changes made
+// in a transaction are appended through the transaction when the storage is also its AuditLog, so that they are
+// only kept if it commits.
+type AuditStorage struct {
+	Storage
+	log    AuditLog
+	logger log.Logger
+	actor  Actor
+	now    func() time.Time
+}
+
+func NewAuditStorage(storage Storage, auditLog AuditLog, logger log.Logger) *AuditStorage {
+	return &AuditStorage{
+		Storage: storage,
+		log:     auditLog,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+func (s *AuditStorage) WithActor(actor Actor) Storage {
+	a := *s
+	a.actor = actor
+	return &a
+}
+
+func (s *AuditStorage) WithTransaction(fn func(tx Storage) error) error {
+	return s.Storage.WithTransaction(func(tx Storage) error {
+		a := *s
+		a.Storage = tx
+		if l, ok := tx.(AuditLog); ok {
+			a.log = l
+		}
+		return fn(&a)
+	})
+}
+
+// record appends an event for a change of a document from before to after, either of them nil when the
+// document did not or does not exist. Failures are logged, as the change has been made already.
+func (s *AuditStorage) record(action, entity string, id int64, before, after interface{}) {
+	s.append(&AuditEvent{
+		Action:   action,
+		Entity:   entity,
+		EntityID: id,
+		Changes:  auditChanges(before, after),
+	})
+}
+
+func (s *AuditStorage) append(event *AuditEvent) {
+	event.At = s.now().UTC()
+	event.Actor = s.actor
+	if err := s.log.AppendAuditEvent(event); err != nil {
+		_ = level.Error(s.logger).Log("msg", "append audit event", "action", event.Action,
+			"entity", event.Entity, "entityId", event.EntityID, "err", err)
+	}
+}
+
+// auditRedacted are fields whose values are never written to the audit log, only that they changed.
+var auditRedacted = map[string]bool{"password": true}
+
+// auditChanges lists the fields, by JSON name, which differ between before and after.
+func auditChanges(before, after interface{}) []AuditChange {
+	b, a := auditFields(before), auditFields(after)
+	var changes []AuditChange
+	for field, value := range b {
+		if v, ok := a[field]; !ok || !reflect.DeepEqual(value, v) {
+			changes = append(changes, AuditChange{Field: field, Before: value, After: v})
+		}
+	}
+	for field, value := range a {
+		if _, ok := b[field]; !ok {
+			changes = append(changes, AuditChange{Field: field, After: value})
+		}
+	}
+	for i, c := range changes {
+		if auditRedacted[c.Field] {
+			changes[i] = AuditChange{Field: c.Field}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func auditFields(doc interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if doc == nil || reflect.ValueOf(doc).IsNil() {
+		return fields
+	}
+	data, err := json.Marshal(doc)
+	if err == nil {
+		err = json.Unmarshal(data, &fields)
+	}
+	if err != nil {
+		return map[string]interface{}{}
+	}
+	return fields
+}
+
+func (s *AuditStorage) CreateUser(user *User) error {
+	if err := s.Storage.CreateUser(user); err != nil {
+		return err
+	}
+	s.record(AuditCreate, CollectionUsers, user.ID, nil, user)
+	return nil
+}
+
+func (s *AuditStorage) CreateManyUsers(users []*User) error {
+	if err := s.Storage.CreateManyUsers(users); err != nil {
+		return err
+	}
+	for _, user := range users {
+		s.record(AuditCreate, CollectionUsers, user.ID, nil, user)
+	}
+	return nil
+}
+
+func (s *AuditStorage) UpdateUserByUsername(username string, user *User) (*User, error) {
+	before, _ := s.Storage.RetrieveUserByUsername(username)
+	updated, err := s.Storage.UpdateUserByUsername(username, user)
+	if err != nil {
+		return updated, err
+	}
+	after, _ := s.Storage.RetrieveUserByUsername(username)
+	s.record(AuditUpdate, CollectionUsers, userID(before, after), before, after)
+	return updated, nil
+}
+
+func (s *AuditStorage) PatchUserByUsername(username string, version int64, update *Update) (*User, error) {
+	before, _ := s.Storage.RetrieveUserByUsername(username)
+	after, err := s.Storage.PatchUserByUsername(username, version, update)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditUpdate, CollectionUsers, userID(before, after), before, after)
+	return after, nil
+}
+
+func (s *AuditStorage) DeleteUserByUsername(username string, hard bool) error {
+	before, _ := s.Storage.RetrieveUserByUsername(username)
+	if err := s.Storage.DeleteUserByUsername(username, hard); err != nil {
+		return err
+	}
+	s.record(AuditDelete, CollectionUsers, userID(before, nil), before, nil)
+	return nil
+}
+
+func (s *AuditStorage) RestoreUserByUsername(username string) (*User, error) {
+	after, err := s.Storage.RestoreUserByUsername(username)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditRestore, CollectionUsers, after.ID, nil, after)
+	return after, nil
+}
+
+func userID(users ...*User) int64 {
+	for _, u := range users {
+		if u != nil {
+			return u.ID
+		}
+	}
+	return 0
+}
+
+func (s *AuditStorage) CreatePet(pet *Pet) error {
+	if err := s.Storage.CreatePet(pet); err != nil {
+		return err
+	}
+	s.record(AuditCreate, CollectionPets, pet.ID, nil, pet)
+	return nil
+}
+
+func (s *AuditStorage) CreateManyPets(pets []*Pet) error {
+	if err := s.Storage.CreateManyPets(pets); err != nil {
+		return err
+	}
+	for _, pet := range pets {
+		s.record(AuditCreate, CollectionPets, pet.ID, nil, pet)
+	}
+	return nil
+}
+
+// updatePet records the change of pet id made by update.
+func (s *AuditStorage) updatePet(id int64, update func() error) error {
+	before, _ := s.Storage.RetrievePetByID(id)
+	if err := update(); err != nil {
+		return err
+	}
+	after, _ := s.Storage.RetrievePetByID(id)
+	s.record(AuditUpdate, CollectionPets, id, before, after)
+	return nil
+}
+
+func (s *AuditStorage) UpdatePetByID(pet *Pet) error {
+	return s.updatePet(pet.ID, func() error {
+		return s.Storage.UpdatePetByID(pet)
+	})
+}
+
+func (s *AuditStorage) PatchPetByID(id int64, version int64, update *Update) (*Pet, error) {
+	before, _ := s.Storage.RetrievePetByID(id)
+	after, err := s.Storage.PatchPetByID(id, version, update)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditUpdate, CollectionPets, id, before, after)
+	return after, nil
+}
+
+func (s *AuditStorage) UpdatePetNameAndStatusByID(id int64, version int64, name string, status string) error {
+	return s.updatePet(id, func() error {
+		return s.Storage.UpdatePetNameAndStatusByID(id, version, name, status)
+	})
+}
+
+func (s *AuditStorage) UpdatePetNameByID(id int64, version int64, name string) error {
+	return s.updatePet(id, func() error {
+		return s.Storage.UpdatePetNameByID(id, version, name)
+	})
+}
+
+func (s *AuditStorage) UpdatePetStatusByID(id int64, version int64, status string) error {
+	return s.updatePet(id, func() error {
+		return s.Storage.UpdatePetStatusByID(id, version, status)
+	})
+}
+
+func (s *AuditStorage) AddImageUrlByPetID(id int64, url string) (*Pet, error) {
+	before, _ := s.Storage.RetrievePetByID(id)
+	after, err := s.Storage.AddImageUrlByPetID(id, url)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditUpdate, CollectionPets, id, before, after)
+	return after, nil
+}
+
+func (s *AuditStorage) ReserveStockByPetID(id int64, quantity int64) (*Pet, error) {
+	before, _ := s.Storage.RetrievePetByID(id)
+	after, err := s.Storage.ReserveStockByPetID(id, quantity)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditUpdate, CollectionPets, id, before, after)
+	return after, nil
+}
+
+func (s *AuditStorage) ReleaseStockByPetID(id int64, quantity int64) error {
+	return s.updatePet(id, func() error {
+		return s.Storage.ReleaseStockByPetID(id, quantity)
+	})
+}
+
+func (s *AuditStorage) DeletePetByID(id int64, hard bool) error {
+	before, _ := s.Storage.RetrievePetByID(id)
+	if err := s.Storage.DeletePetByID(id, hard); err != nil {
+		return err
+	}
+	s.record(AuditDelete, CollectionPets, id, before, nil)
+	return nil
+}
+
+func (s *AuditStorage) RestorePetByID(id int64) (*Pet, error) {
+	after, err := s.Storage.RestorePetByID(id)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditRestore, CollectionPets, id, nil, after)
+	return after, nil
+}
+
+func (s *AuditStorage) CreateOrder(order *Order) (*Order, error) {
+	created, err := s.Storage.CreateOrder(order)
+	if err != nil {
+		return created, err
+	}
+	s.record(AuditCreate, CollectionOrders, created.ID, nil, created)
+	return created, nil
+}
+
+func (s *AuditStorage) DeleteOrderByID(id int64, hard bool) error {
+	before, _ := s.Storage.RetrieveOrderByID(id)
+	if err := s.Storage.DeleteOrderByID(id, hard); err != nil {
+		return err
+	}
+	s.record(AuditDelete, CollectionOrders, id, before, nil)
+	return nil
+}
+
+func (s *AuditStorage) UpdateOrderStatusByID(id int64, version int64, status string) (*Order, error) {
+	before, _ := s.Storage.RetrieveOrderByID(id)
+	after, err := s.Storage.UpdateOrderStatusByID(id, version, status)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditUpdate, CollectionOrders, id, before, after)
+	return after, nil
+}
+
+func (s *AuditStorage) RestoreOrderByID(id int64) (*Order, error) {
+	after, err := s.Storage.RestoreOrderByID(id)
+	if err != nil {
+		return after, err
+	}
+	s.record(AuditRestore, CollectionOrders, id, nil, after)
+	return after, nil
+}
+
+func (s *AuditStorage) ImportDocument(collection string, doc interface{}, mode ConflictMode, dryRun bool) (ImportOutcome, error) {
+	outcome, err := s.Storage.ImportDocument(collection, doc, mode, dryRun)
+	if err != nil || dryRun || outcome == ImportSkipped {
+		return outcome, err
+	}
+	id, _ := DocumentID(doc)
+	s.record(AuditImport, collection, id, nil, doc)
+	return outcome, nil
+}
+
+func (s *AuditStorage) PurgeDeleted(collection string, before time.Time) (int64, error) {
+	n, err := s.Storage.PurgeDeleted(collection, before)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	s.append(&AuditEvent{Action: AuditPurge, Entity: collection, Count: n})
+	return n, nil
+}
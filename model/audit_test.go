@@ -0,0 +1,148 @@
+package model
+
+// This is to test the audit log decorator, with a storage keeping pets and users in memory
+
+import (
+	"errors"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// memoryStorage keeps pets and users in maps, other Storage methods are not implemented.
+type memoryStorage struct {
+	Storage
+	pets   map[int64]Pet
+	users  map[string]User
+	events []*AuditEvent
+}
+
+func (m *memoryStorage) AppendAuditEvent(event *AuditEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memoryStorage) FindAuditEvents(filter AuditFilter) ([]*AuditEvent, int64, error) {
+	return m.events, int64(len(m.events)), nil
+}
+
+func (m *memoryStorage) CreatePet(pet *Pet) error {
+	if _, ok := m.pets[pet.ID]; ok {
+		return errors.New("duplicate pet id exists")
+	}
+	pet.Version = 1
+	m.pets[pet.ID] = *pet
+	return nil
+}
+
+func (m *memoryStorage) RetrievePetByID(id int64) (*Pet, error) {
+	pet, ok := m.pets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &pet, nil
+}
+
+func (m *memoryStorage) UpdatePetNameByID(id int64, version int64, name string) error {
+	pet, ok := m.pets[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if version != 0 && version != pet.Version {
+		return ErrVersionMismatch
+	}
+	pet.Name = name
+	pet.Version++
+	m.pets[id] = pet
+	return nil
+}
+
+func (m *memoryStorage) DeletePetByID(id int64, hard bool) error {
+	if _, ok := m.pets[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.pets, id)
+	return nil
+}
+
+func (m *memoryStorage) CreateUser(user *User) error {
+	m.users[user.Username] = *user
+	return nil
+}
+
+func (m *memoryStorage) RetrieveUserByUsername(username string) (*User, error) {
+	user, ok := m.users[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *memoryStorage) UpdateUserByUsername(username string, user *User) (*User, error) {
+	m.users[username] = *user
+	return user, nil
+}
+
+func (m *memoryStorage) WithTransaction(fn func(tx Storage) error) error {
+	tx := &memoryStorage{pets: m.pets, users: m.users}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.events = append(m.events, tx.events...)
+	return nil
+}
+
+func TestAuditStorage(t *testing.T) {
+	memory := &memoryStorage{pets: map[int64]Pet{}, users: map[string]User{}}
+	audit := NewAuditStorage(memory, memory, log.NewNopLogger())
+	now := time.Date(2019, 6, 2, 0, 0, 0, 0, time.UTC)
+	audit.now = func() time.Time { return now }
+	actor := Actor{UserID: 1, Username: "staff", Role: RoleStaff, RequestID: "req-1"}
+	storage := audit.WithActor(actor)
+
+	assert.NoError(t, storage.CreatePet(&Pet{ID: 1, Name: "rex", Status: "available"}))
+	assert.Len(t, memory.events, 1)
+	created := memory.events[0]
+	assert.Equal(t, now, created.At)
+	assert.Equal(t, actor, created.Actor)
+	assert.Equal(t, AuditCreate, created.Action)
+	assert.Equal(t, CollectionPets, created.Entity)
+	assert.Equal(t, int64(1), created.EntityID)
+	assert.Contains(t, created.Changes, AuditChange{Field: "name", After: "rex"})
+
+	// only changed fields are recorded, and nothing for failed changes
+	assert.NoError(t, storage.UpdatePetNameByID(1, 1, "max"))
+	assert.Equal(t, ErrVersionMismatch, storage.UpdatePetNameByID(1, 1, "bob"))
+	assert.Len(t, memory.events, 2)
+	assert.Equal(t, AuditUpdate, memory.events[1].Action)
+	assert.Equal(t, []AuditChange{
+		{Field: "name", Before: "rex", After: "max"},
+		{Field: "version", Before: float64(1), After: float64(2)},
+	}, memory.events[1].Changes)
+
+	// changes in a transaction are recorded through it
+	err := storage.WithTransaction(func(tx Storage) error {
+		return tx.DeletePetByID(1, false)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, memory.events, 3)
+	assert.Equal(t, AuditDelete, memory.events[2].Action)
+	assert.Equal(t, actor, memory.events[2].Actor)
+	assert.Contains(t, memory.events[2].Changes, AuditChange{Field: "name", Before: "max"})
+
+	// passwords are never recorded
+	assert.NoError(t, audit.CreateUser(&User{ID: 2, Username: "jane", Password: "secret"}))
+	_, err = audit.UpdateUserByUsername("jane", &User{ID: 2, Username: "jane", Password: "changed"})
+	assert.NoError(t, err)
+	assert.Len(t, memory.events, 5)
+	assert.Equal(t, Actor{}, memory.events[3].Actor)
+	assert.Contains(t, memory.events[3].Changes, AuditChange{Field: "password"})
+	assert.Equal(t, []AuditChange{{Field: "password"}}, memory.events[4].Changes)
+}
+
+func TestAuditFilterPageSize(t *testing.T) {
+	assert.Equal(t, DefaultAuditPageSize, AuditFilter{}.PageSize())
+	assert.Equal(t, int64(10), AuditFilter{Limit: 10}.PageSize())
+	assert.Equal(t, MaxAuditPageSize, AuditFilter{Limit: 10000}.PageSize())
+}
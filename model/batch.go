@@ -0,0 +1,53 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BatchItemError tells why one item of a batch failed, Index is the position of the item in the batch.
+type BatchItemError struct {
+	Index   int    `json:"index"`
+	ID      int64  `json:"id"`
+	Message string `json:"message"`
+}
+
+const (
+	BatchItemCreated    string = "created"
+	BatchItemFailed     string = "failed"
+	BatchItemNotCreated string = "not_created"
+)
+
+// BatchItemResult tells what happened to one item of a batch. Items which are fine by themselves but were not
+// created because other items of an all or nothing batch failed are not_created.
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchError is returned when a batch is refused as a whole because some of its items failed.
+type BatchError struct {
+	Items []BatchItemError `json:"items"`
+}
+
+func (e *BatchError) Add(index int, id int64, message string) {
+	e.Items = append(e.Items, BatchItemError{Index: index, ID: id, Message: message})
+}
+
+// Err returns e if any item failed, nil otherwise.
+func (e *BatchError) Err() error {
+	if len(e.Items) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *BatchError) Error() string {
+	var msgs []string
+	for _, item := range e.Items {
+		msgs = append(msgs, fmt.Sprintf("item %d ( id %d ): %s", item.Index, item.ID, item.Message))
+	}
+	return fmt.Sprintf("%d items of batch failed: %s", len(e.Items), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBatchError(t *testing.T) {
+	e := &BatchError{}
+	assert.NoError(t, e.Err())
+
+	e.Add(1, 10, "duplicate id")
+	e.Add(3, 12, "invalid name")
+	assert.Error(t, e.Err())
+	assert.Equal(t, []BatchItemError{{Index: 1, ID: 10, Message: "duplicate id"}, {Index: 3, ID: 12, Message: "invalid name"}}, e.Items)
+	assert.Equal(t, "2 items of batch failed: item 1 ( id 10 ): duplicate id; item 3 ( id 12 ): invalid name", e.Error())
+}
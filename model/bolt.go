@@ -0,0 +1,1346 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Backuper is implemented by storages writing a consistent copy of their data to a file while serving.
+type Backuper interface {
+	// Write a copy of all data to path, replacing the file there only once the copy is complete
+	Backup(path string) error
+}
+
+// Buckets indexing documents, keyed by the indexed value and the document id.
+const (
+	boltPetsByStatus    = "pets_by_status"
+	boltPetsByTag       = "pets_by_tag"
+	boltUsersByUsername = "users_by_username"
+)
+
+var boltBuckets = []string{
+	CollectionPets, CollectionUsers, CollectionOrders, CollectionCategories, CollectionTags, CollectionEvents,
+	CollectionLoginAttempts, CollectionSessions, boltPetsByStatus, boltPetsByTag, boltUsersByUsername,
+}
+
+// BoltStorage keeps all documents in a single bbolt file, encoded as bson, with buckets indexing pets by status and
+// tag name and users by username. Each write is a transaction synced to the file before it returns, so that a crash
+// loses none of the writes made and leaves none half made. Errors are those of MongoStorage.
+type BoltStorage struct {
+	db     *bbolt.DB
+	Path   string
+	Logger log.Logger
+
+	// transaction operations take part in, nil outside transactions
+	tx *bbolt.Tx
+}
+
+// NewBoltStorage opens the file at path, creating it if needed. Only one process can open it at a time, others fail
+// after waiting for a second.
+func NewBoltStorage(path string, logger log.Logger) (Storage, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err == bbolt.ErrTimeout {
+		return nil, fmt.Errorf("open %s: held by another process", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return BoltStorage{
+		db:     db,
+		Path:   path,
+		Logger: logger,
+	}, nil
+}
+
+func (b BoltStorage) update(fn func(tx *bbolt.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return b.db.Update(fn)
+}
+
+func (b BoltStorage) view(fn func(tx *bbolt.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return b.db.View(fn)
+}
+
+// WithTransaction runs fn with a storage whose operations take part in one transaction, committed when fn
+// returns nil and rolled back otherwise.
+func (b BoltStorage) WithTransaction(fn func(tx Storage) error) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		s := b
+		s.tx = tx
+		return fn(s)
+	})
+}
+
+// Backup writes the data as of one transaction to a temporary file next to path, then renames it to path, while
+// writes go on.
+func (b BoltStorage) Backup(path string) error {
+	f, err := os.OpenFile(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = b.db.View(func(tx *bbolt.Tx) error {
+		_, err := tx.WriteTo(f)
+		return err
+	})
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// idKey encodes id so that keys sort in the order of ids, negative ones first.
+func idKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id)^1<<63)
+	return key
+}
+
+func keyID(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[len(key)-8:]) ^ 1<<63)
+}
+
+// indexKey is the key of the document of id in an index, by value.
+func indexKey(value string, id int64) []byte {
+	return append([]byte(value+"\x00"), idKey(id)...)
+}
+
+// indexedIDs returns the ids of documents indexed with value in index.
+func indexedIDs(tx *bbolt.Tx, index string, value string) []int64 {
+	var ids []int64
+	prefix := []byte(value + "\x00")
+	c := tx.Bucket([]byte(index)).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids = append(ids, keyID(k))
+	}
+	return ids
+}
+
+// getDoc decodes the document at key of bucket into doc, and tells whether there is one.
+func getDoc(tx *bbolt.Tx, bucket string, key []byte, doc interface{}) (bool, error) {
+	v := tx.Bucket([]byte(bucket)).Get(key)
+	if v == nil {
+		return false, nil
+	}
+	return true, bson.Unmarshal(v, doc)
+}
+
+func putDoc(tx *bbolt.Tx, bucket string, key []byte, doc interface{}) error {
+	v, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(bucket)).Put(key, v)
+}
+
+// eachDoc calls fn with each key and value of bucket, in order of keys.
+func eachDoc(tx *bbolt.Tx, bucket string, fn func(k, v []byte) error) error {
+	return tx.Bucket([]byte(bucket)).ForEach(fn)
+}
+
+func checkVersion(stored, expected int64) error {
+	if expected != 0 && expected != stored {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// loadPet reads the pet of id, deleted or not, nil if there is none.
+func loadPet(tx *bbolt.Tx, id int64) (*Pet, error) {
+	pet := &Pet{}
+	found, err := getDoc(tx, CollectionPets, idKey(id), pet)
+	if !found || err != nil {
+		return nil, err
+	}
+	return pet, nil
+}
+
+// livePet reads the pet of id, ErrNotFound if there is none or it is deleted.
+func livePet(tx *bbolt.Tx, id int64) (*Pet, error) {
+	pet, err := loadPet(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pet == nil || pet.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return pet, nil
+}
+
+func petIndexKeys(pet *Pet) map[string][][]byte {
+	keys := map[string][][]byte{boltPetsByStatus: {indexKey(pet.Status, pet.ID)}}
+	for _, tag := range pet.Tags {
+		if tag != nil {
+			keys[boltPetsByTag] = append(keys[boltPetsByTag], indexKey(tag.Name, pet.ID))
+		}
+	}
+	return keys
+}
+
+// savePet stores pet in place of old, if any, and updates the indexes.
+func savePet(tx *bbolt.Tx, pet *Pet, old *Pet) error {
+	if old != nil {
+		if err := removePet(tx, old); err != nil {
+			return err
+		}
+	}
+	if err := putDoc(tx, CollectionPets, idKey(pet.ID), pet); err != nil {
+		return err
+	}
+	for index, keys := range petIndexKeys(pet) {
+		for _, k := range keys {
+			if err := tx.Bucket([]byte(index)).Put(k, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removePet deletes pet and its index entries.
+func removePet(tx *bbolt.Tx, pet *Pet) error {
+	for index, keys := range petIndexKeys(pet) {
+		for _, k := range keys {
+			if err := tx.Bucket([]byte(index)).Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Bucket([]byte(CollectionPets)).Delete(idKey(pet.ID))
+}
+
+// livePets reads the pets of ids, leaving out those not found or deleted.
+func livePets(tx *bbolt.Tx, ids []int64) ([]*Pet, error) {
+	var pets []*Pet
+	for _, id := range ids {
+		pet, err := loadPet(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if pet != nil && pet.DeletedAt == nil {
+			pets = append(pets, pet)
+		}
+	}
+	return pets, nil
+}
+
+// petIDsByStatus returns the ids of pets of statuses, or of all pets when there are none.
+func petIDsByStatus(tx *bbolt.Tx, statuses []string) []int64 {
+	var ids []int64
+	if len(statuses) == 0 {
+		_ = eachDoc(tx, CollectionPets, func(k, v []byte) error {
+			ids = append(ids, keyID(k))
+			return nil
+		})
+		return ids
+	}
+	for _, status := range statuses {
+		ids = append(ids, indexedIDs(tx, boltPetsByStatus, status)...)
+	}
+	return ids
+}
+
+// loadUser reads the user of id, deleted or not, nil if there is none.
+func loadUser(tx *bbolt.Tx, id int64) (*User, error) {
+	user := &User{}
+	found, err := getDoc(tx, CollectionUsers, idKey(id), user)
+	if !found || err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// loadUserByUsername reads the user of username, deleted or not, nil if there is none.
+func loadUserByUsername(tx *bbolt.Tx, username string) (*User, error) {
+	ids := indexedIDs(tx, boltUsersByUsername, username)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return loadUser(tx, ids[0])
+}
+
+func liveUser(user *User, err error) (*User, error) {
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// saveUser stores user in place of old, if any, and updates the index. Its id and username have to be its own or
+// free.
+func saveUser(tx *bbolt.Tx, user *User, old *User) error {
+	if old == nil || old.ID != user.ID {
+		if found, err := loadUser(tx, user.ID); err != nil || found != nil {
+			return duplicateError(err, "duplicate user id exists")
+		}
+	}
+	if old == nil || old.Username != user.Username {
+		if len(indexedIDs(tx, boltUsersByUsername, user.Username)) > 0 {
+			return errors.New("duplicate username exists")
+		}
+	}
+	if old != nil {
+		if err := removeUser(tx, old); err != nil {
+			return err
+		}
+	}
+	if err := putDoc(tx, CollectionUsers, idKey(user.ID), user); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(boltUsersByUsername)).Put(indexKey(user.Username, user.ID), nil)
+}
+
+// removeUser deletes user and its index entry.
+func removeUser(tx *bbolt.Tx, user *User) error {
+	if err := tx.Bucket([]byte(boltUsersByUsername)).Delete(indexKey(user.Username, user.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(CollectionUsers)).Delete(idKey(user.ID))
+}
+
+// loadOrder reads the order of id, deleted or not, nil if there is none.
+func loadOrder(tx *bbolt.Tx, id int64) (*Order, error) {
+	order := &Order{}
+	found, err := getDoc(tx, CollectionOrders, idKey(id), order)
+	if !found || err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func liveOrder(tx *bbolt.Tx, id int64) (*Order, error) {
+	order, err := loadOrder(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return order, nil
+}
+
+func (b BoltStorage) CreateUser(user *User) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		user.Version = 1
+		return saveUser(tx, user, nil)
+	})
+}
+
+// CreateManyUsers creates all users or none of them, a *BatchError tells which users failed.
+func (b BoltStorage) CreateManyUsers(users []*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	return b.update(func(tx *bbolt.Tx) error {
+		batch := &BatchError{}
+		ids := map[int64]bool{}
+		usernames := map[string]bool{}
+		for i, u := range users {
+			if u == nil {
+				batch.Add(i, 0, "user is null")
+				continue
+			}
+			if ids[u.ID] {
+				batch.Add(i, u.ID, "duplicate user id in batch")
+				continue
+			}
+			if usernames[u.Username] {
+				batch.Add(i, u.ID, fmt.Sprintf("duplicate username %s in batch", u.Username))
+				continue
+			}
+			ids[u.ID] = true
+			usernames[u.Username] = true
+
+			if found, err := loadUser(tx, u.ID); err != nil || found != nil {
+				batch.Add(i, u.ID, duplicateError(err, fmt.Sprintf("duplicate user id exists for %d", u.ID)).Error())
+				continue
+			}
+			if len(indexedIDs(tx, boltUsersByUsername, u.Username)) > 0 {
+				batch.Add(i, u.ID, fmt.Sprintf("duplicate username exists for %s", u.Username))
+				continue
+			}
+		}
+		if err := batch.Err(); err != nil {
+			return err
+		}
+
+		for i, u := range users {
+			u.Version = 1
+			if err := saveUser(tx, u, nil); err != nil {
+				batch.Add(i, u.ID, err.Error())
+				return batch
+			}
+		}
+		return nil
+	})
+}
+
+func (b BoltStorage) RetrieveUserByUsername(username string) (*User, error) {
+	var user *User
+	err := b.view(func(tx *bbolt.Tx) (err error) {
+		user, err = liveUser(loadUserByUsername(tx, username))
+		return err
+	})
+	return user, err
+}
+
+func (b BoltStorage) RetrieveUserByID(id int64) (*User, error) {
+	var user *User
+	err := b.view(func(tx *bbolt.Tx) (err error) {
+		user, err = liveUser(loadUser(tx, id))
+		return err
+	})
+	return user, err
+}
+
+func (b BoltStorage) RetrieveUsersByIDs(ids []int64) ([]*User, error) {
+	users := []*User{}
+	err := b.view(func(tx *bbolt.Tx) error {
+		for _, id := range ids {
+			user, err := loadUser(tx, id)
+			if err != nil {
+				return err
+			}
+			if user != nil && user.DeletedAt == nil {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (b BoltStorage) UpdateUserByUsername(username string, user *User) (*User, error) {
+	err := b.update(func(tx *bbolt.Tx) error {
+		old, err := liveUser(loadUserByUsername(tx, username))
+		if err != nil {
+			return err
+		}
+		if err = checkVersion(old.Version, user.Version); err != nil {
+			return err
+		}
+		replacement := *user
+		replacement.Version = old.Version + 1
+		replacement.DeletedAt = nil
+		if err = saveUser(tx, &replacement, old); err != nil {
+			return err
+		}
+		user.Version = replacement.Version
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (b BoltStorage) PatchUserByUsername(username string, version int64, update *Update) (*User, error) {
+	var user *User
+	err := b.update(func(tx *bbolt.Tx) error {
+		old, err := liveUser(loadUserByUsername(tx, username))
+		if err != nil {
+			return err
+		}
+		if err = checkVersion(old.Version, version); err != nil {
+			return err
+		}
+		patched := *old
+		if err = applyUpdate(&patched, update); err != nil {
+			return err
+		}
+		patched.Version = old.Version + 1
+		if err = saveUser(tx, &patched, old); err != nil {
+			return err
+		}
+		user = &patched
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (b BoltStorage) DeleteUserByUsername(username string, hard bool) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		user, err := loadUserByUsername(tx, username)
+		if err != nil {
+			return err
+		}
+		if hard && user != nil {
+			return removeUser(tx, user)
+		}
+		if user == nil || user.DeletedAt != nil {
+			return ErrNotFound
+		}
+		now := time.Now().UTC()
+		deleted := *user
+		deleted.DeletedAt = &now
+		deleted.Version++
+		return saveUser(tx, &deleted, user)
+	})
+}
+
+func (b BoltStorage) RestoreUserByUsername(username string) (*User, error) {
+	var user *User
+	err := b.update(func(tx *bbolt.Tx) error {
+		old, err := loadUserByUsername(tx, username)
+		if err != nil {
+			return err
+		}
+		if old == nil || old.DeletedAt == nil {
+			return ErrNotFound
+		}
+		restored := *old
+		restored.DeletedAt = nil
+		restored.Version++
+		user = &restored
+		return saveUser(tx, user, old)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (b BoltStorage) CreatePet(pet *Pet) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		if found, err := loadPet(tx, pet.ID); err != nil || found != nil {
+			return duplicateError(err, "duplicate pet id exists")
+		}
+		pet.Version = 1
+		return savePet(tx, pet, nil)
+	})
+}
+
+// CreateManyPets creates all pets or none of them, a *BatchError tells which pets failed.
+func (b BoltStorage) CreateManyPets(pets []*Pet) error {
+	if len(pets) == 0 {
+		return nil
+	}
+	return b.update(func(tx *bbolt.Tx) error {
+		batch := &BatchError{}
+		ids := map[int64]bool{}
+		for i, pet := range pets {
+			if pet == nil {
+				batch.Add(i, 0, "pet is null")
+				continue
+			}
+			if ids[pet.ID] {
+				batch.Add(i, pet.ID, "duplicate pet id in batch")
+				continue
+			}
+			ids[pet.ID] = true
+
+			if found, err := loadPet(tx, pet.ID); err != nil || found != nil {
+				batch.Add(i, pet.ID, duplicateError(err, fmt.Sprintf("duplicate pet id exists for %d", pet.ID)).Error())
+				continue
+			}
+		}
+		if err := batch.Err(); err != nil {
+			return err
+		}
+
+		for i, pet := range pets {
+			pet.Version = 1
+			if err := savePet(tx, pet, nil); err != nil {
+				batch.Add(i, pet.ID, err.Error())
+				return batch
+			}
+		}
+		return nil
+	})
+}
+
+func (b BoltStorage) UpdatePetByID(pet *Pet) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		old, err := livePet(tx, pet.ID)
+		if err != nil {
+			return err
+		}
+		if err = checkVersion(old.Version, pet.Version); err != nil {
+			return err
+		}
+		replacement := *pet
+		replacement.Version = old.Version + 1
+		replacement.DeletedAt = nil
+		if err = savePet(tx, &replacement, old); err != nil {
+			return err
+		}
+		pet.Version = replacement.Version
+		return nil
+	})
+}
+
+func (b BoltStorage) RetrievePetByID(id int64) (*Pet, error) {
+	var pet *Pet
+	err := b.view(func(tx *bbolt.Tx) (err error) {
+		pet, err = livePet(tx, id)
+		return err
+	})
+	return pet, err
+}
+
+func (b BoltStorage) RetrievePetsByIDs(ids []int64) ([]*Pet, error) {
+	pets := []*Pet{}
+	err := b.view(func(tx *bbolt.Tx) error {
+		found, err := livePets(tx, ids)
+		pets = append(pets, found...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pets, nil
+}
+
+func (b BoltStorage) FindPetsByStatus(statuses []string) ([]*Pet, error) {
+	var pets []*Pet
+	err := b.view(func(tx *bbolt.Tx) (err error) {
+		if len(statuses) > 0 {
+			pets, err = livePets(tx, petIDsByStatus(tx, statuses))
+		}
+		return err
+	})
+	return pets, err
+}
+
+// FindPetsByTags returns the pets having any of tags, by tag name, in order of ids.
+func (b BoltStorage) FindPetsByTags(tags []string) ([]*Pet, error) {
+	var pets []*Pet
+	err := b.view(func(tx *bbolt.Tx) (err error) {
+		seen := map[int64]bool{}
+		var ids []int64
+		for _, tag := range tags {
+			for _, id := range indexedIDs(tx, boltPetsByTag, tag) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		pets, err = livePets(tx, ids)
+		return err
+	})
+	return pets, err
+}
+
+// StreamPetsByStatus reads each pet in a transaction of its own, so that fn is free to write.
+func (b BoltStorage) StreamPetsByStatus(statuses []string, fn func(pet *Pet) error) error {
+	var ids []int64
+	err := b.view(func(tx *bbolt.Tx) error {
+		ids = petIDsByStatus(tx, statuses)
+		return nil
+	})
+	if err != nil || len(statuses) == 0 {
+		return err
+	}
+	for _, id := range ids {
+		var pets []*Pet
+		err = b.view(func(tx *bbolt.Tx) (err error) {
+			pets, err = livePets(tx, []int64{id})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, pet := range pets {
+			if err = fn(pet); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SearchPets ranks all pets matching statuses in the process.
+func (b BoltStorage) SearchPets(search PetSearch) ([]*PetSearchResult, int64, error) {
+	if len(search.Terms()) == 0 {
+		return []*PetSearchResult{}, 0, nil
+	}
+	var pets []*Pet
+	err := b.view(func(tx *bbolt.Tx) (err error) {
+		pets, err = livePets(tx, petIDsByStatus(tx, search.Statuses))
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	results, total := SearchPets(pets, search)
+	return results, total, nil
+}
+
+func (b BoltStorage) PatchPetByID(id int64, version int64, update *Update) (*Pet, error) {
+	var pet *Pet
+	err := b.update(func(tx *bbolt.Tx) error {
+		old, err := livePet(tx, id)
+		if err != nil {
+			return err
+		}
+		if err = checkVersion(old.Version, version); err != nil {
+			return err
+		}
+		patched := *old
+		if err = applyUpdate(&patched, update); err != nil {
+			return err
+		}
+		patched.ID = id
+		patched.Version = old.Version + 1
+		pet = &patched
+		return savePet(tx, pet, old)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pet, nil
+}
+
+// updatePet changes the pet of id with fn, only if stored at version unless it is 0, and increments its version.
+func (b BoltStorage) updatePet(id int64, version int64, fn func(pet *Pet)) (*Pet, error) {
+	var pet *Pet
+	err := b.update(func(tx *bbolt.Tx) error {
+		old, err := livePet(tx, id)
+		if err != nil {
+			return err
+		}
+		if err = checkVersion(old.Version, version); err != nil {
+			return err
+		}
+		updated := *old
+		fn(&updated)
+		updated.Version = old.Version + 1
+		pet = &updated
+		return savePet(tx, pet, old)
+	})
+	return pet, err
+}
+
+func (b BoltStorage) UpdatePetNameAndStatusByID(id int64, version int64, name string, status string) error {
+	_, err := b.updatePet(id, version, func(pet *Pet) {
+		pet.Name = name
+		pet.Status = status
+	})
+	return err
+}
+
+func (b BoltStorage) UpdatePetNameByID(id int64, version int64, name string) error {
+	_, err := b.updatePet(id, version, func(pet *Pet) {
+		pet.Name = name
+	})
+	return err
+}
+
+func (b BoltStorage) UpdatePetStatusByID(id int64, version int64, status string) error {
+	_, err := b.updatePet(id, version, func(pet *Pet) {
+		pet.Status = status
+	})
+	return err
+}
+
+func (b BoltStorage) AddImageUrlByPetID(id int64, url string) (*Pet, error) {
+	return b.updatePet(id, 0, func(pet *Pet) {
+		// added once, like to a set
+		for _, u := range pet.PhotoUrls {
+			if u == url {
+				return
+			}
+		}
+		pet.PhotoUrls = append(pet.PhotoUrls, url)
+	})
+}
+
+func (b BoltStorage) RetrieveStoreInventoriesByStatus() (map[string]int64, error) {
+	inv := map[string]int64{}
+	err := b.view(func(tx *bbolt.Tx) error {
+		return eachDoc(tx, CollectionPets, func(k, v []byte) error {
+			pet := Pet{}
+			if err := bson.Unmarshal(v, &pet); err != nil {
+				return err
+			}
+			if pet.DeletedAt == nil {
+				inv[pet.Status] += pet.Units()
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (b BoltStorage) RetrieveStoreStockValue() (StockValue, error) {
+	value := StockValue{}
+	err := b.view(func(tx *bbolt.Tx) error {
+		pets, err := livePets(tx, petIDsByStatus(tx, []string{PetStatusAvailable}))
+		if err != nil {
+			return err
+		}
+		for _, pet := range pets {
+			if pet.Price <= 0 {
+				continue
+			}
+			amount, err := MultiplyAmount(pet.Price, pet.Units())
+			if err != nil {
+				return err
+			}
+			if err = value.Add(pet.Currency, amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (b BoltStorage) ReserveStockByPetID(id int64, quantity int64) (*Pet, error) {
+	var pet *Pet
+	err := b.update(func(tx *bbolt.Tx) error {
+		old, err := livePet(tx, id)
+		if err != nil {
+			return err
+		}
+		reserved := *old
+		if old.Stock == nil {
+			// single pets are taken by moving them from available to pending
+			if quantity > 1 || old.Status != PetStatusAvailable {
+				return ErrOutOfStock
+			}
+			reserved.Status = PetStatusPending
+		} else {
+			if *old.Stock < quantity {
+				return ErrOutOfStock
+			}
+			stock := *old.Stock - quantity
+			reserved.Stock = &stock
+		}
+		reserved.Version++
+		pet = &reserved
+		return savePet(tx, pet, old)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pet, nil
+}
+
+func (b BoltStorage) ReleaseStockByPetID(id int64, quantity int64) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		old, err := loadPet(tx, id)
+		if err != nil || old == nil || (old.Stock == nil && old.Status != PetStatusPending) {
+			return err
+		}
+		released := *old
+		if old.Stock == nil {
+			released.Status = PetStatusAvailable
+		} else {
+			stock := *old.Stock + quantity
+			released.Stock = &stock
+		}
+		released.Version++
+		return savePet(tx, &released, old)
+	})
+}
+
+func (b BoltStorage) DeletePetByID(id int64, hard bool) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		pet, err := loadPet(tx, id)
+		if err != nil {
+			return err
+		}
+		if hard && pet != nil {
+			return removePet(tx, pet)
+		}
+		if pet == nil || pet.DeletedAt != nil {
+			return ErrNotFound
+		}
+		now := time.Now().UTC()
+		deleted := *pet
+		deleted.DeletedAt = &now
+		deleted.Version++
+		return savePet(tx, &deleted, pet)
+	})
+}
+
+func (b BoltStorage) RestorePetByID(id int64) (*Pet, error) {
+	var pet *Pet
+	err := b.update(func(tx *bbolt.Tx) error {
+		old, err := loadPet(tx, id)
+		if err != nil {
+			return err
+		}
+		if old == nil || old.DeletedAt == nil {
+			return ErrNotFound
+		}
+		restored := *old
+		restored.DeletedAt = nil
+		restored.Version++
+		pet = &restored
+		return savePet(tx, pet, old)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pet, nil
+}
+
+func (b BoltStorage) CreateOrder(order *Order) (*Order, error) {
+	err := b.update(func(tx *bbolt.Tx) error {
+		if found, err := loadOrder(tx, order.ID); err != nil || found != nil {
+			return duplicateError(err, "duplicate order id exists")
+		}
+		order.Version = 1
+		return putDoc(tx, CollectionOrders, idKey(order.ID), order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (b BoltStorage) RetrieveOrderByID(id int64) (*Order, error) {
+	var order *Order
+	err := b.view(func(tx *bbolt.Tx) (err error) {
+		order, err = liveOrder(tx, id)
+		return err
+	})
+	return order, err
+}
+
+func (b BoltStorage) DeleteOrderByID(id int64, hard bool) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		order, err := loadOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if hard && order != nil {
+			return tx.Bucket([]byte(CollectionOrders)).Delete(idKey(id))
+		}
+		if order == nil || order.DeletedAt != nil {
+			return ErrNotFound
+		}
+		now := time.Now().UTC()
+		order.DeletedAt = &now
+		order.Version++
+		return putDoc(tx, CollectionOrders, idKey(id), order)
+	})
+}
+
+func (b BoltStorage) RestoreOrderByID(id int64) (*Order, error) {
+	var order *Order
+	err := b.update(func(tx *bbolt.Tx) (err error) {
+		order, err = loadOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if order == nil || order.DeletedAt == nil {
+			return ErrNotFound
+		}
+		order.DeletedAt = nil
+		order.Version++
+		return putDoc(tx, CollectionOrders, idKey(id), order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// findOrders returns the orders not deleted for which match is true, latest ship date first.
+func (b BoltStorage) findOrders(match func(order *Order) bool) ([]*Order, error) {
+	orders := []*Order{}
+	err := b.view(func(tx *bbolt.Tx) error {
+		return eachDoc(tx, CollectionOrders, func(k, v []byte) error {
+			order := &Order{}
+			if err := bson.Unmarshal(v, order); err != nil {
+				return err
+			}
+			if order.DeletedAt == nil && match(order) {
+				orders = append(orders, order)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// orders are read in order of ids
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].ShipDate.After(orders[j].ShipDate) })
+	return orders, nil
+}
+
+// FindOrders scans all orders, stores on the edge keep few of them.
+func (b BoltStorage) FindOrders(filter OrderFilter) ([]*Order, int64, error) {
+	statuses := map[string]bool{}
+	for _, s := range filter.Statuses {
+		statuses[s] = true
+	}
+	orders, err := b.findOrders(func(o *Order) bool {
+		return (filter.UserID == 0 || o.UserID == filter.UserID) &&
+			(filter.PetID == 0 || o.PetID == filter.PetID) &&
+			(len(statuses) == 0 || statuses[o.Status]) &&
+			(filter.ShipDateFrom.IsZero() || !o.ShipDate.Before(filter.ShipDateFrom)) &&
+			(filter.ShipDateTo.IsZero() || o.ShipDate.Before(filter.ShipDateTo))
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(orders))
+	from := filter.Offset
+	if from > total {
+		from = total
+	}
+	to := from + filter.PageSize()
+	if to > total {
+		to = total
+	}
+	return orders[from:to], total, nil
+}
+
+func (b BoltStorage) FindOrdersByUserIDs(userIDs []int64) ([]*Order, error) {
+	users := map[int64]bool{}
+	for _, id := range userIDs {
+		users[id] = true
+	}
+	return b.findOrders(func(o *Order) bool {
+		return users[o.UserID]
+	})
+}
+
+func (b BoltStorage) UpdateOrderStatusByID(id int64, version int64, status string) (*Order, error) {
+	var order *Order
+	err := b.update(func(tx *bbolt.Tx) (err error) {
+		order, err = liveOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if err = checkVersion(order.Version, version); err != nil {
+			return err
+		}
+		order.Status = status
+		order.Version++
+		return putDoc(tx, CollectionOrders, idKey(id), order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (b BoltStorage) AppendEvent(event *Event) error {
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+	return b.update(func(tx *bbolt.Tx) error {
+		seq, err := tx.Bucket([]byte(CollectionEvents)).NextSequence()
+		if err != nil {
+			return err
+		}
+		event.ID = int64(seq)
+		return putDoc(tx, CollectionEvents, idKey(event.ID), event)
+	})
+}
+
+func (b BoltStorage) FindEventsAfter(after int64, limit int64) ([]*Event, error) {
+	events := []*Event{}
+	err := b.view(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(CollectionEvents)).Cursor()
+		for k, v := c.Seek(idKey(after + 1)); k != nil && int64(len(events)) < limit; k, v = c.Next() {
+			event := &Event{}
+			if err := bson.Unmarshal(v, event); err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (b BoltStorage) RetrieveLoginAttemptsByUsername(username string) (*LoginAttempts, error) {
+	attempts := &LoginAttempts{Username: username}
+	err := b.view(func(tx *bbolt.Tx) error {
+		_, err := getDoc(tx, CollectionLoginAttempts, []byte(username), attempts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (b BoltStorage) ReplaceLoginAttempts(seen *LoginAttempts, attempts *LoginAttempts) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		stored := &LoginAttempts{}
+		if _, err := getDoc(tx, CollectionLoginAttempts, []byte(seen.Username), stored); err != nil {
+			return err
+		}
+		if stored.Failures != seen.Failures || !stored.LastFailureAt.Equal(seen.LastFailureAt) {
+			return ErrVersionMismatch
+		}
+		return putDoc(tx, CollectionLoginAttempts, []byte(attempts.Username), attempts)
+	})
+}
+
+func (b BoltStorage) DeleteLoginAttemptsByUsername(username string) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(CollectionLoginAttempts)).Delete([]byte(username))
+	})
+}
+
+// CreateSession deletes expired sessions along, as nothing else expires them.
+func (b BoltStorage) CreateSession(session *Session) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		now := time.Now().UTC()
+		var expired [][]byte
+		err := eachDoc(tx, CollectionSessions, func(k, v []byte) error {
+			s := Session{}
+			if err := bson.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			if !s.ExpiresAt.After(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err = tx.Bucket([]byte(CollectionSessions)).Delete(k); err != nil {
+				return err
+			}
+		}
+		return putDoc(tx, CollectionSessions, []byte(session.Token), session)
+	})
+}
+
+func (b BoltStorage) RetrieveSessionByToken(token string) (*Session, error) {
+	session := &Session{}
+	err := b.view(func(tx *bbolt.Tx) error {
+		found, err := getDoc(tx, CollectionSessions, []byte(token), session)
+		if err == nil && !found {
+			err = ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (b BoltStorage) DeleteSessionByToken(token string) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(CollectionSessions)).Delete([]byte(token))
+	})
+}
+
+// ExportCollection reads all documents in one transaction, fn must not write to the storage.
+func (b BoltStorage) ExportCollection(collection string, fn func(doc interface{}) error) error {
+	if _, err := NewDocument(collection); err != nil {
+		return err
+	}
+	return b.view(func(tx *bbolt.Tx) error {
+		return eachDoc(tx, collection, func(k, v []byte) error {
+			doc, _ := NewDocument(collection)
+			if err := bson.Unmarshal(v, doc); err != nil {
+				return err
+			}
+			return fn(doc)
+		})
+	})
+}
+
+func (b BoltStorage) ImportDocument(collection string, doc interface{}, mode ConflictMode, dryRun bool) (ImportOutcome, error) {
+	id, err := DocumentID(doc)
+	if err != nil {
+		return "", err
+	}
+	if _, err = NewDocument(collection); err != nil {
+		return "", err
+	}
+
+	var outcome ImportOutcome
+	err = b.update(func(tx *bbolt.Tx) error {
+		if u, ok := doc.(*User); ok {
+			for _, other := range indexedIDs(tx, boltUsersByUsername, u.Username) {
+				if other != u.ID {
+					return fmt.Errorf("duplicate username exists for %s", u.Username)
+				}
+			}
+			stored, err := loadUser(tx, u.ID)
+			if err != nil {
+				return err
+			}
+			var password string
+			if stored != nil {
+				password = stored.Password
+			}
+			if err = importPassword(u, password); err != nil {
+				return err
+			}
+		}
+
+		outcome = ImportInserted
+		if tx.Bucket([]byte(collection)).Get(idKey(id)) != nil {
+			if mode == ConflictSkip {
+				outcome = ImportSkipped
+				return nil
+			}
+			outcome = ImportReplaced
+		}
+		if dryRun {
+			return nil
+		}
+
+		// documents exported before versioning start at the first version
+		switch d := doc.(type) {
+		case *Pet:
+			if d.Version == 0 {
+				d.Version = 1
+			}
+			old, err := loadPet(tx, id)
+			if err != nil {
+				return err
+			}
+			return savePet(tx, d, old)
+		case *User:
+			if d.Version == 0 {
+				d.Version = 1
+			}
+			old, err := loadUser(tx, id)
+			if err != nil {
+				return err
+			}
+			return saveUser(tx, d, old)
+		case *Order:
+			if d.Version == 0 {
+				d.Version = 1
+			}
+		}
+		return putDoc(tx, collection, idKey(id), doc)
+	})
+	if err != nil {
+		return "", err
+	}
+	return outcome, nil
+}
+
+func (b BoltStorage) PurgeDeleted(collection string, before time.Time) (int64, error) {
+	if _, err := NewDocument(collection); err != nil {
+		return 0, err
+	}
+	var n int64
+	err := b.update(func(tx *bbolt.Tx) error {
+		var purged []interface{}
+		err := eachDoc(tx, collection, func(k, v []byte) error {
+			doc, _ := NewDocument(collection)
+			if err := bson.Unmarshal(v, doc); err != nil {
+				return err
+			}
+			var deletedAt *time.Time
+			switch d := doc.(type) {
+			case *Pet:
+				deletedAt = d.DeletedAt
+			case *User:
+				deletedAt = d.DeletedAt
+			case *Order:
+				deletedAt = d.DeletedAt
+			}
+			if deletedAt != nil && deletedAt.Before(before) {
+				purged = append(purged, doc)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, doc := range purged {
+			switch d := doc.(type) {
+			case *Pet:
+				err = removePet(tx, d)
+			case *User:
+				err = removeUser(tx, d)
+			case *Order:
+				err = tx.Bucket([]byte(collection)).Delete(idKey(d.ID))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		n = int64(len(purged))
+		return nil
+	})
+	return n, err
+}
+
+// EmptyCollection deletes all documents of collection, along with their index entries.
+func (b BoltStorage) EmptyCollection(collection string) error {
+	buckets := []string{collection}
+	switch collection {
+	case CollectionPets:
+		buckets = append(buckets, boltPetsByStatus, boltPetsByTag)
+	case CollectionUsers:
+		buckets = append(buckets, boltUsersByUsername)
+	}
+	return b.update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(collection)) == nil {
+			return fmt.Errorf("unknown collection %s", collection)
+		}
+		for _, name := range buckets {
+			if err := tx.DeleteBucket([]byte(name)); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
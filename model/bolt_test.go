@@ -0,0 +1,419 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newBoltStorage returns a storage of a new file in a temporary folder, and the function removing it.
+func newBoltStorage(t *testing.T) (BoltStorage, func()) {
+	dir, err := ioutil.TempDir("", "petstore-bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewBoltStorage(filepath.Join(dir, "petstore.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := db.(BoltStorage)
+	return b, func() {
+		_ = b.db.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestBoltStorageUserActions(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+
+	assert.NoError(t, b.CreateUser(&User{ID: 1, Username: "username", Email: "email@email.com", Password: "password"}))
+	assert.EqualError(t, b.CreateUser(&User{ID: 1, Username: "other"}), "duplicate user id exists")
+	assert.EqualError(t, b.CreateUser(&User{ID: 2, Username: "username"}), "duplicate username exists")
+
+	u, err := b.RetrieveUserByUsername("username")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), u.ID)
+	assert.Equal(t, int64(1), u.Version)
+
+	u.ID = 10
+	u.Username = "username-1"
+	u.Phone = "456"
+	u2, err := b.UpdateUserByUsername("username", u)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), u2.Version)
+	u2, err = b.RetrieveUserByID(10)
+	assert.NoError(t, err)
+	assert.Equal(t, "username-1", u2.Username)
+	assert.Equal(t, "456", u2.Phone)
+	_, err = b.RetrieveUserByUsername("username")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = b.RetrieveUserByID(1)
+	assert.Equal(t, ErrNotFound, err)
+	u.Version = 1
+	_, err = b.UpdateUserByUsername("username-1", u)
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	// a batch with one existing user is refused as a whole
+	err = b.CreateManyUsers([]*User{{ID: 3, Username: "carol"}, {ID: 4, Username: "username-1"}})
+	assert.IsType(t, &BatchError{}, err)
+	assert.Equal(t, 1, err.(*BatchError).Items[0].Index)
+	_, err = b.RetrieveUserByID(3)
+	assert.Equal(t, ErrNotFound, err)
+	assert.NoError(t, b.CreateManyUsers([]*User{{ID: 3, Username: "carol"}, {ID: 4, Username: "dave"}}))
+
+	update, err := MergePatch(&User{}, []byte(`{"phone":"789"}`))
+	assert.NoError(t, err)
+	u2, err = b.PatchUserByUsername("carol", 1, update)
+	assert.NoError(t, err)
+	assert.Equal(t, "789", u2.Phone)
+	assert.Equal(t, int64(2), u2.Version)
+	_, err = b.PatchUserByUsername("carol", 1, update)
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	assert.NoError(t, b.DeleteUserByUsername("username-1", false))
+	_, err = b.RetrieveUserByUsername("username-1")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, b.DeleteUserByUsername("username-1", false))
+	assert.Error(t, b.CreateUser(&User{ID: 10, Username: "username-2"}))
+	u2, err = b.RestoreUserByUsername("username-1")
+	assert.NoError(t, err)
+	assert.Nil(t, u2.DeletedAt)
+	_, err = b.RestoreUserByUsername("username-1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, b.DeleteUserByUsername("username-1", true))
+	assert.Equal(t, ErrNotFound, b.DeleteUserByUsername("username-1", true))
+	assert.NoError(t, b.CreateUser(&User{ID: 10, Username: "username-1"}))
+}
+
+func TestBoltStoragePetActions(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+
+	pet := Pet{
+		ID:        1,
+		Name:      "cat1",
+		Status:    PetStatusAvailable,
+		Category:  NewCategory(1, "cat"),
+		PhotoUrls: []string{"a", "b"},
+		Tags:      []*Tag{NewTag(2, "tabby"), NewTag(1, "small")},
+	}
+	assert.NoError(t, b.CreatePet(&pet))
+	assert.Equal(t, int64(1), pet.Version)
+	assert.EqualError(t, b.CreatePet(&pet), "duplicate pet id exists")
+
+	got, err := b.RetrievePetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, &pet, got)
+
+	pet.Name = "cat2"
+	pet.Tags = []*Tag{NewTag(1, "small")}
+	assert.NoError(t, b.UpdatePetByID(&pet))
+	assert.Equal(t, int64(2), pet.Version)
+	assert.NoError(t, b.UpdatePetNameByID(1, 2, "cat3"))
+	assert.NoError(t, b.UpdatePetStatusByID(1, 0, PetStatusSold))
+	assert.Equal(t, ErrVersionMismatch, b.UpdatePetNameByID(1, 2, "cat5"))
+	assert.Equal(t, ErrNotFound, b.UpdatePetNameByID(2, 0, "cat5"))
+	assert.NoError(t, b.UpdatePetNameAndStatusByID(1, 4, "cat4", PetStatusAvailable))
+
+	got, err = b.AddImageUrlByPetID(1, "c")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, got.PhotoUrls)
+	assert.Equal(t, int64(6), got.Version)
+	got, err = b.AddImageUrlByPetID(1, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, got.PhotoUrls)
+
+	var ps []*Pet
+	for _, id := range []int64{2, 3, 4, 5} {
+		ps = append(ps, &Pet{ID: id, Name: "cat", Status: PetStatusPending, Tags: []*Tag{NewTag(2, "tabby")}})
+	}
+	assert.NoError(t, b.CreateManyPets(ps))
+	err = b.CreateManyPets([]*Pet{{ID: 6, Name: "cat6", Status: PetStatusAvailable}, ps[0]})
+	assert.IsType(t, &BatchError{}, err)
+	_, err = b.RetrievePetByID(6)
+	assert.Equal(t, ErrNotFound, err)
+
+	// indexes follow changes of status and tags
+	pets, err := b.FindPetsByStatus([]string{PetStatusAvailable})
+	assert.NoError(t, err)
+	if assert.Len(t, pets, 1) {
+		assert.Equal(t, "cat4", pets[0].Name)
+	}
+	pets, err = b.FindPetsByStatus([]string{PetStatusSold})
+	assert.NoError(t, err)
+	assert.Nil(t, pets)
+	pets, err = b.FindPetsByTags([]string{"tabby", "small"})
+	assert.NoError(t, err)
+	assert.Len(t, pets, 5)
+	pets, err = b.FindPetsByTags([]string{"tabby"})
+	assert.NoError(t, err)
+	assert.Len(t, pets, 4)
+	pets, err = b.RetrievePetsByIDs([]int64{3, 1, 7})
+	assert.NoError(t, err)
+	assert.Len(t, pets, 2)
+
+	var streamed []int64
+	assert.NoError(t, b.StreamPetsByStatus([]string{PetStatusPending}, func(pet *Pet) error {
+		streamed = append(streamed, pet.ID)
+		return b.UpdatePetStatusByID(pet.ID, 0, PetStatusAvailable)
+	}))
+	assert.Equal(t, []int64{2, 3, 4, 5}, streamed)
+
+	inv, err := b.RetrieveStoreInventoriesByStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{PetStatusAvailable: 5}, inv)
+
+	assert.NoError(t, b.DeletePetByID(1, false))
+	_, err = b.RetrievePetByID(1)
+	assert.Equal(t, ErrNotFound, err)
+	pets, err = b.FindPetsByTags([]string{"small"})
+	assert.NoError(t, err)
+	assert.Empty(t, pets)
+	got, err = b.RestorePetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, got.PhotoUrls)
+	assert.NoError(t, b.DeletePetByID(1, true))
+	assert.Equal(t, ErrNotFound, b.DeletePetByID(1, true))
+}
+
+func TestBoltStorageStock(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+
+	stock := int64(3)
+	pet := Pet{ID: 20, Name: "fish", Status: PetStatusAvailable, Price: 250, Currency: "USD", Stock: &stock}
+	assert.NoError(t, b.CreatePet(&pet))
+
+	got, err := b.ReserveStockByPetID(20, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *got.Stock)
+	_, err = b.ReserveStockByPetID(20, 2)
+	assert.Equal(t, ErrOutOfStock, err)
+
+	assert.NoError(t, b.ReleaseStockByPetID(20, 2))
+	value, err := b.RetrieveStoreStockValue()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(750), value["USD"])
+
+	// single pets are taken from available to pending, once
+	single := Pet{ID: 21, Name: "rex", Status: PetStatusSold}
+	assert.NoError(t, b.CreatePet(&single))
+	_, err = b.ReserveStockByPetID(21, 1)
+	assert.Equal(t, ErrOutOfStock, err)
+	assert.NoError(t, b.UpdatePetStatusByID(21, 0, PetStatusAvailable))
+	got, err = b.ReserveStockByPetID(21, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, PetStatusPending, got.Status)
+	_, err = b.ReserveStockByPetID(21, 1)
+	assert.Equal(t, ErrOutOfStock, err)
+	assert.NoError(t, b.ReleaseStockByPetID(21, 1))
+	got, err = b.RetrievePetByID(21)
+	assert.NoError(t, err)
+	assert.Equal(t, PetStatusAvailable, got.Status)
+}
+
+func TestBoltStorageOrders(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+
+	ts := time.Now().UTC().Truncate(time.Millisecond)
+	for i, o := range []*Order{
+		{ID: 11, PetID: 1, UserID: 1, Quantity: 1, ShipDate: ts.Add(-2 * time.Hour), Status: OrderStatusPlaced},
+		{ID: 12, PetID: 2, UserID: 1, Quantity: 1, ShipDate: ts.Add(-time.Hour), Status: OrderStatusDelivered},
+		{ID: 13, PetID: 1, UserID: 2, Quantity: 1, ShipDate: ts, Status: OrderStatusPlaced},
+	} {
+		_, err := b.CreateOrder(o)
+		assert.NoError(t, err, i)
+	}
+	_, err := b.CreateOrder(&Order{ID: 11, ShipDate: ts})
+	assert.EqualError(t, err, "duplicate order id exists")
+
+	o, err := b.RetrieveOrderByID(13)
+	assert.NoError(t, err)
+	assert.True(t, ts.Equal(o.ShipDate))
+
+	orders, total, err := b.FindOrders(OrderFilter{UserID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, int64(12), orders[0].ID)
+	orders, total, err = b.FindOrders(OrderFilter{Statuses: []string{OrderStatusPlaced}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, int64(13), orders[0].ID)
+	orders, total, err = b.FindOrders(OrderFilter{PetID: 1, ShipDateTo: ts})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, int64(11), orders[0].ID)
+	orders, err = b.FindOrdersByUserIDs([]int64{2, 3})
+	assert.NoError(t, err)
+	assert.Len(t, orders, 1)
+
+	o, err = b.UpdateOrderStatusByID(11, 1, OrderStatusApproved)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), o.Version)
+	_, err = b.UpdateOrderStatusByID(11, 1, OrderStatusDelivered)
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	assert.NoError(t, b.DeleteOrderByID(11, false))
+	n, err := b.PurgeDeleted(CollectionOrders, time.Now().UTC().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	n, err = b.PurgeDeleted(CollectionOrders, time.Now().UTC().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = b.RestoreOrderByID(11)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBoltStorageTransaction(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+
+	err := b.WithTransaction(func(tx Storage) error {
+		assert.NoError(t, tx.CreatePet(&Pet{ID: 1, Name: "cat", Status: PetStatusAvailable}))
+		_, err := tx.CreateOrder(&Order{ID: 1, PetID: 1, Quantity: 1})
+		assert.NoError(t, err)
+		return ErrOutOfStock
+	})
+	assert.Equal(t, ErrOutOfStock, err)
+	_, err = b.RetrievePetByID(1)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = b.RetrieveOrderByID(1)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBoltStorageEvents(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+
+	first := NewPetEvent(EventPetCreated, &Pet{ID: 30, Name: "rex"})
+	second := NewOrderEvent(EventOrderPlaced, &Order{ID: 30, PetID: 30})
+	assert.NoError(t, b.AppendEvent(first))
+	assert.NoError(t, b.AppendEvent(second))
+	assert.True(t, second.ID > first.ID)
+	events, err := b.FindEventsAfter(first.ID-1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "rex", events[0].Pet.Name)
+		assert.Equal(t, int64(30), events[1].Order.PetID)
+	}
+	events, err = b.FindEventsAfter(first.ID, 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	attempts, err := b.RetrieveLoginAttemptsByUsername("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), attempts.Failures)
+	failed := &LoginAttempts{Username: "alice", Failures: 1, LastFailureAt: time.Now().UTC().Truncate(time.Millisecond)}
+	assert.NoError(t, b.ReplaceLoginAttempts(attempts, failed))
+	assert.Equal(t, ErrVersionMismatch, b.ReplaceLoginAttempts(attempts, failed))
+	assert.NoError(t, b.ReplaceLoginAttempts(failed, &LoginAttempts{Username: "alice", Failures: 2, LastFailureAt: time.Now().UTC()}))
+	assert.Equal(t, ErrVersionMismatch, b.ReplaceLoginAttempts(failed, failed))
+	attempts, err = b.RetrieveLoginAttemptsByUsername("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), attempts.Failures)
+	assert.NoError(t, b.DeleteLoginAttemptsByUsername("alice"))
+
+	assert.NoError(t, b.CreateSession(&Session{Token: "expired", ExpiresAt: time.Now().UTC().Add(-time.Hour)}))
+	assert.NoError(t, b.CreateSession(&Session{Token: "token", UserID: 1, Username: "alice", ExpiresAt: time.Now().UTC().Add(time.Hour)}))
+	_, err = b.RetrieveSessionByToken("expired")
+	assert.Equal(t, ErrNotFound, err)
+	s, err := b.RetrieveSessionByToken("token")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", s.Username)
+	assert.NoError(t, b.DeleteSessionByToken("token"))
+	_, err = b.RetrieveSessionByToken("token")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBoltStorageImport(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+
+	pet := NewPet(40, NewCategory(1, "cats"), "Fluffy", []string{"a"}, []*Tag{NewTag(1, "tabby")}, PetStatusAvailable)
+	outcome, err := b.ImportDocument(CollectionPets, pet, ConflictUpsert, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportInserted, outcome)
+	pet.Name = "Tabby"
+	pet.Tags = []*Tag{NewTag(2, "persian")}
+	outcome, err = b.ImportDocument(CollectionPets, pet, ConflictSkip, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportSkipped, outcome)
+	outcome, err = b.ImportDocument(CollectionPets, pet, ConflictUpsert, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportReplaced, outcome)
+	got, err := b.RetrievePetByID(40)
+	assert.NoError(t, err)
+	assert.Equal(t, "Fluffy", got.Name)
+	outcome, err = b.ImportDocument(CollectionPets, pet, ConflictUpsert, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportReplaced, outcome)
+	pets, err := b.FindPetsByTags([]string{"tabby"})
+	assert.NoError(t, err)
+	assert.Empty(t, pets)
+
+	assert.NoError(t, b.CreateUser(&User{ID: 1, Username: "alice", Password: "secret"}))
+	_, err = b.ImportDocument(CollectionUsers, &User{ID: 2, Username: "alice"}, ConflictUpsert, false)
+	assert.EqualError(t, err, "duplicate username exists for alice")
+	// exported users have no password, the stored one is kept
+	_, err = b.ImportDocument(CollectionUsers, &User{ID: 1, Username: "alicia"}, ConflictUpsert, false)
+	assert.NoError(t, err)
+	u, err := b.RetrieveUserByUsername("alicia")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", u.Password)
+	_, err = b.ImportDocument(CollectionUsers, &User{ID: 2, Username: "bob"}, ConflictUpsert, true)
+	assert.EqualError(t, err, "user bob has no password")
+
+	var exported []interface{}
+	assert.NoError(t, b.ExportCollection(CollectionPets, func(doc interface{}) error {
+		exported = append(exported, doc)
+		return nil
+	}))
+	if assert.Len(t, exported, 1) {
+		assert.Equal(t, "Tabby", exported[0].(*Pet).Name)
+	}
+
+	results, total, err := b.SearchPets(PetSearch{Query: "tabby"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, int64(40), results[0].Pet.ID)
+
+	assert.NoError(t, b.EmptyCollection(CollectionPets))
+	pets, err = b.FindPetsByStatus([]string{PetStatusAvailable})
+	assert.NoError(t, err)
+	assert.Empty(t, pets)
+	assert.Error(t, b.EmptyCollection("unknown"))
+}
+
+func TestBoltStorageBackup(t *testing.T) {
+	b, done := newBoltStorage(t)
+	defer done()
+	assert.NoError(t, b.CreatePet(&Pet{ID: 1, Name: "cat", Status: PetStatusAvailable}))
+
+	path := filepath.Join(filepath.Dir(b.Path), "backup.db")
+	assert.NoError(t, b.Backup(path))
+	assert.NoError(t, b.CreatePet(&Pet{ID: 2, Name: "dog", Status: PetStatusAvailable}))
+
+	// the backup holds the data as of when it was made, and opens as a storage of its own
+	db, err := NewBoltStorage(path, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+	backup := db.(BoltStorage)
+	defer backup.db.Close()
+	pets, err := backup.FindPetsByStatus([]string{PetStatusAvailable})
+	assert.NoError(t, err)
+	if assert.Len(t, pets, 1) {
+		assert.Equal(t, "cat", pets[0].Name)
+	}
+
+	// the file is held by one process at a time
+	_, err = NewBoltStorage(b.Path, logger)
+	assert.Error(t, err)
+}
@@ -0,0 +1,504 @@
+package model
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PetCacheKey is the key a pet is cached under.
+func PetCacheKey(id int64) string {
+	return "pet:" + strconv.FormatInt(id, 10)
+}
+
+// UserCacheKey is the key a user is cached under by username.
+func UserCacheKey(username string) string {
+	return "user:" + username
+}
+
+// UserIDCacheKey is the key a user is cached under by id.
+func UserIDCacheKey(id int64) string {
+	return "userid:" + strconv.FormatInt(id, 10)
+}
+
+// CacheWatcher is implemented by storages telling about changes of cached documents, made by all processes.
+type CacheWatcher interface {
+	// Tells whether changes can be watched, which may depend on the server
+	CanWatchCachedDocuments() bool
+	// Call fn with the cache keys of documents changed from now on, or with none when any may have changed, until
+	// stop is closed or watching fails
+	WatchCachedDocuments(stop <-chan struct{}, fn func(keys []string)) error
+}
+
+// CacheStats counts the use of a cache since it was made.
+type CacheStats struct {
+	// Reads served from the cache
+	Hits int64 `json:"hits"`
+	// Reads not found in the cache, Collapsed of them waited for the same read of another caller
+	Misses    int64 `json:"misses"`
+	Collapsed int64 `json:"collapsed"`
+	// Entries dropped to make room for others
+	Evictions int64 `json:"evictions"`
+	// Entries cached now, out of Size
+	Entries int `json:"entries"`
+	Size    int `json:"size"`
+}
+
+// cacheEntry is a document cached under one or more keys.
+type cacheEntry struct {
+	keys    []string
+	value   interface{}
+	expires time.Time
+}
+
+// cacheCall is a read of a document not found in the cache, waited for by all callers missing it meanwhile.
+type cacheCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// cache keeps at most size documents for ttl, dropping the least recently used ones first.
+type cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	entries *list.List
+	index   map[string]*list.Element
+	calls   map[string]*cacheCall
+	// Incremented on each invalidation, documents read meanwhile may be stale and are not cached
+	epoch uint64
+	stats CacheStats
+	hook  func(keys []string)
+}
+
+// get returns the document cached under key, or calls load once for all callers missing it at the same time and
+// caches the document it returns under the keys it returns.
+func (c *cache) get(key string, load func() (interface{}, []string, error)) (interface{}, error) {
+	c.mu.Lock()
+	if el, ok := c.index[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if c.now().Before(e.expires) {
+			c.entries.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e.value, nil
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	if call, ok := c.calls[key]; ok {
+		c.stats.Collapsed++
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	epoch := c.epoch
+	c.mu.Unlock()
+
+	var keys []string
+	call.value, keys, call.err = load()
+
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	if call.err == nil && c.epoch == epoch {
+		c.put(keys, call.value)
+	}
+	c.mu.Unlock()
+	call.wg.Done()
+	return call.value, call.err
+}
+
+// put caches value under keys, replacing documents cached under any of them.
+func (c *cache) put(keys []string, value interface{}) {
+	for _, key := range keys {
+		if el, ok := c.index[key]; ok {
+			c.remove(el)
+		}
+	}
+	el := c.entries.PushFront(&cacheEntry{keys: keys, value: value, expires: c.now().Add(c.ttl)})
+	for _, key := range keys {
+		c.index[key] = el
+	}
+	for c.entries.Len() > c.size {
+		c.remove(c.entries.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *cache) remove(el *list.Element) {
+	for _, key := range el.Value.(*cacheEntry).keys {
+		delete(c.index, key)
+	}
+	c.entries.Remove(el)
+}
+
+// invalidate drops documents cached under keys, all of them when keys is empty, and has reads of them in progress
+// not cached nor shared with later callers.
+func (c *cache) invalidate(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	if len(keys) == 0 {
+		c.entries.Init()
+		c.index = map[string]*list.Element{}
+		c.calls = map[string]*cacheCall{}
+		return
+	}
+	for _, key := range keys {
+		if el, ok := c.index[key]; ok {
+			c.remove(el)
+		}
+		delete(c.calls, key)
+	}
+}
+
+// CacheStorage is a Storage decorator caching pets and users read by id or username, for ttl and at most size of
+// them, the least recently used being dropped first. Concurrent reads of a document not cached are made once.
+// Documents are dropped from the cache when changed through it, and again when a transaction changing them ends.
+// Reads made in a transaction are not cached.
+//
+// Documents changed by other processes stay cached until ttl elapses, unless they are told with Invalidate, as
+// watched with a CacheWatcher of the storage, or passed on from the hook set with OnInvalidate in the other processes,
+// which is called with the keys of documents changed through the storage.
+type CacheStorage struct {
+	Storage
+	cache *cache
+	// Documents changed in the transaction, nil outside transactions
+	pending *cacheChanges
+}
+
+// cacheChanges are the keys of documents changed in a transaction, or all of them.
+type cacheChanges struct {
+	keys []string
+	all  bool
+}
+
+func NewCacheStorage(storage Storage, size int, ttl time.Duration) *CacheStorage {
+	return &CacheStorage{
+		Storage: storage,
+		cache: &cache{
+			size:    size,
+			ttl:     ttl,
+			now:     time.Now,
+			entries: list.New(),
+			index:   map[string]*list.Element{},
+			calls:   map[string]*cacheCall{},
+		},
+	}
+}
+
+// OnInvalidate sets the function called with the keys of documents changed through the storage, or with no keys
+// when all documents may have changed. It is called after the change is made, or committed.
+func (s *CacheStorage) OnInvalidate(fn func(keys []string)) {
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+	s.cache.hook = fn
+}
+
+// Invalidate drops documents cached under keys, all of them when none are given, as they have been changed by
+// another process.
+func (s *CacheStorage) Invalidate(keys ...string) {
+	s.cache.invalidate(keys)
+}
+
+// Stats returns counts of the use of the cache.
+func (s *CacheStorage) Stats() CacheStats {
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+	stats := s.cache.stats
+	stats.Entries = s.cache.entries.Len()
+	stats.Size = s.cache.size
+	return stats
+}
+
+// changed drops documents cached under keys, and tells the hook unless in a transaction, where it is done once it
+// ends.
+func (s *CacheStorage) changed(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	s.cache.invalidate(keys)
+	if s.pending != nil {
+		s.pending.keys = append(s.pending.keys, keys...)
+		return
+	}
+	s.notify(keys)
+}
+
+// changedAll drops all cached documents, and tells the hook like changed.
+func (s *CacheStorage) changedAll() {
+	s.cache.invalidate(nil)
+	if s.pending != nil {
+		s.pending.all = true
+		return
+	}
+	s.notify(nil)
+}
+
+func (s *CacheStorage) notify(keys []string) {
+	s.cache.mu.Lock()
+	hook := s.cache.hook
+	s.cache.mu.Unlock()
+	if hook != nil {
+		hook(keys)
+	}
+}
+
+func (s *CacheStorage) WithActor(actor Actor) Storage {
+	a, ok := s.Storage.(ActorStorage)
+	if !ok {
+		return s
+	}
+	c := *s
+	c.Storage = a.WithActor(actor)
+	return &c
+}
+
+func (s *CacheStorage) WithTransaction(fn func(tx Storage) error) error {
+	if s.pending != nil {
+		return s.Storage.WithTransaction(func(tx Storage) error {
+			c := *s
+			c.Storage = tx
+			return fn(&c)
+		})
+	}
+	pending := &cacheChanges{}
+	err := s.Storage.WithTransaction(func(tx Storage) error {
+		c := *s
+		c.Storage = tx
+		c.pending = pending
+		return fn(&c)
+	})
+	// documents read by others until the transaction ended may have been cached again
+	if pending.all {
+		s.changedAll()
+	} else if len(pending.keys) > 0 {
+		s.changed(pending.keys...)
+	}
+	return err
+}
+
+func (s *CacheStorage) RetrievePetByID(id int64) (*Pet, error) {
+	if s.pending != nil {
+		return s.Storage.RetrievePetByID(id)
+	}
+	pet, err := s.cache.get(PetCacheKey(id), func() (interface{}, []string, error) {
+		pet, err := s.Storage.RetrievePetByID(id)
+		return pet, []string{PetCacheKey(id)}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyPet(pet.(*Pet)), nil
+}
+
+func (s *CacheStorage) RetrieveUserByUsername(username string) (*User, error) {
+	if s.pending != nil {
+		return s.Storage.RetrieveUserByUsername(username)
+	}
+	return s.user(UserCacheKey(username), func() (*User, error) {
+		return s.Storage.RetrieveUserByUsername(username)
+	})
+}
+
+func (s *CacheStorage) RetrieveUserByID(id int64) (*User, error) {
+	if s.pending != nil {
+		return s.Storage.RetrieveUserByID(id)
+	}
+	return s.user(UserIDCacheKey(id), func() (*User, error) {
+		return s.Storage.RetrieveUserByID(id)
+	})
+}
+
+// user returns the user cached under key, reading it with load when missing, and caches it by username and id.
+func (s *CacheStorage) user(key string, load func() (*User, error)) (*User, error) {
+	user, err := s.cache.get(key, func() (interface{}, []string, error) {
+		user, err := load()
+		if err != nil {
+			return nil, nil, err
+		}
+		return user, []string{UserCacheKey(user.Username), UserIDCacheKey(user.ID)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	u := *user.(*User)
+	return &u, nil
+}
+
+// copyPet returns a copy of pet sharing nothing with it, so that callers can't change cached pets.
+func copyPet(pet *Pet) *Pet {
+	p := *pet
+	if pet.Category != nil {
+		c := *pet.Category
+		p.Category = &c
+	}
+	if pet.PhotoUrls != nil {
+		p.PhotoUrls = append([]string{}, pet.PhotoUrls...)
+	}
+	if pet.Tags != nil {
+		p.Tags = make([]*Tag, len(pet.Tags))
+		for i, tag := range pet.Tags {
+			if tag != nil {
+				t := *tag
+				p.Tags[i] = &t
+			}
+		}
+	}
+	if pet.Stock != nil {
+		stock := *pet.Stock
+		p.Stock = &stock
+	}
+	return &p
+}
+
+func petCacheKeys(pets []*Pet) []string {
+	keys := make([]string, len(pets))
+	for i, pet := range pets {
+		keys[i] = PetCacheKey(pet.ID)
+	}
+	return keys
+}
+
+func (s *CacheStorage) CreateUser(user *User) error {
+	err := s.Storage.CreateUser(user)
+	s.changed(UserCacheKey(user.Username), UserIDCacheKey(user.ID))
+	return err
+}
+
+func (s *CacheStorage) CreateManyUsers(users []*User) error {
+	err := s.Storage.CreateManyUsers(users)
+	keys := make([]string, 0, len(users)*2)
+	for _, user := range users {
+		keys = append(keys, UserCacheKey(user.Username), UserIDCacheKey(user.ID))
+	}
+	s.changed(keys...)
+	return err
+}
+
+func (s *CacheStorage) UpdateUserByUsername(username string, user *User) (*User, error) {
+	before, err := s.Storage.UpdateUserByUsername(username, user)
+	s.changed(UserCacheKey(username), UserCacheKey(user.Username), UserIDCacheKey(user.ID))
+	return before, err
+}
+
+func (s *CacheStorage) PatchUserByUsername(username string, version int64, update *Update) (*User, error) {
+	user, err := s.Storage.PatchUserByUsername(username, version, update)
+	keys := []string{UserCacheKey(username)}
+	if user != nil {
+		keys = append(keys, UserCacheKey(user.Username), UserIDCacheKey(user.ID))
+	}
+	s.changed(keys...)
+	return user, err
+}
+
+func (s *CacheStorage) DeleteUserByUsername(username string, hard bool) error {
+	err := s.Storage.DeleteUserByUsername(username, hard)
+	s.changed(UserCacheKey(username))
+	return err
+}
+
+func (s *CacheStorage) RestoreUserByUsername(username string) (*User, error) {
+	user, err := s.Storage.RestoreUserByUsername(username)
+	s.changed(UserCacheKey(username))
+	return user, err
+}
+
+func (s *CacheStorage) CreatePet(pet *Pet) error {
+	err := s.Storage.CreatePet(pet)
+	s.changed(PetCacheKey(pet.ID))
+	return err
+}
+
+func (s *CacheStorage) CreateManyPets(pets []*Pet) error {
+	err := s.Storage.CreateManyPets(pets)
+	s.changed(petCacheKeys(pets)...)
+	return err
+}
+
+func (s *CacheStorage) UpdatePetByID(pet *Pet) error {
+	err := s.Storage.UpdatePetByID(pet)
+	s.changed(PetCacheKey(pet.ID))
+	return err
+}
+
+func (s *CacheStorage) PatchPetByID(id int64, version int64, update *Update) (*Pet, error) {
+	pet, err := s.Storage.PatchPetByID(id, version, update)
+	s.changed(PetCacheKey(id))
+	return pet, err
+}
+
+func (s *CacheStorage) UpdatePetNameAndStatusByID(id int64, version int64, name string, status string) error {
+	err := s.Storage.UpdatePetNameAndStatusByID(id, version, name, status)
+	s.changed(PetCacheKey(id))
+	return err
+}
+
+func (s *CacheStorage) UpdatePetNameByID(id int64, version int64, name string) error {
+	err := s.Storage.UpdatePetNameByID(id, version, name)
+	s.changed(PetCacheKey(id))
+	return err
+}
+
+func (s *CacheStorage) UpdatePetStatusByID(id int64, version int64, status string) error {
+	err := s.Storage.UpdatePetStatusByID(id, version, status)
+	s.changed(PetCacheKey(id))
+	return err
+}
+
+func (s *CacheStorage) AddImageUrlByPetID(id int64, url string) (*Pet, error) {
+	pet, err := s.Storage.AddImageUrlByPetID(id, url)
+	s.changed(PetCacheKey(id))
+	return pet, err
+}
+
+func (s *CacheStorage) ReserveStockByPetID(id int64, quantity int64) (*Pet, error) {
+	pet, err := s.Storage.ReserveStockByPetID(id, quantity)
+	s.changed(PetCacheKey(id))
+	return pet, err
+}
+
+func (s *CacheStorage) ReleaseStockByPetID(id int64, quantity int64) error {
+	err := s.Storage.ReleaseStockByPetID(id, quantity)
+	s.changed(PetCacheKey(id))
+	return err
+}
+
+func (s *CacheStorage) DeletePetByID(id int64, hard bool) error {
+	err := s.Storage.DeletePetByID(id, hard)
+	s.changed(PetCacheKey(id))
+	return err
+}
+
+func (s *CacheStorage) RestorePetByID(id int64) (*Pet, error) {
+	pet, err := s.Storage.RestorePetByID(id)
+	s.changed(PetCacheKey(id))
+	return pet, err
+}
+
+func (s *CacheStorage) ImportDocument(collection string, doc interface{}, mode ConflictMode, dryRun bool) (ImportOutcome, error) {
+	outcome, err := s.Storage.ImportDocument(collection, doc, mode, dryRun)
+	switch d := doc.(type) {
+	case *Pet:
+		s.changed(PetCacheKey(d.ID))
+	case *User:
+		s.changed(UserCacheKey(d.Username), UserIDCacheKey(d.ID))
+	}
+	return outcome, err
+}
+
+func (s *CacheStorage) EmptyCollection(collection string) error {
+	err := s.Storage.EmptyCollection(collection)
+	if collection == CollectionPets || collection == CollectionUsers {
+		s.changedAll()
+	}
+	return err
+}
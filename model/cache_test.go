@@ -0,0 +1,163 @@
+package model
+
+// This is to test the cache decorator, over the storage keeping pets and users in memory
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readCountingStorage counts reads of pets and users, holding pet reads until release is closed when set.
+type readCountingStorage struct {
+	*memoryStorage
+	mu      sync.Mutex
+	reads   int
+	release chan struct{}
+}
+
+func (s *readCountingStorage) count() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+}
+
+func (s *readCountingStorage) RetrievePetByID(id int64) (*Pet, error) {
+	s.count()
+	if s.release != nil {
+		<-s.release
+	}
+	return s.memoryStorage.RetrievePetByID(id)
+}
+
+func (s *readCountingStorage) RetrieveUserByUsername(username string) (*User, error) {
+	s.count()
+	return s.memoryStorage.RetrieveUserByUsername(username)
+}
+
+func (s *readCountingStorage) RetrieveUserByID(id int64) (*User, error) {
+	s.count()
+	for _, user := range s.users {
+		if user.ID == id {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func TestCacheStorage(t *testing.T) {
+	memory := &readCountingStorage{memoryStorage: &memoryStorage{pets: map[int64]Pet{}, users: map[string]User{}}}
+	storage := NewCacheStorage(memory, 2, time.Minute)
+	now := time.Now()
+	storage.cache.now = func() time.Time { return now }
+	var invalidated [][]string
+	storage.OnInvalidate(func(keys []string) { invalidated = append(invalidated, keys) })
+
+	assert.NoError(t, storage.CreatePet(&Pet{ID: 1, Name: "rex", Tags: []*Tag{{ID: 1, Name: "dog"}}}))
+	pet, err := storage.RetrievePetByID(1)
+	assert.NoError(t, err)
+	pet.Tags[0].Name = "cat"
+	pet, err = storage.RetrievePetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "dog", pet.Tags[0].Name)
+	assert.Equal(t, 1, memory.reads)
+
+	// missing documents are not cached
+	_, err = storage.RetrievePetByID(2)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = storage.RetrievePetByID(2)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 3, memory.reads)
+
+	// changes drop documents, and tell other processes
+	assert.NoError(t, storage.UpdatePetNameByID(1, 0, "max"))
+	pet, err = storage.RetrievePetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "max", pet.Name)
+	assert.Equal(t, []string{PetCacheKey(1)}, invalidated[len(invalidated)-1])
+
+	// users are cached by username and id at once
+	assert.NoError(t, storage.CreateUser(&User{ID: 7, Username: "jane"}))
+	reads := memory.reads
+	_, err = storage.RetrieveUserByUsername("jane")
+	assert.NoError(t, err)
+	user, err := storage.RetrieveUserByID(7)
+	assert.NoError(t, err)
+	assert.Equal(t, "jane", user.Username)
+	assert.Equal(t, reads+1, memory.reads)
+	_, err = storage.UpdateUserByUsername("jane", &User{ID: 7, Username: "jane", Email: "jane@example.com"})
+	assert.NoError(t, err)
+	user, err = storage.RetrieveUserByID(7)
+	assert.NoError(t, err)
+	assert.Equal(t, "jane@example.com", user.Email)
+
+	// least recently used and expired documents are dropped
+	_, _ = storage.RetrievePetByID(1)
+	assert.Equal(t, 2, storage.Stats().Entries)
+	assert.NoError(t, storage.CreatePet(&Pet{ID: 4, Name: "tom"}))
+	_, _ = storage.RetrievePetByID(4)
+	reads = memory.reads
+	_, _ = storage.RetrieveUserByUsername("jane")
+	assert.Equal(t, reads+1, memory.reads)
+	reads = memory.reads
+	now = now.Add(time.Minute)
+	_, _ = storage.RetrievePetByID(1)
+	assert.Equal(t, reads+1, memory.reads)
+	stats := storage.Stats()
+	assert.Equal(t, int64(3), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+
+	// changes in a transaction drop documents again once it ends
+	invalidated = nil
+	err = storage.WithTransaction(func(tx Storage) error {
+		if err := tx.DeletePetByID(1, false); err != nil {
+			return err
+		}
+		_, err := tx.RetrievePetByID(1)
+		assert.Equal(t, ErrNotFound, err)
+		assert.Empty(t, invalidated)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{PetCacheKey(1)}}, invalidated)
+
+	// other processes tell about their changes
+	assert.NoError(t, storage.CreatePet(&Pet{ID: 3, Name: "bob"}))
+	_, _ = storage.RetrievePetByID(3)
+	memory.pets[3] = Pet{ID: 3, Name: "joe"}
+	storage.Invalidate(PetCacheKey(3))
+	pet, err = storage.RetrievePetByID(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "joe", pet.Name)
+}
+
+func TestCacheStorageCollapsesMisses(t *testing.T) {
+	memory := &readCountingStorage{
+		memoryStorage: &memoryStorage{pets: map[int64]Pet{1: {ID: 1, Name: "rex"}}},
+		release:       make(chan struct{}),
+	}
+	storage := NewCacheStorage(memory, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pet, err := storage.RetrievePetByID(1)
+			assert.NoError(t, err)
+			assert.Equal(t, "rex", pet.Name)
+		}()
+	}
+	for storage.Stats().Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(memory.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, memory.reads)
+	assert.Equal(t, CacheStats{Misses: 10, Collapsed: 9, Entries: 1, Size: 10}, storage.Stats())
+	_, err := storage.RetrievePetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), storage.Stats().Hits)
+}
@@ -0,0 +1,68 @@
+package model
+
+import "fmt"
+
+// ConflictMode tells what importing a document does when one with the same id exists.
+type ConflictMode string
+
+const (
+	// Replace the existing document
+	ConflictUpsert ConflictMode = "upsert"
+	// Keep the existing document
+	ConflictSkip ConflictMode = "skip"
+)
+
+// ImportOutcome tells what importing a document did, or would do in a dry run.
+type ImportOutcome string
+
+const (
+	ImportInserted ImportOutcome = "inserted"
+	ImportReplaced ImportOutcome = "replaced"
+	ImportSkipped  ImportOutcome = "skipped"
+)
+
+// NewDocument returns a pointer to an empty document of the type stored in collection.
+func NewDocument(collection string) (interface{}, error) {
+	switch collection {
+	case CollectionPets:
+		return &Pet{}, nil
+	case CollectionUsers:
+		return &User{}, nil
+	case CollectionOrders:
+		return &Order{}, nil
+	case CollectionCategories:
+		return &Category{}, nil
+	case CollectionTags:
+		return &Tag{}, nil
+	}
+	return nil, fmt.Errorf("unknown collection %s", collection)
+}
+
+// DocumentID returns the id of a document created by NewDocument.
+func DocumentID(doc interface{}) (int64, error) {
+	switch d := doc.(type) {
+	case *Pet:
+		return d.ID, nil
+	case *User:
+		return d.ID, nil
+	case *Order:
+		return d.ID, nil
+	case *Category:
+		return d.ID, nil
+	case *Tag:
+		return d.ID, nil
+	}
+	return 0, fmt.Errorf("unknown document type %T", doc)
+}
+
+// importPassword gives an imported user without password the one stored, of the user with the same id if any, as
+// exports leave passwords out. Users left without password are refused, as they could not be logged in safely.
+func importPassword(u *User, stored string) error {
+	if u.Password == "" {
+		u.Password = stored
+	}
+	if u.Password == "" {
+		return fmt.Errorf("user %s has no password", u.Username)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewDocument(t *testing.T) {
+	for collection, expected := range map[string]interface{}{
+		CollectionPets:       &Pet{},
+		CollectionUsers:      &User{},
+		CollectionOrders:     &Order{},
+		CollectionCategories: &Category{},
+		CollectionTags:       &Tag{},
+	} {
+		doc, err := NewDocument(collection)
+		assert.NoError(t, err)
+		assert.IsType(t, expected, doc)
+	}
+	_, err := NewDocument(CollectionSessions)
+	assert.Error(t, err)
+}
+
+func TestDocumentID(t *testing.T) {
+	id, err := DocumentID(&Tag{ID: 3, Name: "tag"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+	_, err = DocumentID(Tag{ID: 3})
+	assert.Error(t, err)
+}
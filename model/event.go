@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+const (
+	// Outbox of domain events, by increasing id
+	CollectionEvents string = "events"
+	// Sequences of ids, one document per sequence
+	CollectionCounters string = "counters"
+)
+
+const (
+	EventPetCreated         string = "pet.created"
+	EventPetUpdated         string = "pet.updated"
+	EventPetStatusChanged   string = "pet.status_changed"
+	EventPetDeleted         string = "pet.deleted"
+	EventPetRestored        string = "pet.restored"
+	EventOrderPlaced        string = "order.placed"
+	EventOrderStatusChanged string = "order.status_changed"
+	EventOrderDelivered     string = "order.delivered"
+	EventOrderDeleted       string = "order.deleted"
+	EventOrderRestored      string = "order.restored"
+)
+
+// EventTypes are the types of events emitted, by entity.
+var EventTypes = []string{
+	EventPetCreated, EventPetUpdated, EventPetStatusChanged, EventPetDeleted, EventPetRestored,
+	EventOrderPlaced, EventOrderStatusChanged, EventOrderDelivered, EventOrderDeleted, EventOrderRestored,
+}
+
+// Event tells what happened to a pet or an order. Ids are increasing in the order events are appended,
+// with gaps left by aborted changes.
+type Event struct {
+	ID       int64     `json:"id" bson:"_id"`
+	Type     string    `json:"type" bson:"type"`
+	At       time.Time `json:"at" bson:"at"`
+	Entity   string    `json:"entity" bson:"entity"`
+	EntityID int64     `json:"entityId" bson:"entityId"`
+	// Status before a status change
+	PreviousStatus string `json:"previousStatus,omitempty" bson:"previousStatus,omitempty"`
+	// Pet or order after the change, before it when deleted
+	Pet   *Pet   `json:"pet,omitempty" bson:"pet,omitempty"`
+	Order *Order `json:"order,omitempty" bson:"order,omitempty"`
+}
+
+// EventWatcher is implemented by storages telling about events as they are committed, from all processes.
+type EventWatcher interface {
+	// Tells whether events can be watched, which may depend on the server
+	CanWatchEvents() bool
+	// Call fn with each event committed from now on, until stop is closed or watching fails
+	WatchEvents(stop <-chan struct{}, fn func(event *Event)) error
+}
+
+func NewPetEvent(eventType string, pet *Pet) *Event {
+	return &Event{Type: eventType, Entity: CollectionPets, EntityID: pet.ID, Pet: pet}
+}
+
+func NewOrderEvent(eventType string, order *Order) *Event {
+	return &Event{Type: eventType, Entity: CollectionOrders, EntityID: order.ID, Order: order}
+}
+
+// IsEventType tells whether t is one of EventTypes.
+func IsEventType(t string) bool {
+	for _, e := range EventTypes {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
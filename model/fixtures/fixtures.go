@@ -0,0 +1,176 @@
+// Package fixtures builds sets of documents for demo and test data, read from fixture files or generated
+// from a seed, and loads them into any model.Storage.
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Set of documents per collection. A fixture file holds a set as a JSON object with any of its keys.
+type Set struct {
+	Categories []*model.Category `json:"categories,omitempty"`
+	Tags       []*model.Tag      `json:"tags,omitempty"`
+	Pets       []*model.Pet      `json:"pets,omitempty"`
+	Users      []*model.User     `json:"users,omitempty"`
+	Orders     []*model.Order    `json:"orders,omitempty"`
+}
+
+// Files of __tests__ folder holding documents the integration suite creates, in the order it creates them.
+var IntegrationFiles = []string{
+	"user_create.json",
+	"user_createWithArray.json",
+	"user_createWithList.json",
+	"pet_add.json",
+	"pet_add2.json",
+	"pet_add3.json",
+	"store_order.json",
+}
+
+// Merge returns one set with the documents of all sets, in the order given.
+func Merge(sets ...*Set) *Set {
+	merged := &Set{}
+	for _, s := range sets {
+		merged.Categories = append(merged.Categories, s.Categories...)
+		merged.Tags = append(merged.Tags, s.Tags...)
+		merged.Pets = append(merged.Pets, s.Pets...)
+		merged.Users = append(merged.Users, s.Users...)
+		merged.Orders = append(merged.Orders, s.Orders...)
+	}
+	return merged
+}
+
+// Parse reads a fixture set. Like the request bodies of the integration suite, data may also be a single pet,
+// user or order, or an array of them, told apart by their photoUrls, username and petId fields.
+func Parse(data []byte) (*Set, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		var sets []*Set
+		for i, item := range items {
+			s, err := parseDocument(item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			sets = append(sets, s)
+		}
+		return Merge(sets...), nil
+	}
+	return parseDocument(data)
+}
+
+func parseDocument(data []byte) (*Set, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	s := &Set{}
+	var err error
+	switch {
+	case has(fields, "username"):
+		var u model.User
+		err = json.Unmarshal(data, &u)
+		s.Users = append(s.Users, &u)
+	case has(fields, "petId"):
+		var o model.Order
+		err = json.Unmarshal(data, &o)
+		s.Orders = append(s.Orders, &o)
+	case has(fields, "photoUrls"):
+		var p model.Pet
+		err = json.Unmarshal(data, &p)
+		s.Pets = append(s.Pets, &p)
+	case has(fields, "categories", "tags", "pets", "users", "orders"):
+		err = json.Unmarshal(data, s)
+	default:
+		return nil, fmt.Errorf("not a fixture set, pet, user or order")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func has(fields map[string]json.RawMessage, names ...string) bool {
+	for _, name := range names {
+		if _, ok := fields[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFiles reads and merges fixture files.
+func ReadFiles(paths ...string) (*Set, error) {
+	var sets []*Set
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		sets = append(sets, s)
+	}
+	return Merge(sets...), nil
+}
+
+// Integration reads the documents the integration suite creates from its folder, usually __tests__.
+func Integration(dir string) (*Set, error) {
+	var paths []string
+	for _, name := range IntegrationFiles {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return ReadFiles(paths...)
+}
+
+// Load stores all documents of the set, categories and tags first and orders last. Documents whose id
+// exists are replaced or kept according to mode. It returns the number of documents stored.
+func (s *Set) Load(storage model.Storage, mode model.ConflictMode) (int64, error) {
+	var n int64
+	load := func(collection string, doc interface{}) error {
+		outcome, err := storage.ImportDocument(collection, doc, mode, false)
+		if err != nil {
+			id, _ := model.DocumentID(doc)
+			return fmt.Errorf("%s %d: %v", collection, id, err)
+		}
+		if outcome != model.ImportSkipped {
+			n++
+		}
+		return nil
+	}
+	for _, c := range s.Categories {
+		if err := load(model.CollectionCategories, c); err != nil {
+			return n, err
+		}
+	}
+	for _, t := range s.Tags {
+		if err := load(model.CollectionTags, t); err != nil {
+			return n, err
+		}
+	}
+	for _, p := range s.Pets {
+		if err := load(model.CollectionPets, p); err != nil {
+			return n, err
+		}
+	}
+	for _, u := range s.Users {
+		if err := load(model.CollectionUsers, u); err != nil {
+			return n, err
+		}
+	}
+	for _, o := range s.Orders {
+		if err := load(model.CollectionOrders, o); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,64 @@
+package fixtures
+
+import (
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// memoryStorage records imported documents, other Storage methods are not implemented.
+type memoryStorage struct {
+	model.Storage
+	docs map[string]map[int64]interface{}
+}
+
+func (m *memoryStorage) ImportDocument(collection string, doc interface{}, mode model.ConflictMode, dryRun bool) (model.ImportOutcome, error) {
+	id, _ := model.DocumentID(doc)
+	if m.docs[collection] == nil {
+		m.docs[collection] = map[int64]interface{}{}
+	}
+	if _, ok := m.docs[collection][id]; ok && mode == model.ConflictSkip {
+		return model.ImportSkipped, nil
+	}
+	m.docs[collection][id] = doc
+	return model.ImportInserted, nil
+}
+
+func TestIntegration(t *testing.T) {
+	s, err := Integration("../../__tests__")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(s.Users))
+	assert.Equal(t, "string", s.Users[0].Username)
+	assert.Equal(t, "username1", s.Users[1].Username)
+	assert.Equal(t, 3, len(s.Pets))
+	assert.Equal(t, "kitten", s.Pets[1].Name)
+	assert.Equal(t, 1, len(s.Orders))
+	assert.Equal(t, model.OrderStatusPlaced, s.Orders[0].Status)
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`{"categories":[{"id":1,"name":"cat"}],"pets":[{"id":1,"name":"kitten","photoUrls":[]}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "cat", s.Categories[0].Name)
+	assert.Equal(t, "kitten", s.Pets[0].Name)
+
+	_, err = Parse([]byte(`{"id":1}`))
+	assert.Error(t, err)
+	_, err = Parse([]byte(`[{"id":1,"username":"username1"},{"id":2}]`))
+	assert.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	storage := &memoryStorage{docs: map[string]map[int64]interface{}{}}
+	s := Generate(1, 10, 5, 20)
+
+	n, err := s.Load(storage, model.ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(s.Categories)+len(s.Tags)+10+5+20), n)
+	assert.Equal(t, 10, len(storage.docs[model.CollectionPets]))
+	assert.Equal(t, 20, len(storage.docs[model.CollectionOrders]))
+
+	n, err = s.Load(storage, model.ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
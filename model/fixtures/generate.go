@@ -0,0 +1,89 @@
+package fixtures
+
+import (
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"math/rand"
+	"time"
+)
+
+var (
+	categoryNames = []string{"cat", "dog", "fish", "bird", "rabbit", "reptile"}
+	tagNames      = []string{"fluffy", "tabby", "playful", "calm", "small", "large", "young", "senior"}
+	petNames      = []string{"bella", "max", "luna", "charlie", "lucy", "cooper", "daisy", "milo", "nemo", "kiwi"}
+	firstNames    = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi"}
+	lastNames     = []string{"smith", "jones", "brown", "taylor", "wilson", "lee"}
+	petStatuses   = []string{model.PetStatusAvailable, model.PetStatusPending, model.PetStatusSold}
+	orderStatuses = []string{model.OrderStatusPlaced, model.OrderStatusApproved, model.OrderStatusDelivered}
+)
+
+// ship dates are spread over the year after this time so that generated sets do not depend on the clock
+var generateEpoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Generate returns a set of random pets, users and orders, the same for the same seed and numbers.
+// Ids start at 1 and orders refer to the generated pets and users.
+func Generate(seed int64, pets, users, orders int) *Set {
+	rnd := rand.New(rand.NewSource(seed))
+	pick := func(list []string) string { return list[rnd.Intn(len(list))] }
+	s := &Set{}
+
+	for i, name := range categoryNames {
+		s.Categories = append(s.Categories, model.NewCategory(int64(i+1), name))
+	}
+	for i, name := range tagNames {
+		s.Tags = append(s.Tags, model.NewTag(int64(i+1), name))
+	}
+
+	for i := 1; i <= pets; i++ {
+		p := model.NewPet(
+			int64(i),
+			s.Categories[rnd.Intn(len(s.Categories))],
+			fmt.Sprintf("%s %d", pick(petNames), i),
+			[]string{fmt.Sprintf("/images/%d.jpg", i)},
+			nil,
+			pick(petStatuses))
+		for _, j := range rnd.Perm(len(s.Tags))[:rnd.Intn(3)] {
+			p.AddTag(s.Tags[j])
+		}
+		if rnd.Intn(4) > 0 {
+			p.Price = int64(500 + rnd.Intn(200)*100)
+			p.Currency = "USD"
+		}
+		if p.Category.Name == "fish" {
+			stock := int64(rnd.Intn(50))
+			p.Stock = &stock
+		}
+		s.Pets = append(s.Pets, p)
+	}
+
+	for i := 1; i <= users; i++ {
+		first, last := pick(firstNames), pick(lastNames)
+		s.Users = append(s.Users, model.NewUser(
+			int64(i),
+			fmt.Sprintf("%s%d", first, i),
+			first,
+			last,
+			fmt.Sprintf("%s.%s%d@example.com", first, last, i),
+			fmt.Sprintf("password%d", i),
+			fmt.Sprintf("555-%04d", rnd.Intn(10000)),
+			0))
+	}
+
+	for i := 1; i <= orders && pets > 0; i++ {
+		pet := s.Pets[rnd.Intn(len(s.Pets))]
+		o := model.NewOrder(
+			int64(i),
+			pet.ID,
+			int32(1+rnd.Intn(3)),
+			generateEpoch.Add(time.Duration(rnd.Intn(365*24))*time.Hour),
+			pick(orderStatuses),
+			false)
+		o.Complete = o.Status == model.OrderStatusDelivered
+		if users > 0 {
+			o.UserID = s.Users[rnd.Intn(len(s.Users))].ID
+		}
+		_ = o.ApplyPrice(pet)
+		s.Orders = append(s.Orders, o)
+	}
+	return s
+}
@@ -0,0 +1,35 @@
+package fixtures
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	a := Generate(42, 20, 10, 30)
+	b := Generate(42, 20, 10, 30)
+	assert.Equal(t, a, b)
+	assert.Equal(t, 20, len(a.Pets))
+	assert.Equal(t, 10, len(a.Users))
+	assert.Equal(t, 30, len(a.Orders))
+
+	c := Generate(43, 20, 10, 30)
+	assert.NotEqual(t, a, c)
+}
+
+func TestGenerateIsValid(t *testing.T) {
+	s := Generate(7, 50, 10, 50)
+	usernames := map[string]bool{}
+	for _, u := range s.Users {
+		assert.False(t, usernames[u.Username])
+		usernames[u.Username] = true
+	}
+	for _, p := range s.Pets {
+		assert.NoError(t, p.Validate())
+	}
+	for _, o := range s.Orders {
+		assert.True(t, o.PetID >= 1 && o.PetID <= 50)
+		assert.True(t, o.UserID >= 1 && o.UserID <= 10)
+		assert.Equal(t, o.UnitPrice*int64(o.Quantity), o.Total)
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+// LoginAttempts tracks failed login attempts of a username. It is kept apart from
+// the user record so that unknown usernames are throttled the same way as known ones.
+type LoginAttempts struct {
+	Username      string    `json:"username" bson:"username"`
+	Failures      int32     `json:"failures" bson:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt" bson:"lastFailureAt"`
+}
+
+// LockoutPolicy decides how long a username has to wait before trying to login again.
+// Each failure doubles the wait starting from BaseDelay up to MaxDelay, and after
+// MaxFailures failures the username is locked for LockoutDuration.
+type LockoutPolicy struct {
+	MaxFailures     int32
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxFailures:     5,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	LockoutDuration: 15 * time.Minute,
+}
+
+// Locked tells whether attempts have reached the lockout threshold and the lockout is not over yet.
+func (p LockoutPolicy) Locked(a *LoginAttempts, now time.Time) bool {
+	if a == nil || p.MaxFailures <= 0 || a.Failures < p.MaxFailures {
+		return false
+	}
+	return now.Before(a.LastFailureAt.Add(p.LockoutDuration))
+}
+
+// Expired tells whether a past lockout is over, so the attempts can be forgotten.
+func (p LockoutPolicy) Expired(a *LoginAttempts, now time.Time) bool {
+	if a == nil || p.MaxFailures <= 0 || a.Failures < p.MaxFailures {
+		return false
+	}
+	return !p.Locked(a, now)
+}
+
+// RetryAfter returns how long to wait before the next login attempt is accepted, zero if it can be made now.
+func (p LockoutPolicy) RetryAfter(a *LoginAttempts, now time.Time) time.Duration {
+	if a == nil || a.Failures <= 0 {
+		return 0
+	}
+	var wait time.Duration
+	if p.Locked(a, now) {
+		wait = p.LockoutDuration
+	} else if p.MaxFailures > 0 && a.Failures >= p.MaxFailures {
+		return 0
+	} else {
+		wait = p.BaseDelay
+		for i := int32(1); i < a.Failures && wait < p.MaxDelay; i++ {
+			wait *= 2
+		}
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			wait = p.MaxDelay
+		}
+	}
+	remaining := a.LastFailureAt.Add(wait).Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicyBackoff(t *testing.T) {
+	p := LockoutPolicy{MaxFailures: 5, BaseDelay: time.Second, MaxDelay: 4 * time.Second, LockoutDuration: time.Minute}
+	now := time.Now().UTC()
+
+	assert.Equal(t, time.Duration(0), p.RetryAfter(nil, now))
+	assert.Equal(t, time.Second, p.RetryAfter(&LoginAttempts{Failures: 1, LastFailureAt: now}, now))
+	assert.Equal(t, 2*time.Second, p.RetryAfter(&LoginAttempts{Failures: 2, LastFailureAt: now}, now))
+	assert.Equal(t, 4*time.Second, p.RetryAfter(&LoginAttempts{Failures: 3, LastFailureAt: now}, now))
+	assert.Equal(t, 4*time.Second, p.RetryAfter(&LoginAttempts{Failures: 4, LastFailureAt: now}, now))
+	assert.Equal(t, time.Duration(0), p.RetryAfter(&LoginAttempts{Failures: 2, LastFailureAt: now.Add(-time.Hour)}, now))
+}
+
+func TestLockoutPolicyLockout(t *testing.T) {
+	p := LockoutPolicy{MaxFailures: 3, BaseDelay: time.Second, MaxDelay: 4 * time.Second, LockoutDuration: time.Minute}
+	now := time.Now().UTC()
+
+	a := &LoginAttempts{Username: "username", Failures: 3, LastFailureAt: now.Add(-10 * time.Second)}
+	assert.True(t, p.Locked(a, now))
+	assert.False(t, p.Expired(a, now))
+	assert.Equal(t, 50*time.Second, p.RetryAfter(a, now))
+
+	a.LastFailureAt = now.Add(-2 * time.Minute)
+	assert.False(t, p.Locked(a, now))
+	assert.True(t, p.Expired(a, now))
+	assert.Equal(t, time.Duration(0), p.RetryAfter(a, now))
+}
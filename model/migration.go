@@ -0,0 +1,286 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+)
+
+const (
+	// Applied schema migrations, one document per version
+	CollectionSchemaMigrations string = "schema_migrations"
+	// Lock held by the process running migrations
+	CollectionSchemaMigrationsLock string = "schema_migrations_lock"
+)
+
+var ErrMigrationLocked = errors.New("schema migrations are locked by another process")
+
+// ErrMigrationLockLost is returned when the migrations lock could not be renewed while migrating.
+var ErrMigrationLockLost = errors.New("schema migrations lock was lost, another process may be migrating")
+
+// Migration changes the layout of the database from Version-1 to Version, Down undoes it.
+// Migrations without Down cannot be reverted.
+type Migration struct {
+	Version     int64
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// AppliedMigration records a migration applied to the database.
+type AppliedMigration struct {
+	Version     int64     `json:"version" bson:"_id"`
+	Description string    `json:"description" bson:"description"`
+	AppliedAt   time.Time `json:"appliedAt" bson:"appliedAt"`
+}
+
+// MigrationStatus tells whether a migration is applied. Migrations applied by a newer
+// release are reported with Unknown set, as they are not registered in this one.
+type MigrationStatus struct {
+	Version     int64
+	Description string
+	AppliedAt   *time.Time
+	Unknown     bool
+}
+
+// Migrator is implemented by storages with a versioned schema.
+type Migrator interface {
+	// Fetch status of registered and applied migrations, by version
+	MigrationStatus() ([]MigrationStatus, error)
+	// Apply pending migrations up to version to, all of them if to is 0
+	MigrateUp(to int64) ([]Migration, error)
+	// Revert applied migrations newer than version to, latest first
+	MigrateDown(to int64) ([]Migration, error)
+}
+
+var migrations []Migration
+
+// RegisterMigration adds a migration to the registry, it panics if the version is taken.
+func RegisterMigration(migration Migration) {
+	if migration.Version <= 0 || migration.Up == nil {
+		panic(fmt.Sprintf("invalid migration %d", migration.Version))
+	}
+	for _, m := range migrations {
+		if m.Version == migration.Version {
+			panic(fmt.Sprintf("duplicate migration %d", migration.Version))
+		}
+	}
+	migrations = append(migrations, migration)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+}
+
+// Migrations returns registered migrations by version.
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+// pendingMigrations returns registered migrations up to version to, all if to is 0, not applied yet.
+func pendingMigrations(registered []Migration, applied map[int64]bool, to int64) []Migration {
+	var pending []Migration
+	for _, m := range registered {
+		if to > 0 && m.Version > to {
+			break
+		}
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// revertibleMigrations returns applied migrations newer than version to, latest first. It fails when one of
+// them is not registered or has no Down, before anything is reverted.
+func revertibleMigrations(registered []Migration, applied map[int64]bool, to int64) ([]Migration, error) {
+	byVersion := map[int64]Migration{}
+	for _, m := range registered {
+		byVersion[m.Version] = m
+	}
+	var versions []int64
+	for v, ok := range applied {
+		if ok && v > to {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	var revert []Migration
+	for _, v := range versions {
+		m, ok := byVersion[v]
+		if !ok {
+			return nil, fmt.Errorf("migration %d is not known to this release", v)
+		}
+		if m.Down == nil {
+			return nil, fmt.Errorf("migration %d cannot be reverted", v)
+		}
+		revert = append(revert, m)
+	}
+	return revert, nil
+}
+
+func createIndexes(collection string, indexes []mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+func dropIndexes(collection string, names ...string) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, name := range names {
+			_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+			if err != nil && !isNamespaceNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// createCollection creates a collection ahead of writes in transactions, which cannot create it.
+func createCollection(collection string) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		err := db.RunCommand(ctx, bson.D{{Key: "create", Value: collection}}).Err()
+		if ce, ok := err.(mongo.CommandError); ok && ce.Code == 48 {
+			// already exists
+			return nil
+		}
+		return err
+	}
+}
+
+func dropCollection(collection string) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		err := db.Collection(collection).Drop(ctx)
+		if err != nil && !isNamespaceNotFound(err) {
+			return err
+		}
+		return nil
+	}
+}
+
+// isNamespaceNotFound tells whether err reports a missing collection or index, which dropping treats as done.
+func isNamespaceNotFound(err error) bool {
+	if ce, ok := err.(mongo.CommandError); ok {
+		return ce.Code == 26 || ce.Code == 27
+	}
+	return false
+}
+
+func init() {
+	RegisterMigration(Migration{
+		Version:     1,
+		Description: "index orders by user, pet, status and ship date",
+		Up: createIndexes(CollectionOrders, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "shipDate", Value: -1}}},
+			{Keys: bson.D{{Key: "petId", Value: 1}, {Key: "shipDate", Value: -1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "shipDate", Value: -1}}},
+			{Keys: bson.D{{Key: "shipDate", Value: -1}}},
+		}),
+		Down: dropIndexes(CollectionOrders, "userId_1_shipDate_-1", "petId_1_shipDate_-1", "status_1_shipDate_-1", "shipDate_-1"),
+	})
+	RegisterMigration(Migration{
+		Version:     2,
+		Description: "index login attempts by username and sessions by token, expire sessions",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			err := createIndexes(CollectionLoginAttempts, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+			})(ctx, db)
+			if err != nil {
+				return err
+			}
+			return createIndexes(CollectionSessions, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			})(ctx, db)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			err := dropIndexes(CollectionLoginAttempts, "username_1")(ctx, db)
+			if err != nil {
+				return err
+			}
+			return dropIndexes(CollectionSessions, "token_1", "expiresAt_1")(ctx, db)
+		},
+	})
+	RegisterMigration(Migration{
+		Version:     3,
+		Description: "start versions of pets, users and orders at 1",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, collection := range []string{CollectionPets, CollectionUsers, CollectionOrders} {
+				_, err := db.Collection(collection).UpdateMany(ctx,
+					bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": 1}})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, collection := range []string{CollectionPets, CollectionUsers, CollectionOrders} {
+				_, err := db.Collection(collection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"version": ""}})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	RegisterMigration(Migration{
+		Version:     4,
+		Description: "index audit events by entity, actor and time",
+		Up: createIndexes(CollectionAudit, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "entity", Value: 1}, {Key: "entityId", Value: 1}, {Key: "at", Value: -1}}},
+			{Keys: bson.D{{Key: "actor.username", Value: 1}, {Key: "at", Value: -1}}},
+			{Keys: bson.D{{Key: "at", Value: -1}}},
+		}),
+		Down: dropIndexes(CollectionAudit, "entity_1_entityId_1_at_-1", "actor.username_1_at_-1", "at_-1"),
+	})
+	RegisterMigration(Migration{
+		Version:     5,
+		Description: "create outbox of events, index webhook deliveries by status and due time",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			err := createCollection(CollectionEvents)(ctx, db)
+			if err != nil {
+				return err
+			}
+			return createIndexes(CollectionWebhookDeliveries, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+				{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "status", Value: 1}, {Key: "event._id", Value: -1}}},
+			})(ctx, db)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			err := dropIndexes(CollectionWebhookDeliveries, "status_1_nextAttemptAt_1", "webhookId_1_status_1_event._id_-1")(ctx, db)
+			if err != nil {
+				return err
+			}
+			return dropCollection(CollectionEvents)(ctx, db)
+		},
+	})
+	RegisterMigration(Migration{
+		Version:     6,
+		Description: "index pet name, category name and tag names for text search",
+		Up: createIndexes(CollectionPets, []mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "name", Value: "text"}, {Key: "category.name", Value: "text"}, {Key: "tags.name", Value: "text"}},
+				Options: options.Index().SetName("pets_text").SetWeights(bson.M{
+					"name":          SearchWeightName,
+					"category.name": SearchWeightCategory,
+					"tags.name":     SearchWeightTags,
+				}),
+			},
+		}),
+		Down: dropIndexes(CollectionPets, "pets_text"),
+	})
+	RegisterMigration(Migration{
+		Version:     7,
+		Description: "index users by id, unique as sessions find their user by id",
+		Up: createIndexes(CollectionUsers, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		}),
+		Down: dropIndexes(CollectionUsers, "id_1"),
+	})
+}
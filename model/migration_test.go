@@ -0,0 +1,45 @@
+package model
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestPendingAndRevertibleMigrations(t *testing.T) {
+	noop := func(ctx context.Context, db *mongo.Database) error { return nil }
+	registered := []Migration{
+		{Version: 1, Up: noop, Down: noop},
+		{Version: 2, Up: noop},
+		{Version: 3, Up: noop, Down: noop},
+	}
+
+	pending := pendingMigrations(registered, map[int64]bool{1: true}, 0)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, int64(2), pending[0].Version)
+	assert.Equal(t, int64(3), pending[1].Version)
+	assert.Len(t, pendingMigrations(registered, map[int64]bool{1: true}, 2), 1)
+	assert.Empty(t, pendingMigrations(registered, map[int64]bool{1: true, 2: true, 3: true}, 0))
+
+	revert, err := revertibleMigrations(registered, map[int64]bool{1: true, 2: true, 3: true}, 2)
+	assert.NoError(t, err)
+	assert.Len(t, revert, 1)
+	assert.Equal(t, int64(3), revert[0].Version)
+
+	_, err = revertibleMigrations(registered, map[int64]bool{1: true, 2: true, 3: true}, 1)
+	assert.Error(t, err, "migration 2 has no down")
+	_, err = revertibleMigrations(registered, map[int64]bool{1: true, 4: true}, 0)
+	assert.Error(t, err, "migration 4 is unknown")
+}
+
+func TestRegisteredMigrations(t *testing.T) {
+	registered := Migrations()
+	assert.NotEmpty(t, registered)
+	for i, m := range registered {
+		assert.Equal(t, int64(i+1), m.Version)
+		assert.NotEmpty(t, m.Description)
+		assert.NotNil(t, m.Down)
+	}
+	assert.Panics(t, func() { RegisterMigration(registered[0]) })
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrAmountOverflow = errors.New("amount overflows")
+
+// StockValue is the value of stock per currency in minor units.
+type StockValue map[string]int64
+
+// ValidCurrency tells whether currency looks like an ISO 4217 code, eg. USD.
+func ValidCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// MultiplyAmount multiplies an amount in minor units by quantity, failing instead of overflowing.
+func MultiplyAmount(amount, quantity int64) (int64, error) {
+	if amount == 0 || quantity == 0 {
+		return 0, nil
+	}
+	if amount < 0 || quantity < 0 || amount > math.MaxInt64/quantity {
+		return 0, ErrAmountOverflow
+	}
+	return amount * quantity, nil
+}
+
+// Add adds amount in currency, failing instead of overflowing.
+func (v StockValue) Add(currency string, amount int64) error {
+	if amount > 0 && v[currency] > math.MaxInt64-amount {
+		return ErrAmountOverflow
+	}
+	v[currency] += amount
+	return nil
+}
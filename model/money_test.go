@@ -0,0 +1,32 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestValidCurrency(t *testing.T) {
+	assert.True(t, ValidCurrency("USD"))
+	assert.False(t, ValidCurrency("usd"))
+	assert.False(t, ValidCurrency("US"))
+	assert.False(t, ValidCurrency(""))
+}
+
+func TestMultiplyAmount(t *testing.T) {
+	a, err := MultiplyAmount(1999, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5997), a)
+
+	_, err = MultiplyAmount(math.MaxInt64/2, 3)
+	assert.Equal(t, ErrAmountOverflow, err)
+}
+
+func TestStockValueAdd(t *testing.T) {
+	v := StockValue{}
+	assert.NoError(t, v.Add("USD", 100))
+	assert.NoError(t, v.Add("USD", 50))
+	assert.NoError(t, v.Add("EUR", 10))
+	assert.Equal(t, StockValue{"USD": 150, "EUR": 10}, v)
+	assert.Equal(t, ErrAmountOverflow, v.Add("USD", math.MaxInt64))
+}
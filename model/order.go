@@ -5,13 +5,24 @@ import (
 	"time"
 )
 
+// Order of a pet. UnitPrice and Total are in minor units of Currency, recorded from the pet when placed.
+// Version is 1 when placed and incremented by each change of the stored order. DeletedAt is set while the
+// order is deleted but not purged yet.
 type Order struct {
-	ID       int64     `json:"id" bson:"id"`
-	PetID    int64     `json:"petId" bson:"petId"`
-	Quantity int32     `json:"quantity" bson:"quantity"`
-	ShipDate time.Time `json:"shipDate" bson:"shipDate"`
-	Status   string    `json:"status" bson:"status"`
-	Complete bool      `json:"complete" bson:"complete"`
+	ID        int64     `json:"id" bson:"id"`
+	PetID     int64     `json:"petId" bson:"petId"`
+	Quantity  int32     `json:"quantity" bson:"quantity"`
+	ShipDate  time.Time `json:"shipDate" bson:"shipDate"`
+	Status    string    `json:"status" bson:"status"`
+	Complete  bool      `json:"complete" bson:"complete"`
+	UserID    int64     `json:"userId,omitempty" bson:"userId,omitempty"`
+	UnitPrice int64     `json:"unitPrice,omitempty" bson:"unitPrice,omitempty"`
+	Currency  string    `json:"currency,omitempty" bson:"currency,omitempty"`
+	Total     int64     `json:"total,omitempty" bson:"total,omitempty"`
+	// Set when placing the order took its single pet from available to pending, to be put back when it is cancelled
+	PetHeld   bool       `json:"petHeld,omitempty" bson:"petHeld,omitempty"`
+	Version   int64      `json:"version,omitempty" bson:"version"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
 
 const (
@@ -31,8 +42,48 @@ func NewOrder(id, petId int64, quantity int32, shipDate time.Time, status string
 	}
 }
 
+// ApplyPrice records unit price and currency of pet on the order and computes its total.
+func (o *Order) ApplyPrice(pet *Pet) error {
+	total, err := MultiplyAmount(pet.Price, int64(o.Quantity))
+	if err != nil {
+		return err
+	}
+	o.UnitPrice = pet.Price
+	o.Currency = pet.Currency
+	o.Total = total
+	return nil
+}
+
 type Inventory map[string]int64
 
 func (inv *Inventory) Count() int64 {
 	return int64(len(reflect.ValueOf(*inv).MapKeys()))
 }
+
+const (
+	DefaultOrderPageSize int64 = 20
+	MaxOrderPageSize     int64 = 100
+)
+
+// OrderFilter selects orders by their fields, zero valued fields don't filter.
+// ShipDateFrom is inclusive and ShipDateTo exclusive.
+type OrderFilter struct {
+	UserID       int64
+	PetID        int64
+	Statuses     []string
+	ShipDateFrom time.Time
+	ShipDateTo   time.Time
+	Offset       int64
+	Limit        int64
+}
+
+// PageSize returns Limit bounded by MaxOrderPageSize, DefaultOrderPageSize if not set.
+func (f OrderFilter) PageSize() int64 {
+	if f.Limit <= 0 {
+		return DefaultOrderPageSize
+	}
+	if f.Limit > MaxOrderPageSize {
+		return MaxOrderPageSize
+	}
+	return f.Limit
+}
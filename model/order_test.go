@@ -17,3 +17,17 @@ func TestNewOrder(t *testing.T) {
 	assert.Equal(t, OrderStatusPlaced, order.Status)
 	assert.Equal(t, false, order.Complete)
 }
+
+func TestOrderFilterPageSize(t *testing.T) {
+	assert.Equal(t, DefaultOrderPageSize, OrderFilter{}.PageSize())
+	assert.Equal(t, int64(5), OrderFilter{Limit: 5}.PageSize())
+	assert.Equal(t, MaxOrderPageSize, OrderFilter{Limit: 1000}.PageSize())
+}
+
+func TestOrderApplyPrice(t *testing.T) {
+	order := NewOrder(1, 1, 3, time.Now().UTC(), OrderStatusPlaced, false)
+	assert.NoError(t, order.ApplyPrice(&Pet{ID: 1, Price: 1250, Currency: "USD"}))
+	assert.Equal(t, int64(1250), order.UnitPrice)
+	assert.Equal(t, "USD", order.Currency)
+	assert.Equal(t, int64(3750), order.Total)
+}
@@ -0,0 +1,488 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Update is a partial update of a stored document, translated from a JSON Merge Patch ( RFC 7396 ) or a
+// JSON Patch ( RFC 6902 ). Paths are dot separated names of stored fields, array elements are addressed
+// by index, and values have the type of the field they go to.
+type Update struct {
+	// Values to set by path
+	Set map[string]interface{}
+	// Paths to remove
+	Unset map[string]bool
+	// Values to append by path of array
+	Push map[string][]interface{}
+	// Values the document must have by path for the update to be made
+	Test map[string]interface{}
+}
+
+// ErrPatchTestFailed is returned when a test operation of a JSON Patch does not hold.
+var ErrPatchTestFailed = errors.New("patch test failed")
+
+func newUpdate() *Update {
+	return &Update{
+		Set:   map[string]interface{}{},
+		Unset: map[string]bool{},
+		Push:  map[string][]interface{}{},
+		Test:  map[string]interface{}{},
+	}
+}
+
+func (u *Update) set(path string, value interface{}) {
+	delete(u.Unset, path)
+	delete(u.Push, path)
+	u.Set[path] = value
+}
+
+func (u *Update) unset(path string) {
+	delete(u.Set, path)
+	delete(u.Push, path)
+	u.Unset[path] = true
+}
+
+// Empty tells whether the update changes nothing.
+func (u *Update) Empty() bool {
+	return len(u.Set) == 0 && len(u.Unset) == 0 && len(u.Push) == 0
+}
+
+// Touches tells whether the update changes path or a field within it.
+func (u *Update) Touches(path string) bool {
+	for _, p := range u.paths() {
+		if p == path || strings.HasPrefix(p, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *Update) paths() []string {
+	var paths []string
+	for p := range u.Set {
+		paths = append(paths, p)
+	}
+	for p := range u.Unset {
+		paths = append(paths, p)
+	}
+	for p := range u.Push {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// check refuses updates changing a field along with a field within it, which cannot be made at once.
+func (u *Update) check() error {
+	paths := u.paths()
+	for i, a := range paths {
+		for _, b := range paths[i+1:] {
+			if a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".") {
+				return fmt.Errorf("conflicting changes of %s and %s", a, b)
+			}
+		}
+	}
+	if u.Empty() {
+		return errors.New("patch makes no change")
+	}
+	return nil
+}
+
+// MergePatch translates a JSON Merge Patch of documents like doc, a pointer to a Pet or User, into an Update.
+// Members set to null are removed, objects are merged into embedded documents and other values replace
+// the stored ones, arrays as a whole.
+func MergePatch(doc interface{}, patch []byte) (*Update, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+	u := newUpdate()
+	if err := mergePatch(u, reflect.TypeOf(doc), nil, members); err != nil {
+		return nil, err
+	}
+	return u, u.check()
+}
+
+func mergePatch(u *Update, t reflect.Type, prefix []string, members map[string]json.RawMessage) error {
+	t = indirect(t)
+	for name, raw := range members {
+		field, ok := fieldByJSONName(t, name)
+		if !ok {
+			return fmt.Errorf("unknown field %s", strings.Join(append(append([]string(nil), prefix...), name), "."))
+		}
+		path := append(append([]string(nil), prefix...), bsonName(field))
+		if isNull(raw) {
+			u.unset(strings.Join(path, "."))
+			continue
+		}
+		if indirect(field.Type).Kind() == reflect.Struct && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+			var nested map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &nested); err != nil {
+				return fmt.Errorf("invalid %s: %v", name, err)
+			}
+			if err := mergePatch(u, field.Type, path, nested); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := decodeValue(field.Type, raw)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+		u.set(strings.Join(path, "."), value)
+	}
+	return nil
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// JSONPatch translates a JSON Patch of documents like doc, a pointer to a Pet or User, into an Update.
+// Operations apply in order to the same update, so a later one wins over an earlier one on the same path.
+// Arrays can be appended to with an add to /-, but their elements cannot be inserted or removed by index,
+// and move and copy operations are not supported.
+func JSONPatch(doc interface{}, patch []byte) (*Update, error) {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, errors.New("json patch must be a JSON array of operations")
+	}
+	u := newUpdate()
+	t := reflect.TypeOf(doc)
+	for i, op := range ops {
+		if err := applyOperation(u, t, op); err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+	}
+	return u, u.check()
+}
+
+func applyOperation(u *Update, t reflect.Type, op patchOperation) error {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return errors.New("whole document cannot be patched")
+	}
+	parentPath, parent, err := resolvePath(t, tokens[:len(tokens)-1])
+	if err != nil {
+		return err
+	}
+	last := tokens[len(tokens)-1]
+	inArray := indirect(parent).Kind() == reflect.Slice
+	if (op.Op == "add" || op.Op == "replace" || op.Op == "test") && op.Value == nil {
+		return fmt.Errorf("%s of %s has no value", op.Op, op.Path)
+	}
+
+	switch op.Op {
+	case "add":
+		if inArray && last == "-" {
+			value, err := decodeValue(indirect(parent).Elem(), op.Value)
+			if err != nil {
+				return fmt.Errorf("invalid value of %s: %v", op.Path, err)
+			}
+			p := strings.Join(parentPath, ".")
+			u.Push[p] = append(u.Push[p], value)
+			return nil
+		}
+		if inArray {
+			return fmt.Errorf("cannot insert into %s, only appending with /- is supported", op.Path)
+		}
+		fallthrough
+	case "replace", "test":
+		path, typ, err := resolvePath(t, tokens)
+		if err != nil {
+			return err
+		}
+		value, err := decodeValue(typ, op.Value)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s: %v", op.Path, err)
+		}
+		if op.Op == "test" {
+			u.Test[strings.Join(path, ".")] = value
+		} else {
+			u.set(strings.Join(path, "."), value)
+		}
+	case "remove":
+		if inArray {
+			return fmt.Errorf("cannot remove %s, array elements cannot be removed by index", op.Path)
+		}
+		path, _, err := resolvePath(t, tokens)
+		if err != nil {
+			return err
+		}
+		u.unset(strings.Join(path, "."))
+	case "move", "copy":
+		return fmt.Errorf("%s operations are not supported", op.Op)
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+	return nil
+}
+
+// parsePointer splits a JSON Pointer ( RFC 6901 ) into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// resolvePath returns the stored field path of JSON Pointer tokens within documents of type t, and the type of
+// the value it points to.
+func resolvePath(t reflect.Type, tokens []string) ([]string, reflect.Type, error) {
+	var path []string
+	for i, token := range tokens {
+		t = indirect(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByJSONName(t, token)
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown field /%s", strings.Join(tokens[:i+1], "/"))
+			}
+			path = append(path, bsonName(field))
+			t = field.Type
+		case reflect.Slice:
+			if _, err := strconv.ParseUint(token, 10, 32); err != nil {
+				return nil, nil, fmt.Errorf("invalid index /%s", strings.Join(tokens[:i+1], "/"))
+			}
+			path = append(path, token)
+			t = t.Elem()
+		default:
+			return nil, nil, fmt.Errorf("/%s has no fields", strings.Join(tokens[:i], "/"))
+		}
+	}
+	return path, t, nil
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tagName(f.Tag.Get("json")) == name && name != "-" {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func bsonName(f reflect.StructField) string {
+	if name := tagName(f.Tag.Get("bson")); name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+func tagName(tag string) string {
+	return strings.Split(tag, ",")[0]
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// decodeValue decodes raw into a value of type t, so that it is stored like the field it goes to.
+func decodeValue(t reflect.Type, raw json.RawMessage) (interface{}, error) {
+	v := reflect.New(t)
+	if err := json.Unmarshal(raw, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// applyUpdate applies update to doc, a pointer to a Pet, User or Order, in the process the way the server applies
+// it to a stored document: paths are followed through embedded documents and array elements, creating missing
+// documents on the way, and pushes append to arrays. ErrPatchTestFailed is returned when a test does not hold.
+func applyUpdate(doc interface{}, update *Update) error {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	fields := bson.M{}
+	if err = bson.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for path, value := range update.Test {
+		same, err := sameValue(lookupPath(fields, strings.Split(path, ".")), value)
+		if err != nil {
+			return err
+		}
+		if !same {
+			return ErrPatchTestFailed
+		}
+	}
+	var node interface{} = fields
+	for path, value := range update.Set {
+		if node, err = setPath(node, strings.Split(path, "."), value); err != nil {
+			return err
+		}
+	}
+	for path := range update.Unset {
+		if node, err = unsetPath(node, strings.Split(path, ".")); err != nil {
+			return err
+		}
+	}
+	for path, values := range update.Push {
+		tokens := strings.Split(path, ".")
+		var array bson.A
+		switch a := lookupPath(node, tokens).(type) {
+		case nil:
+		case bson.A:
+			array = a
+		default:
+			return fmt.Errorf("cannot push to %s, not an array", path)
+		}
+		if node, err = setPath(node, tokens, append(array, values...)); err != nil {
+			return err
+		}
+	}
+
+	if b, err = bson.Marshal(node); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(doc).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return bson.Unmarshal(b, doc)
+}
+
+// lookupPath returns the value at path within node, nil if there is none.
+func lookupPath(node interface{}, path []string) interface{} {
+	for _, token := range path {
+		switch n := node.(type) {
+		case bson.M:
+			node = n[token]
+		case bson.A:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil
+			}
+			node = n[i]
+		default:
+			return nil
+		}
+	}
+	return node
+}
+
+// setPath sets the value at path within node, a document, an array or nil for a missing document, and returns
+// the changed node.
+func setPath(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	switch n := node.(type) {
+	case nil:
+		child, err := setPath(nil, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{path[0]: child}, nil
+	case bson.M:
+		child, err := setPath(n[path[0]], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = child
+		return n, nil
+	case bson.A:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid index %s", path[0])
+		}
+		for len(n) <= i {
+			n = append(n, nil)
+		}
+		child, err := setPath(n[i], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	}
+	return nil, fmt.Errorf("cannot set %s, not within a document or an array", path[0])
+}
+
+// unsetPath removes the value at path within node and returns the changed node. Array elements are set to nil
+// rather than removed, so that the indexes of those following are kept.
+func unsetPath(node interface{}, path []string) (interface{}, error) {
+	switch n := node.(type) {
+	case bson.M:
+		if len(path) == 1 {
+			delete(n, path[0])
+			return n, nil
+		}
+		child, err := unsetPath(n[path[0]], path[1:])
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			n[path[0]] = child
+		}
+		return n, nil
+	case bson.A:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(n) {
+			return n, nil
+		}
+		if len(path) == 1 {
+			n[i] = nil
+			return n, nil
+		}
+		child, err := unsetPath(n[i], path[1:])
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	}
+	return node, nil
+}
+
+// sameValue tells whether stored and expected are equal once stored, a missing value being equal to nil.
+func sameValue(stored, expected interface{}) (bool, error) {
+	normalize := func(v interface{}) (interface{}, error) {
+		b, err := bson.Marshal(bson.M{"v": v})
+		if err != nil {
+			return nil, err
+		}
+		var m bson.M
+		if err = bson.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		return m["v"], nil
+	}
+	s, err := normalize(stored)
+	if err != nil {
+		return false, err
+	}
+	e, err := normalize(expected)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(s, e), nil
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	u, err := MergePatch(&Pet{}, []byte(`{"name":"cat2","category":{"name":"cats"},"photoUrls":["a","b"],"stock":3,"currency":null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "cat2", u.Set["name"])
+	assert.Equal(t, "cats", u.Set["category.name"])
+	assert.Equal(t, []string{"a", "b"}, u.Set["photoUrls"])
+	assert.Equal(t, int64(3), *u.Set["stock"].(*int64))
+	assert.True(t, u.Unset["currency"])
+	assert.True(t, u.Touches("category"))
+	assert.False(t, u.Touches("tags"))
+
+	_, err = MergePatch(&Pet{}, []byte(`{"nickname":"cat"}`))
+	assert.Error(t, err)
+	_, err = MergePatch(&Pet{}, []byte(`{"price":"free"}`))
+	assert.Error(t, err)
+	_, err = MergePatch(&Pet{}, []byte(`[]`))
+	assert.Error(t, err)
+	_, err = MergePatch(&Pet{}, []byte(`{}`))
+	assert.Error(t, err, "no change")
+}
+
+func TestJSONPatch(t *testing.T) {
+	_, err := JSONPatch(&Pet{}, []byte(`[
+		{"op":"test","path":"/status","value":"available"},
+		{"op":"replace","path":"/status","value":"sold"},
+		{"op":"add","path":"/photoUrls/-","value":"c"},
+		{"op":"add","path":"/tags/-","value":{"id":1,"name":"black"}},
+		{"op":"replace","path":"/tags/0/name","value":"white"},
+		{"op":"remove","path":"/category"}
+	]`))
+	assert.Error(t, err, "tags are appended to and changed at once")
+
+	u, err := JSONPatch(&Pet{}, []byte(`[
+		{"op":"test","path":"/status","value":"available"},
+		{"op":"replace","path":"/status","value":"sold"},
+		{"op":"add","path":"/photoUrls/-","value":"c"},
+		{"op":"add","path":"/tags/-","value":{"id":1,"name":"black"}},
+		{"op":"remove","path":"/category"},
+		{"op":"add","path":"/category","value":{"id":2,"name":"dogs"}}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, "available", u.Test["status"])
+	assert.Equal(t, "sold", u.Set["status"])
+	assert.Equal(t, []interface{}{"c"}, u.Push["photoUrls"])
+	assert.Equal(t, &Tag{ID: 1, Name: "black"}, u.Push["tags"][0])
+	assert.Equal(t, &Category{ID: 2, Name: "dogs"}, u.Set["category"])
+	assert.False(t, u.Unset["category"])
+
+	u, err = JSONPatch(&User{}, []byte(`[{"op":"replace","path":"/firstName","value":"a"}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", u.Set["firstName"])
+
+	for _, patch := range []string{
+		`{"op":"replace","path":"/name","value":"a"}`,
+		`[{"op":"move","from":"/name","path":"/status"}]`,
+		`[{"op":"remove","path":"/photoUrls/0"}]`,
+		`[{"op":"add","path":"/photoUrls/0","value":"a"}]`,
+		`[{"op":"replace","path":"/name"}]`,
+		`[{"op":"replace","path":"/name/first","value":"a"}]`,
+		`[{"op":"replace","path":"","value":{}}]`,
+	} {
+		_, err = JSONPatch(&Pet{}, []byte(patch))
+		assert.Error(t, err, patch)
+	}
+}
+
+func TestValidateUpdates(t *testing.T) {
+	for patch, valid := range map[string]bool{
+		`{"name":"cat"}`:        true,
+		`{"name":""}`:           false,
+		`{"name":null}`:         false,
+		`{"id":2}`:              false,
+		`{"status":"lost"}`:     false,
+		`{"price":-1}`:          false,
+		`{"currency":"EURO"}`:   false,
+		`{"stock":-1}`:          false,
+		`{"stock":null}`:        true,
+		`{"category":{"id":1}}`: true,
+	} {
+		u, err := MergePatch(&Pet{}, []byte(patch))
+		assert.NoError(t, err, patch)
+		assert.Equal(t, valid, ValidatePetUpdate(u) == nil, patch)
+	}
+
+	for patch, valid := range map[string]bool{
+		`{"email":"a@b.c"}`: true,
+		`{"username":"b"}`:  false,
+		`{"role":"staff"}`:  true,
+		`{"role":"owner"}`:  false,
+		`{"version":1}`:     false,
+	} {
+		u, err := MergePatch(&User{}, []byte(patch))
+		assert.NoError(t, err, patch)
+		assert.Equal(t, valid, ValidateUserUpdate(u) == nil, patch)
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	stock := int64(3)
+	pet := &Pet{ID: 1, Name: "cat", Status: PetStatusAvailable, Category: NewCategory(1, "cat"),
+		PhotoUrls: []string{"a"}, Tags: []*Tag{NewTag(1, "black")}, Stock: &stock, Version: 2}
+
+	u, err := JSONPatch(&Pet{}, []byte(`[
+		{"op":"test","path":"/status","value":"available"},
+		{"op":"test","path":"/stock","value":3},
+		{"op":"replace","path":"/tags/0/name","value":"white"},
+		{"op":"add","path":"/photoUrls/-","value":"b"},
+		{"op":"remove","path":"/stock"}
+	]`))
+	assert.NoError(t, err)
+	assert.NoError(t, applyUpdate(pet, u))
+	assert.Equal(t, "white", pet.Tags[0].Name)
+	assert.Equal(t, []string{"a", "b"}, pet.PhotoUrls)
+	assert.Nil(t, pet.Stock)
+	assert.Equal(t, int64(2), pet.Version)
+
+	u, err = MergePatch(&Pet{}, []byte(`{"category":{"name":"cats"},"price":250}`))
+	assert.NoError(t, err)
+	assert.NoError(t, applyUpdate(pet, u))
+	assert.Equal(t, &Category{ID: 1, Name: "cats"}, pet.Category)
+	assert.Equal(t, int64(250), pet.Price)
+
+	// embedded documents missing on the way are created
+	pet.Category = nil
+	assert.NoError(t, applyUpdate(pet, u))
+	assert.Equal(t, &Category{Name: "cats"}, pet.Category)
+
+	u, err = JSONPatch(&Pet{}, []byte(`[{"op":"test","path":"/status","value":"sold"},{"op":"remove","path":"/name"}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, ErrPatchTestFailed, applyUpdate(pet, u))
+	assert.Equal(t, "cat", pet.Name)
+}
@@ -1,20 +1,41 @@
 package model
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Pet for sale. Price is in minor units of Currency ( eg. cents ) and zero when not priced.
+// Pets sold in multiples keep their number in Stock, which is nil for single pets.
+// Version is incremented by each change of the stored pet, starting at 1. Deleted pets keep the time of
+// deletion in DeletedAt until they are restored or purged.
 type Pet struct {
-	ID        int64     `json:"id" bson:"id"`
-	Category  *Category `json:"category,omitempty" bson:"category,omitempty"`
-	Name      string    `json:"name" bson:"name"`
-	PhotoUrls []string  `json:"photoUrls" bson:"photoUrls,omitempty"`
-	Tags      []*Tag    `json:"tags" bson:"tags,omitempty"`
-	Status    string    `json:"status" bson:"status"`
+	ID        int64      `json:"id" bson:"id"`
+	Category  *Category  `json:"category,omitempty" bson:"category,omitempty"`
+	Name      string     `json:"name" bson:"name"`
+	PhotoUrls []string   `json:"photoUrls" bson:"photoUrls,omitempty"`
+	Tags      []*Tag     `json:"tags" bson:"tags,omitempty"`
+	Status    string     `json:"status" bson:"status"`
+	Price     int64      `json:"price,omitempty" bson:"price,omitempty"`
+	Currency  string     `json:"currency,omitempty" bson:"currency,omitempty"`
+	Stock     *int64     `json:"stock,omitempty" bson:"stock,omitempty"`
+	Version   int64      `json:"version,omitempty" bson:"version"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
 
+var ErrOutOfStock = errors.New("not enough pets in stock")
+
 const (
 	PetStatusAvailable string = "available"
 	PetStatusPending   string = "pending"
 	PetStatusSold      string = "sold"
 )
 
+func ValidPetStatus(status string) bool {
+	return status == PetStatusAvailable || status == PetStatusPending || status == PetStatusSold
+}
+
 func NewPet(id int64, category *Category, name string, photoUrls []string, tags []*Tag, status string) *Pet {
 	return &Pet{
 		ID:        id,
@@ -26,6 +47,59 @@ func NewPet(id int64, category *Category, name string, photoUrls []string, tags
 	}
 }
 
+// Validate checks fields of a pet to be stored.
+func (p *Pet) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.Status != "" && !ValidPetStatus(p.Status) {
+		return fmt.Errorf("invalid status %q", p.Status)
+	}
+	if p.Price < 0 {
+		return errors.New("price must not be negative")
+	}
+	if p.Price > 0 && !ValidCurrency(p.Currency) {
+		return fmt.Errorf("invalid currency %q for price", p.Currency)
+	}
+	if p.Stock != nil && *p.Stock < 0 {
+		return errors.New("stock must not be negative")
+	}
+	return nil
+}
+
+// ValidatePetUpdate checks fields a partial update of a pet changes, as far as it can be done without the stored pet.
+func ValidatePetUpdate(u *Update) error {
+	for _, field := range []string{"id", "version", "deletedAt"} {
+		if u.Touches(field) {
+			return fmt.Errorf("%s cannot be changed", field)
+		}
+	}
+	if name, ok := u.Set["name"]; u.Unset["name"] || (ok && name == "") {
+		return errors.New("name is required")
+	}
+	if status, ok := u.Set["status"].(string); ok && status != "" && !ValidPetStatus(status) {
+		return fmt.Errorf("invalid status %q", status)
+	}
+	if price, ok := u.Set["price"].(int64); ok && price < 0 {
+		return errors.New("price must not be negative")
+	}
+	if currency, ok := u.Set["currency"].(string); ok && currency != "" && !ValidCurrency(currency) {
+		return fmt.Errorf("invalid currency %q", currency)
+	}
+	if stock, ok := u.Set["stock"].(*int64); ok && stock != nil && *stock < 0 {
+		return errors.New("stock must not be negative")
+	}
+	return nil
+}
+
+// Units returns number of units available for sale, the stock or one for single pets.
+func (p *Pet) Units() int64 {
+	if p.Stock != nil {
+		return *p.Stock
+	}
+	return 1
+}
+
 func (p *Pet) AddPhotoUrl(url string) {
 	if p.PhotoUrls == nil {
 		p.PhotoUrls = []string{}
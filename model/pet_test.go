@@ -20,3 +20,28 @@ func TestNewPet(t *testing.T) {
 	assert.Equal(t, PetStatusAvailable, p.Status)
 	assert.Equal(t, int64(1), p.Tags[0].ID)
 }
+
+func TestPetValidate(t *testing.T) {
+	p := NewPet(1, nil, "cat 1", []string{}, nil, PetStatusAvailable)
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, int64(1), p.Units())
+
+	p.Status = "lost"
+	assert.Error(t, p.Validate())
+	p.Status = PetStatusSold
+	p.Name = ""
+	assert.Error(t, p.Validate())
+	p.Name = "cat 1"
+
+	p.Price = 1000
+	assert.Error(t, p.Validate())
+	p.Currency = "AUD"
+	assert.NoError(t, p.Validate())
+
+	stock := int64(-1)
+	p.Stock = &stock
+	assert.Error(t, p.Validate())
+	stock = 12
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, int64(12), p.Units())
+}
@@ -0,0 +1,1517 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	"github.com/lib/pq"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PostgresStorage keeps documents in tables of a Postgres database, created by its migrations. Categories and tags
+// are rows of their own, shared by the pets referring to them by id, and keep their names unless a pet gives them
+// other ones. Photo urls and tags of a pet are kept in order. Errors are those of MongoStorage.
+type PostgresStorage struct {
+	db      *sql.DB
+	DSN     string
+	Timeout int64
+	Logger  log.Logger
+
+	// transaction operations take part in, nil outside transactions
+	tx *sql.Tx
+}
+
+func NewPostgresStorage(dsn string, timeout int64, logger log.Logger) (Storage, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return PostgresStorage{
+		db:      db,
+		DSN:     dsn,
+		Timeout: timeout,
+		Logger:  logger,
+	}, nil
+}
+
+// querier runs statements on the database, or within a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanner is a row of a query, one of many or the only one.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (p PostgresStorage) conn() querier {
+	if p.tx != nil {
+		return p.tx
+	}
+	return p.db
+}
+
+// context returns the context of one operation.
+func (p PostgresStorage) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Duration(p.Timeout)*time.Second)
+}
+
+// WithTransaction runs fn with a storage whose operations take part in one transaction, committed when fn
+// returns nil and rolled back otherwise.
+func (p PostgresStorage) WithTransaction(fn func(tx Storage) error) error {
+	return p.withTransaction(func(tx PostgresStorage) error {
+		return fn(tx)
+	})
+}
+
+func (p PostgresStorage) withTransaction(fn func(tx PostgresStorage) error) error {
+	if p.tx != nil {
+		return fn(p)
+	}
+	// the transaction lasts as long as fn takes, its statements are bound to the operation timeout
+	sqlTx, err := p.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	tx := p
+	tx.tx = sqlTx
+	if err := fn(tx); err != nil {
+		if e := sqlTx.Rollback(); e != nil {
+			_ = p.Logger.Log("msg", "rollback transaction", "err", e)
+		}
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// exec runs a statement and returns how many rows it affected.
+func (p PostgresStorage) exec(query string, args ...interface{}) (int64, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	res, err := p.conn().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// eachRow calls fn with each row of query, until fn fails.
+func (p PostgresStorage) eachRow(ctx context.Context, query string, args []interface{}, fn func(row scanner) error) error {
+	rows, err := p.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// notFound translates sql.ErrNoRows into ErrNotFound.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// uniqueMessages are the errors of MongoStorage for duplicates, by unique constraint.
+var uniqueMessages = map[string]string{
+	"pets_pkey":          "duplicate pet id exists",
+	"users_pkey":         "duplicate user id exists",
+	"users_username_key": "duplicate username exists",
+	"orders_pkey":        "duplicate order id exists",
+}
+
+// uniqueViolation translates the violation of a unique constraint into the error MongoStorage returns for the
+// duplicate.
+func uniqueViolation(err error) error {
+	if pe, ok := err.(*pq.Error); ok && pe.Code == "23505" {
+		if message, ok := uniqueMessages[pe.Constraint]; ok {
+			return errors.New(message)
+		}
+	}
+	return err
+}
+
+// expectedVersion is the version argument of conditional statements, null when any version will do.
+func expectedVersion(version int64) interface{} {
+	if version == 0 {
+		return nil
+	}
+	return version
+}
+
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// exists tells whether a row of table has value in column, deleted or not.
+func (p PostgresStorage) exists(table, column string, value interface{}) (bool, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	var found bool
+	err := p.conn().QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE `+column+` = $1)`, value).Scan(&found)
+	return found, err
+}
+
+// notUpdated tells why a conditional statement matched no row of table with value in column, ErrVersionMismatch
+// when one not deleted is at another version and ErrNotFound when there is none.
+func (p PostgresStorage) notUpdated(table, column string, value interface{}, version int64) error {
+	if version == 0 {
+		return ErrNotFound
+	}
+	ctx, cancel := p.context()
+	defer cancel()
+
+	var found bool
+	err := p.conn().QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE `+column+` = $1 AND deleted_at IS NULL)`, value).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
+
+// updateVersioned sets columns of the row of table with value in column, not deleted and at version unless it is
+// 0, and increments its version. Arguments of set are numbered from $3.
+func (p PostgresStorage) updateVersioned(table, column string, value interface{}, version int64, set string, args ...interface{}) error {
+	n, err := p.exec(`UPDATE `+table+` SET `+set+`, version = version + 1
+		WHERE `+column+` = $1 AND deleted_at IS NULL AND version = COALESCE($2, version)`,
+		append([]interface{}{value, expectedVersion(version)}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return p.notUpdated(table, column, value, version)
+	}
+	return nil
+}
+
+// lockVersion locks the row of table with value in column, not deleted, until the end of the transaction and
+// returns its version, which has to be version unless it is 0.
+func (p PostgresStorage) lockVersion(table, column string, value interface{}, version int64) (int64, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	var current int64
+	err := p.conn().QueryRowContext(ctx,
+		`SELECT version FROM `+table+` WHERE `+column+` = $1 AND deleted_at IS NULL FOR UPDATE`, value).Scan(&current)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	if version != 0 && version != current {
+		return 0, ErrVersionMismatch
+	}
+	return current, nil
+}
+
+// deleteRow hides the row of table with value in column by setting its deleted_at, or removes it when hard,
+// deleted or not. ErrNotFound is returned if there is no such row.
+func (p PostgresStorage) deleteRow(table, column string, value interface{}, hard bool) error {
+	var n int64
+	var err error
+	if hard {
+		n, err = p.exec(`DELETE FROM `+table+` WHERE `+column+` = $1`, value)
+	} else {
+		n, err = p.exec(`UPDATE `+table+` SET deleted_at = $2, version = version + 1
+			WHERE `+column+` = $1 AND deleted_at IS NULL`, value, time.Now().UTC())
+	}
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// restoreRow clears deleted_at of the deleted row of table with value in column. ErrNotFound is returned if there
+// is no such deleted row.
+func (p PostgresStorage) restoreRow(table, column string, value interface{}) error {
+	n, err := p.exec(`UPDATE `+table+` SET deleted_at = NULL, version = version + 1
+		WHERE `+column+` = $1 AND deleted_at IS NOT NULL`, value)
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+const userColumns = `id, username, first_name, last_name, email, password, phone, user_status, role, version, deleted_at`
+
+func scanUser(row scanner) (*User, error) {
+	var user User
+	err := row.Scan(&user.ID, &user.Username, &user.Firstname, &user.Lastname, &user.Email, &user.Password,
+		&user.Phone, &user.UserStatus, &user.Role, &user.Version, &user.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	user.DeletedAt = utcTime(user.DeletedAt)
+	return &user, nil
+}
+
+func (p PostgresStorage) insertUser(user *User) error {
+	_, err := p.exec(`INSERT INTO users (`+userColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		user.ID, user.Username, user.Firstname, user.Lastname, user.Email, user.Password, user.Phone,
+		user.UserStatus, user.Role, user.Version, user.DeletedAt)
+	return uniqueViolation(err)
+}
+
+// replaceUser replaces the row of the user with username by user.
+func (p PostgresStorage) replaceUser(username string, user *User) error {
+	_, err := p.exec(`UPDATE users SET id = $2, username = $3, first_name = $4, last_name = $5, email = $6,
+		password = $7, phone = $8, user_status = $9, role = $10, version = $11, deleted_at = $12 WHERE username = $1`,
+		username, user.ID, user.Username, user.Firstname, user.Lastname, user.Email, user.Password, user.Phone,
+		user.UserStatus, user.Role, user.Version, user.DeletedAt)
+	return uniqueViolation(err)
+}
+
+func (p PostgresStorage) retrieveUser(column string, value interface{}) (*User, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	user, err := scanUser(p.conn().QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE `+column+` = $1 AND deleted_at IS NULL`, value))
+	return user, notFound(err)
+}
+
+func (p PostgresStorage) CreateUser(user *User) error {
+	user.Version = 1
+	return p.insertUser(user)
+}
+
+// CreateManyUsers creates all users or none of them, a *BatchError tells which users failed.
+func (p PostgresStorage) CreateManyUsers(users []*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	batch := &BatchError{}
+	ids := map[int64]bool{}
+	usernames := map[string]bool{}
+	for i, u := range users {
+		if u == nil {
+			batch.Add(i, 0, "user is null")
+			continue
+		}
+		if ids[u.ID] {
+			batch.Add(i, u.ID, "duplicate user id in batch")
+			continue
+		}
+		if usernames[u.Username] {
+			batch.Add(i, u.ID, fmt.Sprintf("duplicate username %s in batch", u.Username))
+			continue
+		}
+		ids[u.ID] = true
+		usernames[u.Username] = true
+
+		if found, err := p.exists(CollectionUsers, "id", u.ID); err != nil || found {
+			batch.Add(i, u.ID, duplicateError(err, fmt.Sprintf("duplicate user id exists for %d", u.ID)).Error())
+			continue
+		}
+		if found, err := p.exists(CollectionUsers, "username", u.Username); err != nil || found {
+			batch.Add(i, u.ID, duplicateError(err, fmt.Sprintf("duplicate username exists for %s", u.Username)).Error())
+			continue
+		}
+	}
+	if err := batch.Err(); err != nil {
+		return err
+	}
+
+	return p.withTransaction(func(tx PostgresStorage) error {
+		for i, u := range users {
+			u.Version = 1
+			if err := tx.insertUser(u); err != nil {
+				batch.Add(i, u.ID, err.Error())
+				return batch
+			}
+		}
+		return nil
+	})
+}
+
+func (p PostgresStorage) RetrieveUserByUsername(username string) (*User, error) {
+	return p.retrieveUser("username", username)
+}
+
+func (p PostgresStorage) RetrieveUserByID(id int64) (*User, error) {
+	return p.retrieveUser("id", id)
+}
+
+func (p PostgresStorage) RetrieveUsersByIDs(ids []int64) ([]*User, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	users := []*User{}
+	err := p.eachRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id`,
+		[]interface{}{pq.Array(ids)}, func(row scanner) error {
+			user, err := scanUser(row)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (p PostgresStorage) UpdateUserByUsername(username string, user *User) (*User, error) {
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		current, err := tx.lockVersion(CollectionUsers, "username", username, user.Version)
+		if err != nil {
+			return err
+		}
+		replacement := *user
+		replacement.Version = current + 1
+		replacement.DeletedAt = nil
+		if err = tx.replaceUser(username, &replacement); err != nil {
+			return err
+		}
+		user.Version = replacement.Version
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p PostgresStorage) PatchUserByUsername(username string, version int64, update *Update) (*User, error) {
+	var patched *User
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		if _, err := tx.lockVersion(CollectionUsers, "username", username, version); err != nil {
+			return err
+		}
+		user, err := tx.RetrieveUserByUsername(username)
+		if err != nil {
+			return err
+		}
+		if err = applyUpdate(user, update); err != nil {
+			return err
+		}
+		user.Version++
+		if err = tx.replaceUser(username, user); err != nil {
+			return err
+		}
+		patched = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+func (p PostgresStorage) DeleteUserByUsername(username string, hard bool) error {
+	return p.deleteRow(CollectionUsers, "username", username, hard)
+}
+
+func (p PostgresStorage) RestoreUserByUsername(username string) (*User, error) {
+	var user *User
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		err := tx.restoreRow(CollectionUsers, "username", username)
+		if err != nil {
+			return err
+		}
+		user, err = tx.RetrieveUserByUsername(username)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// petColumns are the columns of a pet and of its category, photo urls and tags read by scanPet, selected from pets
+// p joined with categories c.
+const petColumns = `p.id, c.id, c.name, p.name, p.status, p.price, p.currency, p.stock, p.version, p.deleted_at,
+	ARRAY(SELECT u.url FROM pet_photo_urls u WHERE u.pet_id = p.id ORDER BY u.position),
+	ARRAY(SELECT t.id FROM pet_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.pet_id = p.id ORDER BY pt.position),
+	ARRAY(SELECT t.name FROM pet_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.pet_id = p.id ORDER BY pt.position)
+	FROM pets p LEFT JOIN categories c ON c.id = p.category_id`
+
+func scanPet(row scanner) (*Pet, error) {
+	var pet Pet
+	var categoryID sql.NullInt64
+	var categoryName sql.NullString
+	var photoUrls, tagNames []string
+	var tagIDs []int64
+	err := row.Scan(&pet.ID, &categoryID, &categoryName, &pet.Name, &pet.Status, &pet.Price, &pet.Currency,
+		&pet.Stock, &pet.Version, &pet.DeletedAt, pq.Array(&photoUrls), pq.Array(&tagIDs), pq.Array(&tagNames))
+	if err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		pet.Category = &Category{ID: categoryID.Int64, Name: categoryName.String}
+	}
+	if len(photoUrls) > 0 {
+		pet.PhotoUrls = photoUrls
+	}
+	for i, id := range tagIDs {
+		pet.Tags = append(pet.Tags, &Tag{ID: id, Name: tagNames[i]})
+	}
+	pet.DeletedAt = utcTime(pet.DeletedAt)
+	return &pet, nil
+}
+
+// saveNamed stores the category or tag of id in table, renaming it unless name is empty.
+func (p PostgresStorage) saveNamed(ctx context.Context, table string, id int64, name string) error {
+	_, err := p.conn().ExecContext(ctx, `INSERT INTO `+table+` (id, name) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name WHERE EXCLUDED.name <> ''`, id, name)
+	return err
+}
+
+// savePet inserts the row of pet, or replaces the one with its id when replace, along with its category, photo
+// urls and tags. It is to be run in a transaction.
+func (p PostgresStorage) savePet(pet *Pet, replace bool) error {
+	ctx, cancel := p.context()
+	defer cancel()
+	q := p.conn()
+
+	var categoryID interface{}
+	if pet.Category != nil {
+		if err := p.saveNamed(ctx, CollectionCategories, pet.Category.ID, pet.Category.Name); err != nil {
+			return err
+		}
+		categoryID = pet.Category.ID
+	}
+	var err error
+	if replace {
+		_, err = q.ExecContext(ctx, `UPDATE pets SET category_id = $2, name = $3, status = $4, price = $5,
+			currency = $6, stock = $7, version = $8, deleted_at = $9 WHERE id = $1`,
+			pet.ID, categoryID, pet.Name, pet.Status, pet.Price, pet.Currency, pet.Stock, pet.Version, pet.DeletedAt)
+		if err == nil {
+			_, err = q.ExecContext(ctx, `DELETE FROM pet_photo_urls WHERE pet_id = $1`, pet.ID)
+		}
+		if err == nil {
+			_, err = q.ExecContext(ctx, `DELETE FROM pet_tags WHERE pet_id = $1`, pet.ID)
+		}
+	} else {
+		_, err = q.ExecContext(ctx, `INSERT INTO pets (id, category_id, name, status, price, currency, stock,
+			version, deleted_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			pet.ID, categoryID, pet.Name, pet.Status, pet.Price, pet.Currency, pet.Stock, pet.Version, pet.DeletedAt)
+	}
+	if err != nil {
+		return uniqueViolation(err)
+	}
+
+	if len(pet.PhotoUrls) > 0 {
+		_, err = q.ExecContext(ctx, `INSERT INTO pet_photo_urls (pet_id, position, url)
+			SELECT $1::bigint, u.position, u.url FROM unnest($2::text[]) WITH ORDINALITY AS u (url, position)`,
+			pet.ID, pq.Array(pet.PhotoUrls))
+		if err != nil {
+			return err
+		}
+	}
+	var tagIDs []int64
+	for _, tag := range pet.Tags {
+		if tag == nil {
+			continue
+		}
+		if err = p.saveNamed(ctx, CollectionTags, tag.ID, tag.Name); err != nil {
+			return err
+		}
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	if len(tagIDs) > 0 {
+		_, err = q.ExecContext(ctx, `INSERT INTO pet_tags (pet_id, position, tag_id)
+			SELECT $1::bigint, t.position, t.tag_id FROM unnest($2::bigint[]) WITH ORDINALITY AS t (tag_id, position)`,
+			pet.ID, pq.Array(tagIDs))
+	}
+	return err
+}
+
+func (p PostgresStorage) CreatePet(pet *Pet) error {
+	pet.Version = 1
+	return p.withTransaction(func(tx PostgresStorage) error {
+		return tx.savePet(pet, false)
+	})
+}
+
+// CreateManyPets creates all pets or none of them, a *BatchError tells which pets failed.
+func (p PostgresStorage) CreateManyPets(pets []*Pet) error {
+	if len(pets) == 0 {
+		return nil
+	}
+
+	batch := &BatchError{}
+	ids := map[int64]bool{}
+	for i, pet := range pets {
+		if pet == nil {
+			batch.Add(i, 0, "pet is null")
+			continue
+		}
+		if ids[pet.ID] {
+			batch.Add(i, pet.ID, "duplicate pet id in batch")
+			continue
+		}
+		ids[pet.ID] = true
+
+		if found, err := p.exists(CollectionPets, "id", pet.ID); err != nil || found {
+			batch.Add(i, pet.ID, duplicateError(err, fmt.Sprintf("duplicate pet id exists for %d", pet.ID)).Error())
+			continue
+		}
+	}
+	if err := batch.Err(); err != nil {
+		return err
+	}
+
+	return p.withTransaction(func(tx PostgresStorage) error {
+		for i, pet := range pets {
+			pet.Version = 1
+			if err := tx.savePet(pet, false); err != nil {
+				batch.Add(i, pet.ID, err.Error())
+				return batch
+			}
+		}
+		return nil
+	})
+}
+
+func (p PostgresStorage) UpdatePetByID(pet *Pet) error {
+	return p.withTransaction(func(tx PostgresStorage) error {
+		current, err := tx.lockVersion(CollectionPets, "id", pet.ID, pet.Version)
+		if err != nil {
+			return err
+		}
+		replacement := *pet
+		replacement.Version = current + 1
+		replacement.DeletedAt = nil
+		if err = tx.savePet(&replacement, true); err != nil {
+			return err
+		}
+		pet.Version = replacement.Version
+		return nil
+	})
+}
+
+func (p PostgresStorage) RetrievePetByID(id int64) (*Pet, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	pet, err := scanPet(p.conn().QueryRowContext(ctx,
+		`SELECT `+petColumns+` WHERE p.id = $1 AND p.deleted_at IS NULL`, id))
+	return pet, notFound(err)
+}
+
+func (p PostgresStorage) RetrievePetsByIDs(ids []int64) ([]*Pet, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	pets := []*Pet{}
+	err := p.eachRow(ctx, `SELECT `+petColumns+` WHERE p.id = ANY($1) AND p.deleted_at IS NULL ORDER BY p.id`,
+		[]interface{}{pq.Array(ids)}, func(row scanner) error {
+			pet, err := scanPet(row)
+			if err != nil {
+				return err
+			}
+			pets = append(pets, pet)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return pets, nil
+}
+
+func (p PostgresStorage) FindPetsByStatus(statuses []string) ([]*Pet, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	var pets []*Pet
+	err := p.eachRow(ctx, `SELECT `+petColumns+` WHERE p.status = ANY($1) AND p.deleted_at IS NULL`,
+		[]interface{}{pq.Array(statuses)}, func(row scanner) error {
+			pet, err := scanPet(row)
+			if err != nil {
+				return err
+			}
+			pets = append(pets, pet)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return pets, nil
+}
+
+func (p PostgresStorage) StreamPetsByStatus(statuses []string, fn func(pet *Pet) error) error {
+	// the stream lasts as long as it takes fn to take all pets, no operation timeout
+	return p.eachRow(context.Background(),
+		`SELECT `+petColumns+` WHERE p.status = ANY($1) AND p.deleted_at IS NULL ORDER BY p.id`,
+		[]interface{}{pq.Array(statuses)}, func(row scanner) error {
+			pet, err := scanPet(row)
+			if err != nil {
+				return err
+			}
+			return fn(pet)
+		})
+}
+
+// SearchPets ranks all pets matching statuses in the process.
+func (p PostgresStorage) SearchPets(search PetSearch) ([]*PetSearchResult, int64, error) {
+	if len(search.Terms()) == 0 {
+		return []*PetSearchResult{}, 0, nil
+	}
+	ctx, cancel := p.context()
+	defer cancel()
+
+	query := `SELECT ` + petColumns + ` WHERE p.deleted_at IS NULL`
+	var args []interface{}
+	if len(search.Statuses) > 0 {
+		query += ` AND p.status = ANY($1)`
+		args = append(args, pq.Array(search.Statuses))
+	}
+	var pets []*Pet
+	err := p.eachRow(ctx, query, args, func(row scanner) error {
+		pet, err := scanPet(row)
+		if err != nil {
+			return err
+		}
+		pets = append(pets, pet)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	results, total := SearchPets(pets, search)
+	return results, total, nil
+}
+
+func (p PostgresStorage) PatchPetByID(id int64, version int64, update *Update) (*Pet, error) {
+	var patched *Pet
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		if _, err := tx.lockVersion(CollectionPets, "id", id, version); err != nil {
+			return err
+		}
+		pet, err := tx.RetrievePetByID(id)
+		if err != nil {
+			return err
+		}
+		if err = applyUpdate(pet, update); err != nil {
+			return err
+		}
+		pet.ID = id
+		pet.Version++
+		if err = tx.savePet(pet, true); err != nil {
+			return err
+		}
+		// categories and tags keep their names unless given
+		patched, err = tx.RetrievePetByID(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+func (p PostgresStorage) UpdatePetNameAndStatusByID(id int64, version int64, name string, status string) error {
+	return p.updateVersioned(CollectionPets, "id", id, version, `name = $3, status = $4`, name, status)
+}
+
+func (p PostgresStorage) UpdatePetNameByID(id int64, version int64, name string) error {
+	return p.updateVersioned(CollectionPets, "id", id, version, `name = $3`, name)
+}
+
+func (p PostgresStorage) UpdatePetStatusByID(id int64, version int64, status string) error {
+	return p.updateVersioned(CollectionPets, "id", id, version, `status = $3`, status)
+}
+
+func (p PostgresStorage) AddImageUrlByPetID(id int64, url string) (*Pet, error) {
+	var pet *Pet
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		err := tx.updateVersioned(CollectionPets, "id", id, 0, `name = name`)
+		if err != nil {
+			return err
+		}
+		// added once, at the end, like to a set
+		_, err = tx.exec(`INSERT INTO pet_photo_urls (pet_id, position, url)
+			SELECT $1::bigint, COALESCE(MAX(position), 0) + 1, $2::text FROM pet_photo_urls WHERE pet_id = $1::bigint
+			HAVING NOT COALESCE(bool_or(url = $2::text), false)`, id, url)
+		if err != nil {
+			return err
+		}
+		pet, err = tx.RetrievePetByID(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pet, nil
+}
+
+func (p PostgresStorage) RetrieveStoreInventoriesByStatus() (map[string]int64, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	inv := map[string]int64{}
+	err := p.eachRow(ctx, `SELECT status, SUM(COALESCE(stock, 1)) FROM pets WHERE deleted_at IS NULL GROUP BY status`,
+		nil, func(row scanner) error {
+			var status string
+			var units int64
+			if err := row.Scan(&status, &units); err != nil {
+				return err
+			}
+			inv[status] = units
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (p PostgresStorage) RetrieveStoreStockValue() (StockValue, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	value := StockValue{}
+	err := p.eachRow(ctx, `SELECT price, currency, stock FROM pets
+		WHERE status = $1 AND price > 0 AND deleted_at IS NULL`, []interface{}{PetStatusAvailable}, func(row scanner) error {
+		pet := Pet{}
+		if err := row.Scan(&pet.Price, &pet.Currency, &pet.Stock); err != nil {
+			return err
+		}
+		amount, err := MultiplyAmount(pet.Price, pet.Units())
+		if err != nil {
+			return err
+		}
+		return value.Add(pet.Currency, amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (p PostgresStorage) ReserveStockByPetID(id int64, quantity int64) (*Pet, error) {
+	var reserved *Pet
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		pet, err := tx.RetrievePetByID(id)
+		if err != nil {
+			return err
+		}
+		var n int64
+		if pet.Stock == nil {
+			if quantity > 1 {
+				return ErrOutOfStock
+			}
+			// single pets are taken by moving them from available to pending
+			n, err = tx.exec(`UPDATE pets SET status = $2, version = version + 1
+				WHERE id = $1 AND stock IS NULL AND status = $3 AND deleted_at IS NULL`,
+				id, PetStatusPending, PetStatusAvailable)
+		} else {
+			n, err = tx.exec(`UPDATE pets SET stock = stock - $2, version = version + 1
+				WHERE id = $1 AND stock >= $2 AND deleted_at IS NULL`, id, quantity)
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrOutOfStock
+		}
+		reserved, err = tx.RetrievePetByID(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reserved, nil
+}
+
+func (p PostgresStorage) ReleaseStockByPetID(id int64, quantity int64) error {
+	_, err := p.exec(`UPDATE pets SET stock = stock + $2, version = version + 1 WHERE id = $1 AND stock IS NOT NULL`,
+		id, quantity)
+	if err != nil {
+		return err
+	}
+	_, err = p.exec(`UPDATE pets SET status = $2, version = version + 1 WHERE id = $1 AND stock IS NULL AND status = $3`,
+		id, PetStatusAvailable, PetStatusPending)
+	return err
+}
+
+func (p PostgresStorage) DeletePetByID(id int64, hard bool) error {
+	return p.deleteRow(CollectionPets, "id", id, hard)
+}
+
+func (p PostgresStorage) RestorePetByID(id int64) (*Pet, error) {
+	var pet *Pet
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		err := tx.restoreRow(CollectionPets, "id", id)
+		if err != nil {
+			return err
+		}
+		pet, err = tx.RetrievePetByID(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pet, nil
+}
+
+const orderColumns = `id, pet_id, quantity, ship_date, status, complete, user_id, unit_price, currency, total, version,
+	deleted_at, pet_held`
+
+func scanOrder(row scanner) (*Order, error) {
+	var order Order
+	err := row.Scan(&order.ID, &order.PetID, &order.Quantity, &order.ShipDate, &order.Status, &order.Complete,
+		&order.UserID, &order.UnitPrice, &order.Currency, &order.Total, &order.Version, &order.DeletedAt,
+		&order.PetHeld)
+	if err != nil {
+		return nil, err
+	}
+	order.ShipDate = order.ShipDate.UTC()
+	order.DeletedAt = utcTime(order.DeletedAt)
+	return &order, nil
+}
+
+func (p PostgresStorage) insertOrder(order *Order) error {
+	_, err := p.exec(`INSERT INTO orders (`+orderColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		order.ID, order.PetID, order.Quantity, order.ShipDate, order.Status, order.Complete, order.UserID,
+		order.UnitPrice, order.Currency, order.Total, order.Version, order.DeletedAt, order.PetHeld)
+	return uniqueViolation(err)
+}
+
+// queryOrders returns the orders selected by the conditions of query.
+func (p PostgresStorage) queryOrders(query string, args ...interface{}) ([]*Order, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	orders := []*Order{}
+	err := p.eachRow(ctx, `SELECT `+orderColumns+` FROM orders `+query, args, func(row scanner) error {
+		order, err := scanOrder(row)
+		if err != nil {
+			return err
+		}
+		orders = append(orders, order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (p PostgresStorage) CreateOrder(order *Order) (*Order, error) {
+	order.Version = 1
+	if err := p.insertOrder(order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (p PostgresStorage) RetrieveOrderByID(id int64) (*Order, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	order, err := scanOrder(p.conn().QueryRowContext(ctx,
+		`SELECT `+orderColumns+` FROM orders WHERE id = $1 AND deleted_at IS NULL`, id))
+	return order, notFound(err)
+}
+
+func (p PostgresStorage) DeleteOrderByID(id int64, hard bool) error {
+	return p.deleteRow(CollectionOrders, "id", id, hard)
+}
+
+func (p PostgresStorage) RestoreOrderByID(id int64) (*Order, error) {
+	var order *Order
+	err := p.withTransaction(func(tx PostgresStorage) error {
+		err := tx.restoreRow(CollectionOrders, "id", id)
+		if err != nil {
+			return err
+		}
+		order, err = tx.RetrieveOrderByID(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (p PostgresStorage) FindOrders(filter OrderFilter) ([]*Order, int64, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID))
+	}
+	if filter.PetID != 0 {
+		conditions = append(conditions, "pet_id = "+arg(filter.PetID))
+	}
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(pq.Array(filter.Statuses))+")")
+	}
+	if !filter.ShipDateFrom.IsZero() {
+		conditions = append(conditions, "ship_date >= "+arg(filter.ShipDateFrom))
+	}
+	if !filter.ShipDateTo.IsZero() {
+		conditions = append(conditions, "ship_date < "+arg(filter.ShipDateTo))
+	}
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	ctx, cancel := p.context()
+	defer cancel()
+	var total int64
+	err := p.conn().QueryRowContext(ctx, `SELECT COUNT(*) FROM orders `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	orders, err := p.queryOrders(where+" ORDER BY ship_date DESC, id OFFSET "+arg(filter.Offset)+
+		" LIMIT "+arg(filter.PageSize()), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (p PostgresStorage) FindOrdersByUserIDs(userIDs []int64) ([]*Order, error) {
+	return p.queryOrders(`WHERE user_id = ANY($1) AND deleted_at IS NULL ORDER BY ship_date DESC, id`,
+		pq.Array(userIDs))
+}
+
+func (p PostgresStorage) UpdateOrderStatusByID(id int64, version int64, status string) (*Order, error) {
+	err := p.updateVersioned(CollectionOrders, "id", id, version, `status = $3`, status)
+	if err != nil {
+		return nil, err
+	}
+	return p.RetrieveOrderByID(id)
+}
+
+// Pets and orders of events are kept as JSON documents.
+func (p PostgresStorage) AppendEvent(event *Event) error {
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+	var pet, order interface{}
+	if event.Pet != nil {
+		b, err := json.Marshal(event.Pet)
+		if err != nil {
+			return err
+		}
+		pet = string(b)
+	}
+	if event.Order != nil {
+		b, err := json.Marshal(event.Order)
+		if err != nil {
+			return err
+		}
+		order = string(b)
+	}
+	// appenders take the lock until their transaction ends before taking an id, so that transactions commit in the
+	// order of their ids and readers of the outbox never see an event before those with lower ids
+	return p.withTransaction(func(tx PostgresStorage) error {
+		if _, err := tx.exec(`SELECT pg_advisory_xact_lock($1)`, postgresEventsLock); err != nil {
+			return err
+		}
+		ctx, cancel := tx.context()
+		defer cancel()
+
+		return tx.conn().QueryRowContext(ctx, `INSERT INTO events (type, at, entity, entity_id, previous_status, pet,
+			placed_order) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			event.Type, event.At, event.Entity, event.EntityID, event.PreviousStatus, pet, order).Scan(&event.ID)
+	})
+}
+
+func (p PostgresStorage) FindEventsAfter(after int64, limit int64) ([]*Event, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	events := []*Event{}
+	err := p.eachRow(ctx, `SELECT id, type, at, entity, entity_id, previous_status, pet, placed_order FROM events
+		WHERE id > $1 ORDER BY id LIMIT $2`, []interface{}{after, limit}, func(row scanner) error {
+		var event Event
+		var pet, order []byte
+		err := row.Scan(&event.ID, &event.Type, &event.At, &event.Entity, &event.EntityID, &event.PreviousStatus,
+			&pet, &order)
+		if err != nil {
+			return err
+		}
+		event.At = event.At.UTC()
+		if pet != nil {
+			if err = json.Unmarshal(pet, &event.Pet); err != nil {
+				return err
+			}
+		}
+		if order != nil {
+			if err = json.Unmarshal(order, &event.Order); err != nil {
+				return err
+			}
+		}
+		events = append(events, &event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (p PostgresStorage) RetrieveLoginAttemptsByUsername(username string) (*LoginAttempts, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	var attempts LoginAttempts
+	err := p.conn().QueryRowContext(ctx,
+		`SELECT username, failures, last_failure_at FROM login_attempts WHERE username = $1`, username).
+		Scan(&attempts.Username, &attempts.Failures, &attempts.LastFailureAt)
+	if err == sql.ErrNoRows {
+		return &LoginAttempts{Username: username}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	attempts.LastFailureAt = attempts.LastFailureAt.UTC()
+	return &attempts, nil
+}
+
+func (p PostgresStorage) ReplaceLoginAttempts(seen *LoginAttempts, attempts *LoginAttempts) error {
+	var n int64
+	var err error
+	if seen.Failures == 0 {
+		n, err = p.exec(`INSERT INTO login_attempts (username, failures, last_failure_at) VALUES ($1, $2, $3)
+			ON CONFLICT (username) DO NOTHING`, attempts.Username, attempts.Failures, attempts.LastFailureAt)
+	} else {
+		n, err = p.exec(`UPDATE login_attempts SET failures = $2, last_failure_at = $3
+			WHERE username = $1 AND failures = $4 AND last_failure_at = $5`,
+			attempts.Username, attempts.Failures, attempts.LastFailureAt, seen.Failures, seen.LastFailureAt)
+	}
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+func (p PostgresStorage) DeleteLoginAttemptsByUsername(username string) error {
+	_, err := p.exec(`DELETE FROM login_attempts WHERE username = $1`, username)
+	return err
+}
+
+// CreateSession deletes expired sessions along, as there is no index expiring them.
+func (p PostgresStorage) CreateSession(session *Session) error {
+	_, err := p.exec(`DELETE FROM sessions WHERE expires_at <= $1`, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	_, err = p.exec(`INSERT INTO sessions (token, user_id, username, expires_at) VALUES ($1, $2, $3, $4)`,
+		session.Token, session.UserID, session.Username, session.ExpiresAt)
+	return err
+}
+
+func (p PostgresStorage) RetrieveSessionByToken(token string) (*Session, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	var session Session
+	err := p.conn().QueryRowContext(ctx,
+		`SELECT token, user_id, username, expires_at FROM sessions WHERE token = $1`, token).
+		Scan(&session.Token, &session.UserID, &session.Username, &session.ExpiresAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	return &session, nil
+}
+
+func (p PostgresStorage) DeleteSessionByToken(token string) error {
+	_, err := p.exec(`DELETE FROM sessions WHERE token = $1`, token)
+	return err
+}
+
+func (p PostgresStorage) ExportCollection(collection string, fn func(doc interface{}) error) error {
+	if _, err := NewDocument(collection); err != nil {
+		return err
+	}
+	var query string
+	var scan func(row scanner) (interface{}, error)
+	switch collection {
+	case CollectionPets:
+		query = `SELECT ` + petColumns + ` ORDER BY p.id`
+		scan = func(row scanner) (interface{}, error) { return scanPet(row) }
+	case CollectionUsers:
+		query = `SELECT ` + userColumns + ` FROM users ORDER BY id`
+		scan = func(row scanner) (interface{}, error) { return scanUser(row) }
+	case CollectionOrders:
+		query = `SELECT ` + orderColumns + ` FROM orders ORDER BY id`
+		scan = func(row scanner) (interface{}, error) { return scanOrder(row) }
+	default:
+		query = `SELECT id, name FROM ` + collection + ` ORDER BY id`
+		scan = func(row scanner) (interface{}, error) {
+			var id int64
+			var name string
+			if err := row.Scan(&id, &name); err != nil {
+				return nil, err
+			}
+			if collection == CollectionTags {
+				return NewTag(id, name), nil
+			}
+			return NewCategory(id, name), nil
+		}
+	}
+	// the export lasts as long as it takes to write all documents, no operation timeout
+	return p.eachRow(context.Background(), query, nil, func(row scanner) error {
+		doc, err := scan(row)
+		if err != nil {
+			return err
+		}
+		return fn(doc)
+	})
+}
+
+// ImportDocument replaces pets, users and orders with their rows, photo urls and tags of pets included. Categories
+// and tags are renamed in place, pets keep referring to them.
+func (p PostgresStorage) ImportDocument(collection string, doc interface{}, mode ConflictMode, dryRun bool) (ImportOutcome, error) {
+	id, err := DocumentID(doc)
+	if err != nil {
+		return "", err
+	}
+	if _, err = NewDocument(collection); err != nil {
+		return "", err
+	}
+
+	if u, ok := doc.(*User); ok {
+		ctx, cancel := p.context()
+		var found bool
+		err := p.conn().QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE username = $1 AND id <> $2)`,
+			u.Username, u.ID).Scan(&found)
+		if err != nil || found {
+			cancel()
+			return "", duplicateError(err, fmt.Sprintf("duplicate username exists for %s", u.Username))
+		}
+		var stored string
+		err = p.conn().QueryRowContext(ctx, `SELECT password FROM users WHERE id = $1`, u.ID).Scan(&stored)
+		cancel()
+		if err != nil && err != sql.ErrNoRows {
+			return "", err
+		}
+		if err = importPassword(u, stored); err != nil {
+			return "", err
+		}
+	}
+
+	found, err := p.exists(collection, "id", id)
+	if err != nil {
+		return "", err
+	}
+	outcome := ImportInserted
+	if found {
+		if mode == ConflictSkip {
+			return ImportSkipped, nil
+		}
+		outcome = ImportReplaced
+	}
+	if dryRun {
+		return outcome, nil
+	}
+
+	err = p.withTransaction(func(tx PostgresStorage) error {
+		ctx, cancel := tx.context()
+		defer cancel()
+
+		switch d := doc.(type) {
+		case *Category:
+			_, err := tx.conn().ExecContext(ctx, `INSERT INTO categories (id, name) VALUES ($1, $2)
+				ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name`, d.ID, d.Name)
+			return err
+		case *Tag:
+			_, err := tx.conn().ExecContext(ctx, `INSERT INTO tags (id, name) VALUES ($1, $2)
+				ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name`, d.ID, d.Name)
+			return err
+		}
+		if found {
+			if _, err := tx.conn().ExecContext(ctx, `DELETE FROM `+collection+` WHERE id = $1`, id); err != nil {
+				return err
+			}
+		}
+		// documents exported before versioning start at the first version
+		switch d := doc.(type) {
+		case *Pet:
+			if d.Version == 0 {
+				d.Version = 1
+			}
+			return tx.savePet(d, false)
+		case *User:
+			if d.Version == 0 {
+				d.Version = 1
+			}
+			return tx.insertUser(d)
+		case *Order:
+			if d.Version == 0 {
+				d.Version = 1
+			}
+			return tx.insertOrder(d)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return outcome, nil
+}
+
+func (p PostgresStorage) PurgeDeleted(collection string, before time.Time) (int64, error) {
+	switch collection {
+	case CollectionPets, CollectionUsers, CollectionOrders:
+	default:
+		return 0, fmt.Errorf("%s has no deleted documents", collection)
+	}
+	return p.exec(`DELETE FROM `+collection+` WHERE deleted_at < $1`, before)
+}
+
+// postgresTables are the tables emptied by EmptyCollection, by collection.
+var postgresTables = map[string]bool{
+	CollectionPets:             true,
+	CollectionUsers:            true,
+	CollectionOrders:           true,
+	CollectionCategories:       true,
+	CollectionTags:             true,
+	CollectionLoginAttempts:    true,
+	CollectionSessions:         true,
+	CollectionEvents:           true,
+	CollectionSchemaMigrations: true,
+}
+
+// EmptyCollection deletes all rows of the table of collection, photo urls and tags of pets along with them.
+func (p PostgresStorage) EmptyCollection(collection string) error {
+	if !postgresTables[collection] {
+		return fmt.Errorf("unknown collection %s", collection)
+	}
+	_, err := p.exec(`DELETE FROM ` + collection)
+	return err
+}
+
+// lockMigrations takes the advisory lock of migrations on a connection of its own, waiting up to wait for the
+// process holding it, and returns the function releasing it. The lock is released by the server when the
+// connection is lost.
+func (p PostgresStorage) lockMigrations(wait time.Duration) (func(), error) {
+	ctx, cancel := p.context()
+	conn, err := p.db.Conn(ctx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		var locked bool
+		ctx, cancel := p.context()
+		err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, postgresMigrationsLock).Scan(&locked)
+		cancel()
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			_ = conn.Close()
+			return nil, ErrMigrationLocked
+		}
+		time.Sleep(time.Second)
+	}
+	return func() {
+		ctx, cancel := p.context()
+		defer cancel()
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, postgresMigrationsLock)
+		if err != nil {
+			_ = p.Logger.Log("msg", "release migrations lock", "err", err)
+		}
+		_ = conn.Close()
+	}, nil
+}
+
+func (p PostgresStorage) appliedMigrations() ([]AppliedMigration, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	// nothing is applied before the table is created by the first migration run
+	var created bool
+	err := p.conn().QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&created)
+	if err != nil || !created {
+		return nil, err
+	}
+	var applied []AppliedMigration
+	err = p.eachRow(ctx, `SELECT version, description, applied_at FROM schema_migrations ORDER BY version`, nil,
+		func(row scanner) error {
+			var a AppliedMigration
+			if err := row.Scan(&a.Version, &a.Description, &a.AppliedAt); err != nil {
+				return err
+			}
+			a.AppliedAt = a.AppliedAt.UTC()
+			applied = append(applied, a)
+			return nil
+		})
+	return applied, err
+}
+
+func (p PostgresStorage) appliedVersions() (map[int64]bool, error) {
+	applied, err := p.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	versions := map[int64]bool{}
+	for _, a := range applied {
+		versions[a.Version] = true
+	}
+	return versions, nil
+}
+
+func (p PostgresStorage) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := p.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]AppliedMigration{}
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	var status []MigrationStatus
+	for _, mig := range postgresMigrations {
+		s := MigrationStatus{Version: mig.Version, Description: mig.Description}
+		if a, ok := byVersion[mig.Version]; ok {
+			s.AppliedAt = &a.AppliedAt
+			delete(byVersion, mig.Version)
+		}
+		status = append(status, s)
+	}
+	for _, a := range applied {
+		if _, ok := byVersion[a.Version]; ok {
+			appliedAt := a.AppliedAt
+			status = append(status, MigrationStatus{
+				Version: a.Version, Description: a.Description, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+// MigrateUp applies pending migrations in order while holding the migrations lock, and returns the ones
+// applied. It stops at the first failing migration. Migrations returned have no Up and Down, their statements
+// are not for a mongo database.
+func (p PostgresStorage) MigrateUp(to int64) ([]Migration, error) {
+	unlock, err := p.lockMigrations(migrationLockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	_, err = p.exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := p.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range postgresMigrations {
+		if to > 0 && mig.Version > to {
+			break
+		}
+		if versions[mig.Version] {
+			continue
+		}
+		_ = p.Logger.Log("msg", "apply migration", "version", mig.Version, "description", mig.Description)
+		err := p.withTransaction(func(tx PostgresStorage) error {
+			// migrations are not bound to the operation timeout, changing a large table takes a while
+			if _, err := tx.tx.Exec(mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES ($1, $2, $3)`,
+				mig.Version, mig.Description, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d: %v", mig.Version, err)
+		}
+		done = append(done, Migration{Version: mig.Version, Description: mig.Description})
+	}
+	return done, nil
+}
+
+// MigrateDown reverts applied migrations newer than to, latest first, while holding the migrations lock, and
+// returns the ones reverted. It stops at the first failing migration.
+func (p PostgresStorage) MigrateDown(to int64) ([]Migration, error) {
+	unlock, err := p.lockMigrations(migrationLockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	versions, err := p.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]postgresMigration{}
+	for _, mig := range postgresMigrations {
+		byVersion[mig.Version] = mig
+	}
+	var revert []postgresMigration
+	for v := range versions {
+		if v <= to {
+			continue
+		}
+		mig, ok := byVersion[v]
+		if !ok {
+			return nil, fmt.Errorf("migration %d is not known to this release", v)
+		}
+		revert = append(revert, mig)
+	}
+	sort.Slice(revert, func(i, j int) bool { return revert[i].Version > revert[j].Version })
+
+	var done []Migration
+	for _, mig := range revert {
+		_ = p.Logger.Log("msg", "revert migration", "version", mig.Version, "description", mig.Description)
+		err := p.withTransaction(func(tx PostgresStorage) error {
+			if _, err := tx.tx.Exec(mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.exec(`DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d: %v", mig.Version, err)
+		}
+		done = append(done, Migration{Version: mig.Version, Description: mig.Description})
+	}
+	return done, nil
+}
@@ -0,0 +1,137 @@
+package model
+
+// postgresMigration changes the schema of a Postgres database from Version-1 to Version with the statements of Up,
+// those of Down undo it. Each of them runs in a transaction of its own along with its record in schema_migrations.
+type postgresMigration struct {
+	Version     int64
+	Description string
+	Up          string
+	Down        string
+}
+
+// postgresMigrationsLock is the key of the advisory lock held by the process running migrations.
+const postgresMigrationsLock int64 = 0x70657473746f7265
+
+// postgresEventsLock is the key of the advisory lock held by transactions appending events until they end.
+const postgresEventsLock int64 = 0x70657473746f7266
+
+// postgresMigrations are the migrations of PostgresStorage by version. Tables are named after the collections
+// of MongoStorage, columns after the fields of documents in snake case.
+var postgresMigrations = []postgresMigration{
+	{
+		Version:     1,
+		Description: "create pets with their categories, tags and photo urls",
+		Up: `
+CREATE TABLE categories (
+	id BIGINT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+CREATE TABLE tags (
+	id BIGINT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+CREATE TABLE pets (
+	id BIGINT PRIMARY KEY,
+	category_id BIGINT REFERENCES categories (id) ON DELETE SET NULL,
+	name TEXT NOT NULL,
+	status TEXT NOT NULL,
+	price BIGINT NOT NULL,
+	currency TEXT NOT NULL,
+	stock BIGINT,
+	version BIGINT NOT NULL,
+	deleted_at TIMESTAMPTZ
+);
+CREATE INDEX pets_status ON pets (status);
+CREATE TABLE pet_photo_urls (
+	pet_id BIGINT NOT NULL REFERENCES pets (id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	PRIMARY KEY (pet_id, position)
+);
+CREATE TABLE pet_tags (
+	pet_id BIGINT NOT NULL REFERENCES pets (id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (pet_id, position)
+);
+CREATE INDEX pet_tags_tag_id ON pet_tags (tag_id);`,
+		Down: `DROP TABLE pet_tags, pet_photo_urls, pets, tags, categories;`,
+	},
+	{
+		Version:     2,
+		Description: "create users, unique by username",
+		Up: `
+CREATE TABLE users (
+	id BIGINT PRIMARY KEY,
+	username TEXT NOT NULL UNIQUE,
+	first_name TEXT NOT NULL,
+	last_name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	password TEXT NOT NULL,
+	phone TEXT NOT NULL,
+	user_status INTEGER NOT NULL,
+	role TEXT NOT NULL,
+	version BIGINT NOT NULL,
+	deleted_at TIMESTAMPTZ
+);`,
+		Down: `DROP TABLE users;`,
+	},
+	{
+		Version:     3,
+		Description: "create orders indexed by user, pet, status and ship date",
+		Up: `
+CREATE TABLE orders (
+	id BIGINT PRIMARY KEY,
+	pet_id BIGINT NOT NULL,
+	quantity INTEGER NOT NULL,
+	ship_date TIMESTAMPTZ NOT NULL,
+	status TEXT NOT NULL,
+	complete BOOLEAN NOT NULL,
+	user_id BIGINT NOT NULL,
+	unit_price BIGINT NOT NULL,
+	currency TEXT NOT NULL,
+	total BIGINT NOT NULL,
+	version BIGINT NOT NULL,
+	deleted_at TIMESTAMPTZ
+);
+CREATE INDEX orders_user_id_ship_date ON orders (user_id, ship_date DESC);
+CREATE INDEX orders_pet_id_ship_date ON orders (pet_id, ship_date DESC);
+CREATE INDEX orders_status_ship_date ON orders (status, ship_date DESC);
+CREATE INDEX orders_ship_date ON orders (ship_date DESC);`,
+		Down: `DROP TABLE orders;`,
+	},
+	{
+		Version:     4,
+		Description: "create outbox of events, login attempts and sessions",
+		Up: `
+CREATE TABLE events (
+	id BIGSERIAL PRIMARY KEY,
+	type TEXT NOT NULL,
+	at TIMESTAMPTZ NOT NULL,
+	entity TEXT NOT NULL,
+	entity_id BIGINT NOT NULL,
+	previous_status TEXT NOT NULL,
+	pet JSONB,
+	placed_order JSONB
+);
+CREATE TABLE login_attempts (
+	username TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failure_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE sessions (
+	token TEXT PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	username TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX sessions_expires_at ON sessions (expires_at);`,
+		Down: `DROP TABLE sessions, login_attempts, events;`,
+	},
+	{
+		Version:     5,
+		Description: "record orders holding their single pet",
+		Up:          `ALTER TABLE orders ADD COLUMN pet_held BOOLEAN NOT NULL DEFAULT false;`,
+		Down:        `ALTER TABLE orders DROP COLUMN pet_held;`,
+	},
+}
@@ -0,0 +1,399 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newPostgresStorage returns a storage of the database at PETSTORE_POSTGRES_DSN, migrated and emptied, and skips
+// the test when it is not set.
+func newPostgresStorage(t *testing.T) PostgresStorage {
+	dsn := os.Getenv("PETSTORE_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("PETSTORE_POSTGRES_DSN not set")
+	}
+	db, err := NewPostgresStorage(dsn, 10, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := db.(PostgresStorage)
+	if _, err = p.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{CollectionOrders, CollectionPets, CollectionUsers, CollectionCategories, CollectionTags,
+		CollectionEvents, CollectionLoginAttempts, CollectionSessions} {
+		if err = p.EmptyCollection(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestPostgresStorageUserActions(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	assert.NoError(t, p.CreateUser(&User{ID: 1, Username: "username", Email: "email@email.com", Password: "password"}))
+	assert.EqualError(t, p.CreateUser(&User{ID: 1, Username: "other"}), "duplicate user id exists")
+	assert.EqualError(t, p.CreateUser(&User{ID: 2, Username: "username"}), "duplicate username exists")
+
+	u, err := p.RetrieveUserByUsername("username")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), u.ID)
+	assert.Equal(t, int64(1), u.Version)
+
+	u.ID = 10
+	u.Username = "username-1"
+	u.Phone = "456"
+	u2, err := p.UpdateUserByUsername("username", u)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), u2.Version)
+	u2, err = p.RetrieveUserByID(10)
+	assert.NoError(t, err)
+	assert.Equal(t, "username-1", u2.Username)
+	assert.Equal(t, "456", u2.Phone)
+	u.Version = 1
+	_, err = p.UpdateUserByUsername("username-1", u)
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	// a batch with one existing user is refused as a whole
+	err = p.CreateManyUsers([]*User{{ID: 3, Username: "carol"}, {ID: 4, Username: "username-1"}})
+	assert.IsType(t, &BatchError{}, err)
+	assert.Equal(t, 1, err.(*BatchError).Items[0].Index)
+	_, err = p.RetrieveUserByID(3)
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, p.DeleteUserByUsername("username-1", false))
+	_, err = p.RetrieveUserByUsername("username-1")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, p.DeleteUserByUsername("username-1", false))
+	assert.Error(t, p.CreateUser(&User{ID: 10, Username: "username-2"}))
+	u2, err = p.RestoreUserByUsername("username-1")
+	assert.NoError(t, err)
+	assert.Nil(t, u2.DeletedAt)
+	_, err = p.RestoreUserByUsername("username-1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, p.DeleteUserByUsername("username-1", true))
+	assert.Equal(t, ErrNotFound, p.DeleteUserByUsername("username-1", true))
+}
+
+func TestPostgresStoragePetActions(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	pet := Pet{
+		ID:        1,
+		Name:      "cat1",
+		Status:    PetStatusAvailable,
+		Category:  NewCategory(1, "cat"),
+		PhotoUrls: []string{"a", "b"},
+		Tags:      []*Tag{NewTag(2, "tabby"), NewTag(1, "small")},
+	}
+	assert.NoError(t, p.CreatePet(&pet))
+	assert.Equal(t, int64(1), pet.Version)
+	assert.EqualError(t, p.CreatePet(&pet), "duplicate pet id exists")
+
+	got, err := p.RetrievePetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, pet.Category, got.Category)
+	assert.Equal(t, pet.PhotoUrls, got.PhotoUrls)
+	assert.Equal(t, pet.Tags, got.Tags)
+	assert.Nil(t, got.Stock)
+
+	pet.Name = "cat2"
+	pet.Tags = []*Tag{NewTag(1, "small")}
+	assert.NoError(t, p.UpdatePetByID(&pet))
+	assert.Equal(t, int64(2), pet.Version)
+	assert.NoError(t, p.UpdatePetNameByID(1, 2, "cat3"))
+	assert.NoError(t, p.UpdatePetStatusByID(1, 0, PetStatusSold))
+	assert.Equal(t, ErrVersionMismatch, p.UpdatePetNameByID(1, 2, "cat5"))
+	assert.Equal(t, ErrNotFound, p.UpdatePetNameByID(2, 0, "cat5"))
+	assert.NoError(t, p.UpdatePetNameAndStatusByID(1, 4, "cat4", PetStatusAvailable))
+
+	got, err = p.AddImageUrlByPetID(1, "c")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, got.PhotoUrls)
+	assert.Equal(t, []*Tag{NewTag(1, "small")}, got.Tags)
+	assert.Equal(t, int64(6), got.Version)
+	got, err = p.AddImageUrlByPetID(1, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, got.PhotoUrls)
+
+	var ps []*Pet
+	for _, id := range []int64{2, 3, 4, 5} {
+		ps = append(ps, &Pet{ID: id, Name: "cat", Status: PetStatusAvailable, Category: NewCategory(1, "")})
+	}
+	assert.NoError(t, p.CreateManyPets(ps))
+	err = p.CreateManyPets([]*Pet{{ID: 6, Name: "cat6", Status: PetStatusAvailable}, ps[0]})
+	assert.IsType(t, &BatchError{}, err)
+	_, err = p.RetrievePetByID(6)
+	assert.Equal(t, ErrNotFound, err)
+
+	// categories keep their names unless given other ones
+	got, err = p.RetrievePetByID(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "cat", got.Category.Name)
+
+	pets, err := p.FindPetsByStatus([]string{PetStatusAvailable})
+	assert.NoError(t, err)
+	assert.Len(t, pets, 5)
+	pets, err = p.RetrievePetsByIDs([]int64{3, 1, 7})
+	assert.NoError(t, err)
+	assert.Len(t, pets, 2)
+
+	inv, err := p.RetrieveStoreInventoriesByStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{PetStatusAvailable: 5}, inv)
+
+	assert.NoError(t, p.DeletePetByID(1, false))
+	_, err = p.RetrievePetByID(1)
+	assert.Equal(t, ErrNotFound, err)
+	got, err = p.RestorePetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, got.PhotoUrls)
+	assert.NoError(t, p.DeletePetByID(1, true))
+	assert.Equal(t, ErrNotFound, p.DeletePetByID(1, true))
+}
+
+func TestPostgresStorageStock(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	stock := int64(3)
+	pet := Pet{ID: 20, Name: "fish", Status: PetStatusAvailable, Price: 250, Currency: "USD", Stock: &stock}
+	assert.NoError(t, p.CreatePet(&pet))
+
+	got, err := p.ReserveStockByPetID(20, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *got.Stock)
+	_, err = p.ReserveStockByPetID(20, 2)
+	assert.Equal(t, ErrOutOfStock, err)
+
+	assert.NoError(t, p.ReleaseStockByPetID(20, 2))
+	value, err := p.RetrieveStoreStockValue()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(750), value["USD"])
+
+	// single pets are taken from available to pending, once
+	single := Pet{ID: 21, Name: "rex", Status: PetStatusSold}
+	assert.NoError(t, p.CreatePet(&single))
+	_, err = p.ReserveStockByPetID(21, 1)
+	assert.Equal(t, ErrOutOfStock, err)
+	assert.NoError(t, p.UpdatePetStatusByID(21, 0, PetStatusAvailable))
+	got, err = p.ReserveStockByPetID(21, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, PetStatusPending, got.Status)
+	_, err = p.ReserveStockByPetID(21, 1)
+	assert.Equal(t, ErrOutOfStock, err)
+	assert.NoError(t, p.ReleaseStockByPetID(21, 1))
+	got, err = p.RetrievePetByID(21)
+	assert.NoError(t, err)
+	assert.Equal(t, PetStatusAvailable, got.Status)
+}
+
+func TestPostgresStorageOrders(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	ts := time.Now().UTC().Truncate(time.Millisecond)
+	for i, o := range []*Order{
+		{ID: 11, PetID: 1, UserID: 1, Quantity: 1, ShipDate: ts.Add(-2 * time.Hour), Status: OrderStatusPlaced},
+		{ID: 12, PetID: 2, UserID: 1, Quantity: 1, ShipDate: ts.Add(-time.Hour), Status: OrderStatusDelivered},
+		{ID: 13, PetID: 1, UserID: 2, Quantity: 1, ShipDate: ts, Status: OrderStatusPlaced},
+	} {
+		_, err := p.CreateOrder(o)
+		assert.NoError(t, err, i)
+	}
+	_, err := p.CreateOrder(&Order{ID: 11, ShipDate: ts})
+	assert.EqualError(t, err, "duplicate order id exists")
+
+	o, err := p.RetrieveOrderByID(13)
+	assert.NoError(t, err)
+	assert.Equal(t, ts, o.ShipDate)
+
+	orders, total, err := p.FindOrders(OrderFilter{UserID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, int64(12), orders[0].ID)
+	orders, total, err = p.FindOrders(OrderFilter{Statuses: []string{OrderStatusPlaced}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, int64(13), orders[0].ID)
+	orders, total, err = p.FindOrders(OrderFilter{PetID: 1, ShipDateTo: ts})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, int64(11), orders[0].ID)
+
+	o, err = p.UpdateOrderStatusByID(11, 1, OrderStatusApproved)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), o.Version)
+	_, err = p.UpdateOrderStatusByID(11, 1, OrderStatusDelivered)
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	assert.NoError(t, p.DeleteOrderByID(11, false))
+	n, err := p.PurgeDeleted(CollectionOrders, time.Now().UTC().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = p.RestoreOrderByID(11)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPostgresStoragePatch(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	pet := &Pet{ID: 20, Name: "cat20", Status: PetStatusAvailable, Category: NewCategory(1, "cat"), PhotoUrls: []string{"a"}}
+	assert.NoError(t, p.CreatePet(pet))
+
+	u, err := MergePatch(&Pet{}, []byte(`{"category":{"name":"cats"},"status":"pending"}`))
+	assert.NoError(t, err)
+	got, err := p.PatchPetByID(20, 1, u)
+	assert.NoError(t, err)
+	assert.Equal(t, NewCategory(1, "cats"), got.Category)
+	assert.Equal(t, PetStatusPending, got.Status)
+	assert.Equal(t, int64(2), got.Version)
+
+	_, err = p.PatchPetByID(20, 1, u)
+	assert.Equal(t, ErrVersionMismatch, err)
+	_, err = p.PatchPetByID(21, 0, u)
+	assert.Equal(t, ErrNotFound, err)
+
+	u, err = JSONPatch(&Pet{}, []byte(`[{"op":"test","path":"/status","value":"available"},{"op":"remove","path":"/category"}]`))
+	assert.NoError(t, err)
+	_, err = p.PatchPetByID(20, 0, u)
+	assert.Equal(t, ErrPatchTestFailed, err)
+
+	u, err = JSONPatch(&Pet{}, []byte(`[{"op":"remove","path":"/category"},{"op":"add","path":"/photoUrls/-","value":"b"}]`))
+	assert.NoError(t, err)
+	got, err = p.PatchPetByID(20, 0, u)
+	assert.NoError(t, err)
+	assert.Nil(t, got.Category)
+	assert.Equal(t, []string{"a", "b"}, got.PhotoUrls)
+	assert.Equal(t, int64(3), got.Version)
+}
+
+func TestPostgresStorageMigrations(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	applied, err := p.MigrateUp(0)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := p.MigrateDown(1)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, len(postgresMigrations)-1)
+	status, err := p.MigrationStatus()
+	assert.NoError(t, err)
+	assert.NotNil(t, status[0].AppliedAt)
+	assert.Nil(t, status[1].AppliedAt)
+
+	unlock, err := p.lockMigrations(0)
+	assert.NoError(t, err)
+	_, err = p.lockMigrations(0)
+	assert.Equal(t, ErrMigrationLocked, err)
+	unlock()
+
+	applied, err = p.MigrateUp(0)
+	assert.NoError(t, err)
+	assert.Len(t, applied, len(postgresMigrations)-1)
+}
+
+func TestPostgresStorageEvents(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	first := NewPetEvent(EventPetCreated, &Pet{ID: 30, Name: "rex"})
+	second := NewOrderEvent(EventOrderPlaced, &Order{ID: 30, PetID: 30})
+	assert.NoError(t, p.AppendEvent(first))
+	assert.NoError(t, p.AppendEvent(second))
+	assert.True(t, second.ID > first.ID)
+	events, err := p.FindEventsAfter(first.ID-1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "rex", events[0].Pet.Name)
+		assert.Equal(t, int64(30), events[1].Order.PetID)
+	}
+
+	// transactions appending events concurrently are serialized, none fails
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			errs <- p.WithTransaction(func(tx Storage) error {
+				return tx.AppendEvent(NewPetEvent(EventPetUpdated, &Pet{ID: id, Name: "rex"}))
+			})
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	events, err = p.FindEventsAfter(second.ID, 100)
+	assert.NoError(t, err)
+	assert.Len(t, events, 8)
+
+	attempts, err := p.RetrieveLoginAttemptsByUsername("alice")
+	assert.NoError(t, err)
+	failed := &LoginAttempts{Username: "alice", Failures: 1, LastFailureAt: time.Now().UTC()}
+	assert.NoError(t, p.ReplaceLoginAttempts(attempts, failed))
+	assert.Equal(t, ErrVersionMismatch, p.ReplaceLoginAttempts(attempts, failed))
+	attempts, err = p.RetrieveLoginAttemptsByUsername("alice")
+	assert.NoError(t, err)
+	assert.NoError(t, p.ReplaceLoginAttempts(attempts, &LoginAttempts{Username: "alice", Failures: 2, LastFailureAt: time.Now().UTC()}))
+	assert.Equal(t, ErrVersionMismatch, p.ReplaceLoginAttempts(attempts, attempts))
+	attempts, err = p.RetrieveLoginAttemptsByUsername("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, int(attempts.Failures))
+
+	session := &Session{Token: "token", UserID: 1, Username: "alice", ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	assert.NoError(t, p.CreateSession(session))
+	s, err := p.RetrieveSessionByToken("token")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", s.Username)
+	assert.NoError(t, p.DeleteSessionByToken("token"))
+	_, err = p.RetrieveSessionByToken("token")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPostgresStorageImport(t *testing.T) {
+	p := newPostgresStorage(t)
+
+	pet := NewPet(40, NewCategory(1, "cats"), "Fluffy", []string{"a"}, []*Tag{NewTag(1, "tabby")}, PetStatusAvailable)
+	outcome, err := p.ImportDocument(CollectionPets, pet, ConflictUpsert, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportInserted, outcome)
+	pet.Name = "Tabby"
+	outcome, err = p.ImportDocument(CollectionPets, pet, ConflictSkip, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportSkipped, outcome)
+	outcome, err = p.ImportDocument(CollectionPets, pet, ConflictUpsert, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportReplaced, outcome)
+
+	// exported users have no password, the stored one is kept
+	assert.NoError(t, p.CreateUser(&User{ID: 1, Username: "alice", Password: "secret"}))
+	_, err = p.ImportDocument(CollectionUsers, &User{ID: 1, Username: "alice", Phone: "123"}, ConflictUpsert, false)
+	assert.NoError(t, err)
+	u, err := p.RetrieveUserByUsername("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", u.Password)
+	_, err = p.ImportDocument(CollectionUsers, &User{ID: 2, Username: "bob"}, ConflictUpsert, false)
+	assert.EqualError(t, err, "user bob has no password")
+
+	var exported []interface{}
+	assert.NoError(t, p.ExportCollection(CollectionPets, func(doc interface{}) error {
+		exported = append(exported, doc)
+		return nil
+	}))
+	if assert.Len(t, exported, 1) {
+		assert.Equal(t, "Tabby", exported[0].(*Pet).Name)
+		assert.Equal(t, []string{"a"}, exported[0].(*Pet).PhotoUrls)
+	}
+
+	results, total, err := p.SearchPets(PetSearch{Query: "tabby"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, int64(40), results[0].Pet.ID)
+}
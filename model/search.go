@@ -0,0 +1,243 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	DefaultPetSearchPageSize int64 = 20
+	MaxPetSearchPageSize     int64 = 100
+)
+
+// Weights of pet fields in the relevance of search results, as given to the text index
+const (
+	SearchWeightName     = 10
+	SearchWeightCategory = 5
+	SearchWeightTags     = 3
+)
+
+// PetSearch selects pets whose name, category name or tag names have any of the words of Query, the more
+// of them the more relevant. With Fuzzy, words one or two typos away match too.
+type PetSearch struct {
+	Query    string
+	Statuses []string
+	Fuzzy    bool
+	Offset   int64
+	Limit    int64
+}
+
+// PageSize returns Limit bounded by MaxPetSearchPageSize, DefaultPetSearchPageSize if not set.
+func (s PetSearch) PageSize() int64 {
+	if s.Limit <= 0 {
+		return DefaultPetSearchPageSize
+	}
+	if s.Limit > MaxPetSearchPageSize {
+		return MaxPetSearchPageSize
+	}
+	return s.Limit
+}
+
+// Terms returns the distinct words of Query.
+func (s PetSearch) Terms() []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, t := range Tokenize(s.Query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// SearchHighlight is the value of a field matching a search, with the matching words in <em> tags.
+type SearchHighlight struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// PetSearchResult is a pet matching a search, with its relevance and the fields it matched on.
+type PetSearchResult struct {
+	Pet        *Pet              `json:"pet"`
+	Score      float64           `json:"score"`
+	Highlights []SearchHighlight `json:"highlights,omitempty"`
+}
+
+// Tokenize splits text into lower case words of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stem strips English plural endings from word, so that "tabbies" matches "tabby".
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && strings.HasSuffix(word, "es") && strings.ContainsAny(word[len(word)-3:len(word)-2], "sxz"):
+		return word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// maxTypos is how many edits a word may be away from term to match it fuzzily.
+func maxTypos(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// matchTerm tells how well word matches term: 1 when they are the same but for plural endings, 0.5 when they are
+// a few typos apart and fuzzy, 0 otherwise.
+func matchTerm(word, term string, fuzzy bool) float64 {
+	if word == term || stem(word) == stem(term) {
+		return 1
+	}
+	if fuzzy && distance(word, term) <= maxTypos(term) {
+		return 0.5
+	}
+	return 0
+}
+
+// matchText returns text with the words matching terms in <em> tags, and the sum over terms of how well they
+// match the text.
+func matchText(text string, terms []string, fuzzy bool) (string, float64) {
+	best := make([]float64, len(terms))
+	var sb strings.Builder
+	matched := false
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			sb.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+			j++
+		}
+		word := string(runes[i:j])
+		hit := false
+		for k, term := range terms {
+			if m := matchTerm(strings.ToLower(word), term, fuzzy); m > 0 {
+				hit = true
+				if m > best[k] {
+					best[k] = m
+				}
+			}
+		}
+		if hit {
+			matched = true
+			sb.WriteString("<em>" + word + "</em>")
+		} else {
+			sb.WriteString(word)
+		}
+		i = j
+	}
+	var score float64
+	for _, m := range best {
+		score += m
+	}
+	if !matched {
+		return "", 0
+	}
+	return sb.String(), score
+}
+
+// MatchPet scores how relevant pet is to terms, weighting the fields it matches, and highlights them. A score of 0
+// means the pet does not match.
+func MatchPet(pet *Pet, terms []string, fuzzy bool) (float64, []SearchHighlight) {
+	var score float64
+	var highlights []SearchHighlight
+	match := func(field, text string, weight int) {
+		if value, s := matchText(text, terms, fuzzy); s > 0 {
+			score += s * float64(weight)
+			highlights = append(highlights, SearchHighlight{Field: field, Value: value})
+		}
+	}
+	match("name", pet.Name, SearchWeightName)
+	if pet.Category != nil {
+		match("category", pet.Category.Name, SearchWeightCategory)
+	}
+	for _, tag := range pet.Tags {
+		if tag != nil {
+			match("tags", tag.Name, SearchWeightTags)
+		}
+	}
+	return score, highlights
+}
+
+// SearchPets ranks pets by their relevance to search, for storages without text indexes, and returns one page
+// of those matching with the total count of matches.
+func SearchPets(pets []*Pet, search PetSearch) ([]*PetSearchResult, int64) {
+	terms := search.Terms()
+	statuses := map[string]bool{}
+	for _, s := range search.Statuses {
+		statuses[s] = true
+	}
+	results := []*PetSearchResult{}
+	for _, pet := range pets {
+		if len(statuses) > 0 && !statuses[pet.Status] {
+			continue
+		}
+		if score, highlights := MatchPet(pet, terms, search.Fuzzy); score > 0 {
+			results = append(results, &PetSearchResult{Pet: pet, Score: score, Highlights: highlights})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Pet.ID < results[j].Pet.ID
+	})
+	total := int64(len(results))
+	if search.Offset >= total {
+		return []*PetSearchResult{}, total
+	}
+	results = results[search.Offset:]
+	if int64(len(results)) > search.PageSize() {
+		results = results[:search.PageSize()]
+	}
+	return results, total
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"fluffy", "tabby", "2"}, Tokenize("  Fluffy, tabby-2! "))
+	assert.Equal(t, []string{"fluffy", "tabby"}, PetSearch{Query: "fluffy Tabby fluffy"}.Terms())
+	assert.Empty(t, PetSearch{Query: "--"}.Terms())
+}
+
+func TestMatchPet(t *testing.T) {
+	pet := &Pet{ID: 1, Name: "Fluffy Jr", Category: &Category{Name: "Cats"}, Tags: []*Tag{{Name: "tabby"}, {Name: "indoor"}}}
+
+	score, highlights := MatchPet(pet, []string{"fluffy", "tabbies", "cat"}, false)
+	assert.Equal(t, float64(SearchWeightName+SearchWeightCategory+SearchWeightTags), score)
+	assert.Equal(t, []SearchHighlight{
+		{Field: "name", Value: "<em>Fluffy</em> Jr"},
+		{Field: "category", Value: "<em>Cats</em>"},
+		{Field: "tags", Value: "<em>tabby</em>"},
+	}, highlights)
+
+	// typos only match fuzzily, for half as much
+	score, _ = MatchPet(pet, []string{"fluffi"}, false)
+	assert.Zero(t, score)
+	score, highlights = MatchPet(pet, []string{"fluffi"}, true)
+	assert.Equal(t, float64(SearchWeightName)/2, score)
+	assert.Equal(t, []SearchHighlight{{Field: "name", Value: "<em>Fluffy</em> Jr"}}, highlights)
+	score, _ = MatchPet(pet, []string{"jt"}, true)
+	assert.Zero(t, score)
+}
+
+func TestSearchPets(t *testing.T) {
+	pets := []*Pet{
+		{ID: 1, Name: "Tabby", Status: PetStatusAvailable},
+		{ID: 2, Name: "Fluffy", Status: PetStatusSold, Tags: []*Tag{{Name: "tabby"}}},
+		{ID: 3, Name: "Rex", Status: PetStatusAvailable, Category: &Category{Name: "dogs"}},
+		{ID: 4, Name: "Fluffy", Status: PetStatusAvailable},
+	}
+
+	results, total := SearchPets(pets, PetSearch{Query: "fluffy tabby"})
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, int64(2), results[0].Pet.ID)
+	assert.Equal(t, int64(1), results[1].Pet.ID)
+	assert.Equal(t, int64(4), results[2].Pet.ID)
+
+	results, total = SearchPets(pets, PetSearch{Query: "fluffy tabby", Statuses: []string{PetStatusAvailable}, Offset: 1, Limit: 1})
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, results, 1)
+	assert.Equal(t, int64(4), results[0].Pet.ID)
+
+	results, total = SearchPets(pets, PetSearch{Query: "fluffy", Offset: 10})
+	assert.Equal(t, int64(2), total)
+	assert.Empty(t, results)
+}
+
+func TestPetSearchPageSize(t *testing.T) {
+	assert.Equal(t, DefaultPetSearchPageSize, PetSearch{}.PageSize())
+	assert.Equal(t, MaxPetSearchPageSize, PetSearch{Limit: 1000}.PageSize())
+}
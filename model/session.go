@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Session is issued on successful login, its token authenticates further calls until it expires.
+type Session struct {
+	Token     string    `json:"token" bson:"token"`
+	UserID    int64     `json:"userId" bson:"userId"`
+	Username  string    `json:"username" bson:"username"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+func NewSession(token string, user *User, expiresAt time.Time) *Session {
+	return &Session{
+		Token:     token,
+		UserID:    user.ID,
+		Username:  user.Username,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewSession(t *testing.T) {
+	now := time.Now().UTC()
+	s := NewSession("token", &User{ID: 1, Username: "username"}, now.Add(time.Hour))
+	assert.NotNil(t, s)
+	assert.Equal(t, "token", s.Token)
+	assert.Equal(t, int64(1), s.UserID)
+	assert.Equal(t, "username", s.Username)
+	assert.False(t, s.Expired(now))
+	assert.True(t, s.Expired(now.Add(time.Hour)))
+}
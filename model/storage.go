@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"github.com/go-kit/kit/log"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"sort"
 	"time"
 )
 
@@ -18,51 +20,122 @@ const (
 	CollectionOrders     string = "orders"
 	CollectionCategories string = "categories"
 	CollectionTags       string = "tags"
+
+	CollectionLoginAttempts string = "login_attempts"
+	CollectionSessions      string = "sessions"
 )
 
+// ErrNotFound is returned when no document matches, the same as mongo.ErrNoDocuments.
+var ErrNotFound = mongo.ErrNoDocuments
+
+// ErrVersionMismatch is returned by conditional updates of a document which has changed since the expected version.
+var ErrVersionMismatch = errors.New("document has been modified since the expected version")
+
 type Storage interface {
 	// Create user
 	CreateUser(user *User) error
-	// Create users from slice
+	// Create all users from slice or none of them
 	CreateManyUsers(users []*User) error
 	// Fetch user by username
 	RetrieveUserByUsername(username string) (*User, error)
 	// Fetch user by user id
 	RetrieveUserByID(id int64) (*User, error)
-	// Update user by username
+	// Fetch users of given ids, leaving out those not found
+	RetrieveUsersByIDs(ids []int64) ([]*User, error)
+	// Update user by username, only if stored at user.Version unless it is 0, and set user.Version to the new version
 	UpdateUserByUsername(username string, user *User) (*User, error)
-	// Delete user by username
-	DeleteUserByUsername(username string) error
+	// Apply update to user by username, only if stored at version unless it is 0, and fetch the updated user
+	PatchUserByUsername(username string, version int64, update *Update) (*User, error)
+	// Delete user by username, hidden from all reads until purged, or for good when hard
+	DeleteUserByUsername(username string, hard bool) error
+	// Undo the deletion of a user not purged yet
+	RestoreUserByUsername(username string) (*User, error)
 
 	// Create pet
 	CreatePet(pet *Pet) error
-	// Create pets from slice
+	// Create all pets from slice or none of them
 	CreateManyPets(pets []*Pet) error
-	// Update pet by pet id
+	// Update pet by pet id, only if stored at pet.Version unless it is 0, and set pet.Version to the new version
 	UpdatePetByID(pet *Pet) error
 	// Fetch pet by id
 	RetrievePetByID(id int64) (*Pet, error)
+	// Fetch pets of given ids, leaving out those not found
+	RetrievePetsByIDs(ids []int64) ([]*Pet, error)
 	// Find pets by given statuses slice
 	FindPetsByStatus(statuses []string) ([]*Pet, error)
-	// Update pet naem and status by given pet id
-	UpdatePetNameAndStatusByID(id int64, name string, status string) error
-	// Update pet name by given id
-	UpdatePetNameByID(id int64, name string) error
-	// Update pet status by given id
-	UpdatePetStatusByID(id int64, status string) error
+	// Call fn with each pet of given statuses, without loading them all at once
+	StreamPetsByStatus(statuses []string, fn func(pet *Pet) error) error
+	// Find one page of pets matching search, most relevant first, with total count of matches
+	SearchPets(search PetSearch) ([]*PetSearchResult, int64, error)
+	// Apply update to pet by given id, only if stored at version unless it is 0, and fetch the updated pet
+	PatchPetByID(id int64, version int64, update *Update) (*Pet, error)
+	// Update pet naem and status by given pet id, only if stored at version unless it is 0
+	UpdatePetNameAndStatusByID(id int64, version int64, name string, status string) error
+	// Update pet name by given id, only if stored at version unless it is 0
+	UpdatePetNameByID(id int64, version int64, name string) error
+	// Update pet status by given id, only if stored at version unless it is 0
+	UpdatePetStatusByID(id int64, version int64, status string) error
 	// Add image url to pet by give pet id
 	AddImageUrlByPetID(id int64, url string) (*Pet, error)
-	// Fetch store inventory of all statuses
+	// Fetch store inventory of all statuses, in units of stock
 	RetrieveStoreInventoriesByStatus() (map[string]int64, error)
-	// Delete pet by given ID
-	DeletePetByID(id int64) error
+	// Fetch value of units in stock per currency
+	RetrieveStoreStockValue() (StockValue, error)
+	// Take quantity units out of stock of given pet, or a single pet from available to pending, fails with
+	// ErrOutOfStock if not enough left
+	ReserveStockByPetID(id int64, quantity int64) (*Pet, error)
+	// Put quantity units back into stock of given pet, or a single pet from pending back to available
+	ReleaseStockByPetID(id int64, quantity int64) error
+	// Delete pet by given ID, hidden from all reads until purged, or for good when hard
+	DeletePetByID(id int64, hard bool) error
+	// Undo the deletion of a pet not purged yet
+	RestorePetByID(id int64) (*Pet, error)
 
 	// Create order
 	CreateOrder(order *Order) (*Order, error)
 	// Fetch order by given order id
 	RetrieveOrderByID(id int64) (*Order, error)
-	// Delete order by given order id
-	DeleteOrderByID(id int64) error
+	// Delete order by given order id, hidden from all reads until purged, or for good when hard
+	DeleteOrderByID(id int64, hard bool) error
+	// Undo the deletion of an order not purged yet
+	RestoreOrderByID(id int64) (*Order, error)
+	// Find one page of orders matching filter, latest ship date first, with total count of matches
+	FindOrders(filter OrderFilter) ([]*Order, int64, error)
+	// Find orders of given users, latest ship date first
+	FindOrdersByUserIDs(userIDs []int64) ([]*Order, error)
+	// Update order status by given order id, only if stored at version unless it is 0, and fetch the updated order
+	UpdateOrderStatusByID(id int64, version int64, status string) (*Order, error)
+
+	// Append event to the outbox, setting its id
+	AppendEvent(event *Event) error
+	// Fetch at most limit events appended after the one with id after, oldest first
+	FindEventsAfter(after int64, limit int64) ([]*Event, error)
+
+	// Fetch failed login attempts by username, zero attempts if none recorded
+	RetrieveLoginAttemptsByUsername(username string) (*LoginAttempts, error)
+	// Replace login attempts of a username by attempts, only if they are still seen, none recorded when seen has no
+	// failures, and fail with ErrVersionMismatch otherwise
+	ReplaceLoginAttempts(seen *LoginAttempts, attempts *LoginAttempts) error
+	// Forget failed login attempts of username
+	DeleteLoginAttemptsByUsername(username string) error
+
+	// Create login session
+	CreateSession(session *Session) error
+	// Fetch login session by token
+	RetrieveSessionByToken(token string) (*Session, error)
+	// Delete login session by token
+	DeleteSessionByToken(token string) error
+
+	// Call fn with each document of collection, as typed by NewDocument, without loading them all at once
+	ExportCollection(collection string, fn func(doc interface{}) error) error
+	// Store a document of collection, replacing or keeping one with the same id, only reporting what would be done in a dry run
+	ImportDocument(collection string, doc interface{}, mode ConflictMode, dryRun bool) (ImportOutcome, error)
+
+	// Delete for good documents of collection deleted before given time, returning how many
+	PurgeDeleted(collection string, before time.Time) (int64, error)
+
+	// Run fn with a storage whose operations are committed together when fn succeeds, where supported
+	WithTransaction(fn func(tx Storage) error) error
 
 	// Drop whole specified collection
 	EmptyCollection(collection string) error
@@ -74,6 +147,11 @@ type MongoStorage struct {
 	Timeout  int64
 	Database string
 	Logger   log.Logger
+
+	// whether the server supports transactions, that is a replica set or sharded cluster
+	transactions bool
+	// session context of the transaction operations take part in, nil outside transactions
+	session mongo.SessionContext
 }
 
 func NewMongoStorage(uri, database string, timeout int64, logger log.Logger) (Storage, error) {
@@ -83,7 +161,8 @@ func NewMongoStorage(uri, database string, timeout int64, logger log.Logger) (St
 		Timeout:  timeout,
 		Logger:   logger,
 	}
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		return nil, err
@@ -93,9 +172,87 @@ func NewMongoStorage(uri, database string, timeout int64, logger log.Logger) (St
 		return nil, err
 	}
 	storage.client = client
+
+	var hello struct {
+		SetName        string `bson:"setName"`
+		Msg            string `bson:"msg"`
+		MaxWireVersion int32  `bson:"maxWireVersion"`
+	}
+	err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return nil, err
+	}
+	storage.transactions = (hello.SetName != "" && hello.MaxWireVersion >= 7) ||
+		(hello.Msg == "isdbgrid" && hello.MaxWireVersion >= 8)
+	if !storage.transactions {
+		_ = logger.Log("msg", "mongo server does not support transactions, multi document writes are not atomic")
+	}
 	return storage, nil
 }
 
+// ForDatabase returns a storage of database sharing the connection of m, outside of any transaction.
+func (m MongoStorage) ForDatabase(database string) Storage {
+	m.Database = database
+	m.session = nil
+	return m
+}
+
+// context returns the context of one operation, bound to the session of the transaction if any.
+func (m MongoStorage) context() (context.Context, context.CancelFunc) {
+	var parent context.Context = context.Background()
+	if m.session != nil {
+		parent = m.session
+	}
+	return context.WithTimeout(parent, time.Duration(m.Timeout)*time.Second)
+}
+
+// WithTransaction runs fn with a storage whose operations take part in one transaction, committed when fn
+// returns nil and aborted otherwise. Servers without transactions run fn with the storage itself, so it is up
+// to fn to undo partial writes.
+func (m MongoStorage) WithTransaction(fn func(tx Storage) error) error {
+	return m.withTransaction(func(tx MongoStorage) error {
+		return fn(tx)
+	})
+}
+
+func (m MongoStorage) withTransaction(fn func(tx MongoStorage) error) error {
+	if !m.transactions || m.session != nil {
+		return fn(m)
+	}
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	// transactions conflicting with another one, like those appending events, are run again until the timeout
+	deadline := time.Now().Add(time.Duration(m.Timeout) * time.Second)
+	for {
+		err = mongo.WithSession(context.Background(), session, func(sc mongo.SessionContext) error {
+			if err := session.StartTransaction(); err != nil {
+				return err
+			}
+			tx := m
+			tx.session = sc
+			if err := fn(tx); err != nil {
+				if e := session.AbortTransaction(sc); e != nil {
+					_ = m.Logger.Log("msg", "abort transaction", "err", e)
+				}
+				return err
+			}
+			return session.CommitTransaction(sc)
+		})
+		if !isTransientTransactionError(err) || time.Now().After(deadline) {
+			return err
+		}
+	}
+}
+
+func isTransientTransactionError(err error) bool {
+	ce, ok := err.(mongo.CommandError)
+	return ok && ce.HasErrorLabel("TransientTransactionError")
+}
+
 func toBsonD(val interface{}) (*bson.D, error) {
 	b, err := bson.Marshal(val)
 	if err != nil {
@@ -109,20 +266,191 @@ func toBsonD(val interface{}) (*bson.D, error) {
 	return &d, nil
 }
 
+// notDeleted adds the condition of documents not deleted to filter, so that deleted documents are hidden.
+func notDeleted(filter bson.M) bson.M {
+	f := bson.M{"deletedAt": bson.M{"$exists": false}}
+	for k, v := range filter {
+		f[k] = v
+	}
+	return f
+}
+
+// exists tells whether a document matching filter is stored, deleted or not.
+func (m MongoStorage) exists(collection string, filter bson.M) (bool, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	n, err := m.client.Database(m.Database).Collection(collection).CountDocuments(ctx, filter)
+	return n > 0, err
+}
+
+// versionFilter adds the expected version to filter of a conditional update, unless it is 0.
+func versionFilter(filter bson.M, version int64) bson.M {
+	if version == 0 {
+		return filter
+	}
+	f := bson.M{"version": version}
+	for k, v := range filter {
+		f[k] = v
+	}
+	return f
+}
+
+// notUpdated tells why a conditional update matched no document, ErrVersionMismatch when one matches filter
+// at another version and mongo.ErrNoDocuments when none does.
+func (m MongoStorage) notUpdated(collection string, filter bson.M, version int64) error {
+	if version == 0 {
+		return mongo.ErrNoDocuments
+	}
+	ctx, cancel := m.context()
+	defer cancel()
+
+	n, err := m.client.Database(m.Database).Collection(collection).CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrVersionMismatch
+	}
+	return mongo.ErrNoDocuments
+}
+
+// updateVersioned applies update to the document matching filter, at version unless it is 0, and increments its
+// version.
+func (m MongoStorage) updateVersioned(collection string, filter bson.M, version int64, update bson.M) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	update["$inc"] = bson.M{"version": 1}
+	err := m.client.Database(m.Database).Collection(collection).FindOneAndUpdate(ctx,
+		versionFilter(filter, version), update).Err()
+	if err == mongo.ErrNoDocuments {
+		return m.notUpdated(collection, filter, version)
+	}
+	return err
+}
+
+// patch applies update to the document matching filter, at version unless it is 0, and decodes the updated
+// document into doc. Its fields are changed in place, with tests of the update as conditions of the filter.
+func (m MongoStorage) patch(collection string, filter bson.M, version int64, update *Update, doc interface{}) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	u := bson.M{"$inc": bson.M{"version": 1}}
+	if len(update.Set) > 0 {
+		set := bson.M{}
+		for path, value := range update.Set {
+			set[path] = value
+		}
+		u["$set"] = set
+	}
+	if len(update.Unset) > 0 {
+		unset := bson.M{}
+		for path := range update.Unset {
+			unset[path] = ""
+		}
+		u["$unset"] = unset
+	}
+	if len(update.Push) > 0 {
+		push := bson.M{}
+		for path, values := range update.Push {
+			push[path] = bson.M{"$each": values}
+		}
+		u["$push"] = push
+	}
+	f := bson.M{}
+	for k, v := range versionFilter(filter, version) {
+		f[k] = v
+	}
+	for path, value := range update.Test {
+		f[path] = value
+	}
+
+	err := m.client.Database(m.Database).Collection(collection).FindOneAndUpdate(ctx, f, u,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(doc)
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+	if len(update.Test) > 0 {
+		n, err := m.client.Database(m.Database).Collection(collection).CountDocuments(ctx, versionFilter(filter, version))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrPatchTestFailed
+		}
+	}
+	return m.notUpdated(collection, filter, version)
+}
+
+// replaceVersioned replaces the document matching filter with doc at the next version, which is returned. The stored
+// document has to be at version unless it is 0, in which case it is replaced at whatever version it is.
+func (m MongoStorage) replaceVersioned(collection string, filter bson.M, version int64, doc interface{}) (int64, error) {
+	coll := m.client.Database(m.Database).Collection(collection)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	d, err := toBsonD(doc)
+	if err != nil {
+		return 0, err
+	}
+	// an unconditional replace reads the current version first, and tries again if it changes meanwhile
+	for attempt := 0; ; attempt++ {
+		current := version
+		if version == 0 {
+			var stored struct {
+				Version int64 `bson:"version"`
+			}
+			if err := coll.FindOne(ctx, filter).Decode(&stored); err != nil {
+				return 0, err
+			}
+			current = stored.Version
+		}
+		replacement := bson.D{}
+		for _, e := range *d {
+			if e.Key != "version" && e.Key != "deletedAt" {
+				replacement = append(replacement, e)
+			}
+		}
+		replacement = append(replacement, bson.E{Key: "version", Value: current + 1})
+
+		err := coll.FindOneAndReplace(ctx, versionFilter(filter, current), replacement).Err()
+		if err == nil {
+			return current + 1, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return 0, err
+		}
+		if version != 0 {
+			return 0, m.notUpdated(collection, filter, version)
+		}
+		if attempt == 2 {
+			return 0, ErrVersionMismatch
+		}
+	}
+}
+
+// duplicateError returns err of a failed duplicate check, or the duplicate message when the check succeeded.
+func duplicateError(err error, message string) error {
+	if err != nil {
+		return err
+	}
+	return errors.New(message)
+}
+
 func (m MongoStorage) CreateUser(user *User) error {
 	collection := m.client.Database(m.Database).Collection(CollectionUsers)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
-	u, err := m.RetrieveUserByID(user.ID)
-	if err == nil && u != nil {
-		return errors.New("duplicate user id exists")
+	if found, err := m.exists(CollectionUsers, bson.M{"id": user.ID}); err != nil || found {
+		return duplicateError(err, "duplicate user id exists")
 	}
-
-	u, err = m.RetrieveUserByUsername(user.Username)
-	if err == nil && u != nil {
-		return errors.New("duplicate username exists")
+	if found, err := m.exists(CollectionUsers, bson.M{"username": user.Username}); err != nil || found {
+		return duplicateError(err, "duplicate username exists")
 	}
 
+	user.Version = 1
 	d, err := toBsonD(user)
 	if err != nil {
 		return err
@@ -134,40 +462,92 @@ func (m MongoStorage) CreateUser(user *User) error {
 	return nil
 }
 
+// CreateManyUsers creates all users or none of them, a *BatchError tells which users failed.
 func (m MongoStorage) CreateManyUsers(users []*User) error {
-	collection := m.client.Database(m.Database).Collection(CollectionUsers)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	if len(users) == 0 {
+		return nil
+	}
 
+	batch := &BatchError{}
+	ids := map[int64]bool{}
+	usernames := map[string]bool{}
 	var docs []interface{}
-	for _, u := range users {
-		u2, err := m.RetrieveUserByID(u.ID)
-		if err == nil && u2 != nil {
-			return fmt.Errorf("duplicate user id exists for %d", u.ID)
+	var docIds []int64
+	for i, u := range users {
+		if u == nil {
+			batch.Add(i, 0, "user is null")
+			continue
+		}
+		if ids[u.ID] {
+			batch.Add(i, u.ID, "duplicate user id in batch")
+			continue
+		}
+		if usernames[u.Username] {
+			batch.Add(i, u.ID, fmt.Sprintf("duplicate username %s in batch", u.Username))
+			continue
 		}
-		u2, err = m.RetrieveUserByUsername(u.Username)
-		if err == nil && u2 != nil {
-			return fmt.Errorf("duplicate username exists for %s", u.Username)
+		ids[u.ID] = true
+		usernames[u.Username] = true
+
+		if found, err := m.exists(CollectionUsers, bson.M{"id": u.ID}); err != nil || found {
+			batch.Add(i, u.ID, duplicateError(err, fmt.Sprintf("duplicate user id exists for %d", u.ID)).Error())
+			continue
+		}
+		if found, err := m.exists(CollectionUsers, bson.M{"username": u.Username}); err != nil || found {
+			batch.Add(i, u.ID, duplicateError(err, fmt.Sprintf("duplicate username exists for %s", u.Username)).Error())
+			continue
 		}
+		u.Version = 1
 		d, err := toBsonD(u)
 		if err != nil {
-			return err
+			batch.Add(i, u.ID, err.Error())
+			continue
 		}
 		docs = append(docs, d)
+		docIds = append(docIds, u.ID)
 	}
-
-	_, err := collection.InsertMany(ctx, docs)
-	if err != nil {
+	if err := batch.Err(); err != nil {
 		return err
 	}
-	return nil
+
+	return m.withTransaction(func(tx MongoStorage) error {
+		return tx.insertBatch(CollectionUsers, docs, docIds)
+	})
+}
+
+// insertBatch inserts docs of given ids at once. Outside transactions the docs inserted before a failure are
+// deleted again. Write errors are reported as *BatchError.
+func (m MongoStorage) insertBatch(collection string, docs []interface{}, ids []int64) error {
+	coll := m.client.Database(m.Database).Collection(collection)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	_, err := coll.InsertMany(ctx, docs)
+	if err == nil {
+		return nil
+	}
+	if m.session == nil {
+		if _, e := coll.DeleteMany(ctx, bson.M{"id": bson.M{"$in": ids}}); e != nil {
+			_ = m.Logger.Log("msg", "undo partial batch", "collection", collection, "err", e)
+		}
+	}
+	if bwe, ok := err.(mongo.BulkWriteException); ok && len(bwe.WriteErrors) > 0 {
+		batch := &BatchError{}
+		for _, we := range bwe.WriteErrors {
+			batch.Add(we.Index, ids[we.Index], we.Message)
+		}
+		return batch
+	}
+	return err
 }
 
 func (m MongoStorage) RetrieveUserByUsername(username string) (*User, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionUsers)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
 	var user User
-	err := collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"username": username})).Decode(&user)
 	if err != nil {
 		return nil, err
 	}
@@ -176,42 +556,75 @@ func (m MongoStorage) RetrieveUserByUsername(username string) (*User, error) {
 
 func (m MongoStorage) RetrieveUserByID(id int64) (*User, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionUsers)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
 	var user User
-	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&user)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"id": id})).Decode(&user)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (m MongoStorage) UpdateUserByUsername(username string, user *User) (*User, error) {
+func (m MongoStorage) RetrieveUsersByIDs(ids []int64) ([]*User, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionUsers)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
-	d, err := toBsonD(user)
+	cur, err := collection.Find(ctx, notDeleted(bson.M{"id": bson.M{"$in": ids}}))
 	if err != nil {
 		return nil, err
 	}
-	err = collection.FindOneAndReplace(ctx, bson.M{"username": username}, d).Err()
+	defer cur.Close(context.Background())
+
+	users := []*User{}
+	for cur.Next(ctx) {
+		var user User
+		if err = cur.Decode(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	return users, cur.Err()
+}
+
+func (m MongoStorage) UpdateUserByUsername(username string, user *User) (*User, error) {
+	version, err := m.replaceVersioned(CollectionUsers, notDeleted(bson.M{"username": username}), user.Version, user)
 	if err != nil {
 		return nil, err
 	}
+	user.Version = version
 	return user, nil
 }
 
-func (m MongoStorage) DeleteUserByUsername(username string) error {
-	collection := m.client.Database(m.Database).Collection(CollectionUsers)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+func (m MongoStorage) PatchUserByUsername(username string, version int64, update *Update) (*User, error) {
+	var user User
+	err := m.patch(CollectionUsers, notDeleted(bson.M{"username": username}), version, update, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (m MongoStorage) DeleteUserByUsername(username string, hard bool) error {
+	return m.deleteDocument(CollectionUsers, bson.M{"username": username}, hard)
+}
 
-	return collection.FindOneAndDelete(ctx, bson.M{"username": username}).Err()
+func (m MongoStorage) RestoreUserByUsername(username string) (*User, error) {
+	var user User
+	err := m.restoreDocument(CollectionUsers, bson.M{"username": username}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
 func (m MongoStorage) RetrieveStoreInventoriesByStatus() (map[string]int64, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
-	cur, err := collection.Find(ctx, bson.M{})
+	ctx, cancel := m.context()
+	defer cancel()
+	cur, err := collection.Find(ctx, notDeleted(bson.M{}))
 	if err != nil {
 		return nil, err
 	}
@@ -228,120 +641,286 @@ func (m MongoStorage) RetrieveStoreInventoriesByStatus() (map[string]int64, erro
 
 		i, ok := inv[pet.Status]
 		if ok {
-			inv[pet.Status] = int64(i + 1)
+			inv[pet.Status] = int64(i + pet.Units())
 		} else {
-			inv[pet.Status] = int64(1)
+			inv[pet.Status] = pet.Units()
 		}
 	}
 	return inv, nil
 }
 
+func (m MongoStorage) RetrieveStoreStockValue() (StockValue, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionPets)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cur, err := collection.Find(ctx, notDeleted(bson.M{"status": PetStatusAvailable, "price": bson.M{"$gt": 0}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	value := StockValue{}
+	for cur.Next(ctx) {
+		pet := Pet{}
+		err = cur.Decode(&pet)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := MultiplyAmount(pet.Price, pet.Units())
+		if err != nil {
+			return nil, err
+		}
+		if err = value.Add(pet.Currency, amount); err != nil {
+			return nil, err
+		}
+	}
+	return value, cur.Err()
+}
+
 func (m MongoStorage) CreateOrder(order *Order) (*Order, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionOrders)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
-	o, err := m.RetrieveOrderByID(order.ID)
-	if err == nil && o != nil {
-		return nil, errors.New("duplicate order id exists")
+	if found, err := m.exists(CollectionOrders, bson.M{"id": order.ID}); err != nil || found {
+		return nil, duplicateError(err, "duplicate order id exists")
 	}
 
+	order.Version = 1
 	d, err := toBsonD(order)
 	if err != nil {
 		return nil, err
 	}
 	_, err = collection.InsertOne(ctx, d)
-
+	if err != nil {
+		return nil, err
+	}
 	return order, nil
 }
 
 func (m MongoStorage) RetrieveOrderByID(id int64) (*Order, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionOrders)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var order Order
+	err := collection.FindOne(ctx, notDeleted(bson.M{"id": id})).Decode(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (m MongoStorage) DeleteOrderByID(id int64, hard bool) error {
+	return m.deleteDocument(CollectionOrders, bson.M{"id": id}, hard)
+}
+
+func (m MongoStorage) UpdateOrderStatusByID(id int64, version int64, status string) (*Order, error) {
+	err := m.updateVersioned(CollectionOrders, notDeleted(bson.M{"id": id}), version, bson.M{
+		"$set": bson.M{
+			"status": status,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.RetrieveOrderByID(id)
+}
 
+func (m MongoStorage) RestoreOrderByID(id int64) (*Order, error) {
 	var order Order
-	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&order)
+	err := m.restoreDocument(CollectionOrders, bson.M{"id": id}, &order)
 	if err != nil {
 		return nil, err
 	}
 	return &order, nil
 }
 
-func (m MongoStorage) DeleteOrderByID(id int64) error {
+func (m MongoStorage) FindOrders(filter OrderFilter) ([]*Order, int64, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionOrders)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	query := notDeleted(bson.M{})
+	if filter.UserID != 0 {
+		query["userId"] = filter.UserID
+	}
+	if filter.PetID != 0 {
+		query["petId"] = filter.PetID
+	}
+	if len(filter.Statuses) > 0 {
+		query["status"] = bson.M{"$in": filter.Statuses}
+	}
+	shipDate := bson.M{}
+	if !filter.ShipDateFrom.IsZero() {
+		shipDate["$gte"] = filter.ShipDateFrom
+	}
+	if !filter.ShipDateTo.IsZero() {
+		shipDate["$lt"] = filter.ShipDateTo
+	}
+	if len(shipDate) > 0 {
+		query["shipDate"] = shipDate
+	}
+
+	total, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cur, err := collection.Find(ctx, query, options.Find().
+		SetSort(bson.D{{Key: "shipDate", Value: -1}, {Key: "id", Value: 1}}).
+		SetSkip(filter.Offset).
+		SetLimit(filter.PageSize()))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(context.Background())
+
+	orders := []*Order{}
+	for cur.Next(ctx) {
+		var order Order
+		err = cur.Decode(&order)
+		if err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, &order)
+	}
+	return orders, total, cur.Err()
+}
+
+func (m MongoStorage) FindOrdersByUserIDs(userIDs []int64) ([]*Order, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionOrders)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cur, err := collection.Find(ctx, notDeleted(bson.M{"userId": bson.M{"$in": userIDs}}), options.Find().
+		SetSort(bson.D{{Key: "shipDate", Value: -1}, {Key: "id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
 
-	return collection.FindOneAndDelete(ctx, bson.M{"id": id}).Err()
+	orders := []*Order{}
+	for cur.Next(ctx) {
+		var order Order
+		if err = cur.Decode(&order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, &order)
+	}
+	return orders, cur.Err()
 }
 
 func (m MongoStorage) CreatePet(pet *Pet) error {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
-	u, err := m.RetrievePetByID(pet.ID)
-	if err == nil && u != nil {
-		return errors.New("duplicate pet id exists")
+	if found, err := m.exists(CollectionPets, bson.M{"id": pet.ID}); err != nil || found {
+		return duplicateError(err, "duplicate pet id exists")
 	}
 
+	pet.Version = 1
 	d, err := toBsonD(pet)
 	if err != nil {
 		return err
 	}
 	_, err = collection.InsertOne(ctx, d)
-
-	return nil
+	return err
 }
 
+// CreateManyPets creates all pets or none of them, a *BatchError tells which pets failed.
 func (m MongoStorage) CreateManyPets(pets []*Pet) error {
-	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	if len(pets) == 0 {
+		return nil
+	}
 
+	batch := &BatchError{}
+	ids := map[int64]bool{}
 	var docs []interface{}
-	for _, p := range pets {
-		u, err := m.RetrievePetByID(p.ID)
-		if err == nil && u != nil {
-			return fmt.Errorf("duplicate pet id exists for %d", p.ID)
+	var docIds []int64
+	for i, p := range pets {
+		if p == nil {
+			batch.Add(i, 0, "pet is null")
+			continue
+		}
+		if ids[p.ID] {
+			batch.Add(i, p.ID, "duplicate pet id in batch")
+			continue
 		}
+		ids[p.ID] = true
 
+		if found, err := m.exists(CollectionPets, bson.M{"id": p.ID}); err != nil || found {
+			batch.Add(i, p.ID, duplicateError(err, fmt.Sprintf("duplicate pet id exists for %d", p.ID)).Error())
+			continue
+		}
+		p.Version = 1
 		d, err := toBsonD(p)
 		if err != nil {
-			return err
+			batch.Add(i, p.ID, err.Error())
+			continue
 		}
 		docs = append(docs, d)
+		docIds = append(docIds, p.ID)
 	}
-
-	_, err := collection.InsertMany(ctx, docs)
-	if err != nil {
+	if err := batch.Err(); err != nil {
 		return err
 	}
-	return nil
+
+	return m.withTransaction(func(tx MongoStorage) error {
+		return tx.insertBatch(CollectionPets, docs, docIds)
+	})
 }
 
 func (m MongoStorage) UpdatePetByID(pet *Pet) error {
-	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
-
-	d, err := toBsonD(pet)
+	version, err := m.replaceVersioned(CollectionPets, notDeleted(bson.M{"id": pet.ID}), pet.Version, pet)
 	if err != nil {
 		return err
 	}
-	return collection.FindOneAndReplace(ctx, bson.M{"id": pet.ID}, d).Err()
+	pet.Version = version
+	return nil
 }
 
 func (m MongoStorage) RetrievePetByID(id int64) (*Pet, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
 	var pet Pet
-	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&pet)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"id": id})).Decode(&pet)
 	if err != nil {
 		return nil, err
 	}
 	return &pet, nil
 }
 
+func (m MongoStorage) RetrievePetsByIDs(ids []int64) ([]*Pet, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionPets)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cur, err := collection.Find(ctx, notDeleted(bson.M{"id": bson.M{"$in": ids}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	pets := []*Pet{}
+	for cur.Next(ctx) {
+		var pet Pet
+		if err = cur.Decode(&pet); err != nil {
+			return nil, err
+		}
+		pets = append(pets, &pet)
+	}
+	return pets, cur.Err()
+}
+
 func (m MongoStorage) FindPetsByStatus(statuses []string) ([]*Pet, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 
 	var A bson.A
 	for _, s := range statuses {
@@ -349,7 +928,7 @@ func (m MongoStorage) FindPetsByStatus(statuses []string) ([]*Pet, error) {
 	}
 
 	var pets []*Pet
-	cur, err := collection.Find(ctx, bson.M{"status": bson.M{"$in": A}})
+	cur, err := collection.Find(ctx, notDeleted(bson.M{"status": bson.M{"$in": A}}))
 	if err != nil {
 		return nil, err
 	}
@@ -366,60 +945,1087 @@ func (m MongoStorage) FindPetsByStatus(statuses []string) ([]*Pet, error) {
 	return pets, nil
 }
 
-func (m MongoStorage) UpdatePetNameAndStatusByID(id int64, name string, status string) error {
+func (m MongoStorage) StreamPetsByStatus(statuses []string, fn func(pet *Pet) error) error {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	// the stream lasts as long as it takes fn to take all pets, no operation timeout
+	cur, err := collection.Find(context.Background(), notDeleted(bson.M{"status": bson.M{"$in": statuses}}),
+		options.Find().SetSort(bson.M{"id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.Background())
 
-	return collection.FindOneAndUpdate(ctx, bson.M{"id": id}, bson.D{
-		{"$set", bson.D{
-			{"name", name},
-			{"status", status},
-		}},
-	}).Err()
+	for cur.Next(context.Background()) {
+		var pet Pet
+		if err = cur.Decode(&pet); err != nil {
+			return err
+		}
+		if err = fn(&pet); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
 }
 
-func (m MongoStorage) UpdatePetNameByID(id int64, name string) error {
-	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
-
-	return collection.FindOneAndUpdate(ctx, bson.M{"id": id}, bson.D{
-		{"$set", bson.D{
-			{"name", name},
-		}},
-	}).Err()
-}
+// SearchPets queries the text index of pets, or ranks all pets matching statuses in the process for fuzzy
+// searches, which the index can't do, and when the index is missing.
+func (m MongoStorage) SearchPets(search PetSearch) ([]*PetSearchResult, int64, error) {
+	if len(search.Terms()) == 0 {
+		return []*PetSearchResult{}, 0, nil
+	}
+	query := notDeleted(bson.M{})
+	if len(search.Statuses) > 0 {
+		query["status"] = bson.M{"$in": search.Statuses}
+	}
+	if search.Fuzzy {
+		return m.scanPets(query, search)
+	}
 
-func (m MongoStorage) UpdatePetStatusByID(id int64, status string) error {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
-
-	return collection.FindOneAndUpdate(ctx, bson.M{"id": id}, bson.D{
-		{"$set", bson.D{
-			{"status", status},
-		}},
-	}).Err()
-}
+	ctx, cancel := m.context()
+	defer cancel()
 
-func (m MongoStorage) AddImageUrlByPetID(id int64, url string) (*Pet, error) {
+	query["$text"] = bson.M{"$search": search.Query}
+	total, err := collection.CountDocuments(ctx, query)
+	if isNamespaceNotFound(err) {
+		delete(query, "$text")
+		return m.scanPets(query, search)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	cur, err := collection.Find(ctx, query, options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "id", Value: 1}}).
+		SetSkip(search.Offset).
+		SetLimit(search.PageSize()))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(context.Background())
+
+	terms := search.Terms()
+	results := []*PetSearchResult{}
+	for cur.Next(context.Background()) {
+		var doc struct {
+			Pet   `bson:",inline"`
+			Score float64 `bson:"score"`
+		}
+		if err = cur.Decode(&doc); err != nil {
+			return nil, 0, err
+		}
+		pet := doc.Pet
+		_, highlights := MatchPet(&pet, terms, false)
+		results = append(results, &PetSearchResult{Pet: &pet, Score: doc.Score, Highlights: highlights})
+	}
+	return results, total, cur.Err()
+}
+
+// scanPets ranks pets matching query in the process.
+func (m MongoStorage) scanPets(query bson.M, search PetSearch) ([]*PetSearchResult, int64, error) {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
-	err := collection.FindOneAndUpdate(ctx, bson.M{"id": id}, bson.D{
-		{"$addToSet", bson.M{"photoUrls": url}}}).Err()
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cur, err := collection.Find(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(context.Background())
+
+	var pets []*Pet
+	for cur.Next(context.Background()) {
+		var pet Pet
+		if err = cur.Decode(&pet); err != nil {
+			return nil, 0, err
+		}
+		pets = append(pets, &pet)
+	}
+	if err = cur.Err(); err != nil {
+		return nil, 0, err
+	}
+	results, total := SearchPets(pets, search)
+	return results, total, nil
+}
+
+func (m MongoStorage) PatchPetByID(id int64, version int64, update *Update) (*Pet, error) {
+	var pet Pet
+	err := m.patch(CollectionPets, notDeleted(bson.M{"id": id}), version, update, &pet)
+	if err != nil {
+		return nil, err
+	}
+	return &pet, nil
+}
+
+func (m MongoStorage) UpdatePetNameAndStatusByID(id int64, version int64, name string, status string) error {
+	return m.updateVersioned(CollectionPets, notDeleted(bson.M{"id": id}), version, bson.M{
+		"$set": bson.M{
+			"name":   name,
+			"status": status,
+		},
+	})
+}
+
+func (m MongoStorage) UpdatePetNameByID(id int64, version int64, name string) error {
+	return m.updateVersioned(CollectionPets, notDeleted(bson.M{"id": id}), version, bson.M{
+		"$set": bson.M{
+			"name": name,
+		},
+	})
+}
+
+func (m MongoStorage) UpdatePetStatusByID(id int64, version int64, status string) error {
+	return m.updateVersioned(CollectionPets, notDeleted(bson.M{"id": id}), version, bson.M{
+		"$set": bson.M{
+			"status": status,
+		},
+	})
+}
+
+func (m MongoStorage) AddImageUrlByPetID(id int64, url string) (*Pet, error) {
+	err := m.updateVersioned(CollectionPets, notDeleted(bson.M{"id": id}), 0, bson.M{
+		"$addToSet": bson.M{"photoUrls": url},
+	})
 	if err != nil {
 		return nil, err
 	}
 	return m.RetrievePetByID(id)
 }
 
-func (m MongoStorage) DeletePetByID(id int64) error {
+func (m MongoStorage) ReserveStockByPetID(id int64, quantity int64) (*Pet, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionPets)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	pet, err := m.RetrievePetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"id": id, "stock": bson.M{"$gte": quantity}}
+	update := bson.M{"$inc": bson.M{"stock": -quantity, "version": 1}}
+	if pet.Stock == nil {
+		if quantity > 1 {
+			return nil, ErrOutOfStock
+		}
+		// single pets are taken by moving them from available to pending
+		filter = bson.M{"id": id, "stock": bson.M{"$exists": false}, "status": PetStatusAvailable}
+		update = bson.M{"$set": bson.M{"status": PetStatusPending}, "$inc": bson.M{"version": 1}}
+	}
+
+	var reserved Pet
+	err = collection.FindOneAndUpdate(ctx, notDeleted(filter), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&reserved)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOutOfStock
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reserved, nil
+}
+
+func (m MongoStorage) ReleaseStockByPetID(id int64, quantity int64) error {
 	collection := m.client.Database(m.Database).Collection(CollectionPets)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"id": id, "stock": bson.M{"$exists": true}},
+		bson.M{"$inc": bson.M{"stock": quantity, "version": 1}})
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"id": id, "stock": bson.M{"$exists": false}, "status": PetStatusPending},
+		bson.M{"$set": bson.M{"status": PetStatusAvailable}, "$inc": bson.M{"version": 1}})
+	return err
+}
+
+func (m MongoStorage) DeletePetByID(id int64, hard bool) error {
+	return m.deleteDocument(CollectionPets, bson.M{"id": id}, hard)
+}
+
+func (m MongoStorage) RestorePetByID(id int64) (*Pet, error) {
+	var pet Pet
+	err := m.restoreDocument(CollectionPets, bson.M{"id": id}, &pet)
+	if err != nil {
+		return nil, err
+	}
+	return &pet, nil
+}
+
+// deleteDocument hides the document matching filter by setting its deletedAt, or removes it when hard, deleted
+// or not. ErrNotFound is returned if there is no such document.
+func (m MongoStorage) deleteDocument(collection string, filter bson.M, hard bool) error {
+	coll := m.client.Database(m.Database).Collection(collection)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	if hard {
+		res, err := coll.DeleteOne(ctx, filter)
+		if err == nil && res.DeletedCount == 0 {
+			err = ErrNotFound
+		}
+		return err
+	}
+	res, err := coll.UpdateOne(ctx, notDeleted(filter), bson.M{
+		"$set": bson.M{"deletedAt": time.Now().UTC()},
+		"$inc": bson.M{"version": 1},
+	})
+	if err == nil && res.MatchedCount == 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// restoreDocument clears deletedAt of the deleted document matching filter, and decodes the restored document
+// into doc. ErrNotFound is returned if there is no such deleted document.
+func (m MongoStorage) restoreDocument(collection string, filter bson.M, doc interface{}) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	f := bson.M{"deletedAt": bson.M{"$exists": true}}
+	for k, v := range filter {
+		f[k] = v
+	}
+	return m.client.Database(m.Database).Collection(collection).FindOneAndUpdate(ctx, f, bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+		"$inc":   bson.M{"version": 1},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(doc)
+}
+
+func (m MongoStorage) PurgeDeleted(collection string, before time.Time) (int64, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	res, err := m.client.Database(m.Database).Collection(collection).DeleteMany(ctx,
+		bson.M{"deletedAt": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// nextSequence returns the next id of a sequence. In a transaction, the id is taken along with its other writes,
+// so that transactions taking ids of the same sequence are serialized and commit in the order of their ids.
+func (m MongoStorage) nextSequence(name string) (int64, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := m.client.Database(m.Database).Collection(CollectionCounters).FindOneAndUpdate(ctx,
+		bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&counter)
+	return counter.Seq, err
+}
+
+// AppendEvent takes the id of event and inserts it in one transaction, where supported, so that readers of the
+// outbox never see an event before those with lower ids.
+func (m MongoStorage) AppendEvent(event *Event) error {
+	return m.withTransaction(func(tx MongoStorage) error {
+		id, err := tx.nextSequence(CollectionEvents)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := tx.context()
+		defer cancel()
+
+		event.ID = id
+		if event.At.IsZero() {
+			event.At = time.Now().UTC()
+		}
+		_, err = tx.client.Database(tx.Database).Collection(CollectionEvents).InsertOne(ctx, event)
+		return err
+	})
+}
+
+func (m MongoStorage) FindEventsAfter(after int64, limit int64) ([]*Event, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionEvents)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cur, err := collection.Find(ctx, bson.M{"_id": bson.M{"$gt": after}}, options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	events := []*Event{}
+	for cur.Next(ctx) {
+		var event Event
+		err = cur.Decode(&event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, cur.Err()
+}
+
+// CanWatchEvents tells whether the server has change streams, that is it is a replica set or sharded cluster.
+func (m MongoStorage) CanWatchEvents() bool {
+	return m.transactions
+}
+
+// WatchEvents follows inserts into the events collection with a change stream.
+func (m MongoStorage) WatchEvents(stop <-chan struct{}, fn func(event *Event)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stream, err := m.client.Database(m.Database).Collection(CollectionEvents).Watch(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var change struct {
+			FullDocument Event `bson:"fullDocument"`
+		}
+		if err = stream.Decode(&change); err != nil {
+			return err
+		}
+		fn(&change.FullDocument)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return stream.Err()
+}
+
+func (m MongoStorage) CanWatchCachedDocuments() bool {
+	return m.transactions
+}
+
+// WatchCachedDocuments follows changes of pets and users with a change stream of the database. Documents deleted for
+// good are no longer known by their ids, any may have changed then.
+func (m MongoStorage) WatchCachedDocuments(stop <-chan struct{}, fn func(keys []string)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stream, err := m.client.Database(m.Database).Watch(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "ns.coll", Value: bson.M{"$in": bson.A{CollectionPets, CollectionUsers}}}}}},
+	}, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var change struct {
+			Namespace struct {
+				Collection string `bson:"coll"`
+			} `bson:"ns"`
+			FullDocument *struct {
+				ID       int64  `bson:"id"`
+				Username string `bson:"username"`
+			} `bson:"fullDocument"`
+		}
+		if err = stream.Decode(&change); err != nil {
+			return err
+		}
+		switch {
+		case change.FullDocument == nil:
+			fn(nil)
+		case change.Namespace.Collection == CollectionPets:
+			fn([]string{PetCacheKey(change.FullDocument.ID)})
+		default:
+			fn([]string{UserCacheKey(change.FullDocument.Username), UserIDCacheKey(change.FullDocument.ID)})
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return stream.Err()
+}
+
+func (m MongoStorage) CreateWebhook(webhook *Webhook) error {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhooks)
+	id, err := m.nextSequence(CollectionWebhooks)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := m.context()
+	defer cancel()
+
+	webhook.ID = id
+	_, err = collection.InsertOne(ctx, webhook)
+	return err
+}
+
+func (m MongoStorage) RetrieveWebhookByID(id int64) (*Webhook, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhooks)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var webhook Webhook
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (m MongoStorage) FindWebhooks() ([]*Webhook, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhooks)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cur, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	webhooks := []*Webhook{}
+	for cur.Next(ctx) {
+		var webhook Webhook
+		err = cur.Decode(&webhook)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, cur.Err()
+}
+
+func (m MongoStorage) DeleteWebhookByID(id int64) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	res, err := m.client.Database(m.Database).Collection(CollectionWebhooks).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	_, err = m.client.Database(m.Database).Collection(CollectionWebhookDeliveries).DeleteMany(ctx,
+		bson.M{"webhookId": id})
+	return err
+}
+
+func (m MongoStorage) EnqueueDeliveries(deliveries []*Delivery) error {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhookDeliveries)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	for _, delivery := range deliveries {
+		_, err := collection.InsertOne(ctx, delivery)
+		if err != nil && !isDuplicateKey(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MongoStorage) FindDueDeliveries(now time.Time, limit int64) ([]*Delivery, error) {
+	return m.findDeliveries(bson.M{"status": DeliveryPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.D{{Key: "nextAttemptAt", Value: 1}}, limit)
+}
+
+func (m MongoStorage) ClaimDelivery(id string, now time.Time, until time.Time) (*Delivery, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhookDeliveries)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var delivery Delivery
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": DeliveryPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"nextAttemptAt": until}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&delivery)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (m MongoStorage) FindDeliveries(webhookID int64, status string, limit int64) ([]*Delivery, error) {
+	query := bson.M{"webhookId": webhookID}
+	if status != "" {
+		query["status"] = status
+	}
+	return m.findDeliveries(query, bson.D{{Key: "event._id", Value: -1}}, limit)
+}
+
+func (m MongoStorage) findDeliveries(query bson.M, sort bson.D, limit int64) ([]*Delivery, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhookDeliveries)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cur, err := collection.Find(ctx, query, options.Find().SetSort(sort).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	deliveries := []*Delivery{}
+	for cur.Next(ctx) {
+		var delivery Delivery
+		err = cur.Decode(&delivery)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, cur.Err()
+}
+
+func (m MongoStorage) RetrieveDeliveryByID(id string) (*Delivery, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhookDeliveries)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var delivery Delivery
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&delivery)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (m MongoStorage) UpdateDelivery(delivery *Delivery) error {
+	collection := m.client.Database(m.Database).Collection(CollectionWebhookDeliveries)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	res, err := collection.ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Event cursors are kept in the counters collection, by name prefixed with cursor.
+func (m MongoStorage) RetrieveEventCursor(name string) (int64, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := m.client.Database(m.Database).Collection(CollectionCounters).FindOne(ctx,
+		bson.M{"_id": "cursor:" + name}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return counter.Seq, err
+}
+
+func (m MongoStorage) UpdateEventCursor(name string, position int64) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	_, err := m.client.Database(m.Database).Collection(CollectionCounters).UpdateOne(ctx,
+		bson.M{"_id": "cursor:" + name}, bson.M{"$set": bson.M{"seq": position}}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (m MongoStorage) AppendAuditEvent(event *AuditEvent) error {
+	collection := m.client.Database(m.Database).Collection(CollectionAudit)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	_, err := collection.InsertOne(ctx, event)
+	return err
+}
+
+func (m MongoStorage) FindAuditEvents(filter AuditFilter) ([]*AuditEvent, int64, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionAudit)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	query := bson.M{}
+	if filter.Entity != "" {
+		query["entity"] = filter.Entity
+	}
+	if filter.EntityID != 0 {
+		query["entityId"] = filter.EntityID
+	}
+	if filter.Actor != "" {
+		query["actor.username"] = filter.Actor
+	}
+	at := bson.M{}
+	if !filter.From.IsZero() {
+		at["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		at["$lt"] = filter.To
+	}
+	if len(at) > 0 {
+		query["at"] = at
+	}
+
+	total, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cur, err := collection.Find(ctx, query, options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(filter.Offset).
+		SetLimit(filter.PageSize()))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cur.Close(context.Background())
+
+	events := []*AuditEvent{}
+	for cur.Next(ctx) {
+		var event AuditEvent
+		err = cur.Decode(&event)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, &event)
+	}
+	return events, total, cur.Err()
+}
+
+func (m MongoStorage) RetrieveLoginAttemptsByUsername(username string) (*LoginAttempts, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionLoginAttempts)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var attempts LoginAttempts
+	err := collection.FindOne(ctx, bson.M{"username": username}).Decode(&attempts)
+	if err == mongo.ErrNoDocuments {
+		return &LoginAttempts{Username: username}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (m MongoStorage) ReplaceLoginAttempts(seen *LoginAttempts, attempts *LoginAttempts) error {
+	collection := m.client.Database(m.Database).Collection(CollectionLoginAttempts)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	if seen.Failures == 0 {
+		// the unique index on username refuses a second insert
+		_, err := collection.InsertOne(ctx, attempts)
+		if isDuplicateKey(err) {
+			return ErrVersionMismatch
+		}
+		return err
+	}
+	res, err := collection.ReplaceOne(ctx, bson.M{
+		"username":      seen.Username,
+		"failures":      seen.Failures,
+		"lastFailureAt": seen.LastFailureAt,
+	}, attempts)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+func (m MongoStorage) DeleteLoginAttemptsByUsername(username string) error {
+	collection := m.client.Database(m.Database).Collection(CollectionLoginAttempts)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"username": username})
+	return err
+}
+
+func (m MongoStorage) CreateSession(session *Session) error {
+	collection := m.client.Database(m.Database).Collection(CollectionSessions)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	d, err := toBsonD(session)
+	if err != nil {
+		return err
+	}
+	_, err = collection.InsertOne(ctx, d)
+	return err
+}
+
+func (m MongoStorage) RetrieveSessionByToken(token string) (*Session, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionSessions)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	var session Session
+	err := collection.FindOne(ctx, bson.M{"token": token}).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (m MongoStorage) DeleteSessionByToken(token string) error {
+	collection := m.client.Database(m.Database).Collection(CollectionSessions)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"token": token})
+	return err
+}
+
+func (m MongoStorage) ExportCollection(collection string, fn func(doc interface{}) error) error {
+	if _, err := NewDocument(collection); err != nil {
+		return err
+	}
+	coll := m.client.Database(m.Database).Collection(collection)
+	// the export lasts as long as it takes to write all documents, no operation timeout
+	cur, err := coll.Find(context.Background(), bson.M{}, options.Find().SetSort(bson.M{"id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.Background())
+
+	for cur.Next(context.Background()) {
+		doc, _ := NewDocument(collection)
+		if err = cur.Decode(doc); err != nil {
+			return err
+		}
+		if err = fn(doc); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (m MongoStorage) ImportDocument(collection string, doc interface{}, mode ConflictMode, dryRun bool) (ImportOutcome, error) {
+	id, err := DocumentID(doc)
+	if err != nil {
+		return "", err
+	}
+	coll := m.client.Database(m.Database).Collection(collection)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	if u, ok := doc.(*User); ok {
+		found, err := m.exists(CollectionUsers, bson.M{"username": u.Username, "id": bson.M{"$ne": u.ID}})
+		if err != nil || found {
+			return "", duplicateError(err, fmt.Sprintf("duplicate username exists for %s", u.Username))
+		}
+		var stored User
+		err = coll.FindOne(ctx, bson.M{"id": u.ID}).Decode(&stored)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return "", err
+		}
+		if err = importPassword(u, stored.Password); err != nil {
+			return "", err
+		}
+	}
+
+	n, err := coll.CountDocuments(ctx, bson.M{"id": id})
+	if err != nil {
+		return "", err
+	}
+	outcome := ImportInserted
+	if n > 0 {
+		if mode == ConflictSkip {
+			return ImportSkipped, nil
+		}
+		outcome = ImportReplaced
+	}
+	if dryRun {
+		return outcome, nil
+	}
+
+	// documents exported before versioning start at the first version
+	switch d := doc.(type) {
+	case *Pet:
+		if d.Version == 0 {
+			d.Version = 1
+		}
+	case *User:
+		if d.Version == 0 {
+			d.Version = 1
+		}
+	case *Order:
+		if d.Version == 0 {
+			d.Version = 1
+		}
+	}
+	d, err := toBsonD(doc)
+	if err != nil {
+		return "", err
+	}
+	_, err = coll.ReplaceOne(ctx, bson.M{"id": id}, d, options.Replace().SetUpsert(true))
+	if err != nil {
+		return "", err
+	}
+	return outcome, nil
+}
+
+const (
+	// how long to wait for another process to finish migrating
+	migrationLockWait = time.Minute
+	// how long a lock is held before it is considered left by a crashed process
+	migrationLockExpiry = 10 * time.Minute
+	// how often a held lock is renewed
+	migrationLockRenewal = time.Minute
+)
+
+// lockMigrations takes the migrations lock, waiting up to wait for the process holding it, and returns a context
+// cancelled when the lock is lost with the function releasing it. The lock is renewed until released, so that it
+// only expires when its process is gone, and is lost when it could not be renewed before expiring.
+func (m MongoStorage) lockMigrations(wait time.Duration) (context.Context, func(), error) {
+	collection := m.client.Database(m.Database).Collection(CollectionSchemaMigrationsLock)
+	owner := primitive.NewObjectID().Hex()
+	deadline := time.Now().Add(wait)
+	for {
+		ctx, cancel := m.context()
+		now := time.Now().UTC()
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"_id": "migrations", "lockedAt": bson.M{"$lt": now.Add(-migrationLockExpiry)}},
+			bson.M{"$set": bson.M{"owner": owner, "lockedAt": now}},
+			options.Update().SetUpsert(true))
+		cancel()
+		if err == nil {
+			break
+		}
+		if !isDuplicateKey(err) {
+			return nil, nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, nil, ErrMigrationLocked
+		}
+		time.Sleep(time.Second)
+	}
+
+	locked, lost := context.WithCancel(context.Background())
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(migrationLockRenewal)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := m.context()
+			res, err := collection.UpdateOne(ctx, bson.M{"_id": "migrations", "owner": owner},
+				bson.M{"$set": bson.M{"lockedAt": time.Now().UTC()}})
+			cancel()
+			if err == nil && res.MatchedCount == 0 {
+				err = ErrMigrationLockLost
+			}
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			_ = m.Logger.Log("msg", "renew migrations lock", "err", err)
+			// another process can take the lock once expired, give up before
+			if err == ErrMigrationLockLost || time.Since(renewed) > migrationLockExpiry-2*migrationLockRenewal {
+				lost()
+				return
+			}
+		}
+	}()
+	return locked, func() {
+		close(stop)
+		<-stopped
+		lost()
+		ctx, cancel := m.context()
+		defer cancel()
+		_, err := collection.DeleteOne(ctx, bson.M{"_id": "migrations", "owner": owner})
+		if err != nil {
+			_ = m.Logger.Log("msg", "release migrations lock", "err", err)
+		}
+	}, nil
+}
+
+// migrationError reports the failure of mig, caused by the loss of the migrations lock when locked is done.
+func migrationError(locked context.Context, mig Migration, err error) error {
+	if locked.Err() != nil {
+		err = ErrMigrationLockLost
+	}
+	return fmt.Errorf("migration %d: %v", mig.Version, err)
+}
+
+func isDuplicateKey(err error) bool {
+	if we, ok := err.(mongo.WriteException); ok {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	if ce, ok := err.(mongo.CommandError); ok {
+		return ce.Code == 11000
+	}
+	return false
+}
+
+func (m MongoStorage) appliedMigrations() ([]AppliedMigration, error) {
+	collection := m.client.Database(m.Database).Collection(CollectionSchemaMigrations)
+	ctx, cancel := m.context()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var applied []AppliedMigration
+	for cursor.Next(ctx) {
+		var a AppliedMigration
+		if err := cursor.Decode(&a); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, cursor.Err()
+}
+
+func (m MongoStorage) appliedVersions() (map[int64]bool, error) {
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	versions := map[int64]bool{}
+	for _, a := range applied {
+		versions[a.Version] = true
+	}
+	return versions, nil
+}
+
+func (m MongoStorage) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]AppliedMigration{}
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	var status []MigrationStatus
+	for _, mig := range Migrations() {
+		s := MigrationStatus{Version: mig.Version, Description: mig.Description}
+		if a, ok := byVersion[mig.Version]; ok {
+			s.AppliedAt = &a.AppliedAt
+			delete(byVersion, mig.Version)
+		}
+		status = append(status, s)
+	}
+	for _, a := range applied {
+		if _, ok := byVersion[a.Version]; ok {
+			appliedAt := a.AppliedAt
+			status = append(status, MigrationStatus{
+				Version: a.Version, Description: a.Description, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+// MigrateUp applies pending migrations in order while holding the migrations lock, and returns the ones
+// applied. It stops at the first failing migration.
+func (m MongoStorage) MigrateUp(to int64) ([]Migration, error) {
+	locked, unlock, err := m.lockMigrations(migrationLockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
-	return collection.FindOneAndDelete(ctx, bson.M{"id": id}).Err()
+	versions, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	db := m.client.Database(m.Database)
+	var done []Migration
+	for _, mig := range pendingMigrations(Migrations(), versions, to) {
+		_ = m.Logger.Log("msg", "apply migration", "version", mig.Version, "description", mig.Description)
+		// migrations are not bound to the operation timeout, building an index on a large collection takes a while,
+		// but stop when the lock is lost
+		if err := mig.Up(locked, db); err != nil || locked.Err() != nil {
+			return done, migrationError(locked, mig, err)
+		}
+		ctx, cancel := m.context()
+		_, err := db.Collection(CollectionSchemaMigrations).InsertOne(ctx, AppliedMigration{
+			Version:     mig.Version,
+			Description: mig.Description,
+			AppliedAt:   time.Now().UTC(),
+		})
+		cancel()
+		if err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts applied migrations newer than to, latest first, while holding the migrations lock, and
+// returns the ones reverted. It stops at the first failing migration.
+func (m MongoStorage) MigrateDown(to int64) ([]Migration, error) {
+	locked, unlock, err := m.lockMigrations(migrationLockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	versions, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	revert, err := revertibleMigrations(Migrations(), versions, to)
+	if err != nil {
+		return nil, err
+	}
+	db := m.client.Database(m.Database)
+	var done []Migration
+	for _, mig := range revert {
+		_ = m.Logger.Log("msg", "revert migration", "version", mig.Version, "description", mig.Description)
+		if err := mig.Down(locked, db); err != nil || locked.Err() != nil {
+			return done, migrationError(locked, mig, err)
+		}
+		ctx, cancel := m.context()
+		_, err := db.Collection(CollectionSchemaMigrations).DeleteOne(ctx, bson.M{"_id": mig.Version})
+		cancel()
+		if err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
 }
 
 func (m MongoStorage) EmptyCollection(collection string) error {
 	coll := m.client.Database(m.Database).Collection(collection)
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(m.Timeout)*time.Second)
+	ctx, cancel := m.context()
+	defer cancel()
 	return coll.Drop(ctx)
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -66,8 +67,21 @@ func TestMongoStorageUserActions(t *testing.T) {
 	assert.Equal(t, "456", u2.Phone)
 	assert.Equal(t, int32(0), u2.UserStatus)
 
-	err = storage.DeleteUserByUsername("username-1")
+	// deleted users are hidden, keep their username and id until purged, and can be restored
+	err = storage.DeleteUserByUsername("username-1", false)
 	assert.NoError(t, err)
+	_, err = storage.RetrieveUserByUsername("username-1")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, storage.DeleteUserByUsername("username-1", false))
+	assert.Error(t, storage.CreateUser(&User{ID: 10, Username: "username-2"}))
+	u2, err = storage.RestoreUserByUsername("username-1")
+	assert.NoError(t, err)
+	assert.Nil(t, u2.DeletedAt)
+	_, err = storage.RestoreUserByUsername("username-1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, storage.DeleteUserByUsername("username-1", true))
+	assert.Equal(t, ErrNotFound, storage.DeleteUserByUsername("username-1", true))
 }
 
 func TestMongoStoragePetActions(t *testing.T) {
@@ -81,21 +95,34 @@ func TestMongoStoragePetActions(t *testing.T) {
 
 	err := storage.CreatePet(&pet)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pet.Version)
 
 	pet.Name = "cat2"
 	pet.Status = PetStatusPending
 	err = storage.UpdatePetByID(&pet)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pet.Version)
+
+	assert.NoError(t, storage.UpdatePetNameByID(1, 2, "cat3"))
+	assert.NoError(t, storage.UpdatePetStatusByID(1, 0, PetStatusSold))
+	assert.Equal(t, ErrVersionMismatch, storage.UpdatePetNameByID(1, 2, "cat5"))
+	assert.NoError(t, storage.UpdatePetNameAndStatusByID(1, 4, "cat4", PetStatusAvailable))
 
-	assert.NoError(t, storage.UpdatePetNameByID(1, "cat3"))
-	assert.NoError(t, storage.UpdatePetStatusByID(1, PetStatusSold))
-	assert.NoError(t, storage.UpdatePetNameAndStatusByID(1, "cat4", PetStatusAvailable))
+	// a stale replace is refused, an unconditional one is made at the current version
+	pet.Version = 2
+	assert.Equal(t, ErrVersionMismatch, storage.UpdatePetByID(&pet))
+	pet.Name = "cat4"
+	pet.Status = PetStatusAvailable
+	pet.Version = 0
+	assert.NoError(t, storage.UpdatePetByID(&pet))
+	assert.Equal(t, int64(6), pet.Version)
 
 	url := "http://localhost:8080/images/1.jpg"
 	p, err := storage.AddImageUrlByPetID(1, url)
 	assert.NoError(t, err)
 	assert.NotNil(t, p)
 	assert.Equal(t, []string{url}, p.PhotoUrls)
+	assert.Equal(t, int64(7), p.Version)
 
 	var ps []*Pet
 	for _, id := range []int64{2, 3, 4, 5} {
@@ -110,6 +137,13 @@ func TestMongoStoragePetActions(t *testing.T) {
 	}
 	assert.NoError(t, storage.CreateManyPets(ps))
 
+	// a batch with one existing pet is refused as a whole
+	err = storage.CreateManyPets([]*Pet{{ID: 6, Name: "cat6", Status: PetStatusAvailable}, ps[0]})
+	assert.IsType(t, &BatchError{}, err)
+	assert.Equal(t, 1, err.(*BatchError).Items[0].Index)
+	_, err = storage.RetrievePetByID(6)
+	assert.Error(t, err)
+
 	pets, err := storage.FindPetsByStatus([]string{PetStatusAvailable, PetStatusPending})
 	assert.NoError(t, err)
 	assert.NotNil(t, pets)
@@ -140,10 +174,241 @@ func TestMongoStorageStoreActions(t *testing.T) {
 	assert.NotNil(t, o)
 	assert.ObjectsAreEqualValues(o, order)
 
-	assert.NoError(t, storage.DeleteOrderByID(1))
+	assert.NoError(t, storage.DeleteOrderByID(1, false))
 	o, err = storage.RetrieveOrderByID(1)
 	assert.Error(t, err)
 	assert.Nil(t, o)
+
+	n, err := storage.PurgeDeleted(CollectionOrders, time.Now().UTC().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	n, err = storage.PurgeDeleted(CollectionOrders, time.Now().UTC().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = storage.RestoreOrderByID(1)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMongoStorageStock(t *testing.T) {
+	stock := int64(3)
+	pet := Pet{ID: 20, Name: "fish", Status: PetStatusAvailable, PhotoUrls: []string{}, Price: 250, Currency: "USD", Stock: &stock}
+	assert.NoError(t, storage.CreatePet(&pet))
+
+	p, err := storage.ReserveStockByPetID(20, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *p.Stock)
+
+	_, err = storage.ReserveStockByPetID(20, 2)
+	assert.Equal(t, ErrOutOfStock, err)
+
+	assert.NoError(t, storage.ReleaseStockByPetID(20, 2))
+	value, err := storage.RetrieveStoreStockValue()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(750), value["USD"])
+
+	// single pets are taken from available to pending, once
+	single := Pet{ID: 21, Name: "rex", Status: PetStatusSold, PhotoUrls: []string{}}
+	assert.NoError(t, storage.CreatePet(&single))
+	_, err = storage.ReserveStockByPetID(21, 1)
+	assert.Equal(t, ErrOutOfStock, err)
+	assert.NoError(t, storage.UpdatePetStatusByID(21, 0, PetStatusAvailable))
+	p, err = storage.ReserveStockByPetID(21, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, PetStatusPending, p.Status)
+	_, err = storage.ReserveStockByPetID(21, 1)
+	assert.Equal(t, ErrOutOfStock, err)
+	assert.NoError(t, storage.ReleaseStockByPetID(21, 1))
+	p, err = storage.RetrievePetByID(21)
+	assert.NoError(t, err)
+	assert.Equal(t, PetStatusAvailable, p.Status)
+
+	assert.NoError(t, storage.DeletePetByID(20, true))
+	assert.NoError(t, storage.DeletePetByID(21, true))
+}
+
+func TestMongoStorageFindOrders(t *testing.T) {
+	ts := time.Now().UTC().Truncate(time.Millisecond)
+	for i, o := range []*Order{
+		{ID: 11, PetID: 1, UserID: 1, Quantity: 1, ShipDate: ts.Add(-2 * time.Hour), Status: OrderStatusPlaced},
+		{ID: 12, PetID: 2, UserID: 1, Quantity: 1, ShipDate: ts.Add(-time.Hour), Status: OrderStatusDelivered},
+		{ID: 13, PetID: 1, UserID: 2, Quantity: 1, ShipDate: ts, Status: OrderStatusPlaced},
+	} {
+		_, err := storage.CreateOrder(o)
+		assert.NoError(t, err, i)
+	}
+
+	orders, total, err := storage.FindOrders(OrderFilter{UserID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, int64(12), orders[0].ID)
+
+	orders, total, err = storage.FindOrders(OrderFilter{Statuses: []string{OrderStatusPlaced}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, int64(13), orders[0].ID)
+
+	orders, total, err = storage.FindOrders(OrderFilter{PetID: 1, ShipDateTo: ts})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, int64(11), orders[0].ID)
+}
+
+func TestMongoStoragePatch(t *testing.T) {
+	pet := &Pet{ID: 20, Name: "cat20", Status: PetStatusAvailable, Category: NewCategory(1, "cat"), PhotoUrls: []string{"a"}}
+	assert.NoError(t, storage.CreatePet(pet))
+
+	u, err := MergePatch(&Pet{}, []byte(`{"category":{"name":"cats"},"status":"pending"}`))
+	assert.NoError(t, err)
+	p, err := storage.PatchPetByID(20, 1, u)
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", p.Category.Name)
+	assert.Equal(t, int64(1), p.Category.ID)
+	assert.Equal(t, PetStatusPending, p.Status)
+	assert.Equal(t, int64(2), p.Version)
+
+	_, err = storage.PatchPetByID(20, 1, u)
+	assert.Equal(t, ErrVersionMismatch, err)
+	_, err = storage.PatchPetByID(21, 0, u)
+	assert.Equal(t, ErrNotFound, err)
+
+	u, err = JSONPatch(&Pet{}, []byte(`[{"op":"test","path":"/status","value":"available"},{"op":"remove","path":"/category"}]`))
+	assert.NoError(t, err)
+	_, err = storage.PatchPetByID(20, 0, u)
+	assert.Equal(t, ErrPatchTestFailed, err)
+
+	u, err = JSONPatch(&Pet{}, []byte(`[{"op":"remove","path":"/category"},{"op":"add","path":"/photoUrls/-","value":"b"}]`))
+	assert.NoError(t, err)
+	p, err = storage.PatchPetByID(20, 0, u)
+	assert.NoError(t, err)
+	assert.Nil(t, p.Category)
+	assert.Equal(t, []string{"a", "b"}, p.PhotoUrls)
+	assert.Equal(t, int64(3), p.Version)
+	assert.NoError(t, storage.DeletePetByID(20, true))
+}
+
+func TestMongoStorageMigrations(t *testing.T) {
+	m := storage.(MongoStorage)
+	assert.NoError(t, m.EmptyCollection(CollectionSchemaMigrations))
+
+	applied, err := m.MigrateUp(0)
+	assert.NoError(t, err)
+	assert.Len(t, applied, len(Migrations()))
+	applied, err = m.MigrateUp(0)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	status, err := m.MigrationStatus()
+	assert.NoError(t, err)
+	for _, s := range status {
+		assert.NotNil(t, s.AppliedAt)
+	}
+
+	reverted, err := m.MigrateDown(1)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, len(Migrations())-1)
+	status, err = m.MigrationStatus()
+	assert.NoError(t, err)
+	assert.NotNil(t, status[0].AppliedAt)
+	assert.Nil(t, status[1].AppliedAt)
+
+	locked, unlock, err := m.lockMigrations(0)
+	assert.NoError(t, err)
+	_, _, err = m.lockMigrations(0)
+	assert.Equal(t, ErrMigrationLocked, err)
+	unlock()
+	assert.Error(t, locked.Err())
+
+	_, err = m.MigrateUp(0)
+	assert.NoError(t, err)
+}
+
+func TestMongoStorageEvents(t *testing.T) {
+	m := storage.(MongoStorage)
+	assert.NoError(t, m.EmptyCollection(CollectionEvents))
+	assert.NoError(t, m.EmptyCollection(CollectionWebhooks))
+	assert.NoError(t, m.EmptyCollection(CollectionWebhookDeliveries))
+
+	first := NewPetEvent(EventPetCreated, &Pet{ID: 30, Name: "rex"})
+	second := NewOrderEvent(EventOrderPlaced, &Order{ID: 30, PetID: 30})
+	assert.NoError(t, m.AppendEvent(first))
+	assert.NoError(t, m.AppendEvent(second))
+	assert.True(t, second.ID > first.ID)
+	events, err := m.FindEventsAfter(first.ID-1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "rex", events[0].Pet.Name)
+	assert.Equal(t, int64(30), events[1].Order.PetID)
+
+	// transactions appending events concurrently are serialized, none fails
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			errs <- m.WithTransaction(func(tx Storage) error {
+				return tx.AppendEvent(NewPetEvent(EventPetUpdated, &Pet{ID: id, Name: "rex"}))
+			})
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	events, err = m.FindEventsAfter(second.ID, 100)
+	assert.NoError(t, err)
+	assert.Len(t, events, 8)
+
+	webhook := &Webhook{URL: "http://localhost/hook", Secret: "secret"}
+	assert.NoError(t, m.CreateWebhook(webhook))
+	delivery := NewDelivery(webhook, first, time.Now().UTC().Add(-time.Second))
+	assert.NoError(t, m.EnqueueDeliveries([]*Delivery{delivery, delivery}))
+	due, err := m.FindDueDeliveries(time.Now().UTC(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+	now := time.Now().UTC()
+	claimed, err := m.ClaimDelivery(delivery.ID, now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, delivery.ID, claimed.ID)
+	_, err = m.ClaimDelivery(delivery.ID, now, now.Add(time.Minute))
+	assert.Equal(t, ErrNotFound, err)
+	delivery.Status = DeliveryDead
+	assert.NoError(t, m.UpdateDelivery(delivery))
+	dead, err := m.FindDeliveries(webhook.ID, DeliveryDead, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+
+	assert.NoError(t, m.UpdateEventCursor("test", second.ID))
+	cursor, err := m.RetrieveEventCursor("test")
+	assert.NoError(t, err)
+	assert.Equal(t, second.ID, cursor)
+
+	assert.NoError(t, m.DeleteWebhookByID(webhook.ID))
+	_, err = m.RetrieveDeliveryByID(delivery.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMongoStorageSearchPets(t *testing.T) {
+	m := storage.(MongoStorage)
+	assert.NoError(t, m.EmptyCollection(CollectionPets))
+	_, err := m.MigrateUp(0)
+	assert.NoError(t, err)
+	assert.NoError(t, m.CreatePet(NewPet(40, NewCategory(1, "cats"), "Fluffy", nil, []*Tag{NewTag(1, "tabby")}, PetStatusAvailable)))
+	assert.NoError(t, m.CreatePet(NewPet(41, NewCategory(1, "cats"), "Tabby", nil, nil, PetStatusAvailable)))
+	assert.NoError(t, m.CreatePet(NewPet(42, NewCategory(2, "dogs"), "Rex", nil, nil, PetStatusAvailable)))
+
+	results, total, err := m.SearchPets(PetSearch{Query: "fluffy tabby"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, int64(40), results[0].Pet.ID)
+	assert.Contains(t, results[0].Highlights, SearchHighlight{Field: "name", Value: "<em>Fluffy</em>"})
+
+	results, total, err = m.SearchPets(PetSearch{Query: "fluffi", Fuzzy: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, int64(40), results[0].Pet.ID)
 }
 
 func TestCleanUp(t *testing.T) {
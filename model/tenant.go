@@ -0,0 +1,7 @@
+package model
+
+// TenantStorage is implemented by storages keeping the documents of each tenant in a database of its own.
+type TenantStorage interface {
+	// Storage of database, sharing the connection of this one
+	ForDatabase(database string) Storage
+}
@@ -1,16 +1,33 @@
 package model
 
+import (
+	"fmt"
+	"time"
+)
+
+// User of the store. UserStatus is the user status of the API schema and takes no part in access control,
+// which is decided by Role, one of the Role* constants and customer when empty. Version counts changes
+// of the stored user, starting at 1, and DeletedAt is set while the user is deleted but not purged yet.
 type User struct {
-	ID         int64  `json:"id" bson:"id"`
-	Username   string `json:"username" bson:"username"`
-	Firstname  string `json:"firstName" bson:"firstName"`
-	Lastname   string `json:"lastName" bson:"lastName"`
-	Email      string `json:"email" bson:"email"`
-	Password   string `json:"password" bson:"password"`
-	Phone      string `json:"phone" bson:"phone"`
-	UserStatus int32  `json:"userStatus" bson:"userStatus"`
+	ID         int64      `json:"id" bson:"id"`
+	Username   string     `json:"username" bson:"username"`
+	Firstname  string     `json:"firstName" bson:"firstName"`
+	Lastname   string     `json:"lastName" bson:"lastName"`
+	Email      string     `json:"email" bson:"email"`
+	Password   string     `json:"password" bson:"password"`
+	Phone      string     `json:"phone" bson:"phone"`
+	UserStatus int32      `json:"userStatus" bson:"userStatus"`
+	Role       string     `json:"role,omitempty" bson:"role,omitempty"`
+	Version    int64      `json:"version,omitempty" bson:"version"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
 
+const (
+	RoleCustomer string = "customer"
+	RoleStaff    string = "staff"
+	RoleAdmin    string = "admin"
+)
+
 func NewUser(id int64, username, firstname, lastname, email, password, phone string, status int32) *User {
 	return &User{
 		ID:         id,
@@ -23,3 +40,46 @@ func NewUser(id int64, username, firstname, lastname, email, password, phone str
 		UserStatus: status,
 	}
 }
+
+func (u *User) RoleOrDefault() string {
+	if u.Role == "" {
+		return RoleCustomer
+	}
+	return u.Role
+}
+
+func ValidRole(role string) bool {
+	return role == RoleCustomer || role == RoleStaff || role == RoleAdmin
+}
+
+// ValidateUserUpdate checks fields a partial update of a user changes. Usernames identify users and
+// cannot be changed this way.
+func ValidateUserUpdate(u *Update) error {
+	for _, field := range []string{"id", "username", "version", "deletedAt"} {
+		if u.Touches(field) {
+			return fmt.Errorf("%s cannot be changed", field)
+		}
+	}
+	if role, ok := u.Set["role"].(string); ok && role != "" && !ValidRole(role) {
+		return fmt.Errorf("invalid role %s", role)
+	}
+	return nil
+}
+
+// ValidateUserReplacement checks user replacing existing keeps its id and username, filling them in when left out.
+// Sessions find their user by id, so taking another id would take over that user.
+func ValidateUserReplacement(existing *User, user *User) error {
+	if user.ID == 0 {
+		user.ID = existing.ID
+	}
+	if user.Username == "" {
+		user.Username = existing.Username
+	}
+	if user.ID != existing.ID {
+		return fmt.Errorf("id cannot be changed")
+	}
+	if user.Username != existing.Username {
+		return fmt.Errorf("username cannot be changed")
+	}
+	return nil
+}
@@ -26,3 +26,22 @@ func TestNewUser(t *testing.T) {
 	assert.Equal(t, "1234567", user.Phone)
 	assert.Equal(t, int32(0), user.UserStatus)
 }
+
+func TestUserRole(t *testing.T) {
+	u := NewUser(1, "username", "firstname", "lastname", "email@email.com", "password", "123", 1)
+	assert.Equal(t, RoleCustomer, u.RoleOrDefault())
+	u.Role = RoleStaff
+	assert.Equal(t, RoleStaff, u.RoleOrDefault())
+	assert.True(t, ValidRole(RoleAdmin))
+	assert.False(t, ValidRole("root"))
+}
+
+func TestValidateUserReplacement(t *testing.T) {
+	existing := NewUser(1, "username", "firstname", "lastname", "email@email.com", "password", "123", 1)
+	u := &User{Firstname: "other"}
+	assert.NoError(t, ValidateUserReplacement(existing, u))
+	assert.Equal(t, int64(1), u.ID)
+	assert.Equal(t, "username", u.Username)
+	assert.EqualError(t, ValidateUserReplacement(existing, &User{ID: 2, Username: "username"}), "id cannot be changed")
+	assert.EqualError(t, ValidateUserReplacement(existing, &User{ID: 1, Username: "other"}), "username cannot be changed")
+}
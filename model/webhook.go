@@ -0,0 +1,114 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// Webhook subscriptions
+	CollectionWebhooks string = "webhooks"
+	// Deliveries of events to webhooks, pending, delivered or dead
+	CollectionWebhookDeliveries string = "webhook_deliveries"
+)
+
+const (
+	DeliveryPending   string = "pending"
+	DeliveryDelivered string = "delivered"
+	DeliveryDead      string = "dead"
+)
+
+// Webhook subscribes an URL to events. Deliveries are signed with Secret, which is only returned when the
+// webhook is created.
+type Webhook struct {
+	ID int64 `json:"id" bson:"_id"`
+	// URL events are posted to
+	URL string `json:"url" bson:"url"`
+	// Types of events delivered, all of them if empty
+	Events    []string  `json:"events,omitempty" bson:"events,omitempty"`
+	Secret    string    `json:"secret,omitempty" bson:"secret"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Validate checks the URL and event types of a webhook.
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https url")
+	}
+	for _, t := range w.Events {
+		if !IsEventType(t) {
+			return fmt.Errorf("unknown event type %s", t)
+		}
+	}
+	return nil
+}
+
+// Subscribes tells whether events of type t are delivered to the webhook.
+func (w *Webhook) Subscribes(t string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an event to be posted to a webhook, and the outcome of the attempts made so far.
+type Delivery struct {
+	ID            string     `json:"id" bson:"_id"`
+	WebhookID     int64      `json:"webhookId" bson:"webhookId"`
+	Event         *Event     `json:"event" bson:"event"`
+	Status        string     `json:"status" bson:"status"`
+	Attempts      int        `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LastError     string     `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+}
+
+// NewDelivery returns the pending delivery of event to webhook, due at.
+func NewDelivery(webhook *Webhook, event *Event, at time.Time) *Delivery {
+	return &Delivery{
+		ID:            fmt.Sprintf("%d-%d", webhook.ID, event.ID),
+		WebhookID:     webhook.ID,
+		Event:         event,
+		Status:        DeliveryPending,
+		NextAttemptAt: at,
+	}
+}
+
+// WebhookStore keeps webhooks and their deliveries, it is implemented by storages next to their documents.
+type WebhookStore interface {
+	// Create webhook, setting its id
+	CreateWebhook(webhook *Webhook) error
+	// Fetch webhook by id
+	RetrieveWebhookByID(id int64) (*Webhook, error)
+	// Fetch all webhooks
+	FindWebhooks() ([]*Webhook, error)
+	// Delete webhook and its deliveries
+	DeleteWebhookByID(id int64) error
+
+	// Store deliveries, keeping those stored already
+	EnqueueDeliveries(deliveries []*Delivery) error
+	// Fetch pending deliveries due at or before now, earliest first
+	FindDueDeliveries(now time.Time, limit int64) ([]*Delivery, error)
+	// Claim a pending delivery due at or before now by pushing its next attempt to until, and fetch it, ErrNotFound
+	// when it is not due, claimed by another dispatcher meanwhile
+	ClaimDelivery(id string, now time.Time, until time.Time) (*Delivery, error)
+	// Fetch deliveries of webhook with given status, all of them if empty, latest first
+	FindDeliveries(webhookID int64, status string, limit int64) ([]*Delivery, error)
+	// Fetch delivery by id
+	RetrieveDeliveryByID(id string) (*Delivery, error)
+	// Replace delivery
+	UpdateDelivery(delivery *Delivery) error
+
+	// Fetch the position of a named reader of events, 0 when it has read none
+	RetrieveEventCursor(name string) (int64, error)
+	// Record the position of a named reader of events
+	UpdateEventCursor(name string, position int64) error
+}
@@ -0,0 +1,2774 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: petstore.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Category struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Category) Reset()         { *m = Category{} }
+func (m *Category) String() string { return proto.CompactTextString(m) }
+func (*Category) ProtoMessage()    {}
+func (*Category) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{0}
+}
+
+func (m *Category) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Category.Unmarshal(m, b)
+}
+func (m *Category) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Category.Marshal(b, m, deterministic)
+}
+func (m *Category) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Category.Merge(m, src)
+}
+func (m *Category) XXX_Size() int {
+	return xxx_messageInfo_Category.Size(m)
+}
+func (m *Category) XXX_DiscardUnknown() {
+	xxx_messageInfo_Category.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Category proto.InternalMessageInfo
+
+func (m *Category) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Category) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type Tag struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Tag) Reset()         { *m = Tag{} }
+func (m *Tag) String() string { return proto.CompactTextString(m) }
+func (*Tag) ProtoMessage()    {}
+func (*Tag) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{1}
+}
+
+func (m *Tag) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Tag.Unmarshal(m, b)
+}
+func (m *Tag) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Tag.Marshal(b, m, deterministic)
+}
+func (m *Tag) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Tag.Merge(m, src)
+}
+func (m *Tag) XXX_Size() int {
+	return xxx_messageInfo_Tag.Size(m)
+}
+func (m *Tag) XXX_DiscardUnknown() {
+	xxx_messageInfo_Tag.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Tag proto.InternalMessageInfo
+
+func (m *Tag) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Tag) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// Pet for sale. Price is in minor units of currency, stock is not set for pets not tracked in units.
+type Pet struct {
+	Id                   int64                `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Category             *Category            `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	Name                 string               `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	PhotoUrls            []string             `protobuf:"bytes,4,rep,name=photo_urls,json=photoUrls,proto3" json:"photo_urls,omitempty"`
+	Tags                 []*Tag               `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	Status               string               `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	Price                int64                `protobuf:"varint,7,opt,name=price,proto3" json:"price,omitempty"`
+	Currency             string               `protobuf:"bytes,8,opt,name=currency,proto3" json:"currency,omitempty"`
+	Stock                *wrappers.Int64Value `protobuf:"bytes,9,opt,name=stock,proto3" json:"stock,omitempty"`
+	Version              int64                `protobuf:"varint,10,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Pet) Reset()         { *m = Pet{} }
+func (m *Pet) String() string { return proto.CompactTextString(m) }
+func (*Pet) ProtoMessage()    {}
+func (*Pet) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{2}
+}
+
+func (m *Pet) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Pet.Unmarshal(m, b)
+}
+func (m *Pet) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Pet.Marshal(b, m, deterministic)
+}
+func (m *Pet) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Pet.Merge(m, src)
+}
+func (m *Pet) XXX_Size() int {
+	return xxx_messageInfo_Pet.Size(m)
+}
+func (m *Pet) XXX_DiscardUnknown() {
+	xxx_messageInfo_Pet.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Pet proto.InternalMessageInfo
+
+func (m *Pet) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Pet) GetCategory() *Category {
+	if m != nil {
+		return m.Category
+	}
+	return nil
+}
+
+func (m *Pet) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Pet) GetPhotoUrls() []string {
+	if m != nil {
+		return m.PhotoUrls
+	}
+	return nil
+}
+
+func (m *Pet) GetTags() []*Tag {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Pet) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *Pet) GetPrice() int64 {
+	if m != nil {
+		return m.Price
+	}
+	return 0
+}
+
+func (m *Pet) GetCurrency() string {
+	if m != nil {
+		return m.Currency
+	}
+	return ""
+}
+
+func (m *Pet) GetStock() *wrappers.Int64Value {
+	if m != nil {
+		return m.Stock
+	}
+	return nil
+}
+
+func (m *Pet) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type GetPetRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetPetRequest) Reset()         { *m = GetPetRequest{} }
+func (m *GetPetRequest) String() string { return proto.CompactTextString(m) }
+func (*GetPetRequest) ProtoMessage()    {}
+func (*GetPetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{3}
+}
+
+func (m *GetPetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetPetRequest.Unmarshal(m, b)
+}
+func (m *GetPetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetPetRequest.Marshal(b, m, deterministic)
+}
+func (m *GetPetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetPetRequest.Merge(m, src)
+}
+func (m *GetPetRequest) XXX_Size() int {
+	return xxx_messageInfo_GetPetRequest.Size(m)
+}
+func (m *GetPetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetPetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetPetRequest proto.InternalMessageInfo
+
+func (m *GetPetRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+type FindPetsByStatusRequest struct {
+	Status               []string `protobuf:"bytes,1,rep,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FindPetsByStatusRequest) Reset()         { *m = FindPetsByStatusRequest{} }
+func (m *FindPetsByStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*FindPetsByStatusRequest) ProtoMessage()    {}
+func (*FindPetsByStatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{4}
+}
+
+func (m *FindPetsByStatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FindPetsByStatusRequest.Unmarshal(m, b)
+}
+func (m *FindPetsByStatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FindPetsByStatusRequest.Marshal(b, m, deterministic)
+}
+func (m *FindPetsByStatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FindPetsByStatusRequest.Merge(m, src)
+}
+func (m *FindPetsByStatusRequest) XXX_Size() int {
+	return xxx_messageInfo_FindPetsByStatusRequest.Size(m)
+}
+func (m *FindPetsByStatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FindPetsByStatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FindPetsByStatusRequest proto.InternalMessageInfo
+
+func (m *FindPetsByStatusRequest) GetStatus() []string {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type SearchPetsRequest struct {
+	Query                string   `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Status               []string `protobuf:"bytes,2,rep,name=status,proto3" json:"status,omitempty"`
+	Fuzzy                bool     `protobuf:"varint,3,opt,name=fuzzy,proto3" json:"fuzzy,omitempty"`
+	Offset               int64    `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	Limit                int64    `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SearchPetsRequest) Reset()         { *m = SearchPetsRequest{} }
+func (m *SearchPetsRequest) String() string { return proto.CompactTextString(m) }
+func (*SearchPetsRequest) ProtoMessage()    {}
+func (*SearchPetsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{5}
+}
+
+func (m *SearchPetsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchPetsRequest.Unmarshal(m, b)
+}
+func (m *SearchPetsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchPetsRequest.Marshal(b, m, deterministic)
+}
+func (m *SearchPetsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchPetsRequest.Merge(m, src)
+}
+func (m *SearchPetsRequest) XXX_Size() int {
+	return xxx_messageInfo_SearchPetsRequest.Size(m)
+}
+func (m *SearchPetsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchPetsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchPetsRequest proto.InternalMessageInfo
+
+func (m *SearchPetsRequest) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *SearchPetsRequest) GetStatus() []string {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *SearchPetsRequest) GetFuzzy() bool {
+	if m != nil {
+		return m.Fuzzy
+	}
+	return false
+}
+
+func (m *SearchPetsRequest) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *SearchPetsRequest) GetLimit() int64 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type SearchHighlight struct {
+	Field                string   `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SearchHighlight) Reset()         { *m = SearchHighlight{} }
+func (m *SearchHighlight) String() string { return proto.CompactTextString(m) }
+func (*SearchHighlight) ProtoMessage()    {}
+func (*SearchHighlight) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{6}
+}
+
+func (m *SearchHighlight) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchHighlight.Unmarshal(m, b)
+}
+func (m *SearchHighlight) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchHighlight.Marshal(b, m, deterministic)
+}
+func (m *SearchHighlight) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchHighlight.Merge(m, src)
+}
+func (m *SearchHighlight) XXX_Size() int {
+	return xxx_messageInfo_SearchHighlight.Size(m)
+}
+func (m *SearchHighlight) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchHighlight.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchHighlight proto.InternalMessageInfo
+
+func (m *SearchHighlight) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *SearchHighlight) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type PetSearchResult struct {
+	Pet                  *Pet               `protobuf:"bytes,1,opt,name=pet,proto3" json:"pet,omitempty"`
+	Score                float64            `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	Highlights           []*SearchHighlight `protobuf:"bytes,3,rep,name=highlights,proto3" json:"highlights,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *PetSearchResult) Reset()         { *m = PetSearchResult{} }
+func (m *PetSearchResult) String() string { return proto.CompactTextString(m) }
+func (*PetSearchResult) ProtoMessage()    {}
+func (*PetSearchResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{7}
+}
+
+func (m *PetSearchResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PetSearchResult.Unmarshal(m, b)
+}
+func (m *PetSearchResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PetSearchResult.Marshal(b, m, deterministic)
+}
+func (m *PetSearchResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PetSearchResult.Merge(m, src)
+}
+func (m *PetSearchResult) XXX_Size() int {
+	return xxx_messageInfo_PetSearchResult.Size(m)
+}
+func (m *PetSearchResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_PetSearchResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PetSearchResult proto.InternalMessageInfo
+
+func (m *PetSearchResult) GetPet() *Pet {
+	if m != nil {
+		return m.Pet
+	}
+	return nil
+}
+
+func (m *PetSearchResult) GetScore() float64 {
+	if m != nil {
+		return m.Score
+	}
+	return 0
+}
+
+func (m *PetSearchResult) GetHighlights() []*SearchHighlight {
+	if m != nil {
+		return m.Highlights
+	}
+	return nil
+}
+
+type SearchPetsResponse struct {
+	Results              []*PetSearchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	Total                int64              `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *SearchPetsResponse) Reset()         { *m = SearchPetsResponse{} }
+func (m *SearchPetsResponse) String() string { return proto.CompactTextString(m) }
+func (*SearchPetsResponse) ProtoMessage()    {}
+func (*SearchPetsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{8}
+}
+
+func (m *SearchPetsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchPetsResponse.Unmarshal(m, b)
+}
+func (m *SearchPetsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchPetsResponse.Marshal(b, m, deterministic)
+}
+func (m *SearchPetsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchPetsResponse.Merge(m, src)
+}
+func (m *SearchPetsResponse) XXX_Size() int {
+	return xxx_messageInfo_SearchPetsResponse.Size(m)
+}
+func (m *SearchPetsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchPetsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchPetsResponse proto.InternalMessageInfo
+
+func (m *SearchPetsResponse) GetResults() []*PetSearchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *SearchPetsResponse) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+// Name and status of a pet to set, either may be empty, only if the pet is at version unless it is 0.
+type UpdatePetRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Version              int64    `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Status               string   `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdatePetRequest) Reset()         { *m = UpdatePetRequest{} }
+func (m *UpdatePetRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdatePetRequest) ProtoMessage()    {}
+func (*UpdatePetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{9}
+}
+
+func (m *UpdatePetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdatePetRequest.Unmarshal(m, b)
+}
+func (m *UpdatePetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdatePetRequest.Marshal(b, m, deterministic)
+}
+func (m *UpdatePetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdatePetRequest.Merge(m, src)
+}
+func (m *UpdatePetRequest) XXX_Size() int {
+	return xxx_messageInfo_UpdatePetRequest.Size(m)
+}
+func (m *UpdatePetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdatePetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdatePetRequest proto.InternalMessageInfo
+
+func (m *UpdatePetRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *UpdatePetRequest) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *UpdatePetRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *UpdatePetRequest) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+type DeletePetRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Hard                 bool     `protobuf:"varint,2,opt,name=hard,proto3" json:"hard,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeletePetRequest) Reset()         { *m = DeletePetRequest{} }
+func (m *DeletePetRequest) String() string { return proto.CompactTextString(m) }
+func (*DeletePetRequest) ProtoMessage()    {}
+func (*DeletePetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{10}
+}
+
+func (m *DeletePetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeletePetRequest.Unmarshal(m, b)
+}
+func (m *DeletePetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeletePetRequest.Marshal(b, m, deterministic)
+}
+func (m *DeletePetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeletePetRequest.Merge(m, src)
+}
+func (m *DeletePetRequest) XXX_Size() int {
+	return xxx_messageInfo_DeletePetRequest.Size(m)
+}
+func (m *DeletePetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeletePetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeletePetRequest proto.InternalMessageInfo
+
+func (m *DeletePetRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *DeletePetRequest) GetHard() bool {
+	if m != nil {
+		return m.Hard
+	}
+	return false
+}
+
+type RestorePetRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RestorePetRequest) Reset()         { *m = RestorePetRequest{} }
+func (m *RestorePetRequest) String() string { return proto.CompactTextString(m) }
+func (*RestorePetRequest) ProtoMessage()    {}
+func (*RestorePetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{11}
+}
+
+func (m *RestorePetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestorePetRequest.Unmarshal(m, b)
+}
+func (m *RestorePetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestorePetRequest.Marshal(b, m, deterministic)
+}
+func (m *RestorePetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestorePetRequest.Merge(m, src)
+}
+func (m *RestorePetRequest) XXX_Size() int {
+	return xxx_messageInfo_RestorePetRequest.Size(m)
+}
+func (m *RestorePetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestorePetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestorePetRequest proto.InternalMessageInfo
+
+func (m *RestorePetRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+// Order of a pet. Unit price and total are in minor units of currency, recorded from the pet when placed.
+type Order struct {
+	Id                   int64                `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	PetId                int64                `protobuf:"varint,2,opt,name=pet_id,json=petId,proto3" json:"pet_id,omitempty"`
+	Quantity             int32                `protobuf:"varint,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	ShipDate             *timestamp.Timestamp `protobuf:"bytes,4,opt,name=ship_date,json=shipDate,proto3" json:"ship_date,omitempty"`
+	Status               string               `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Complete             bool                 `protobuf:"varint,6,opt,name=complete,proto3" json:"complete,omitempty"`
+	UserId               int64                `protobuf:"varint,7,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UnitPrice            int64                `protobuf:"varint,8,opt,name=unit_price,json=unitPrice,proto3" json:"unit_price,omitempty"`
+	Currency             string               `protobuf:"bytes,9,opt,name=currency,proto3" json:"currency,omitempty"`
+	Total                int64                `protobuf:"varint,10,opt,name=total,proto3" json:"total,omitempty"`
+	Version              int64                `protobuf:"varint,11,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Order) Reset()         { *m = Order{} }
+func (m *Order) String() string { return proto.CompactTextString(m) }
+func (*Order) ProtoMessage()    {}
+func (*Order) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{12}
+}
+
+func (m *Order) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Order.Unmarshal(m, b)
+}
+func (m *Order) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Order.Marshal(b, m, deterministic)
+}
+func (m *Order) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Order.Merge(m, src)
+}
+func (m *Order) XXX_Size() int {
+	return xxx_messageInfo_Order.Size(m)
+}
+func (m *Order) XXX_DiscardUnknown() {
+	xxx_messageInfo_Order.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Order proto.InternalMessageInfo
+
+func (m *Order) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Order) GetPetId() int64 {
+	if m != nil {
+		return m.PetId
+	}
+	return 0
+}
+
+func (m *Order) GetQuantity() int32 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *Order) GetShipDate() *timestamp.Timestamp {
+	if m != nil {
+		return m.ShipDate
+	}
+	return nil
+}
+
+func (m *Order) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *Order) GetComplete() bool {
+	if m != nil {
+		return m.Complete
+	}
+	return false
+}
+
+func (m *Order) GetUserId() int64 {
+	if m != nil {
+		return m.UserId
+	}
+	return 0
+}
+
+func (m *Order) GetUnitPrice() int64 {
+	if m != nil {
+		return m.UnitPrice
+	}
+	return 0
+}
+
+func (m *Order) GetCurrency() string {
+	if m != nil {
+		return m.Currency
+	}
+	return ""
+}
+
+func (m *Order) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *Order) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type Inventory struct {
+	// Units in stock by pet status
+	Counts               map[string]int64 `protobuf:"bytes,1,rep,name=counts,proto3" json:"counts,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *Inventory) Reset()         { *m = Inventory{} }
+func (m *Inventory) String() string { return proto.CompactTextString(m) }
+func (*Inventory) ProtoMessage()    {}
+func (*Inventory) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{13}
+}
+
+func (m *Inventory) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Inventory.Unmarshal(m, b)
+}
+func (m *Inventory) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Inventory.Marshal(b, m, deterministic)
+}
+func (m *Inventory) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Inventory.Merge(m, src)
+}
+func (m *Inventory) XXX_Size() int {
+	return xxx_messageInfo_Inventory.Size(m)
+}
+func (m *Inventory) XXX_DiscardUnknown() {
+	xxx_messageInfo_Inventory.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Inventory proto.InternalMessageInfo
+
+func (m *Inventory) GetCounts() map[string]int64 {
+	if m != nil {
+		return m.Counts
+	}
+	return nil
+}
+
+type StockValue struct {
+	// Value of units in stock by currency
+	Values               map[string]int64 `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *StockValue) Reset()         { *m = StockValue{} }
+func (m *StockValue) String() string { return proto.CompactTextString(m) }
+func (*StockValue) ProtoMessage()    {}
+func (*StockValue) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{14}
+}
+
+func (m *StockValue) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StockValue.Unmarshal(m, b)
+}
+func (m *StockValue) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StockValue.Marshal(b, m, deterministic)
+}
+func (m *StockValue) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StockValue.Merge(m, src)
+}
+func (m *StockValue) XXX_Size() int {
+	return xxx_messageInfo_StockValue.Size(m)
+}
+func (m *StockValue) XXX_DiscardUnknown() {
+	xxx_messageInfo_StockValue.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StockValue proto.InternalMessageInfo
+
+func (m *StockValue) GetValues() map[string]int64 {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+type GetOrderRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetOrderRequest) Reset()         { *m = GetOrderRequest{} }
+func (m *GetOrderRequest) String() string { return proto.CompactTextString(m) }
+func (*GetOrderRequest) ProtoMessage()    {}
+func (*GetOrderRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{15}
+}
+
+func (m *GetOrderRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetOrderRequest.Unmarshal(m, b)
+}
+func (m *GetOrderRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetOrderRequest.Marshal(b, m, deterministic)
+}
+func (m *GetOrderRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetOrderRequest.Merge(m, src)
+}
+func (m *GetOrderRequest) XXX_Size() int {
+	return xxx_messageInfo_GetOrderRequest.Size(m)
+}
+func (m *GetOrderRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetOrderRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetOrderRequest proto.InternalMessageInfo
+
+func (m *GetOrderRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+type FindOrdersRequest struct {
+	UserId               int64                `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username             string               `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	PetId                int64                `protobuf:"varint,3,opt,name=pet_id,json=petId,proto3" json:"pet_id,omitempty"`
+	Status               []string             `protobuf:"bytes,4,rep,name=status,proto3" json:"status,omitempty"`
+	ShipDateFrom         *timestamp.Timestamp `protobuf:"bytes,5,opt,name=ship_date_from,json=shipDateFrom,proto3" json:"ship_date_from,omitempty"`
+	ShipDateTo           *timestamp.Timestamp `protobuf:"bytes,6,opt,name=ship_date_to,json=shipDateTo,proto3" json:"ship_date_to,omitempty"`
+	Offset               int64                `protobuf:"varint,7,opt,name=offset,proto3" json:"offset,omitempty"`
+	Limit                int64                `protobuf:"varint,8,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *FindOrdersRequest) Reset()         { *m = FindOrdersRequest{} }
+func (m *FindOrdersRequest) String() string { return proto.CompactTextString(m) }
+func (*FindOrdersRequest) ProtoMessage()    {}
+func (*FindOrdersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{16}
+}
+
+func (m *FindOrdersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FindOrdersRequest.Unmarshal(m, b)
+}
+func (m *FindOrdersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FindOrdersRequest.Marshal(b, m, deterministic)
+}
+func (m *FindOrdersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FindOrdersRequest.Merge(m, src)
+}
+func (m *FindOrdersRequest) XXX_Size() int {
+	return xxx_messageInfo_FindOrdersRequest.Size(m)
+}
+func (m *FindOrdersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FindOrdersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FindOrdersRequest proto.InternalMessageInfo
+
+func (m *FindOrdersRequest) GetUserId() int64 {
+	if m != nil {
+		return m.UserId
+	}
+	return 0
+}
+
+func (m *FindOrdersRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *FindOrdersRequest) GetPetId() int64 {
+	if m != nil {
+		return m.PetId
+	}
+	return 0
+}
+
+func (m *FindOrdersRequest) GetStatus() []string {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *FindOrdersRequest) GetShipDateFrom() *timestamp.Timestamp {
+	if m != nil {
+		return m.ShipDateFrom
+	}
+	return nil
+}
+
+func (m *FindOrdersRequest) GetShipDateTo() *timestamp.Timestamp {
+	if m != nil {
+		return m.ShipDateTo
+	}
+	return nil
+}
+
+func (m *FindOrdersRequest) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *FindOrdersRequest) GetLimit() int64 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type FindOrdersResponse struct {
+	Orders               []*Order `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
+	Total                int64    `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FindOrdersResponse) Reset()         { *m = FindOrdersResponse{} }
+func (m *FindOrdersResponse) String() string { return proto.CompactTextString(m) }
+func (*FindOrdersResponse) ProtoMessage()    {}
+func (*FindOrdersResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{17}
+}
+
+func (m *FindOrdersResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FindOrdersResponse.Unmarshal(m, b)
+}
+func (m *FindOrdersResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FindOrdersResponse.Marshal(b, m, deterministic)
+}
+func (m *FindOrdersResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FindOrdersResponse.Merge(m, src)
+}
+func (m *FindOrdersResponse) XXX_Size() int {
+	return xxx_messageInfo_FindOrdersResponse.Size(m)
+}
+func (m *FindOrdersResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_FindOrdersResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FindOrdersResponse proto.InternalMessageInfo
+
+func (m *FindOrdersResponse) GetOrders() []*Order {
+	if m != nil {
+		return m.Orders
+	}
+	return nil
+}
+
+func (m *FindOrdersResponse) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+// Status of an order to set, only if the order is at version unless it is 0.
+type UpdateOrderStatusRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Version              int64    `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Status               string   `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateOrderStatusRequest) Reset()         { *m = UpdateOrderStatusRequest{} }
+func (m *UpdateOrderStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateOrderStatusRequest) ProtoMessage()    {}
+func (*UpdateOrderStatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{18}
+}
+
+func (m *UpdateOrderStatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateOrderStatusRequest.Unmarshal(m, b)
+}
+func (m *UpdateOrderStatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateOrderStatusRequest.Marshal(b, m, deterministic)
+}
+func (m *UpdateOrderStatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateOrderStatusRequest.Merge(m, src)
+}
+func (m *UpdateOrderStatusRequest) XXX_Size() int {
+	return xxx_messageInfo_UpdateOrderStatusRequest.Size(m)
+}
+func (m *UpdateOrderStatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateOrderStatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateOrderStatusRequest proto.InternalMessageInfo
+
+func (m *UpdateOrderStatusRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *UpdateOrderStatusRequest) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *UpdateOrderStatusRequest) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+type DeleteOrderRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Hard                 bool     `protobuf:"varint,2,opt,name=hard,proto3" json:"hard,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteOrderRequest) Reset()         { *m = DeleteOrderRequest{} }
+func (m *DeleteOrderRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteOrderRequest) ProtoMessage()    {}
+func (*DeleteOrderRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{19}
+}
+
+func (m *DeleteOrderRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteOrderRequest.Unmarshal(m, b)
+}
+func (m *DeleteOrderRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteOrderRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteOrderRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteOrderRequest.Merge(m, src)
+}
+func (m *DeleteOrderRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteOrderRequest.Size(m)
+}
+func (m *DeleteOrderRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteOrderRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteOrderRequest proto.InternalMessageInfo
+
+func (m *DeleteOrderRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *DeleteOrderRequest) GetHard() bool {
+	if m != nil {
+		return m.Hard
+	}
+	return false
+}
+
+type RestoreOrderRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RestoreOrderRequest) Reset()         { *m = RestoreOrderRequest{} }
+func (m *RestoreOrderRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreOrderRequest) ProtoMessage()    {}
+func (*RestoreOrderRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{20}
+}
+
+func (m *RestoreOrderRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestoreOrderRequest.Unmarshal(m, b)
+}
+func (m *RestoreOrderRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestoreOrderRequest.Marshal(b, m, deterministic)
+}
+func (m *RestoreOrderRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreOrderRequest.Merge(m, src)
+}
+func (m *RestoreOrderRequest) XXX_Size() int {
+	return xxx_messageInfo_RestoreOrderRequest.Size(m)
+}
+func (m *RestoreOrderRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreOrderRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestoreOrderRequest proto.InternalMessageInfo
+
+func (m *RestoreOrderRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+// User of the store. Password is only set in requests.
+type User struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Username             string   `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	FirstName            string   `protobuf:"bytes,3,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName             string   `protobuf:"bytes,4,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+	Email                string   `protobuf:"bytes,5,opt,name=email,proto3" json:"email,omitempty"`
+	Password             string   `protobuf:"bytes,6,opt,name=password,proto3" json:"password,omitempty"`
+	Phone                string   `protobuf:"bytes,7,opt,name=phone,proto3" json:"phone,omitempty"`
+	UserStatus           int32    `protobuf:"varint,8,opt,name=user_status,json=userStatus,proto3" json:"user_status,omitempty"`
+	Role                 string   `protobuf:"bytes,9,opt,name=role,proto3" json:"role,omitempty"`
+	Version              int64    `protobuf:"varint,10,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *User) Reset()         { *m = User{} }
+func (m *User) String() string { return proto.CompactTextString(m) }
+func (*User) ProtoMessage()    {}
+func (*User) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{21}
+}
+
+func (m *User) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_User.Unmarshal(m, b)
+}
+func (m *User) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_User.Marshal(b, m, deterministic)
+}
+func (m *User) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_User.Merge(m, src)
+}
+func (m *User) XXX_Size() int {
+	return xxx_messageInfo_User.Size(m)
+}
+func (m *User) XXX_DiscardUnknown() {
+	xxx_messageInfo_User.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_User proto.InternalMessageInfo
+
+func (m *User) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *User) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *User) GetFirstName() string {
+	if m != nil {
+		return m.FirstName
+	}
+	return ""
+}
+
+func (m *User) GetLastName() string {
+	if m != nil {
+		return m.LastName
+	}
+	return ""
+}
+
+func (m *User) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *User) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *User) GetPhone() string {
+	if m != nil {
+		return m.Phone
+	}
+	return ""
+}
+
+func (m *User) GetUserStatus() int32 {
+	if m != nil {
+		return m.UserStatus
+	}
+	return 0
+}
+
+func (m *User) GetRole() string {
+	if m != nil {
+		return m.Role
+	}
+	return ""
+}
+
+func (m *User) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type CreateUsersRequest struct {
+	Users                []*User  `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateUsersRequest) Reset()         { *m = CreateUsersRequest{} }
+func (m *CreateUsersRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateUsersRequest) ProtoMessage()    {}
+func (*CreateUsersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{22}
+}
+
+func (m *CreateUsersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateUsersRequest.Unmarshal(m, b)
+}
+func (m *CreateUsersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateUsersRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateUsersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateUsersRequest.Merge(m, src)
+}
+func (m *CreateUsersRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateUsersRequest.Size(m)
+}
+func (m *CreateUsersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateUsersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateUsersRequest proto.InternalMessageInfo
+
+func (m *CreateUsersRequest) GetUsers() []*User {
+	if m != nil {
+		return m.Users
+	}
+	return nil
+}
+
+type LoginRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoginRequest) Reset()         { *m = LoginRequest{} }
+func (m *LoginRequest) String() string { return proto.CompactTextString(m) }
+func (*LoginRequest) ProtoMessage()    {}
+func (*LoginRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{23}
+}
+
+func (m *LoginRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoginRequest.Unmarshal(m, b)
+}
+func (m *LoginRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoginRequest.Marshal(b, m, deterministic)
+}
+func (m *LoginRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoginRequest.Merge(m, src)
+}
+func (m *LoginRequest) XXX_Size() int {
+	return xxx_messageInfo_LoginRequest.Size(m)
+}
+func (m *LoginRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoginRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoginRequest proto.InternalMessageInfo
+
+func (m *LoginRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *LoginRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+// Session token to pass in authorization metadata, as "Bearer <token>".
+type Session struct {
+	Token                string               `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	UserId               int64                `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username             string               `protobuf:"bytes,3,opt,name=username,proto3" json:"username,omitempty"`
+	ExpiresAt            *timestamp.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Session) Reset()         { *m = Session{} }
+func (m *Session) String() string { return proto.CompactTextString(m) }
+func (*Session) ProtoMessage()    {}
+func (*Session) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{24}
+}
+
+func (m *Session) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Session.Unmarshal(m, b)
+}
+func (m *Session) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Session.Marshal(b, m, deterministic)
+}
+func (m *Session) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Session.Merge(m, src)
+}
+func (m *Session) XXX_Size() int {
+	return xxx_messageInfo_Session.Size(m)
+}
+func (m *Session) XXX_DiscardUnknown() {
+	xxx_messageInfo_Session.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Session proto.InternalMessageInfo
+
+func (m *Session) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *Session) GetUserId() int64 {
+	if m != nil {
+		return m.UserId
+	}
+	return 0
+}
+
+func (m *Session) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *Session) GetExpiresAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.ExpiresAt
+	}
+	return nil
+}
+
+type GetUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetUserRequest) Reset()         { *m = GetUserRequest{} }
+func (m *GetUserRequest) String() string { return proto.CompactTextString(m) }
+func (*GetUserRequest) ProtoMessage()    {}
+func (*GetUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{25}
+}
+
+func (m *GetUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetUserRequest.Unmarshal(m, b)
+}
+func (m *GetUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetUserRequest.Marshal(b, m, deterministic)
+}
+func (m *GetUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetUserRequest.Merge(m, src)
+}
+func (m *GetUserRequest) XXX_Size() int {
+	return xxx_messageInfo_GetUserRequest.Size(m)
+}
+func (m *GetUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetUserRequest proto.InternalMessageInfo
+
+func (m *GetUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+// User to store in place of username, only if it is at user.version unless it is 0.
+type UpdateUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	User                 *User    `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateUserRequest) Reset()         { *m = UpdateUserRequest{} }
+func (m *UpdateUserRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateUserRequest) ProtoMessage()    {}
+func (*UpdateUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{26}
+}
+
+func (m *UpdateUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateUserRequest.Unmarshal(m, b)
+}
+func (m *UpdateUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateUserRequest.Marshal(b, m, deterministic)
+}
+func (m *UpdateUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateUserRequest.Merge(m, src)
+}
+func (m *UpdateUserRequest) XXX_Size() int {
+	return xxx_messageInfo_UpdateUserRequest.Size(m)
+}
+func (m *UpdateUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateUserRequest proto.InternalMessageInfo
+
+func (m *UpdateUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *UpdateUserRequest) GetUser() *User {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+type DeleteUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Hard                 bool     `protobuf:"varint,2,opt,name=hard,proto3" json:"hard,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteUserRequest) Reset()         { *m = DeleteUserRequest{} }
+func (m *DeleteUserRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteUserRequest) ProtoMessage()    {}
+func (*DeleteUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{27}
+}
+
+func (m *DeleteUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteUserRequest.Unmarshal(m, b)
+}
+func (m *DeleteUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteUserRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteUserRequest.Merge(m, src)
+}
+func (m *DeleteUserRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteUserRequest.Size(m)
+}
+func (m *DeleteUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteUserRequest proto.InternalMessageInfo
+
+func (m *DeleteUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *DeleteUserRequest) GetHard() bool {
+	if m != nil {
+		return m.Hard
+	}
+	return false
+}
+
+type RestoreUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RestoreUserRequest) Reset()         { *m = RestoreUserRequest{} }
+func (m *RestoreUserRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreUserRequest) ProtoMessage()    {}
+func (*RestoreUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{28}
+}
+
+func (m *RestoreUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestoreUserRequest.Unmarshal(m, b)
+}
+func (m *RestoreUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestoreUserRequest.Marshal(b, m, deterministic)
+}
+func (m *RestoreUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreUserRequest.Merge(m, src)
+}
+func (m *RestoreUserRequest) XXX_Size() int {
+	return xxx_messageInfo_RestoreUserRequest.Size(m)
+}
+func (m *RestoreUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestoreUserRequest proto.InternalMessageInfo
+
+func (m *RestoreUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+type UnlockUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UnlockUserRequest) Reset()         { *m = UnlockUserRequest{} }
+func (m *UnlockUserRequest) String() string { return proto.CompactTextString(m) }
+func (*UnlockUserRequest) ProtoMessage()    {}
+func (*UnlockUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_749e5a3d28fcc1b1, []int{29}
+}
+
+func (m *UnlockUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnlockUserRequest.Unmarshal(m, b)
+}
+func (m *UnlockUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnlockUserRequest.Marshal(b, m, deterministic)
+}
+func (m *UnlockUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnlockUserRequest.Merge(m, src)
+}
+func (m *UnlockUserRequest) XXX_Size() int {
+	return xxx_messageInfo_UnlockUserRequest.Size(m)
+}
+func (m *UnlockUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnlockUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnlockUserRequest proto.InternalMessageInfo
+
+func (m *UnlockUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Category)(nil), "petstore.v1.Category")
+	proto.RegisterType((*Tag)(nil), "petstore.v1.Tag")
+	proto.RegisterType((*Pet)(nil), "petstore.v1.Pet")
+	proto.RegisterType((*GetPetRequest)(nil), "petstore.v1.GetPetRequest")
+	proto.RegisterType((*FindPetsByStatusRequest)(nil), "petstore.v1.FindPetsByStatusRequest")
+	proto.RegisterType((*SearchPetsRequest)(nil), "petstore.v1.SearchPetsRequest")
+	proto.RegisterType((*SearchHighlight)(nil), "petstore.v1.SearchHighlight")
+	proto.RegisterType((*PetSearchResult)(nil), "petstore.v1.PetSearchResult")
+	proto.RegisterType((*SearchPetsResponse)(nil), "petstore.v1.SearchPetsResponse")
+	proto.RegisterType((*UpdatePetRequest)(nil), "petstore.v1.UpdatePetRequest")
+	proto.RegisterType((*DeletePetRequest)(nil), "petstore.v1.DeletePetRequest")
+	proto.RegisterType((*RestorePetRequest)(nil), "petstore.v1.RestorePetRequest")
+	proto.RegisterType((*Order)(nil), "petstore.v1.Order")
+	proto.RegisterType((*Inventory)(nil), "petstore.v1.Inventory")
+	proto.RegisterMapType((map[string]int64)(nil), "petstore.v1.Inventory.CountsEntry")
+	proto.RegisterType((*StockValue)(nil), "petstore.v1.StockValue")
+	proto.RegisterMapType((map[string]int64)(nil), "petstore.v1.StockValue.ValuesEntry")
+	proto.RegisterType((*GetOrderRequest)(nil), "petstore.v1.GetOrderRequest")
+	proto.RegisterType((*FindOrdersRequest)(nil), "petstore.v1.FindOrdersRequest")
+	proto.RegisterType((*FindOrdersResponse)(nil), "petstore.v1.FindOrdersResponse")
+	proto.RegisterType((*UpdateOrderStatusRequest)(nil), "petstore.v1.UpdateOrderStatusRequest")
+	proto.RegisterType((*DeleteOrderRequest)(nil), "petstore.v1.DeleteOrderRequest")
+	proto.RegisterType((*RestoreOrderRequest)(nil), "petstore.v1.RestoreOrderRequest")
+	proto.RegisterType((*User)(nil), "petstore.v1.User")
+	proto.RegisterType((*CreateUsersRequest)(nil), "petstore.v1.CreateUsersRequest")
+	proto.RegisterType((*LoginRequest)(nil), "petstore.v1.LoginRequest")
+	proto.RegisterType((*Session)(nil), "petstore.v1.Session")
+	proto.RegisterType((*GetUserRequest)(nil), "petstore.v1.GetUserRequest")
+	proto.RegisterType((*UpdateUserRequest)(nil), "petstore.v1.UpdateUserRequest")
+	proto.RegisterType((*DeleteUserRequest)(nil), "petstore.v1.DeleteUserRequest")
+	proto.RegisterType((*RestoreUserRequest)(nil), "petstore.v1.RestoreUserRequest")
+	proto.RegisterType((*UnlockUserRequest)(nil), "petstore.v1.UnlockUserRequest")
+}
+
+func init() { proto.RegisterFile("petstore.proto", fileDescriptor_749e5a3d28fcc1b1) }
+
+var fileDescriptor_749e5a3d28fcc1b1 = []byte{
+	// 1586 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x57, 0x5f, 0x4f, 0xdc, 0x46,
+	0x10, 0xd7, 0xfd, 0xc5, 0x9e, 0xa3, 0x04, 0xb6, 0x84, 0xb8, 0x26, 0x09, 0xd4, 0x49, 0x54, 0x5a,
+	0x45, 0x47, 0xa0, 0x11, 0x25, 0x69, 0x92, 0x86, 0x40, 0xa0, 0x48, 0x49, 0x8a, 0x0c, 0xe4, 0xa1,
+	0xaa, 0x84, 0xcc, 0xdd, 0x72, 0x67, 0xe1, 0xb3, 0x1d, 0xef, 0x9a, 0x94, 0x3c, 0x56, 0xcd, 0x43,
+	0x9f, 0xf2, 0x29, 0xfa, 0x01, 0xfa, 0x56, 0xa9, 0x5f, 0xa0, 0x1f, 0xab, 0xda, 0x5d, 0xff, 0x59,
+	0xff, 0x3b, 0xae, 0x2f, 0x27, 0xcf, 0xee, 0xec, 0xec, 0xcc, 0xec, 0x6f, 0x7e, 0x33, 0x07, 0x33,
+	0x3e, 0xa6, 0x84, 0x7a, 0x01, 0xee, 0xfa, 0x81, 0x47, 0x3d, 0xd4, 0x49, 0xe4, 0x8b, 0x35, 0x7d,
+	0x71, 0xe0, 0x79, 0x03, 0x07, 0xaf, 0xf2, 0xad, 0xd3, 0xf0, 0x6c, 0x15, 0x8f, 0x7c, 0x7a, 0x29,
+	0x34, 0xf5, 0xa5, 0xfc, 0x26, 0xb5, 0x47, 0x98, 0x50, 0x6b, 0xe4, 0x47, 0x0a, 0xb7, 0xf3, 0x0a,
+	0xef, 0x03, 0xcb, 0xf7, 0x71, 0x40, 0xc4, 0xbe, 0xd1, 0x05, 0x65, 0xdb, 0xa2, 0x78, 0xe0, 0x05,
+	0x97, 0x68, 0x06, 0xea, 0x76, 0x5f, 0xab, 0x2d, 0xd7, 0x56, 0x1a, 0x66, 0xdd, 0xee, 0x23, 0x04,
+	0x4d, 0xd7, 0x1a, 0x61, 0xad, 0xbe, 0x5c, 0x5b, 0x51, 0x4d, 0xfe, 0x6d, 0x7c, 0x0d, 0x8d, 0x23,
+	0x6b, 0x30, 0x91, 0xea, 0xdf, 0x75, 0x68, 0x1c, 0x60, 0x5a, 0xd0, 0x5d, 0x03, 0xa5, 0x17, 0x5d,
+	0xc9, 0xf5, 0x3b, 0xeb, 0xd7, 0xbb, 0x52, 0xc0, 0xdd, 0xd8, 0x1f, 0x33, 0x51, 0x4b, 0xcc, 0x37,
+	0x52, 0xf3, 0xe8, 0x16, 0x80, 0x3f, 0xf4, 0xa8, 0x77, 0x12, 0x06, 0x0e, 0xd1, 0x9a, 0xcb, 0x8d,
+	0x15, 0xd5, 0x54, 0xf9, 0xca, 0x71, 0xe0, 0x10, 0x74, 0x17, 0x9a, 0xd4, 0x1a, 0x10, 0xad, 0xb5,
+	0xdc, 0x58, 0xe9, 0xac, 0xcf, 0x66, 0x6e, 0x38, 0xb2, 0x06, 0x26, 0xdf, 0x45, 0x0b, 0xd0, 0x26,
+	0xd4, 0xa2, 0x21, 0xd1, 0xda, 0xdc, 0x74, 0x24, 0xa1, 0x79, 0x68, 0xf9, 0x81, 0xdd, 0xc3, 0xda,
+	0x14, 0x77, 0x5b, 0x08, 0x48, 0x07, 0xa5, 0x17, 0x06, 0x01, 0x76, 0x7b, 0x97, 0x9a, 0xc2, 0xf5,
+	0x13, 0x19, 0xad, 0x41, 0x8b, 0x50, 0xaf, 0x77, 0xae, 0xa9, 0x3c, 0xa4, 0xc5, 0xae, 0x48, 0x7c,
+	0x37, 0x4e, 0x7c, 0x77, 0xdf, 0xa5, 0x1b, 0x0f, 0xdf, 0x5a, 0x4e, 0x88, 0x4d, 0xa1, 0x89, 0x34,
+	0x98, 0xba, 0xc0, 0x01, 0xb1, 0x3d, 0x57, 0x03, 0x7e, 0x4d, 0x2c, 0x1a, 0x4b, 0xf0, 0xd9, 0x1e,
+	0xa6, 0x07, 0x98, 0x9a, 0xf8, 0x5d, 0x88, 0x49, 0x21, 0x87, 0xc6, 0x1a, 0xdc, 0xd8, 0xb5, 0xdd,
+	0xfe, 0x01, 0xa6, 0xe4, 0xc5, 0xe5, 0x21, 0xf7, 0x39, 0x56, 0x4d, 0x43, 0xaa, 0xf1, 0x9c, 0x44,
+	0x92, 0xf1, 0xb1, 0x06, 0x73, 0x87, 0xd8, 0x0a, 0x7a, 0x43, 0x76, 0x2a, 0xd6, 0x9e, 0x87, 0xd6,
+	0xbb, 0x10, 0x07, 0x97, 0xdc, 0xb6, 0x6a, 0x0a, 0x41, 0xb2, 0x51, 0x97, 0x6d, 0x30, 0xed, 0xb3,
+	0xf0, 0xc3, 0x87, 0x4b, 0xfe, 0x10, 0x8a, 0x29, 0x04, 0xa6, 0xed, 0x9d, 0x9d, 0x11, 0x4c, 0xb5,
+	0x26, 0x77, 0x30, 0x92, 0x98, 0xb6, 0x63, 0x8f, 0x6c, 0xaa, 0xb5, 0x44, 0x12, 0xb9, 0x60, 0x3c,
+	0x85, 0x6b, 0xc2, 0x8d, 0x1f, 0xed, 0xc1, 0xd0, 0xb1, 0x07, 0x43, 0xae, 0x78, 0x66, 0x63, 0xa7,
+	0x1f, 0x3b, 0xc1, 0x05, 0xb6, 0x7a, 0xc1, 0xd2, 0x15, 0x81, 0x4a, 0x08, 0xc6, 0x1f, 0x35, 0xb8,
+	0x76, 0x80, 0xa9, 0x30, 0x61, 0x62, 0x12, 0x3a, 0x14, 0x19, 0xd0, 0xf0, 0x31, 0xe5, 0xa7, 0xf3,
+	0x4f, 0xcd, 0x72, 0xc8, 0x36, 0x99, 0x35, 0xd2, 0xf3, 0x02, 0x61, 0xad, 0x66, 0x0a, 0x01, 0x3d,
+	0x01, 0x18, 0xc6, 0x6e, 0x10, 0xad, 0xc1, 0xb1, 0x72, 0x33, 0x63, 0x20, 0xe7, 0xab, 0x29, 0xe9,
+	0x1b, 0xa7, 0x80, 0xe4, 0x8c, 0x12, 0xdf, 0x73, 0x09, 0x46, 0x1b, 0x30, 0x15, 0x70, 0xbf, 0xc4,
+	0x0b, 0xe4, 0x0d, 0xe6, 0x9c, 0x37, 0x63, 0x65, 0xe6, 0x21, 0xf5, 0xa8, 0xe5, 0x70, 0x0f, 0x1b,
+	0xa6, 0x10, 0x8c, 0x21, 0xcc, 0x1e, 0xfb, 0x7d, 0x8b, 0xe2, 0x6a, 0x34, 0xc8, 0x40, 0xaa, 0x67,
+	0x80, 0x54, 0x5a, 0x38, 0xe9, 0xe3, 0x36, 0x65, 0xcc, 0x1b, 0x1b, 0x30, 0xbb, 0x83, 0x1d, 0x3c,
+	0xf6, 0x26, 0x04, 0xcd, 0xa1, 0x15, 0xf4, 0xf9, 0x35, 0x8a, 0xc9, 0xbf, 0x8d, 0x3b, 0x30, 0x67,
+	0x62, 0x1e, 0xde, 0x18, 0xc0, 0xfe, 0x53, 0x87, 0xd6, 0x4f, 0x41, 0x1f, 0x07, 0x05, 0x93, 0xd7,
+	0xa1, 0xed, 0x63, 0x7a, 0x62, 0xf7, 0xe3, 0xb8, 0x7d, 0x4c, 0xf7, 0xfb, 0xac, 0xd6, 0xde, 0x85,
+	0x96, 0x4b, 0x6d, 0x2a, 0xd0, 0xd6, 0x32, 0x13, 0x19, 0x7d, 0x07, 0x2a, 0x19, 0xda, 0xfe, 0x09,
+	0x4b, 0x0b, 0x0f, 0xa2, 0xb3, 0xae, 0x17, 0xea, 0xed, 0x28, 0x66, 0x42, 0x53, 0x61, 0xca, 0x3b,
+	0x16, 0x95, 0x43, 0x6f, 0x65, 0xca, 0x9d, 0x15, 0xb6, 0x37, 0xf2, 0x59, 0xf0, 0x9c, 0x08, 0x14,
+	0x33, 0x91, 0xd1, 0x0d, 0x98, 0x0a, 0x09, 0x0e, 0x98, 0x83, 0x82, 0x0c, 0xda, 0x4c, 0xdc, 0xef,
+	0x33, 0x02, 0x0a, 0x5d, 0x9b, 0x9e, 0x08, 0xa2, 0x50, 0xf8, 0x9e, 0xca, 0x56, 0x0e, 0x0a, 0x64,
+	0xa1, 0xe6, 0xc8, 0x22, 0x79, 0x6a, 0x90, 0x9e, 0x5a, 0x7e, 0xc6, 0x4e, 0x96, 0x0f, 0x7e, 0xab,
+	0x81, 0xba, 0xef, 0x5e, 0x60, 0x97, 0x32, 0x36, 0x7c, 0x0c, 0xed, 0x9e, 0x17, 0xba, 0x09, 0xbe,
+	0x8c, 0x0c, 0xbe, 0x12, 0xbd, 0xee, 0x36, 0x57, 0x7a, 0xe9, 0xd2, 0xe0, 0xd2, 0x8c, 0x4e, 0xe8,
+	0x8f, 0xa0, 0x23, 0x2d, 0xa3, 0x59, 0x68, 0x9c, 0xe3, 0xb8, 0xf8, 0xd9, 0x67, 0xb6, 0xea, 0x1a,
+	0x51, 0xd5, 0x3d, 0xae, 0x6f, 0xd6, 0x8c, 0xdf, 0x6b, 0x00, 0x87, 0x8c, 0xb8, 0x38, 0x89, 0xa1,
+	0xef, 0xa1, 0xcd, 0xf7, 0x62, 0x2f, 0xee, 0x64, 0xcb, 0x26, 0x51, 0xec, 0xf2, 0xdf, 0xd8, 0x0d,
+	0x71, 0x84, 0xb9, 0x21, 0x2d, 0xff, 0x2f, 0x37, 0xbe, 0x84, 0x6b, 0x7b, 0x98, 0x72, 0x2c, 0x55,
+	0x81, 0xed, 0xcf, 0x3a, 0xcc, 0x31, 0x7a, 0xe4, 0x4a, 0x09, 0xd5, 0x49, 0x0f, 0x59, 0xcb, 0x3c,
+	0xa4, 0x0e, 0x0a, 0xfb, 0x92, 0x1a, 0x58, 0x22, 0x4b, 0xe8, 0x6c, 0xc8, 0xe8, 0x94, 0x6b, 0x48,
+	0x26, 0xc8, 0xe7, 0x30, 0x93, 0x20, 0xf3, 0xe4, 0x2c, 0xf0, 0x46, 0x5a, 0xeb, 0x4a, 0x78, 0x4e,
+	0xc7, 0xf0, 0xdc, 0x0d, 0xbc, 0x11, 0x7a, 0x02, 0xd3, 0xa9, 0x05, 0xea, 0x69, 0xed, 0x2b, 0xcf,
+	0x43, 0x7c, 0xfe, 0xc8, 0x93, 0xa8, 0x78, 0xaa, 0x9c, 0x8a, 0x15, 0x99, 0x8a, 0xdf, 0x02, 0x92,
+	0xd3, 0x14, 0xf1, 0xd7, 0x37, 0xd0, 0xf6, 0xf8, 0x4a, 0xf4, 0xb0, 0x28, 0xf3, 0xb0, 0x22, 0xf1,
+	0x91, 0x46, 0x05, 0x67, 0xfd, 0x02, 0x9a, 0xe0, 0x2c, 0xae, 0x9c, 0x6d, 0x4f, 0x93, 0x73, 0x57,
+	0x9a, 0xe3, 0x46, 0x86, 0xa7, 0x36, 0x01, 0x09, 0x9e, 0x1a, 0x87, 0x81, 0x52, 0xa6, 0xba, 0x07,
+	0x9f, 0x47, 0x4c, 0x35, 0x16, 0x3e, 0x1f, 0xeb, 0xd0, 0x3c, 0x26, 0x25, 0x54, 0x35, 0x0e, 0x28,
+	0xb7, 0x00, 0xce, 0xec, 0x80, 0xd0, 0x13, 0x89, 0x6f, 0x55, 0xbe, 0xf2, 0x86, 0x6d, 0x2f, 0x82,
+	0xea, 0x58, 0xf1, 0xae, 0xe0, 0x5d, 0xc5, 0xb1, 0xa2, 0xcd, 0x79, 0x68, 0xe1, 0x91, 0x65, 0x3b,
+	0x11, 0x2b, 0x09, 0x81, 0xdd, 0xe6, 0x5b, 0x84, 0xbc, 0xf7, 0x82, 0x7e, 0x34, 0x9d, 0x24, 0x32,
+	0x3b, 0xe1, 0x0f, 0x3d, 0x57, 0xcc, 0x27, 0xaa, 0x29, 0x04, 0xb4, 0x04, 0x1d, 0x8e, 0xf0, 0x28,
+	0x6d, 0x0a, 0xa7, 0x4d, 0x08, 0x49, 0xfc, 0x06, 0x2c, 0x29, 0x81, 0xe7, 0xe0, 0x88, 0x8f, 0xf8,
+	0xf7, 0x98, 0x29, 0xe4, 0x29, 0xa0, 0xed, 0x00, 0x5b, 0x14, 0xb3, 0x64, 0x24, 0x0f, 0xf8, 0x15,
+	0xb4, 0x42, 0x92, 0xa2, 0x63, 0x2e, 0x83, 0x0e, 0xa6, 0x69, 0x8a, 0x7d, 0x63, 0x17, 0xa6, 0x5f,
+	0x79, 0x03, 0xdb, 0x8d, 0x0f, 0xca, 0xd9, 0xab, 0xe5, 0xb2, 0x27, 0xc7, 0x5a, 0xcf, 0xc6, 0x6a,
+	0x7c, 0xaa, 0xc1, 0xd4, 0x21, 0x26, 0x1c, 0x13, 0x1c, 0x6f, 0xe7, 0xd8, 0x8d, 0x27, 0x05, 0x2e,
+	0xc8, 0x95, 0x5d, 0xaf, 0xac, 0xec, 0x46, 0xee, 0xca, 0x47, 0x00, 0xf8, 0x57, 0xdf, 0x0e, 0x30,
+	0x39, 0xb1, 0xe8, 0x04, 0x5d, 0x44, 0x8d, 0xb4, 0xb7, 0xa8, 0x71, 0x1f, 0x66, 0xf6, 0x30, 0xe5,
+	0xb1, 0x5e, 0x1d, 0x9b, 0xf1, 0x16, 0xe6, 0x44, 0x35, 0x4c, 0x78, 0x00, 0xdd, 0x83, 0x26, 0xfb,
+	0x8e, 0x86, 0xe3, 0x92, 0x04, 0xf3, 0x6d, 0x63, 0x1b, 0xe6, 0x44, 0x1d, 0x4c, 0x6a, 0xb7, 0xac,
+	0x24, 0x1e, 0x00, 0x8a, 0x4a, 0x62, 0xd2, 0x70, 0x56, 0x61, 0xee, 0xd8, 0x75, 0xbc, 0xde, 0xf9,
+	0x84, 0x07, 0xd6, 0x3f, 0x35, 0x01, 0xf8, 0xd0, 0x13, 0x5c, 0xb0, 0xbe, 0x78, 0x1f, 0xda, 0x5b,
+	0x7d, 0x36, 0xb9, 0xa2, 0xc2, 0xa4, 0xa6, 0x17, 0x56, 0xd0, 0x2a, 0xa8, 0xc9, 0xf8, 0x33, 0xd1,
+	0x81, 0x0d, 0x68, 0x8b, 0xd1, 0x19, 0xe9, 0x99, 0xbd, 0xcc, 0x3c, 0x5d, 0x72, 0xee, 0x15, 0xcc,
+	0xe6, 0x27, 0x6a, 0x74, 0x37, 0xa3, 0x55, 0x31, 0x70, 0x17, 0x6d, 0x3d, 0xa8, 0xa1, 0xd7, 0x00,
+	0xe9, 0x64, 0x88, 0x6e, 0x97, 0x4c, 0x94, 0xd2, 0x10, 0xae, 0x2f, 0x55, 0xee, 0x47, 0x94, 0xbc,
+	0x0f, 0x0b, 0x49, 0x16, 0x18, 0x63, 0x6c, 0xb9, 0xfd, 0xc8, 0xc5, 0x5b, 0x59, 0x74, 0xe4, 0x26,
+	0xc5, 0x92, 0x38, 0x5f, 0x80, 0x9a, 0x4c, 0x79, 0xb9, 0xd3, 0xf9, 0xe9, 0x4f, 0x5f, 0x28, 0x94,
+	0xc3, 0x4b, 0xf6, 0xdf, 0x13, 0x3d, 0x03, 0x48, 0x27, 0xbe, 0x5c, 0x74, 0x85, 0x51, 0xb0, 0xe8,
+	0xc3, 0xfa, 0x5f, 0x4d, 0x98, 0x3e, 0x64, 0x0b, 0x31, 0x26, 0x9e, 0xc1, 0xf4, 0x1e, 0xa6, 0xe9,
+	0x84, 0x53, 0x71, 0xb1, 0xbe, 0x50, 0x3e, 0xe9, 0xa0, 0xe7, 0xfc, 0xff, 0x92, 0x34, 0x9c, 0x54,
+	0x19, 0xb8, 0x51, 0x31, 0xa4, 0xa0, 0x87, 0x00, 0x07, 0x8e, 0xd5, 0x13, 0x8d, 0x01, 0x95, 0xb4,
+	0x3c, 0xbd, 0x64, 0x0d, 0x3d, 0x01, 0x25, 0x9e, 0x45, 0xd0, 0xcd, 0x3c, 0xdc, 0xe4, 0x1e, 0x53,
+	0x7a, 0xfa, 0x35, 0x40, 0xda, 0x7e, 0x73, 0x69, 0x2c, 0x8c, 0x2f, 0xfa, 0x52, 0xe5, 0x7e, 0x04,
+	0x92, 0x37, 0x31, 0xcf, 0x48, 0x5d, 0x17, 0xdd, 0x2b, 0xc1, 0x47, 0xb1, 0x2b, 0x97, 0xba, 0xb7,
+	0x0b, 0x1d, 0xa9, 0xcf, 0xa2, 0xa5, 0x12, 0xac, 0x64, 0x42, 0xac, 0x42, 0xcb, 0x0e, 0x4c, 0xcb,
+	0x5d, 0x17, 0x2d, 0x97, 0xe1, 0xe5, 0xaa, 0x64, 0xad, 0xff, 0xdb, 0x84, 0x0e, 0x63, 0x9c, 0x18,
+	0x32, 0xeb, 0x00, 0x69, 0x73, 0x42, 0x45, 0x92, 0xd4, 0x8b, 0x4b, 0x2c, 0xa2, 0xf4, 0x0c, 0xc9,
+	0x45, 0x54, 0x6c, 0x75, 0x95, 0x11, 0x6d, 0x42, 0x8b, 0x77, 0x36, 0xf4, 0x45, 0xc6, 0x82, 0xdc,
+	0xed, 0xf4, 0xf9, 0x5c, 0x4d, 0x8b, 0xfe, 0xb5, 0x09, 0xed, 0x57, 0xde, 0xc0, 0x0b, 0xe9, 0x18,
+	0x88, 0x97, 0xdf, 0xf9, 0x08, 0xa6, 0xa2, 0x9e, 0x83, 0x16, 0xf3, 0x48, 0x93, 0x98, 0xb8, 0x2c,
+	0xec, 0x1f, 0x00, 0xd2, 0x06, 0x94, 0xc3, 0x59, 0xa1, 0x33, 0x95, 0x19, 0xd8, 0x01, 0x48, 0x3b,
+	0x4d, 0xce, 0x40, 0xa1, 0x05, 0x55, 0x46, 0xb0, 0x05, 0x1d, 0xa9, 0xd5, 0xe4, 0xb2, 0x5f, 0x6c,
+	0x42, 0x15, 0x8e, 0xa4, 0xbd, 0x27, 0x1f, 0x49, 0xbe, 0x29, 0x55, 0x39, 0xf2, 0xa2, 0xf9, 0x73,
+	0xdd, 0x3f, 0x3d, 0x6d, 0xf3, 0xd5, 0x6f, 0xff, 0x1b, 0x00, 0xb9, 0x39, 0xf2, 0xa1, 0x7d, 0x13,
+	0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// PetServiceClient is the client API for PetService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PetServiceClient interface {
+	AddPet(ctx context.Context, in *Pet, opts ...grpc.CallOption) (*Pet, error)
+	UpdatePet(ctx context.Context, in *Pet, opts ...grpc.CallOption) (*Pet, error)
+	GetPet(ctx context.Context, in *GetPetRequest, opts ...grpc.CallOption) (*Pet, error)
+	// Pets of any of the statuses, sent as they are read
+	FindPetsByStatus(ctx context.Context, in *FindPetsByStatusRequest, opts ...grpc.CallOption) (PetService_FindPetsByStatusClient, error)
+	SearchPets(ctx context.Context, in *SearchPetsRequest, opts ...grpc.CallOption) (*SearchPetsResponse, error)
+	UpdatePetNameAndStatus(ctx context.Context, in *UpdatePetRequest, opts ...grpc.CallOption) (*Pet, error)
+	DeletePet(ctx context.Context, in *DeletePetRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	RestorePet(ctx context.Context, in *RestorePetRequest, opts ...grpc.CallOption) (*Pet, error)
+}
+
+type petServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewPetServiceClient(cc *grpc.ClientConn) PetServiceClient {
+	return &petServiceClient{cc}
+}
+
+func (c *petServiceClient) AddPet(ctx context.Context, in *Pet, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, "/petstore.v1.PetService/AddPet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) UpdatePet(ctx context.Context, in *Pet, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, "/petstore.v1.PetService/UpdatePet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) GetPet(ctx context.Context, in *GetPetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, "/petstore.v1.PetService/GetPet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) FindPetsByStatus(ctx context.Context, in *FindPetsByStatusRequest, opts ...grpc.CallOption) (PetService_FindPetsByStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_PetService_serviceDesc.Streams[0], "/petstore.v1.PetService/FindPetsByStatus", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &petServiceFindPetsByStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PetService_FindPetsByStatusClient interface {
+	Recv() (*Pet, error)
+	grpc.ClientStream
+}
+
+type petServiceFindPetsByStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *petServiceFindPetsByStatusClient) Recv() (*Pet, error) {
+	m := new(Pet)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *petServiceClient) SearchPets(ctx context.Context, in *SearchPetsRequest, opts ...grpc.CallOption) (*SearchPetsResponse, error) {
+	out := new(SearchPetsResponse)
+	err := c.cc.Invoke(ctx, "/petstore.v1.PetService/SearchPets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) UpdatePetNameAndStatus(ctx context.Context, in *UpdatePetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, "/petstore.v1.PetService/UpdatePetNameAndStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) DeletePet(ctx context.Context, in *DeletePetRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/petstore.v1.PetService/DeletePet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) RestorePet(ctx context.Context, in *RestorePetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, "/petstore.v1.PetService/RestorePet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PetServiceServer is the server API for PetService service.
+type PetServiceServer interface {
+	AddPet(context.Context, *Pet) (*Pet, error)
+	UpdatePet(context.Context, *Pet) (*Pet, error)
+	GetPet(context.Context, *GetPetRequest) (*Pet, error)
+	// Pets of any of the statuses, sent as they are read
+	FindPetsByStatus(*FindPetsByStatusRequest, PetService_FindPetsByStatusServer) error
+	SearchPets(context.Context, *SearchPetsRequest) (*SearchPetsResponse, error)
+	UpdatePetNameAndStatus(context.Context, *UpdatePetRequest) (*Pet, error)
+	DeletePet(context.Context, *DeletePetRequest) (*empty.Empty, error)
+	RestorePet(context.Context, *RestorePetRequest) (*Pet, error)
+}
+
+func RegisterPetServiceServer(s *grpc.Server, srv PetServiceServer) {
+	s.RegisterService(&_PetService_serviceDesc, srv)
+}
+
+func _PetService_AddPet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Pet)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).AddPet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.PetService/AddPet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).AddPet(ctx, req.(*Pet))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_UpdatePet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Pet)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).UpdatePet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.PetService/UpdatePet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).UpdatePet(ctx, req.(*Pet))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_GetPet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).GetPet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.PetService/GetPet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).GetPet(ctx, req.(*GetPetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_FindPetsByStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FindPetsByStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PetServiceServer).FindPetsByStatus(m, &petServiceFindPetsByStatusServer{stream})
+}
+
+type PetService_FindPetsByStatusServer interface {
+	Send(*Pet) error
+	grpc.ServerStream
+}
+
+type petServiceFindPetsByStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *petServiceFindPetsByStatusServer) Send(m *Pet) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PetService_SearchPets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchPetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).SearchPets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.PetService/SearchPets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).SearchPets(ctx, req.(*SearchPetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_UpdatePetNameAndStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).UpdatePetNameAndStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.PetService/UpdatePetNameAndStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).UpdatePetNameAndStatus(ctx, req.(*UpdatePetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_DeletePet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).DeletePet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.PetService/DeletePet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).DeletePet(ctx, req.(*DeletePetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_RestorePet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestorePetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).RestorePet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.PetService/RestorePet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).RestorePet(ctx, req.(*RestorePetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _PetService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "petstore.v1.PetService",
+	HandlerType: (*PetServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddPet",
+			Handler:    _PetService_AddPet_Handler,
+		},
+		{
+			MethodName: "UpdatePet",
+			Handler:    _PetService_UpdatePet_Handler,
+		},
+		{
+			MethodName: "GetPet",
+			Handler:    _PetService_GetPet_Handler,
+		},
+		{
+			MethodName: "SearchPets",
+			Handler:    _PetService_SearchPets_Handler,
+		},
+		{
+			MethodName: "UpdatePetNameAndStatus",
+			Handler:    _PetService_UpdatePetNameAndStatus_Handler,
+		},
+		{
+			MethodName: "DeletePet",
+			Handler:    _PetService_DeletePet_Handler,
+		},
+		{
+			MethodName: "RestorePet",
+			Handler:    _PetService_RestorePet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FindPetsByStatus",
+			Handler:       _PetService_FindPetsByStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "petstore.proto",
+}
+
+// StoreServiceClient is the client API for StoreService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type StoreServiceClient interface {
+	GetInventory(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*Inventory, error)
+	GetStockValue(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*StockValue, error)
+	PlaceOrder(ctx context.Context, in *Order, opts ...grpc.CallOption) (*Order, error)
+	GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	FindOrders(ctx context.Context, in *FindOrdersRequest, opts ...grpc.CallOption) (*FindOrdersResponse, error)
+	UpdateOrderStatus(ctx context.Context, in *UpdateOrderStatusRequest, opts ...grpc.CallOption) (*Order, error)
+	DeleteOrder(ctx context.Context, in *DeleteOrderRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	RestoreOrder(ctx context.Context, in *RestoreOrderRequest, opts ...grpc.CallOption) (*Order, error)
+}
+
+type storeServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewStoreServiceClient(cc *grpc.ClientConn) StoreServiceClient {
+	return &storeServiceClient{cc}
+}
+
+func (c *storeServiceClient) GetInventory(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*Inventory, error) {
+	out := new(Inventory)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/GetInventory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) GetStockValue(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*StockValue, error) {
+	out := new(StockValue)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/GetStockValue", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) PlaceOrder(ctx context.Context, in *Order, opts ...grpc.CallOption) (*Order, error) {
+	out := new(Order)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/PlaceOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	out := new(Order)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/GetOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) FindOrders(ctx context.Context, in *FindOrdersRequest, opts ...grpc.CallOption) (*FindOrdersResponse, error) {
+	out := new(FindOrdersResponse)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/FindOrders", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) UpdateOrderStatus(ctx context.Context, in *UpdateOrderStatusRequest, opts ...grpc.CallOption) (*Order, error) {
+	out := new(Order)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/UpdateOrderStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) DeleteOrder(ctx context.Context, in *DeleteOrderRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/DeleteOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeServiceClient) RestoreOrder(ctx context.Context, in *RestoreOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	out := new(Order)
+	err := c.cc.Invoke(ctx, "/petstore.v1.StoreService/RestoreOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StoreServiceServer is the server API for StoreService service.
+type StoreServiceServer interface {
+	GetInventory(context.Context, *empty.Empty) (*Inventory, error)
+	GetStockValue(context.Context, *empty.Empty) (*StockValue, error)
+	PlaceOrder(context.Context, *Order) (*Order, error)
+	GetOrder(context.Context, *GetOrderRequest) (*Order, error)
+	FindOrders(context.Context, *FindOrdersRequest) (*FindOrdersResponse, error)
+	UpdateOrderStatus(context.Context, *UpdateOrderStatusRequest) (*Order, error)
+	DeleteOrder(context.Context, *DeleteOrderRequest) (*empty.Empty, error)
+	RestoreOrder(context.Context, *RestoreOrderRequest) (*Order, error)
+}
+
+func RegisterStoreServiceServer(s *grpc.Server, srv StoreServiceServer) {
+	s.RegisterService(&_StoreService_serviceDesc, srv)
+}
+
+func _StoreService_GetInventory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).GetInventory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/GetInventory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).GetInventory(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_GetStockValue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).GetStockValue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/GetStockValue",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).GetStockValue(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_PlaceOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Order)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).PlaceOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/PlaceOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).PlaceOrder(ctx, req.(*Order))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_GetOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).GetOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/GetOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).GetOrder(ctx, req.(*GetOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_FindOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindOrdersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).FindOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/FindOrders",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).FindOrders(ctx, req.(*FindOrdersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_UpdateOrderStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateOrderStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).UpdateOrderStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/UpdateOrderStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).UpdateOrderStatus(ctx, req.(*UpdateOrderStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_DeleteOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).DeleteOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/DeleteOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).DeleteOrder(ctx, req.(*DeleteOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreService_RestoreOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServiceServer).RestoreOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.StoreService/RestoreOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServiceServer).RestoreOrder(ctx, req.(*RestoreOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _StoreService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "petstore.v1.StoreService",
+	HandlerType: (*StoreServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetInventory",
+			Handler:    _StoreService_GetInventory_Handler,
+		},
+		{
+			MethodName: "GetStockValue",
+			Handler:    _StoreService_GetStockValue_Handler,
+		},
+		{
+			MethodName: "PlaceOrder",
+			Handler:    _StoreService_PlaceOrder_Handler,
+		},
+		{
+			MethodName: "GetOrder",
+			Handler:    _StoreService_GetOrder_Handler,
+		},
+		{
+			MethodName: "FindOrders",
+			Handler:    _StoreService_FindOrders_Handler,
+		},
+		{
+			MethodName: "UpdateOrderStatus",
+			Handler:    _StoreService_UpdateOrderStatus_Handler,
+		},
+		{
+			MethodName: "DeleteOrder",
+			Handler:    _StoreService_DeleteOrder_Handler,
+		},
+		{
+			MethodName: "RestoreOrder",
+			Handler:    _StoreService_RestoreOrder_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "petstore.proto",
+}
+
+// UserServiceClient is the client API for UserService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type UserServiceClient interface {
+	CreateUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*User, error)
+	CreateUsers(ctx context.Context, in *CreateUsersRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Session, error)
+	Logout(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*empty.Empty, error)
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	UpdateUser(ctx context.Context, in *UpdateUserRequest, opts ...grpc.CallOption) (*User, error)
+	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	RestoreUser(ctx context.Context, in *RestoreUserRequest, opts ...grpc.CallOption) (*User, error)
+	UnlockUser(ctx context.Context, in *UnlockUserRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+}
+
+type userServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewUserServiceClient(cc *grpc.ClientConn) UserServiceClient {
+	return &userServiceClient{cc}
+}
+
+func (c *userServiceClient) CreateUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/CreateUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) CreateUsers(ctx context.Context, in *CreateUsersRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/CreateUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Session, error) {
+	out := new(Session)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) Logout(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/Logout", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/GetUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) UpdateUser(ctx context.Context, in *UpdateUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/UpdateUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/DeleteUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) RestoreUser(ctx context.Context, in *RestoreUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/RestoreUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) UnlockUser(ctx context.Context, in *UnlockUserRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/petstore.v1.UserService/UnlockUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserServiceServer is the server API for UserService service.
+type UserServiceServer interface {
+	CreateUser(context.Context, *User) (*User, error)
+	CreateUsers(context.Context, *CreateUsersRequest) (*empty.Empty, error)
+	Login(context.Context, *LoginRequest) (*Session, error)
+	Logout(context.Context, *empty.Empty) (*empty.Empty, error)
+	GetUser(context.Context, *GetUserRequest) (*User, error)
+	UpdateUser(context.Context, *UpdateUserRequest) (*User, error)
+	DeleteUser(context.Context, *DeleteUserRequest) (*empty.Empty, error)
+	RestoreUser(context.Context, *RestoreUserRequest) (*User, error)
+	UnlockUser(context.Context, *UnlockUserRequest) (*empty.Empty, error)
+}
+
+func RegisterUserServiceServer(s *grpc.Server, srv UserServiceServer) {
+	s.RegisterService(&_UserService_serviceDesc, srv)
+}
+
+func _UserService_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(User)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).CreateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/CreateUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).CreateUser(ctx, req.(*User))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_CreateUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).CreateUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/CreateUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).CreateUsers(ctx, req.(*CreateUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/Logout",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).Logout(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/GetUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_UpdateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).UpdateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/UpdateUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).UpdateUser(ctx, req.(*UpdateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/DeleteUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_RestoreUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).RestoreUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/RestoreUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).RestoreUser(ctx, req.(*RestoreUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_UnlockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).UnlockUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/petstore.v1.UserService/UnlockUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).UnlockUser(ctx, req.(*UnlockUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _UserService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "petstore.v1.UserService",
+	HandlerType: (*UserServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateUser",
+			Handler:    _UserService_CreateUser_Handler,
+		},
+		{
+			MethodName: "CreateUsers",
+			Handler:    _UserService_CreateUsers_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _UserService_Login_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _UserService_Logout_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _UserService_GetUser_Handler,
+		},
+		{
+			MethodName: "UpdateUser",
+			Handler:    _UserService_UpdateUser_Handler,
+		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _UserService_DeleteUser_Handler,
+		},
+		{
+			MethodName: "RestoreUser",
+			Handler:    _UserService_RestoreUser_Handler,
+		},
+		{
+			MethodName: "UnlockUser",
+			Handler:    _UserService_UnlockUser_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "petstore.proto",
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"net/http"
+)
+
+type AuditService interface {
+	FindAuditEvents(ctx context.Context, filter model.AuditFilter) ([]*model.AuditEvent, int64, error)
+}
+
+type auditService struct {
+	logger log.Logger
+	log    model.AuditLog
+}
+
+func NewAuditService(logger log.Logger, auditLog model.AuditLog) AuditService {
+	return &auditService{
+		logger: logger,
+		log:    auditLog,
+	}
+}
+
+func (s auditService) FindAuditEvents(ctx context.Context, filter model.AuditFilter) ([]*model.AuditEvent, int64, error) {
+	return s.log.FindAuditEvents(filter)
+}
+
+type requestIDContextKey struct{}
+
+// maxRequestIDLength bounds X-Request-ID headers taken from callers, longer ones are replaced.
+const maxRequestIDLength = 64
+
+// requestID takes the id of a request from X-Request-ID header, or makes one up, and echoes it in the response.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestIDOrNew(r.Header.Get("X-Request-ID"))
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(NewContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// requestIDOrNew returns id taken from a caller, or a new one when it is empty or too long.
+func requestIDOrNew(id string) string {
+	if id == "" || len(id) > maxRequestIDLength {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err == nil {
+			id = hex.EncodeToString(b)
+		}
+	}
+	return id
+}
+
+func NewContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the id of the request, empty outside requests.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// actorFromContext returns who makes the request, for the audit log.
+func actorFromContext(ctx context.Context) model.Actor {
+	actor := model.Actor{RequestID: RequestIDFromContext(ctx)}
+	if p := PrincipalFromContext(ctx); p != nil {
+		actor.UserID = p.UserID
+		actor.Username = p.Username
+		actor.Role = p.Role
+	}
+	return actor
+}
+
+// storageFor returns the storage making changes on behalf of the caller of ctx, where the storage records it.
+func storageFor(ctx context.Context, storage model.Storage) model.Storage {
+	if s, ok := storage.(model.ActorStorage); ok {
+		return s.WithActor(actorFromContext(ctx))
+	}
+	return storage
+}
@@ -0,0 +1,39 @@
+package service
+
+// This is to test request ids and actors given to the audit log
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestIDFromContext(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "abc")
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "abc", got)
+	assert.Equal(t, "abc", w.Header().Get("X-Request-ID"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, got, 24)
+	assert.Equal(t, got, w.Header().Get("X-Request-ID"))
+}
+
+func TestActorFromContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), requestIDContextKey{}, "abc")
+	assert.Equal(t, model.Actor{RequestID: "abc"}, actorFromContext(ctx))
+
+	ctx = NewContextWithPrincipal(ctx, &Principal{UserID: 3, Username: "jane", Role: model.RoleStaff, Token: "t"})
+	assert.Equal(t, model.Actor{UserID: 3, Username: "jane", Role: model.RoleStaff, RequestID: "abc"}, actorFromContext(ctx))
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-chi/chi"
+	"net/http"
+	"strings"
+)
+
+type Permission string
+
+const (
+	// Place orders for oneself and see them
+	PermissionPlaceOrder Permission = "order:place"
+	// See, and delete any order
+	PermissionManageOrders Permission = "order:manage"
+	// Add, update and delete pets and their images
+	PermissionManagePets Permission = "pet:manage"
+	// See the store inventory
+	PermissionViewInventory Permission = "inventory:view"
+	// See any user
+	PermissionViewUsers Permission = "user:view"
+	// Create, update, delete and unlock any user and grant roles
+	PermissionManageUsers Permission = "user:manage"
+	// Restore deleted pets, users and orders, and delete them for good
+	PermissionManageDeleted Permission = "deleted:manage"
+	// See the audit log of changes
+	PermissionViewAudit Permission = "audit:view"
+	// Subscribe webhooks to events and see their deliveries
+	PermissionManageWebhooks Permission = "webhook:manage"
+	// See counts of the use of the storage cache
+	PermissionViewStats Permission = "stats:view"
+)
+
+// Permission matrix of roles, calls made by anonymous callers have none of them.
+var rolePermissions = map[string][]Permission{
+	model.RoleCustomer: {
+		PermissionPlaceOrder,
+	},
+	model.RoleStaff: {
+		PermissionPlaceOrder,
+		PermissionManageOrders,
+		PermissionManagePets,
+		PermissionViewInventory,
+		PermissionViewUsers,
+	},
+	model.RoleAdmin: {
+		PermissionPlaceOrder,
+		PermissionManageOrders,
+		PermissionManagePets,
+		PermissionViewInventory,
+		PermissionViewUsers,
+		PermissionManageUsers,
+		PermissionManageDeleted,
+		PermissionViewAudit,
+		PermissionManageWebhooks,
+		PermissionViewStats,
+	},
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID   int64
+	Username string
+	Role     string
+	// Session token the principal authenticated with, empty for the admin api key
+	Token string
+}
+
+func (p *Principal) Can(permission Permission) bool {
+	if p == nil {
+		return false
+	}
+	for _, granted := range rolePermissions[p.Role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Is tells whether the principal is the user with given username.
+func (p *Principal) Is(username string) bool {
+	return p != nil && p.Username != "" && p.Username == username
+}
+
+type principalContextKey struct{}
+
+func NewContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller of the request, nil for anonymous callers.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return p
+}
+
+var (
+	errUnauthorized = model.NewErrResponse(http.StatusUnauthorized, "error", "authentication required")
+	errForbidden    = model.NewErrResponse(http.StatusForbidden, "error", "permission denied")
+)
+
+// authenticate resolves the principal from a session token in Authorization bearer or api_key header.
+// Requests without token go on anonymously, requests with an invalid token are rejected.
+func authenticate(users UserService, adminAPIKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get("api_key")
+			if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+				token = strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+			}
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			principal, err := principalFor(r.Context(), users, adminAPIKey, token)
+			if err != nil {
+				encodeError(r.Context(), model.NewErrResponse(http.StatusUnauthorized, "error", err.Error()), w)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// principalFor resolves the principal authenticated by token, the admin api key or a session token.
+func principalFor(ctx context.Context, users UserService, adminAPIKey, token string) (*Principal, error) {
+	if adminAPIKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminAPIKey)) == 1 {
+		return &Principal{Role: model.RoleAdmin}, nil
+	}
+	return users.Authenticate(ctx, token)
+}
+
+func requirePermission(permission Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := authorize(r.Context(), permission); err != nil {
+				encodeError(r.Context(), err, w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSelfOr lets users act on their own {username} and others only with given permission.
+func requireSelfOr(permission Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := authorizeSelfOr(r.Context(), chi.URLParam(r, "username"), permission); err != nil {
+				encodeError(r.Context(), err, w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authorize lets only callers with given permission through.
+func authorize(ctx context.Context, permission Permission) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return errUnauthorized
+	}
+	if !p.Can(permission) {
+		return errForbidden
+	}
+	return nil
+}
+
+// authorizeSelfOr lets users act on their own username and others only with given permission.
+func authorizeSelfOr(ctx context.Context, username string, permission Permission) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return errUnauthorized
+	}
+	if !p.Is(username) && !p.Can(permission) {
+		return errForbidden
+	}
+	return nil
+}
+
+// authorizeHard lets only callers managing deleted documents delete for good.
+func authorizeHard(ctx context.Context, hard bool) error {
+	if hard && !PrincipalFromContext(ctx).Can(PermissionManageDeleted) {
+		return errForbidden
+	}
+	return nil
+}
+
+// authorizeRole keeps the role of user as current unless the caller manages users.
+func authorizeRole(ctx context.Context, user *model.User, current string) error {
+	if user.Role == "" {
+		user.Role = current
+		return nil
+	}
+	if user.Role == current {
+		return nil
+	}
+	if !PrincipalFromContext(ctx).Can(PermissionManageUsers) {
+		return errForbidden
+	}
+	if !model.ValidRole(user.Role) {
+		return model.NewErrResponse(http.StatusBadRequest, "error", "invalid role "+user.Role)
+	}
+	return nil
+}
+
+// canAccessOrder lets users see their own orders and others only with PermissionManageOrders.
+func canAccessOrder(ctx context.Context, order *model.Order) bool {
+	p := PrincipalFromContext(ctx)
+	return p.Can(PermissionManageOrders) || (p != nil && p.UserID != 0 && p.UserID == order.UserID)
+}
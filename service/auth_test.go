@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPrincipalPermissions(t *testing.T) {
+	var anonymous *Principal
+	assert.False(t, anonymous.Can(PermissionPlaceOrder))
+	assert.False(t, anonymous.Is(""))
+
+	customer := &Principal{UserID: 1, Username: "customer", Role: model.RoleCustomer}
+	assert.True(t, customer.Can(PermissionPlaceOrder))
+	assert.False(t, customer.Can(PermissionManagePets))
+	assert.True(t, customer.Is("customer"))
+	assert.False(t, customer.Is("staff"))
+
+	staff := &Principal{UserID: 2, Username: "staff", Role: model.RoleStaff}
+	assert.True(t, staff.Can(PermissionManagePets))
+	assert.True(t, staff.Can(PermissionManageOrders))
+	assert.False(t, staff.Can(PermissionManageUsers))
+
+	admin := &Principal{Role: model.RoleAdmin}
+	assert.True(t, admin.Can(PermissionManageUsers))
+}
+
+func TestAuthorizeRole(t *testing.T) {
+	customer := NewContextWithPrincipal(context.Background(), &Principal{UserID: 1, Username: "customer", Role: model.RoleCustomer})
+	admin := NewContextWithPrincipal(context.Background(), &Principal{Role: model.RoleAdmin})
+
+	u := &model.User{Username: "customer"}
+	assert.NoError(t, authorizeRole(customer, u, model.RoleCustomer))
+	assert.Equal(t, model.RoleCustomer, u.Role)
+
+	u.Role = model.RoleAdmin
+	assert.Error(t, authorizeRole(customer, u, model.RoleCustomer))
+	assert.NoError(t, authorizeRole(admin, u, model.RoleCustomer))
+
+	u.Role = "root"
+	assert.Error(t, authorizeRole(admin, u, model.RoleCustomer))
+}
+
+func TestCanAccessOrder(t *testing.T) {
+	order := &model.Order{ID: 1, UserID: 1}
+	assert.True(t, canAccessOrder(NewContextWithPrincipal(context.Background(), &Principal{UserID: 1, Role: model.RoleCustomer}), order))
+	assert.False(t, canAccessOrder(NewContextWithPrincipal(context.Background(), &Principal{UserID: 2, Role: model.RoleCustomer}), order))
+	assert.True(t, canAccessOrder(NewContextWithPrincipal(context.Background(), &Principal{UserID: 2, Role: model.RoleStaff}), order))
+	assert.False(t, canAccessOrder(context.Background(), order))
+}
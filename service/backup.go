@@ -0,0 +1,49 @@
+package service
+
+import (
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"time"
+)
+
+// BackupRunner copies the data of a storage to a backup file while it keeps serving.
+type BackupRunner struct {
+	logger   log.Logger
+	backuper model.Backuper
+	path     string
+}
+
+func NewBackupRunner(logger log.Logger, backuper model.Backuper, path string) *BackupRunner {
+	return &BackupRunner{
+		logger:   logger,
+		backuper: backuper,
+		path:     path,
+	}
+}
+
+// Backup replaces the backup file with a copy of the data as of now.
+func (b *BackupRunner) Backup() error {
+	start := time.Now()
+	if err := b.backuper.Backup(b.path); err != nil {
+		return err
+	}
+	_ = level.Info(b.logger).Log("msg", "backed up storage", "path", b.path, "took", time.Since(start))
+	return nil
+}
+
+// Run backs up every interval until stop is closed.
+func (b *BackupRunner) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := b.Backup(); err != nil {
+			_ = level.Error(b.logger).Log("msg", "back up storage", "path", b.path, "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+// This is to test backing up storage
+
+import (
+	"errors"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// recordBackuper records the paths it backs up to, failing when told to.
+type recordBackuper struct {
+	paths []string
+	err   error
+}
+
+func (b *recordBackuper) Backup(path string) error {
+	b.paths = append(b.paths, path)
+	return b.err
+}
+
+func TestBackupRunner(t *testing.T) {
+	backuper := &recordBackuper{}
+	r := NewBackupRunner(log.NewNopLogger(), backuper, "/var/backups/petstore.db")
+	assert.NoError(t, r.Backup())
+	assert.Equal(t, []string{"/var/backups/petstore.db"}, backuper.paths)
+
+	backuper.err = errors.New("disk full")
+	assert.Equal(t, backuper.err, r.Backup())
+
+	// failures are logged, backups go on until stopped
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Run(time.Millisecond, stop)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	<-done
+	assert.True(t, len(backuper.paths) > 3)
+}
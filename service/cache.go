@@ -0,0 +1,33 @@
+package service
+
+import (
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"time"
+)
+
+// RelayInvalidations drops documents from cache as they are changed by any process, watched in the storage until
+// stop is closed. While not watching, after it fails and until it is watching again after retry, all documents are
+// dropped as changes may be missed.
+func RelayInvalidations(logger log.Logger, watcher model.CacheWatcher, cache *model.CacheStorage, retry time.Duration,
+	stop <-chan struct{}) {
+	for {
+		err := watcher.WatchCachedDocuments(stop, func(keys []string) {
+			cache.Invalidate(keys...)
+		})
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		_ = level.Error(logger).Log("msg", "watch cached documents", "err", err)
+		cache.Invalidate()
+		select {
+		case <-stop:
+			return
+		case <-time.After(retry):
+		}
+		cache.Invalidate()
+	}
+}
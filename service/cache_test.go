@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// cacheWatcher tells changes sent on changes, and fails with errors sent on failures.
+type cacheWatcher struct {
+	changes  chan []string
+	failures chan error
+	// Receives once a change has been told, or the watch has ended
+	handled chan struct{}
+}
+
+func (w *cacheWatcher) CanWatchCachedDocuments() bool {
+	return true
+}
+
+func (w *cacheWatcher) WatchCachedDocuments(stop <-chan struct{}, fn func(keys []string)) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case keys := <-w.changes:
+			fn(keys)
+			w.handled <- struct{}{}
+		case err := <-w.failures:
+			return err
+		}
+	}
+}
+
+func TestRelayInvalidations(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.pets[1] = model.Pet{ID: 1, Name: "rex", Status: model.PetStatusAvailable, Version: 1}
+	storage.pets[2] = model.Pet{ID: 2, Name: "tom", Status: model.PetStatusAvailable, Version: 1}
+	cache := model.NewCacheStorage(storage, 10, time.Hour)
+	watcher := &cacheWatcher{changes: make(chan []string), failures: make(chan error), handled: make(chan struct{})}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		RelayInvalidations(log.NewNopLogger(), watcher, cache, time.Millisecond, stop)
+		close(done)
+	}()
+	read := func(id int64) string {
+		pet, err := cache.RetrievePetByID(id)
+		assert.NoError(t, err)
+		return pet.Name
+	}
+
+	// changes made by another process are seen once watched
+	assert.Equal(t, "rex", read(1))
+	assert.Equal(t, "tom", read(2))
+	storage.pets[1] = model.Pet{ID: 1, Name: "max", Status: model.PetStatusAvailable, Version: 2}
+	storage.pets[2] = model.Pet{ID: 2, Name: "leo", Status: model.PetStatusAvailable, Version: 2}
+	assert.Equal(t, "rex", read(1))
+	watcher.changes <- []string{model.PetCacheKey(1)}
+	<-watcher.handled
+	assert.Equal(t, "max", read(1))
+	assert.Equal(t, "tom", read(2))
+
+	// all are dropped when watching fails, as changes may be missed
+	watcher.failures <- errors.New("stream closed")
+	watcher.changes <- []string{model.PetCacheKey(9)}
+	<-watcher.handled
+	assert.Equal(t, "leo", read(2))
+
+	close(stop)
+	<-done
+}
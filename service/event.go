@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+)
+
+// petEvents returns the events of a change of a pet from before to after, either of them nil when the pet did
+// not or does not exist.
+func petEvents(before, after *model.Pet) []*model.Event {
+	switch {
+	case before == nil && after == nil:
+		return nil
+	case before == nil:
+		return []*model.Event{model.NewPetEvent(model.EventPetCreated, after)}
+	case after == nil:
+		return []*model.Event{model.NewPetEvent(model.EventPetDeleted, before)}
+	}
+	events := []*model.Event{model.NewPetEvent(model.EventPetUpdated, after)}
+	if before.Status != after.Status {
+		e := model.NewPetEvent(model.EventPetStatusChanged, after)
+		e.PreviousStatus = before.Status
+		events = append(events, e)
+	}
+	return events
+}
+
+// orderStatusEvents returns the events of a change of status of an order from before to after.
+func orderStatusEvents(before, after *model.Order) []*model.Event {
+	if before.Status == after.Status {
+		return nil
+	}
+	e := model.NewOrderEvent(model.EventOrderStatusChanged, after)
+	e.PreviousStatus = before.Status
+	events := []*model.Event{e}
+	if after.Status == model.OrderStatusDelivered {
+		events = append(events, model.NewOrderEvent(model.EventOrderDelivered, after))
+	}
+	return events
+}
+
+// appendEvents appends events to the outbox of tx, so that they are only kept with the change they tell about.
+func appendEvents(tx model.Storage, events ...*model.Event) error {
+	for _, e := range events {
+		if err := tx.AppendEvent(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitter appends events to the outbox of a transaction.
+type emitter func(events ...*model.Event) error
+
+// inTransaction runs fn in a transaction of the storage making changes on behalf of the caller of ctx, with emit
+// appending events to its outbox. The events are published once the transaction has committed.
+func inTransaction(ctx context.Context, storage model.Storage, publisher Publisher, fn func(tx model.Storage, emit emitter) error) error {
+	var emitted []*model.Event
+	err := storageFor(ctx, storage).WithTransaction(func(tx model.Storage) error {
+		emitted = nil
+		return fn(tx, func(events ...*model.Event) error {
+			if err := appendEvents(tx, events...); err != nil {
+				return err
+			}
+			emitted = append(emitted, events...)
+			return nil
+		})
+	})
+	if err == nil && publisher != nil && len(emitted) > 0 {
+		publisher.Publish(emitted...)
+	}
+	return err
+}
+
+// changePet runs change of pet id in a transaction, along with the events telling about it, and returns the pet
+// after the change, nil if it has been deleted.
+func changePet(ctx context.Context, storage model.Storage, publisher Publisher, id int64, change func(tx model.Storage) error) (*model.Pet, error) {
+	var after *model.Pet
+	err := inTransaction(ctx, storage, publisher, func(tx model.Storage, emit emitter) error {
+		var err error
+		after, err = changePetIn(tx, emit, id, func() error {
+			return change(tx)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// changePetIn runs change of pet id in transaction tx, emitting the events telling about it, and returns the pet after
+// the change, nil if there is none.
+func changePetIn(tx model.Storage, emit emitter, id int64, change func() error) (*model.Pet, error) {
+	before, err := tx.RetrievePetByID(id)
+	if err != nil && err != model.ErrNotFound {
+		return nil, err
+	}
+	if err = change(); err != nil {
+		return nil, err
+	}
+	after, err := tx.RetrievePetByID(id)
+	if err == model.ErrNotFound {
+		after, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return after, emit(petEvents(before, after)...)
+}
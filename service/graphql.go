@@ -0,0 +1,903 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"math"
+	"strconv"
+	"sync"
+)
+
+const (
+	DefaultGraphQLMaxDepth      = 12
+	DefaultGraphQLMaxComplexity = 1000
+	// Number of items list fields without limit argument are expected to return, in query complexity
+	graphqlListSize = 10
+)
+
+// graphqlListFields are fields returning lists, whose items cost as much as their selection each.
+var graphqlListFields = map[string]bool{
+	"pets":       true,
+	"searchPets": true,
+	"orders":     true,
+	"tags":       true,
+}
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphqlLimits bound the depth of selections of queries, and their complexity: the number of fields they
+// select, those selected in list items counted once per item expected.
+type graphqlLimits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// executeGraphQL runs the request against schema, if within limits, with loaders batching the lookups of pets,
+// users and orders of users made while resolving it.
+func executeGraphQL(ctx context.Context, schema graphql.Schema, services *Services, req graphqlRequest, limits graphqlLimits) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if validation := graphql.ValidateDocument(&schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if err = checkGraphQLLimits(doc, req.Variables, limits); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(ctx, graphqlLoadersKey{}, newGraphQLLoaders(ctx, services)),
+	})
+}
+
+// checkGraphQLLimits fails when an operation of doc is deeper or more complex than limits allow.
+func checkGraphQLLimits(doc *ast.Document, variables map[string]interface{}, limits graphqlLimits) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, complexity := measureSelections(op.SelectionSet, fragments, variables)
+		if depth > limits.MaxDepth {
+			return fmt.Errorf("query depth %d exceeds the maximum of %d", depth, limits.MaxDepth)
+		}
+		if complexity > limits.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the maximum of %d", complexity, limits.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+// measureSelections returns the depth and complexity of set, fragments expanded. Validated documents have no
+// fragment cycles.
+func measureSelections(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, variables map[string]interface{}) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+	var depth, complexity int
+	add := func(d, c int) {
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+		if complexity > math.MaxInt32 {
+			complexity = math.MaxInt32
+		}
+	}
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			d, c := measureSelections(s.SelectionSet, fragments, variables)
+			if graphqlListFields[s.Name.Value] {
+				c *= listSize(s, variables)
+			}
+			add(d+1, c+1)
+		case *ast.InlineFragment:
+			add(measureSelections(s.SelectionSet, fragments, variables))
+		case *ast.FragmentSpread:
+			if f, ok := fragments[s.Name.Value]; ok {
+				add(measureSelections(f.SelectionSet, fragments, variables))
+			}
+		}
+	}
+	return depth, complexity
+}
+
+// listSize returns the limit argument of field, graphqlListSize when it has none.
+func listSize(field *ast.Field, variables map[string]interface{}) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		var n int64
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			n, _ = strconv.ParseInt(v.Value, 10, 32)
+		case *ast.Variable:
+			if f, ok := variables[v.Name.Value].(float64); ok {
+				n = int64(f)
+			}
+		}
+		if n > 0 && n < math.MaxInt32 {
+			return int(n)
+		}
+	}
+	return graphqlListSize
+}
+
+type graphqlLoadersKey struct{}
+
+// graphqlLoaders batch lookups made while resolving a request.
+type graphqlLoaders struct {
+	pets       *loader
+	users      *loader
+	userOrders *loader
+}
+
+func newGraphQLLoaders(ctx context.Context, services *Services) *graphqlLoaders {
+	return &graphqlLoaders{
+		pets: newLoader(func(ids []int64) (map[int64]interface{}, error) {
+			pets, err := services.PetService.FindPetsByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := map[int64]interface{}{}
+			for _, pet := range pets {
+				byID[pet.ID] = pet
+			}
+			return byID, nil
+		}),
+		users: newLoader(func(ids []int64) (map[int64]interface{}, error) {
+			users, err := services.UserService.GetUsersByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := map[int64]interface{}{}
+			for _, user := range users {
+				byID[user.ID] = user
+			}
+			return byID, nil
+		}),
+		userOrders: newLoader(func(userIDs []int64) (map[int64]interface{}, error) {
+			orders, err := services.StoreService.FindOrdersByUserIDs(ctx, userIDs)
+			if err != nil {
+				return nil, err
+			}
+			byUser := map[int64][]*model.Order{}
+			for _, order := range orders {
+				byUser[order.UserID] = append(byUser[order.UserID], order)
+			}
+			byID := map[int64]interface{}{}
+			for _, id := range userIDs {
+				byID[id] = byUser[id]
+			}
+			return byID, nil
+		}),
+	}
+}
+
+func loadersFromContext(ctx context.Context) *graphqlLoaders {
+	return ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+}
+
+// loader collects the keys asked for while resolving fields at one depth, and fetches them all at once when the
+// first of them is needed.
+type loader struct {
+	mu      sync.Mutex
+	fetch   func(keys []int64) (map[int64]interface{}, error)
+	pending []int64
+	queued  map[int64]bool
+	values  map[int64]interface{}
+	errs    map[int64]error
+	batches int
+}
+
+func newLoader(fetch func(keys []int64) (map[int64]interface{}, error)) *loader {
+	return &loader{
+		fetch:  fetch,
+		queued: map[int64]bool{},
+		values: map[int64]interface{}{},
+		errs:   map[int64]error{},
+	}
+}
+
+// load queues key and returns a thunk resolving to its value, nil when it is not found.
+func (l *loader) load(key int64) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			l.batches++
+			values, err := l.fetch(keys)
+			for _, k := range keys {
+				if err != nil {
+					l.errs[k] = err
+				} else if v, ok := values[k]; ok {
+					l.values[k] = v
+				}
+			}
+		}
+		return l.values[key], l.errs[key]
+	}
+}
+
+// graphqlLong is a 64 bit integer, as ids, versions and amounts are.
+var graphqlLong = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Long",
+	Description: "64 bit integer",
+	Serialize: func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case int64:
+			return v
+		case *int64:
+			if v == nil {
+				return nil
+			}
+			return *v
+		case int32:
+			return int64(v)
+		case int:
+			return int64(v)
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int64(v)
+			}
+		case int:
+			return int64(v)
+		case int64:
+			return v
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+	ParseLiteral: func(value ast.Value) interface{} {
+		if v, ok := value.(*ast.IntValue); ok {
+			if n, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+})
+
+// graphqlResult has documents not found resolve to null.
+func graphqlResult(v interface{}, err error) (interface{}, error) {
+	if err == model.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeInput decodes the input object v into dest, by the JSON names of dest fields.
+func decodeInput(v interface{}, dest interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dest)
+}
+
+func stringsArg(v interface{}) []string {
+	var s []string
+	list, _ := v.([]interface{})
+	for _, item := range list {
+		if str, ok := item.(string); ok {
+			s = append(s, str)
+		}
+	}
+	return s
+}
+
+func int64Arg(args map[string]interface{}, name string) int64 {
+	n, _ := args[name].(int64)
+	return n
+}
+
+func intArg(args map[string]interface{}, name string) int64 {
+	n, _ := args[name].(int)
+	return int64(n)
+}
+
+// newGraphQLSchema returns the schema of POST /graphql, with queries and mutations over the pet, store and user
+// services.
+func newGraphQLSchema(services *Services) (graphql.Schema, error) {
+	category := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphqlLong},
+			"name": &graphql.Field{Type: graphql.String},
+		},
+	})
+	tag := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Tag",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphqlLong},
+			"name": &graphql.Field{Type: graphql.String},
+		},
+	})
+	pet := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Pet",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphqlLong)},
+			"name":      &graphql.Field{Type: graphql.String},
+			"category":  &graphql.Field{Type: category},
+			"photoUrls": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"tags":      &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(tag))},
+			"status":    &graphql.Field{Type: graphql.String},
+			"price":     &graphql.Field{Type: graphqlLong},
+			"currency":  &graphql.Field{Type: graphql.String},
+			"stock":     &graphql.Field{Type: graphqlLong},
+			"version":   &graphql.Field{Type: graphqlLong},
+		},
+	})
+	highlight := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SearchHighlight",
+		Fields: graphql.Fields{
+			"field": &graphql.Field{Type: graphql.String},
+			"value": &graphql.Field{Type: graphql.String},
+		},
+	})
+	searchResult := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PetSearchResult",
+		Fields: graphql.Fields{
+			"pet":        &graphql.Field{Type: graphql.NewNonNull(pet)},
+			"score":      &graphql.Field{Type: graphql.Float},
+			"highlights": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(highlight))},
+		},
+	})
+	order := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Order",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphqlLong)},
+			"petId":     &graphql.Field{Type: graphqlLong},
+			"quantity":  &graphql.Field{Type: graphql.Int},
+			"shipDate":  &graphql.Field{Type: graphql.DateTime},
+			"status":    &graphql.Field{Type: graphql.String},
+			"complete":  &graphql.Field{Type: graphql.Boolean},
+			"userId":    &graphql.Field{Type: graphqlLong},
+			"unitPrice": &graphql.Field{Type: graphqlLong},
+			"currency":  &graphql.Field{Type: graphql.String},
+			"total":     &graphql.Field{Type: graphqlLong},
+			"version":   &graphql.Field{Type: graphqlLong},
+		},
+	})
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphqlLong)},
+			"username":   &graphql.Field{Type: graphql.String},
+			"firstName":  &graphql.Field{Type: graphql.String},
+			"lastName":   &graphql.Field{Type: graphql.String},
+			"email":      &graphql.Field{Type: graphql.String},
+			"phone":      &graphql.Field{Type: graphql.String},
+			"userStatus": &graphql.Field{Type: graphql.Int},
+			"role":       &graphql.Field{Type: graphql.String},
+			"version":    &graphql.Field{Type: graphqlLong},
+		},
+	})
+
+	// relationships, batched by the loaders
+	order.AddFieldConfig("pet", &graphql.Field{
+		Type: pet,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return loadersFromContext(p.Context).pets.load(p.Source.(*model.Order).PetID), nil
+		},
+	})
+	order.AddFieldConfig("user", &graphql.Field{
+		Type:        user,
+		Description: "The user who placed the order, for the user and callers viewing users",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			o := p.Source.(*model.Order)
+			if caller := PrincipalFromContext(p.Context); caller.UserID != o.UserID && !caller.Can(PermissionViewUsers) {
+				return nil, errForbidden
+			}
+			return loadersFromContext(p.Context).users.load(o.UserID), nil
+		},
+	})
+	user.AddFieldConfig("orders", &graphql.Field{
+		Type:        graphql.NewList(graphql.NewNonNull(order)),
+		Description: "Orders of the user, latest ship date first, for the user and callers managing orders",
+		Args: graphql.FieldConfigArgument{
+			"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: int(model.DefaultOrderPageSize)},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			u := p.Source.(*model.User)
+			if !PrincipalFromContext(p.Context).Is(u.Username) && !PrincipalFromContext(p.Context).Can(PermissionManageOrders) {
+				return nil, errForbidden
+			}
+			limit := model.OrderFilter{Limit: intArg(p.Args, "limit")}.PageSize()
+			orders := loadersFromContext(p.Context).userOrders.load(u.ID)
+			return func() (interface{}, error) {
+				v, err := orders()
+				if err != nil {
+					return nil, err
+				}
+				found, _ := v.([]*model.Order)
+				if int64(len(found)) > limit {
+					found = found[:limit]
+				}
+				return found, nil
+			}, nil
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"pet": &graphql.Field{
+				Type: pet,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlLong)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFromContext(p.Context).pets.load(int64Arg(p.Args, "id")), nil
+				},
+			},
+			"pets": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(pet)),
+				Args: graphql.FieldConfigArgument{
+					"status": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					statuses := stringsArg(p.Args["status"])
+					for _, s := range statuses {
+						if !model.ValidPetStatus(s) {
+							return nil, fmt.Errorf("invalid status %q", s)
+						}
+					}
+					return services.PetService.FindPetsByStatus(p.Context, statuses)
+				},
+			},
+			"searchPets": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(searchResult)),
+				Args: graphql.FieldConfigArgument{
+					"query":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"status": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"fuzzy":  &graphql.ArgumentConfig{Type: graphql.Boolean},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					fuzzy, _ := p.Args["fuzzy"].(bool)
+					search := model.PetSearch{
+						Query:    p.Args["query"].(string),
+						Statuses: stringsArg(p.Args["status"]),
+						Fuzzy:    fuzzy,
+						Offset:   intArg(p.Args, "offset"),
+						Limit:    intArg(p.Args, "limit"),
+					}
+					if search.Offset < 0 || search.Limit < 0 {
+						return nil, fmt.Errorf("offset and limit must not be negative")
+					}
+					results, _, err := services.PetService.SearchPets(p.Context, search)
+					return results, err
+				},
+			},
+			"order": &graphql.Field{
+				Type: order,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlLong)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionPlaceOrder); err != nil {
+						return nil, err
+					}
+					o, err := services.StoreService.FindOrderByID(p.Context, int64Arg(p.Args, "id"))
+					if err == nil && !canAccessOrder(p.Context, o) {
+						err = model.ErrNotFound
+					}
+					return graphqlResult(o, err)
+				},
+			},
+			"orders": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(order)),
+				Description: "Orders matching the filter, latest ship date first, only those of the caller unless managing orders",
+				Args: graphql.FieldConfigArgument{
+					"userId": &graphql.ArgumentConfig{Type: graphqlLong},
+					"petId":  &graphql.ArgumentConfig{Type: graphqlLong},
+					"status": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionPlaceOrder); err != nil {
+						return nil, err
+					}
+					filter := model.OrderFilter{
+						UserID:   int64Arg(p.Args, "userId"),
+						PetID:    int64Arg(p.Args, "petId"),
+						Statuses: stringsArg(p.Args["status"]),
+						Offset:   intArg(p.Args, "offset"),
+						Limit:    intArg(p.Args, "limit"),
+					}
+					if filter.Offset < 0 || filter.Limit < 0 {
+						return nil, fmt.Errorf("offset and limit must not be negative")
+					}
+					if caller := PrincipalFromContext(p.Context); !caller.Can(PermissionManageOrders) {
+						if filter.UserID != 0 && filter.UserID != caller.UserID {
+							return nil, errForbidden
+						}
+						filter.UserID = caller.UserID
+					}
+					orders, _, err := services.StoreService.FindOrders(p.Context, filter)
+					return orders, err
+				},
+			},
+			"user": &graphql.Field{
+				Type: user,
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					username := p.Args["username"].(string)
+					if err := authorizeSelfOr(p.Context, username, PermissionViewUsers); err != nil {
+						return nil, err
+					}
+					return graphqlResult(services.UserService.GetUserByUsername(p.Context, username))
+				},
+			},
+			"me": &graphql.Field{
+				Type:        user,
+				Description: "The calling user, null for anonymous callers and the admin api key",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					caller := PrincipalFromContext(p.Context)
+					if caller == nil || caller.Username == "" {
+						return nil, nil
+					}
+					return graphqlResult(services.UserService.GetUserByUsername(p.Context, caller.Username))
+				},
+			},
+		},
+	})
+
+	categoryInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CategoryInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"id":   &graphql.InputObjectFieldConfig{Type: graphqlLong},
+			"name": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+	tagInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "TagInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"id":   &graphql.InputObjectFieldConfig{Type: graphqlLong},
+			"name": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+	petInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PetInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"id":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphqlLong)},
+			"name":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"category":  &graphql.InputObjectFieldConfig{Type: categoryInput},
+			"photoUrls": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"tags":      &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(tagInput))},
+			"status":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"price":     &graphql.InputObjectFieldConfig{Type: graphqlLong},
+			"currency":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"stock":     &graphql.InputObjectFieldConfig{Type: graphqlLong},
+			"version":   &graphql.InputObjectFieldConfig{Type: graphqlLong},
+		},
+	})
+	orderInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "OrderInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"id":       &graphql.InputObjectFieldConfig{Type: graphqlLong},
+			"petId":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphqlLong)},
+			"quantity": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"shipDate": &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"status":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"complete": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+			"userId":   &graphql.InputObjectFieldConfig{Type: graphqlLong},
+		},
+	})
+	userInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"id":         &graphql.InputObjectFieldConfig{Type: graphqlLong},
+			"username":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"firstName":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"lastName":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"email":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"password":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"phone":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"userStatus": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"role":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"version":    &graphql.InputObjectFieldConfig{Type: graphqlLong},
+		},
+	})
+
+	hard := &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false}
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"addPet": &graphql.Field{
+				Type: pet,
+				Args: graphql.FieldConfigArgument{
+					"pet": &graphql.ArgumentConfig{Type: graphql.NewNonNull(petInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionManagePets); err != nil {
+						return nil, err
+					}
+					var added model.Pet
+					if err := decodeInput(p.Args["pet"], &added); err != nil {
+						return nil, err
+					}
+					if err := services.PetService.AddPet(p.Context, &added); err != nil {
+						return nil, err
+					}
+					return graphqlResult(services.PetService.FindPetByID(p.Context, added.ID))
+				},
+			},
+			"updatePet": &graphql.Field{
+				Type:        pet,
+				Description: "Update name and status of a pet, if it is at version unless it is not given",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlLong)},
+					"version": &graphql.ArgumentConfig{Type: graphqlLong},
+					"name":    &graphql.ArgumentConfig{Type: graphql.String},
+					"status":  &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionManagePets); err != nil {
+						return nil, err
+					}
+					id := int64Arg(p.Args, "id")
+					name, _ := p.Args["name"].(string)
+					status, _ := p.Args["status"].(string)
+					if status != "" && !model.ValidPetStatus(status) {
+						return nil, fmt.Errorf("invalid status %q", status)
+					}
+					if err := services.PetService.UpdatePetByID(p.Context, id, int64Arg(p.Args, "version"), name, status); err != nil {
+						return nil, err
+					}
+					return graphqlResult(services.PetService.FindPetByID(p.Context, id))
+				},
+			},
+			"deletePet": &graphql.Field{
+				Type: graphql.Boolean,
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlLong)},
+					"hard": hard,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionManagePets); err != nil {
+						return nil, err
+					}
+					hard, _ := p.Args["hard"].(bool)
+					if err := authorizeHard(p.Context, hard); err != nil {
+						return nil, err
+					}
+					if err := services.PetService.DeletePetByID(p.Context, int64Arg(p.Args, "id"), hard); err != nil {
+						return nil, err
+					}
+					return true, nil
+				},
+			},
+			"placeOrder": &graphql.Field{
+				Type: order,
+				Args: graphql.FieldConfigArgument{
+					"order": &graphql.ArgumentConfig{Type: graphql.NewNonNull(orderInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionPlaceOrder); err != nil {
+						return nil, err
+					}
+					var o model.Order
+					if err := decodeInput(p.Args["order"], &o); err != nil {
+						return nil, err
+					}
+					// staff may place orders on behalf of a user, others always order for themselves
+					caller := PrincipalFromContext(p.Context)
+					if o.UserID == 0 || !caller.Can(PermissionManageOrders) {
+						o.UserID = caller.UserID
+					}
+					return services.StoreService.PlaceOrder(p.Context, &o)
+				},
+			},
+			"updateOrderStatus": &graphql.Field{
+				Type: order,
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlLong)},
+					"version": &graphql.ArgumentConfig{Type: graphqlLong},
+					"status":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionManageOrders); err != nil {
+						return nil, err
+					}
+					return services.StoreService.UpdateOrderStatus(p.Context,
+						int64Arg(p.Args, "id"), int64Arg(p.Args, "version"), p.Args["status"].(string))
+				},
+			},
+			"deleteOrder": &graphql.Field{
+				Type: graphql.Boolean,
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlLong)},
+					"hard": hard,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, PermissionPlaceOrder); err != nil {
+						return nil, err
+					}
+					id := int64Arg(p.Args, "id")
+					hard, _ := p.Args["hard"].(bool)
+					if err := authorizeHard(p.Context, hard); err != nil {
+						return nil, err
+					}
+					// orders deleted already can still be deleted for good
+					if !hard {
+						o, err := services.StoreService.FindOrderByID(p.Context, id)
+						if err == nil && !canAccessOrder(p.Context, o) {
+							err = model.ErrNotFound
+						}
+						if err != nil {
+							return nil, err
+						}
+					}
+					if err := services.StoreService.DeleteOrderByID(p.Context, id, hard); err != nil {
+						return nil, err
+					}
+					return true, nil
+				},
+			},
+			"createUser": &graphql.Field{
+				Type: user,
+				Args: graphql.FieldConfigArgument{
+					"user": &graphql.ArgumentConfig{Type: graphql.NewNonNull(userInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var u model.User
+					if err := decodeInput(p.Args["user"], &u); err != nil {
+						return nil, err
+					}
+					if err := authorizeRole(p.Context, &u, model.RoleCustomer); err != nil {
+						return nil, err
+					}
+					if err := services.UserService.CreateUser(p.Context, &u); err != nil {
+						return nil, err
+					}
+					return graphqlResult(services.UserService.GetUserByUsername(p.Context, u.Username))
+				},
+			},
+			"updateUser": &graphql.Field{
+				Type: user,
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"user":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(userInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					username := p.Args["username"].(string)
+					if err := authorizeSelfOr(p.Context, username, PermissionManageUsers); err != nil {
+						return nil, err
+					}
+					existing, err := services.UserService.GetUserByUsername(p.Context, username)
+					if err != nil {
+						return nil, err
+					}
+					var u model.User
+					if err = decodeInput(p.Args["user"], &u); err != nil {
+						return nil, err
+					}
+					if err = model.ValidateUserReplacement(existing, &u); err != nil {
+						return nil, err
+					}
+					if err = authorizeRole(p.Context, &u, existing.Role); err != nil {
+						return nil, err
+					}
+					if err = services.UserService.UpdateUserByUsername(p.Context, username, &u); err != nil {
+						return nil, err
+					}
+					return graphqlResult(services.UserService.GetUserByUsername(p.Context, u.Username))
+				},
+			},
+			"deleteUser": &graphql.Field{
+				Type: graphql.Boolean,
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"hard":     hard,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					username := p.Args["username"].(string)
+					if err := authorizeSelfOr(p.Context, username, PermissionManageUsers); err != nil {
+						return nil, err
+					}
+					hard, _ := p.Args["hard"].(bool)
+					if err := authorizeHard(p.Context, hard); err != nil {
+						return nil, err
+					}
+					if err := services.UserService.DeleteUserByUsername(p.Context, username, hard); err != nil {
+						return nil, err
+					}
+					return true, nil
+				},
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		return schema, err
+	}
+	for _, object := range []*graphql.Object{query, mutation, order, user} {
+		for _, field := range object.Fields() {
+			if field.Resolve != nil {
+				field.Resolve = resolveWithErrors(field.Resolve)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// resolveWithErrors has the errors of resolve, and of the thunk it returns, told to callers by graphqlError.
+func resolveWithErrors(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		v, err := resolve(p)
+		if thunk, ok := v.(func() (interface{}, error)); ok {
+			return func() (interface{}, error) {
+				v, err := thunk()
+				return v, graphqlError(err)
+			}, nil
+		}
+		return v, graphqlError(err)
+	}
+}
+
+// graphqlError returns the message of an error response alone, and a plain message for documents not found.
+func graphqlError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *model.ErrResponse:
+		return errors.New(e.Message)
+	}
+	if err == model.ErrNotFound {
+		return errors.New("not found")
+	}
+	return err
+}
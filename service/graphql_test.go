@@ -0,0 +1,176 @@
+package service
+
+// This is to test GraphQL queries, the batching of their lookups and their limits
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// graphqlPets serves pets from a map and counts batch lookups, other PetService methods are not implemented.
+type graphqlPets struct {
+	PetService
+	pets    map[int64]*model.Pet
+	batches [][]int64
+}
+
+func (s *graphqlPets) FindPetsByIDs(ctx context.Context, ids []int64) ([]*model.Pet, error) {
+	s.batches = append(s.batches, ids)
+	var pets []*model.Pet
+	for _, id := range ids {
+		if pet, ok := s.pets[id]; ok {
+			pets = append(pets, pet)
+		}
+	}
+	return pets, nil
+}
+
+// graphqlStore serves orders from a slice, other StoreService methods are not implemented.
+type graphqlStore struct {
+	StoreService
+	orders  []*model.Order
+	batches int
+}
+
+func (s *graphqlStore) FindOrders(ctx context.Context, filter model.OrderFilter) ([]*model.Order, int64, error) {
+	var orders []*model.Order
+	for _, o := range s.orders {
+		if filter.UserID == 0 || o.UserID == filter.UserID {
+			orders = append(orders, o)
+		}
+	}
+	return orders, int64(len(orders)), nil
+}
+
+func (s *graphqlStore) FindOrdersByUserIDs(ctx context.Context, userIDs []int64) ([]*model.Order, error) {
+	s.batches++
+	var orders []*model.Order
+	for _, o := range s.orders {
+		for _, id := range userIDs {
+			if o.UserID == id {
+				orders = append(orders, o)
+			}
+		}
+	}
+	return orders, nil
+}
+
+// graphqlUsers authenticates token "alice" as customer alice, other UserService methods are not implemented.
+type graphqlUsers struct {
+	UserService
+	users map[string]*model.User
+}
+
+func (s *graphqlUsers) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if u, ok := s.users[token]; ok {
+		return &Principal{UserID: u.ID, Username: u.Username, Role: u.Role}, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+func (s *graphqlUsers) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	if u, ok := s.users[username]; ok {
+		return u, nil
+	}
+	return nil, model.ErrNotFound
+}
+
+type graphqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, url, token, query string, variables map[string]interface{}) graphqlResponse {
+	body, _ := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	req, _ := http.NewRequest(http.MethodPost, url+"/v2/graphql", bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var out graphqlResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+	return out
+}
+
+func TestGraphQL(t *testing.T) {
+	pets := &graphqlPets{pets: map[int64]*model.Pet{
+		1: {ID: 1, Name: "Tom", Status: "sold", Category: model.NewCategory(1, "Cats")},
+		2: {ID: 2, Name: "Spike", Status: "sold", Category: model.NewCategory(2, "Dogs")},
+	}}
+	store := &graphqlStore{orders: []*model.Order{
+		{ID: 1, PetID: 1, UserID: 1, Quantity: 1},
+		{ID: 2, PetID: 2, UserID: 1, Quantity: 1},
+		{ID: 3, PetID: 1, UserID: 1, Quantity: 2},
+		{ID: 4, PetID: 2, UserID: 2, Quantity: 1},
+	}}
+	users := &graphqlUsers{users: map[string]*model.User{
+		"alice": {ID: 1, Username: "alice", Role: model.RoleCustomer},
+	}}
+	services := &Services{PetService: pets, StoreService: store, UserService: users}
+	server := httptest.NewServer(SetupRoutes(services, Options{GraphQLMaxDepth: 4}, log.NewNopLogger()))
+	defer server.Close()
+
+	// pets of orders are looked up at once
+	res := postGraphQL(t, server.URL, "alice", `{ orders { id pet { name category { name } } } }`, nil)
+	assert.Empty(t, res.Errors)
+	orders := res.Data["orders"].([]interface{})
+	assert.Len(t, orders, 3)
+	assert.Equal(t, "Cats", orders[0].(map[string]interface{})["pet"].(map[string]interface{})["category"].(map[string]interface{})["name"])
+	assert.Equal(t, [][]int64{{1, 2}}, pets.batches)
+
+	res = postGraphQL(t, server.URL, "alice", `query($u: String!) { user(username: $u) { username orders(limit: 2) { id } } }`,
+		map[string]interface{}{"u": "alice"})
+	assert.Empty(t, res.Errors)
+	assert.Len(t, res.Data["user"].(map[string]interface{})["orders"], 2)
+	assert.Equal(t, 1, store.batches)
+
+	// orders and other users are not for anonymous callers
+	res = postGraphQL(t, server.URL, "", `{ orders { id } }`, nil)
+	assert.Equal(t, "authentication required", res.Errors[0].Message)
+	res = postGraphQL(t, server.URL, "alice", `{ user(username: "bob") { id } }`, nil)
+	assert.Equal(t, "permission denied", res.Errors[0].Message)
+	res = postGraphQL(t, server.URL, "", `mutation { deletePet(id: 1) }`, nil)
+	assert.Equal(t, "authentication required", res.Errors[0].Message)
+
+	// missing documents are null
+	res = postGraphQL(t, server.URL, "", `{ pet(id: 9) { name } }`, nil)
+	assert.Empty(t, res.Errors)
+	assert.Nil(t, res.Data["pet"])
+
+	res = postGraphQL(t, server.URL, "alice", `{ me { orders { pet { category { name } } } } }`, nil)
+	assert.Equal(t, "query depth 5 exceeds the maximum of 4", res.Errors[0].Message)
+	assert.Nil(t, res.Data)
+}
+
+func TestCheckGraphQLLimits(t *testing.T) {
+	limits := graphqlLimits{MaxDepth: 10, MaxComplexity: 100}
+	for query, ok := range map[string]bool{
+		`{ pet(id: 1) { name tags { name } } }`:                             true,
+		`{ orders(limit: 20) { id pet { name } } }`:                         true,
+		`{ orders(limit: 50) { id pet { name } } }`:                         false,
+		`query($n: Int) { orders(limit: $n) { id pet { name } } }`:          false,
+		`{ orders { ...o } } fragment o on Order { pet { tags { name } } }`: false,
+	} {
+		doc, err := parser.Parse(parser.ParseParams{Source: query})
+		assert.NoError(t, err)
+		err = checkGraphQLLimits(doc, map[string]interface{}{"n": float64(40)}, limits)
+		if ok {
+			assert.NoError(t, err, query)
+		} else if assert.Error(t, err, query) {
+			assert.Contains(t, err.Error(), "complexity", query)
+		}
+	}
+}
@@ -0,0 +1,661 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/cooljeffrey/petstore/pb"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// grpcPermissions are the permissions required by gRPC methods, as by the matching REST routes. Methods not
+// listed are open to anonymous callers, or check who the caller is themselves.
+var grpcPermissions = map[string]Permission{
+	"/petstore.v1.PetService/AddPet":                 PermissionManagePets,
+	"/petstore.v1.PetService/UpdatePet":              PermissionManagePets,
+	"/petstore.v1.PetService/UpdatePetNameAndStatus": PermissionManagePets,
+	"/petstore.v1.PetService/DeletePet":              PermissionManagePets,
+	"/petstore.v1.PetService/RestorePet":             PermissionManageDeleted,
+	"/petstore.v1.StoreService/GetInventory":         PermissionViewInventory,
+	"/petstore.v1.StoreService/GetStockValue":        PermissionViewInventory,
+	"/petstore.v1.StoreService/PlaceOrder":           PermissionPlaceOrder,
+	"/petstore.v1.StoreService/GetOrder":             PermissionPlaceOrder,
+	"/petstore.v1.StoreService/FindOrders":           PermissionPlaceOrder,
+	"/petstore.v1.StoreService/UpdateOrderStatus":    PermissionManageOrders,
+	"/petstore.v1.StoreService/DeleteOrder":          PermissionPlaceOrder,
+	"/petstore.v1.StoreService/RestoreOrder":         PermissionManageDeleted,
+	"/petstore.v1.UserService/CreateUsers":           PermissionManageUsers,
+	"/petstore.v1.UserService/RestoreUser":           PermissionManageDeleted,
+	"/petstore.v1.UserService/UnlockUser":            PermissionManageUsers,
+}
+
+// NewGRPCServer returns a gRPC server of the pet, store and user services, with health checks and reflection.
+// Callers authenticate with the same tokens as REST callers, in authorization ( "Bearer <token>" ) or api_key
+// metadata.
+func NewGRPCServer(services *Services, options Options, logger log.Logger) *grpc.Server {
+	a := &grpcAuth{users: services.UserService, adminAPIKey: options.AdminAPIKey, logger: logger}
+	s := grpc.NewServer(grpc.UnaryInterceptor(a.unary), grpc.StreamInterceptor(a.stream))
+	pb.RegisterPetServiceServer(s, &grpcPetServer{services: services})
+	pb.RegisterStoreServiceServer(s, &grpcStoreServer{services: services})
+	pb.RegisterUserServiceServer(s, &grpcUserServer{services: services})
+
+	checks := health.NewServer()
+	for name := range s.GetServiceInfo() {
+		checks.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	checks.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, checks)
+	reflection.Register(s)
+	return s
+}
+
+// grpcAuth authenticates gRPC calls and checks grpcPermissions, like the authenticate and requirePermission
+// middlewares of REST routes.
+type grpcAuth struct {
+	users       UserService
+	adminAPIKey string
+	logger      log.Logger
+}
+
+func (a *grpcAuth) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	res, err := handler(ctx, req)
+	a.log(info.FullMethod, err)
+	return res, err
+}
+
+func (a *grpcAuth) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	err = handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+	a.log(info.FullMethod, err)
+	return err
+}
+
+// authorize returns ctx with the request id and principal of the call, or an error if the caller may not call
+// method.
+func (a *grpcAuth) authorize(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	id := requestIDOrNew(first("x-request-id"))
+	ctx = NewContextWithRequestID(ctx, id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+
+	token := first("api_key")
+	if h := first("authorization"); strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	var p *Principal
+	if token != "" {
+		var err error
+		if p, err = principalFor(ctx, a.users, a.adminAPIKey, token); err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		ctx = NewContextWithPrincipal(ctx, p)
+	}
+	if permission, ok := grpcPermissions[method]; ok {
+		if err := authorize(ctx, permission); err != nil {
+			return nil, grpcError(err)
+		}
+	}
+	return ctx, nil
+}
+
+func (a *grpcAuth) log(method string, err error) {
+	if err == nil {
+		return
+	}
+	if s, _ := status.FromError(err); s.Code() == codes.Internal || s.Code() == codes.Unknown {
+		_ = level.Error(a.logger).Log("method", method, "err", err)
+		return
+	}
+	_ = level.Info(a.logger).Log("method", method, "err", err)
+}
+
+// grpcServerStream is a server stream with the context set by the interceptor.
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcCodes map status codes of ErrResponse to gRPC codes.
+var grpcCodes = map[int32]codes.Code{
+	http.StatusBadRequest:         codes.InvalidArgument,
+	http.StatusUnauthorized:       codes.Unauthenticated,
+	http.StatusForbidden:          codes.PermissionDenied,
+	http.StatusNotFound:           codes.NotFound,
+	http.StatusConflict:           codes.FailedPrecondition,
+	http.StatusPreconditionFailed: codes.FailedPrecondition,
+	http.StatusTooManyRequests:    codes.ResourceExhausted,
+}
+
+// grpcError maps errors of the services to gRPC status errors, unknown errors to codes.Internal.
+func grpcError(err error) error {
+	return grpcErrorOr(err, codes.Internal)
+}
+
+// grpcErrorOr maps errors of the services to gRPC status errors, unknown errors to code.
+func grpcErrorOr(err error, code codes.Code) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *model.ErrResponse:
+		if c, ok := grpcCodes[e.Code]; ok {
+			return status.Error(c, e.Message)
+		}
+		return status.Error(codes.Internal, e.Message)
+	case *model.BatchError:
+		return status.Error(codes.InvalidArgument, e.Error())
+	case *LoginThrottledError:
+		return status.Error(codes.ResourceExhausted, e.Error())
+	}
+	switch err {
+	case model.ErrNotFound:
+		return status.Error(codes.NotFound, "not found")
+	case model.ErrVersionMismatch, model.ErrOutOfStock:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case context.Canceled, context.DeadlineExceeded:
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(code, err.Error())
+}
+
+func invalidArgument(format string, a ...interface{}) error {
+	return status.Error(codes.InvalidArgument, fmt.Sprintf(format, a...))
+}
+
+type grpcPetServer struct {
+	services *Services
+}
+
+func (s *grpcPetServer) AddPet(ctx context.Context, req *pb.Pet) (*pb.Pet, error) {
+	pet := petFromProto(req)
+	if err := pet.Validate(); err != nil {
+		return nil, invalidArgument(err.Error())
+	}
+	if err := s.services.PetService.AddPet(ctx, pet); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.get(ctx, pet.ID)
+}
+
+func (s *grpcPetServer) UpdatePet(ctx context.Context, req *pb.Pet) (*pb.Pet, error) {
+	pet := petFromProto(req)
+	if err := pet.Validate(); err != nil {
+		return nil, invalidArgument(err.Error())
+	}
+	if err := s.services.PetService.UpdatePet(ctx, pet); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.get(ctx, pet.ID)
+}
+
+func (s *grpcPetServer) GetPet(ctx context.Context, req *pb.GetPetRequest) (*pb.Pet, error) {
+	return s.get(ctx, req.Id)
+}
+
+func (s *grpcPetServer) get(ctx context.Context, id int64) (*pb.Pet, error) {
+	pet, err := s.services.PetService.FindPetByID(ctx, id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return petToProto(pet), nil
+}
+
+func (s *grpcPetServer) FindPetsByStatus(req *pb.FindPetsByStatusRequest, stream pb.PetService_FindPetsByStatusServer) error {
+	if len(req.Status) == 0 {
+		return invalidArgument("status is required")
+	}
+	err := s.services.PetService.StreamPetsByStatus(stream.Context(), req.Status, func(pet *model.Pet) error {
+		return stream.Send(petToProto(pet))
+	})
+	return grpcError(err)
+}
+
+func (s *grpcPetServer) SearchPets(ctx context.Context, req *pb.SearchPetsRequest) (*pb.SearchPetsResponse, error) {
+	search := model.PetSearch{
+		Query:    strings.TrimSpace(req.Query),
+		Statuses: req.Status,
+		Fuzzy:    req.Fuzzy,
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+	}
+	if search.Query == "" {
+		return nil, invalidArgument("query is required")
+	}
+	if search.Offset < 0 || search.Limit < 0 {
+		return nil, invalidArgument("offset and limit must not be negative")
+	}
+	for _, st := range search.Statuses {
+		if !model.ValidPetStatus(st) {
+			return nil, invalidArgument("invalid status %q", st)
+		}
+	}
+	results, total, err := s.services.PetService.SearchPets(ctx, search)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	res := &pb.SearchPetsResponse{Total: total}
+	for _, r := range results {
+		result := &pb.PetSearchResult{Pet: petToProto(r.Pet), Score: r.Score}
+		for _, h := range r.Highlights {
+			result.Highlights = append(result.Highlights, &pb.SearchHighlight{Field: h.Field, Value: h.Value})
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res, nil
+}
+
+func (s *grpcPetServer) UpdatePetNameAndStatus(ctx context.Context, req *pb.UpdatePetRequest) (*pb.Pet, error) {
+	if req.Name == "" && req.Status == "" {
+		return nil, invalidArgument("name or status is required")
+	}
+	if req.Status != "" && !model.ValidPetStatus(req.Status) {
+		return nil, invalidArgument("invalid status %q", req.Status)
+	}
+	err := s.services.PetService.UpdatePetByID(ctx, req.Id, req.Version, req.Name, req.Status)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.get(ctx, req.Id)
+}
+
+func (s *grpcPetServer) DeletePet(ctx context.Context, req *pb.DeletePetRequest) (*empty.Empty, error) {
+	if err := authorizeHard(ctx, req.Hard); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.services.PetService.DeletePetByID(ctx, req.Id, req.Hard); err != nil {
+		return nil, grpcError(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcPetServer) RestorePet(ctx context.Context, req *pb.RestorePetRequest) (*pb.Pet, error) {
+	pet, err := s.services.PetService.RestorePetByID(ctx, req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return petToProto(pet), nil
+}
+
+type grpcStoreServer struct {
+	services *Services
+}
+
+func (s *grpcStoreServer) GetInventory(ctx context.Context, _ *empty.Empty) (*pb.Inventory, error) {
+	counts, err := s.services.StoreService.GetInventoriesByStatus(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Inventory{Counts: counts}, nil
+}
+
+func (s *grpcStoreServer) GetStockValue(ctx context.Context, _ *empty.Empty) (*pb.StockValue, error) {
+	value, err := s.services.StoreService.GetStockValue(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.StockValue{Values: value}, nil
+}
+
+func (s *grpcStoreServer) PlaceOrder(ctx context.Context, req *pb.Order) (*pb.Order, error) {
+	order, err := orderFromProto(req)
+	if err != nil {
+		return nil, invalidArgument(err.Error())
+	}
+	// staff may place orders on behalf of a user, others always order for themselves
+	p := PrincipalFromContext(ctx)
+	if order.UserID == 0 || !p.Can(PermissionManageOrders) {
+		order.UserID = p.UserID
+	}
+	placed, err := s.services.StoreService.PlaceOrder(ctx, order)
+	if err != nil {
+		return nil, grpcErrorOr(err, codes.InvalidArgument)
+	}
+	return orderToProto(placed), nil
+}
+
+func (s *grpcStoreServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.Order, error) {
+	order, err := s.services.StoreService.FindOrderByID(ctx, req.Id)
+	if err == nil && !canAccessOrder(ctx, order) {
+		err = model.ErrNotFound
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return orderToProto(order), nil
+}
+
+// FindOrders finds orders of any user for callers managing orders, and only their own for others.
+func (s *grpcStoreServer) FindOrders(ctx context.Context, req *pb.FindOrdersRequest) (*pb.FindOrdersResponse, error) {
+	filter := model.OrderFilter{
+		UserID:   req.UserId,
+		PetID:    req.PetId,
+		Statuses: req.Status,
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+	}
+	if filter.Offset < 0 || filter.Limit < 0 {
+		return nil, invalidArgument("offset and limit must not be negative")
+	}
+	var err error
+	for _, ts := range []struct {
+		dest *time.Time
+		src  *timestamp.Timestamp
+	}{{&filter.ShipDateFrom, req.ShipDateFrom}, {&filter.ShipDateTo, req.ShipDateTo}} {
+		if *ts.dest, err = timeFromProto(ts.src); err != nil {
+			return nil, invalidArgument(err.Error())
+		}
+	}
+	if req.Username != "" {
+		user, err := s.services.UserService.GetUserByUsername(ctx, req.Username)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		filter.UserID = user.ID
+	}
+	if p := PrincipalFromContext(ctx); !p.Can(PermissionManageOrders) {
+		if filter.UserID != 0 && filter.UserID != p.UserID {
+			return nil, grpcError(errForbidden)
+		}
+		filter.UserID = p.UserID
+	}
+	orders, total, err := s.services.StoreService.FindOrders(ctx, filter)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	res := &pb.FindOrdersResponse{Total: total}
+	for _, order := range orders {
+		res.Orders = append(res.Orders, orderToProto(order))
+	}
+	return res, nil
+}
+
+func (s *grpcStoreServer) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrderStatusRequest) (*pb.Order, error) {
+	order, err := s.services.StoreService.UpdateOrderStatus(ctx, req.Id, req.Version, req.Status)
+	if err != nil {
+		return nil, grpcErrorOr(err, codes.InvalidArgument)
+	}
+	return orderToProto(order), nil
+}
+
+func (s *grpcStoreServer) DeleteOrder(ctx context.Context, req *pb.DeleteOrderRequest) (*empty.Empty, error) {
+	if err := authorizeHard(ctx, req.Hard); err != nil {
+		return nil, grpcError(err)
+	}
+	// orders deleted already can still be deleted for good
+	if !req.Hard {
+		if _, err := s.GetOrder(ctx, &pb.GetOrderRequest{Id: req.Id}); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.services.StoreService.DeleteOrderByID(ctx, req.Id, req.Hard); err != nil {
+		return nil, grpcError(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcStoreServer) RestoreOrder(ctx context.Context, req *pb.RestoreOrderRequest) (*pb.Order, error) {
+	order, err := s.services.StoreService.RestoreOrderByID(ctx, req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return orderToProto(order), nil
+}
+
+type grpcUserServer struct {
+	services *Services
+}
+
+func (s *grpcUserServer) CreateUser(ctx context.Context, req *pb.User) (*pb.User, error) {
+	user := userFromProto(req)
+	if err := authorizeRole(ctx, user, model.RoleCustomer); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.services.UserService.CreateUser(ctx, user); err != nil {
+		return nil, grpcErrorOr(err, codes.InvalidArgument)
+	}
+	return s.get(ctx, user.Username)
+}
+
+func (s *grpcUserServer) CreateUsers(ctx context.Context, req *pb.CreateUsersRequest) (*empty.Empty, error) {
+	users := make([]*model.User, len(req.Users))
+	for i, u := range req.Users {
+		users[i] = userFromProto(u)
+	}
+	if err := s.services.UserService.CreateUsersWithArray(ctx, users); err != nil {
+		return nil, grpcErrorOr(err, codes.InvalidArgument)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcUserServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.Session, error) {
+	session, err := s.services.UserService.Login(ctx, req.Username, req.Password)
+	if _, ok := err.(*LoginThrottledError); ok {
+		return nil, grpcError(err)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidCredentials.Error())
+	}
+	expiresAt, _ := ptypes.TimestampProto(session.ExpiresAt)
+	return &pb.Session{
+		Token:     session.Token,
+		UserId:    session.UserID,
+		Username:  session.Username,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *grpcUserServer) Logout(ctx context.Context, _ *empty.Empty) (*empty.Empty, error) {
+	if err := s.services.UserService.Logout(ctx); err != nil {
+		return nil, grpcError(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcUserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	if err := authorizeSelfOr(ctx, req.Username, PermissionViewUsers); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.get(ctx, req.Username)
+}
+
+func (s *grpcUserServer) get(ctx context.Context, username string) (*pb.User, error) {
+	user, err := s.services.UserService.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return userToProto(user), nil
+}
+
+func (s *grpcUserServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
+	if err := authorizeSelfOr(ctx, req.Username, PermissionManageUsers); err != nil {
+		return nil, grpcError(err)
+	}
+	if req.User == nil {
+		return nil, invalidArgument("user is required")
+	}
+	existing, err := s.services.UserService.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	user := userFromProto(req.User)
+	if err = model.ValidateUserReplacement(existing, user); err != nil {
+		return nil, invalidArgument(err.Error())
+	}
+	if err = authorizeRole(ctx, user, existing.Role); err != nil {
+		return nil, grpcError(err)
+	}
+	if err = s.services.UserService.UpdateUserByUsername(ctx, req.Username, user); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.get(ctx, user.Username)
+}
+
+func (s *grpcUserServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*empty.Empty, error) {
+	if err := authorizeSelfOr(ctx, req.Username, PermissionManageUsers); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := authorizeHard(ctx, req.Hard); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.services.UserService.DeleteUserByUsername(ctx, req.Username, req.Hard); err != nil {
+		return nil, grpcError(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcUserServer) RestoreUser(ctx context.Context, req *pb.RestoreUserRequest) (*pb.User, error) {
+	user, err := s.services.UserService.RestoreUser(ctx, req.Username)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return userToProto(user), nil
+}
+
+func (s *grpcUserServer) UnlockUser(ctx context.Context, req *pb.UnlockUserRequest) (*empty.Empty, error) {
+	if err := s.services.UserService.UnlockUser(ctx, req.Username); err != nil {
+		return nil, grpcError(err)
+	}
+	return &empty.Empty{}, nil
+}
+
+func petToProto(pet *model.Pet) *pb.Pet {
+	p := &pb.Pet{
+		Id:        pet.ID,
+		Name:      pet.Name,
+		PhotoUrls: pet.PhotoUrls,
+		Status:    pet.Status,
+		Price:     pet.Price,
+		Currency:  pet.Currency,
+		Version:   pet.Version,
+	}
+	if pet.Category != nil {
+		p.Category = &pb.Category{Id: pet.Category.ID, Name: pet.Category.Name}
+	}
+	for _, tag := range pet.Tags {
+		if tag != nil {
+			p.Tags = append(p.Tags, &pb.Tag{Id: tag.ID, Name: tag.Name})
+		}
+	}
+	if pet.Stock != nil {
+		p.Stock = &wrappers.Int64Value{Value: *pet.Stock}
+	}
+	return p
+}
+
+func petFromProto(p *pb.Pet) *model.Pet {
+	pet := &model.Pet{
+		ID:        p.Id,
+		Name:      p.Name,
+		PhotoUrls: p.PhotoUrls,
+		Status:    p.Status,
+		Price:     p.Price,
+		Currency:  p.Currency,
+		Version:   p.Version,
+	}
+	if p.Category != nil {
+		pet.Category = model.NewCategory(p.Category.Id, p.Category.Name)
+	}
+	for _, tag := range p.Tags {
+		pet.Tags = append(pet.Tags, model.NewTag(tag.Id, tag.Name))
+	}
+	if p.Stock != nil {
+		stock := p.Stock.Value
+		pet.Stock = &stock
+	}
+	return pet
+}
+
+func orderToProto(order *model.Order) *pb.Order {
+	shipDate, _ := ptypes.TimestampProto(order.ShipDate)
+	return &pb.Order{
+		Id:        order.ID,
+		PetId:     order.PetID,
+		Quantity:  order.Quantity,
+		ShipDate:  shipDate,
+		Status:    order.Status,
+		Complete:  order.Complete,
+		UserId:    order.UserID,
+		UnitPrice: order.UnitPrice,
+		Currency:  order.Currency,
+		Total:     order.Total,
+		Version:   order.Version,
+	}
+}
+
+func orderFromProto(o *pb.Order) (*model.Order, error) {
+	shipDate, err := timeFromProto(o.ShipDate)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Order{
+		ID:       o.Id,
+		PetID:    o.PetId,
+		Quantity: o.Quantity,
+		ShipDate: shipDate,
+		Status:   o.Status,
+		Complete: o.Complete,
+		UserID:   o.UserId,
+		Version:  o.Version,
+	}, nil
+}
+
+// timeFromProto returns the time of ts, the zero time when it is not set.
+func timeFromProto(ts *timestamp.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	return ptypes.Timestamp(ts)
+}
+
+// userToProto leaves the password out.
+func userToProto(user *model.User) *pb.User {
+	return &pb.User{
+		Id:         user.ID,
+		Username:   user.Username,
+		FirstName:  user.Firstname,
+		LastName:   user.Lastname,
+		Email:      user.Email,
+		Phone:      user.Phone,
+		UserStatus: user.UserStatus,
+		Role:       user.Role,
+		Version:    user.Version,
+	}
+}
+
+func userFromProto(u *pb.User) *model.User {
+	user := model.NewUser(u.Id, u.Username, u.FirstName, u.LastName, u.Email, u.Password, u.Phone, u.UserStatus)
+	user.Role = u.Role
+	user.Version = u.Version
+	return user
+}
@@ -0,0 +1,132 @@
+package service
+
+// This is to test the gRPC server over an in-memory connection
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/cooljeffrey/petstore/pb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// grpcPets serves pets from a map, other PetService methods are not implemented.
+type grpcPets struct {
+	PetService
+	pets map[int64]*model.Pet
+}
+
+func (s *grpcPets) FindPetByID(ctx context.Context, id int64) (*model.Pet, error) {
+	if pet, ok := s.pets[id]; ok {
+		return pet, nil
+	}
+	return nil, model.ErrNotFound
+}
+
+func (s *grpcPets) AddPet(ctx context.Context, pet *model.Pet) error {
+	s.pets[pet.ID] = pet
+	return nil
+}
+
+func (s *grpcPets) StreamPetsByStatus(ctx context.Context, statuses []string, fn func(pet *model.Pet) error) error {
+	for id := int64(1); id <= int64(len(s.pets)); id++ {
+		if pet := s.pets[id]; pet.Status == statuses[0] {
+			if err := fn(pet); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// grpcUsers authenticates no one, other UserService methods are not implemented.
+type grpcUsers struct {
+	UserService
+}
+
+func (s *grpcUsers) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	return nil, ErrInvalidToken
+}
+
+func dialGRPC(t *testing.T, services *Services) (*grpc.ClientConn, func()) {
+	lis := bufconn.Listen(1 << 16)
+	server := NewGRPCServer(services, Options{AdminAPIKey: "key"}, log.NewNopLogger())
+	go func() { _ = server.Serve(lis) }()
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithInsecure(),
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) { return lis.Dial() }))
+	assert.NoError(t, err)
+	return conn, func() {
+		_ = conn.Close()
+		server.Stop()
+	}
+}
+
+func TestGRPCPetService(t *testing.T) {
+	pets := &grpcPets{pets: map[int64]*model.Pet{
+		1: {ID: 1, Name: "Tom", Status: "available", PhotoUrls: []string{"tom.png"}},
+		2: {ID: 2, Name: "Jerry", Status: "sold", PhotoUrls: []string{"jerry.png"}},
+		3: {ID: 3, Name: "Spike", Status: "available", PhotoUrls: []string{"spike.png"}},
+	}}
+	conn, closeConn := dialGRPC(t, &Services{PetService: pets, UserService: &grpcUsers{}})
+	defer closeConn()
+	client := pb.NewPetServiceClient(conn)
+	ctx := context.Background()
+
+	pet, err := client.GetPet(ctx, &pb.GetPetRequest{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Tom", pet.Name)
+	_, err = client.GetPet(ctx, &pb.GetPetRequest{Id: 9})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.FindPetsByStatus(ctx, &pb.FindPetsByStatusRequest{Status: []string{"available"}})
+	assert.NoError(t, err)
+	var names []string
+	for {
+		pet, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, pet.Name)
+	}
+	assert.Equal(t, []string{"Tom", "Spike"}, names)
+
+	// adding pets takes permission
+	tyke := &pb.Pet{Id: 4, Name: "Tyke", Status: "available", PhotoUrls: []string{"tyke.png"}}
+	_, err = client.AddPet(ctx, tyke)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.AddPet(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong"), tyke)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	admin := metadata.AppendToOutgoingContext(ctx, "api_key", "key")
+	_, err = client.AddPet(admin, &pb.Pet{Id: 4})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	pet, err = client.AddPet(admin, tyke)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tyke", pet.Name)
+}
+
+func TestGRPCError(t *testing.T) {
+	for err, code := range map[error]codes.Code{
+		model.ErrNotFound:        codes.NotFound,
+		model.ErrVersionMismatch: codes.FailedPrecondition,
+		model.ErrOutOfStock:      codes.FailedPrecondition,
+		errForbidden:             codes.PermissionDenied,
+		&model.BatchError{}:      codes.InvalidArgument,
+		model.NewErrResponse(http.StatusTooManyRequests, "error", "slow down"): codes.ResourceExhausted,
+		io.ErrUnexpectedEOF: codes.Internal,
+	} {
+		assert.Equal(t, code, status.Code(grpcError(err)), err.Error())
+	}
+	assert.NoError(t, grpcError(nil))
+}
@@ -0,0 +1,524 @@
+package service
+
+// This is to test every route end to end, from request to storage, against the contract in schema/petstore.json.
+// Request bodies are those of __tests__, which also seed the demo data.
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const integrationAdminKey = "admin-key"
+
+// integrationServer serves the routes over real services and a memory storage.
+type integrationServer struct {
+	t       *testing.T
+	server  *httptest.Server
+	storage *memoryStorage
+	// Folder of uploaded images
+	images string
+}
+
+// newIntegrationServer starts a server with a staff user, staff1 whose password is staff, over the services changed
+// by configure.
+func newIntegrationServer(t *testing.T, configure ...func(services *Services, storage *memoryStorage)) *integrationServer {
+	images, err := ioutil.TempDir("", "petstore-images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := newMemoryStorage()
+	storage.users[100] = model.User{ID: 100, Username: "staff1", Password: "staff", Role: model.RoleStaff, Version: 1}
+	services := newMemoryServices(storage, images)
+	for _, fn := range configure {
+		fn(services, storage)
+	}
+	handler := SetupRoutes(services, Options{AdminAPIKey: integrationAdminKey}, log.NewNopLogger())
+	return &integrationServer{
+		t:       t,
+		server:  httptest.NewServer(handler),
+		storage: storage,
+		images:  images,
+	}
+}
+
+// newMemoryServices builds the services over storage, saving images to folder images.
+func newMemoryServices(storage *memoryStorage, images string) *Services {
+	logger := log.NewNopLogger()
+	return &Services{
+		UserService:  NewUserService(logger, storage, model.DefaultLockoutPolicy, time.Hour),
+		PetService:   NewPetService(logger, storage, nil, "http://localhost/images", images),
+		StoreService: NewStoreService(logger, storage, nil),
+		AuditService: NewAuditService(logger, storage),
+	}
+}
+
+func (s *integrationServer) Close() {
+	s.server.Close()
+	_ = os.RemoveAll(s.images)
+}
+
+// call makes a request authenticated with token unless it is empty, with a JSON body unless it is empty or
+// header sets another Content-Type, and returns the response with its body read.
+func (s *integrationServer) call(method, path, token, body string, header ...string) (*http.Response, string) {
+	req, err := http.NewRequest(method, s.server.URL+"/v2"+path, strings.NewReader(body))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	if token != "" {
+		req.Header.Set("api_key", token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return res, string(b)
+}
+
+// status makes a request like call and returns the status code of the response.
+func (s *integrationServer) status(method, path, token, body string, header ...string) int {
+	res, _ := s.call(method, path, token, body, header...)
+	return res.StatusCode
+}
+
+// login returns the session token of username.
+func (s *integrationServer) login(username, password string) string {
+	res, body := s.call(http.MethodPost, "/user/login", "", `{"username":"`+username+`","password":"`+password+`"}`)
+	assert.Equal(s.t, http.StatusOK, res.StatusCode, body)
+	var token string
+	assert.NoError(s.t, json.Unmarshal([]byte(body), &token))
+	return token
+}
+
+// fixture reads a request body of __tests__.
+func fixture(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join("..", "__tests__", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestPetRoutes(t *testing.T) {
+	s := newIntegrationServer(t)
+	defer s.Close()
+	admin := integrationAdminKey
+	staff := s.login("staff1", "staff")
+
+	// addPet
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet", staff, fixture(t, "pet_add.json")))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet", staff, fixture(t, "pet_add2.json")))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet", staff, fixture(t, "pet_add3.json")))
+	assert.Equal(t, http.StatusMethodNotAllowed, s.status(http.MethodPost, "/pet", staff, `{"id":`))
+	assert.Equal(t, http.StatusMethodNotAllowed, s.status(http.MethodPost, "/pet", staff, `{"id":3,"status":"available"}`))
+	assert.Equal(t, http.StatusMethodNotAllowed, s.status(http.MethodPost, "/pet", staff, fixture(t, "pet_add.json")))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodPost, "/pet", "", fixture(t, "pet_add.json")))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodPost, "/pet", "expired", fixture(t, "pet_add.json")))
+
+	// addPets
+	res, body := s.call(http.MethodPost, "/pet/batch?mode=partial", staff, `[{"id":3,"name":"rex"},{"id":4}]`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, body, `"status":"failed"`)
+	res, body = s.call(http.MethodPost, "/pet/batch", staff, `[{"id":5,"name":"max"},{"id":3,"name":"rex"}]`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Contains(t, body, `"status":"not_created"`)
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/pet/batch?mode=some", staff, `[]`))
+
+	// updatePet
+	res, _ = s.call(http.MethodPut, "/pet", staff, fixture(t, "pet_update.json"))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, s.status(http.MethodPut, "/pet", staff, fixture(t, "pet_update.json"), "If-Match", `"1"`))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPut, "/pet", staff, `{"id":9,"name":"ghost","status":"sold"}`))
+	assert.Equal(t, http.StatusMethodNotAllowed, s.status(http.MethodPut, "/pet", staff, `[`))
+
+	// findPetsByStatus
+	res, body = s.call(http.MethodGet, "/pet/findByStatus?status=available,pending", "", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var pets []*model.Pet
+	assert.NoError(t, json.Unmarshal([]byte(body), &pets))
+	assert.Len(t, pets, 2)
+
+	// searchPets
+	res, body = s.call(http.MethodGet, "/pet/search?q=kitten", "", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "1", res.Header.Get("X-Total-Count"))
+	assert.Contains(t, body, `"kitten"`)
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodGet, "/pet/search", "", ""))
+
+	// getPetById
+	res, body = s.call(http.MethodGet, "/pet/1", "", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var pet model.Pet
+	assert.NoError(t, json.Unmarshal([]byte(body), &pet))
+	assert.Equal(t, "kitten", pet.Name)
+	assert.Equal(t, http.StatusNotModified, s.status(http.MethodGet, "/pet/1", "", "", "If-None-Match", res.Header.Get("ETag")))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/pet/9", "", ""))
+	res, body = s.call(http.MethodGet, "/pet/cat", "", "")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Empty(t, body)
+
+	// updatePetWithForm
+	form := []string{"Content-Type", "application/x-www-form-urlencoded"}
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet/1", staff, "name=pet1&status=sold", form...))
+	assert.Equal(t, http.StatusPreconditionFailed, s.status(http.MethodPost, "/pet/1", staff, "name=pet2", append(form, "If-Match", `"1"`)...))
+	assert.Equal(t, http.StatusMethodNotAllowed, s.status(http.MethodPost, "/pet/9", staff, "name=pet9", form...))
+	assert.Equal(t, http.StatusMethodNotAllowed, s.status(http.MethodPost, "/pet/1", staff, "", form...))
+
+	// patchPet
+	mergePatch := []string{"Content-Type", contentTypeMergePatch}
+	res, body = s.call(http.MethodPatch, "/pet/1", staff, `{"name":"pet3"}`, mergePatch...)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, body, `"name":"pet3"`)
+	assert.Equal(t, http.StatusPreconditionFailed, s.status(http.MethodPatch, "/pet/1", staff, `{"name":"pet4"}`, append(mergePatch, "If-Match", `"1"`)...))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPatch, "/pet/1", staff, `{"status":"lost"}`, mergePatch...))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPatch, "/pet/9", staff, `{"name":"pet9"}`, mergePatch...))
+	assert.Equal(t, http.StatusUnsupportedMediaType, s.status(http.MethodPatch, "/pet/1", staff, `{"name":"pet5"}`))
+
+	// uploadFile
+	var upload bytes.Buffer
+	w := multipart.NewWriter(&upload)
+	part, _ := w.CreateFormFile("file", "test.jpg")
+	_, _ = part.Write([]byte(fixture(t, "test.jpg")))
+	_ = w.Close()
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet/2/uploadImage", staff, upload.String(), "Content-Type", w.FormDataContentType()))
+	res, body = s.call(http.MethodGet, "/pet/2", "", "")
+	assert.Contains(t, body, "http://localhost/images/")
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/pet/2/uploadImage", staff, ""))
+
+	// deletePet
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodDelete, "/pet/1?hard=true", staff, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/pet/1", staff, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodDelete, "/pet/1", staff, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/pet/1", "", ""))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodDelete, "/pet/cat", staff, ""))
+
+	// restorePet
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/pet/1/restore", staff, ""))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet/1/restore", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/pet/1/restore", admin, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/pet/1?hard=true", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/pet/1/restore", admin, ""))
+}
+
+func TestStoreRoutes(t *testing.T) {
+	s := newIntegrationServer(t)
+	defer s.Close()
+	admin := integrationAdminKey
+	staff := s.login("staff1", "staff")
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet", staff, fixture(t, "pet_add.json")))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/pet", staff,
+		`{"id":1,"name":"kitten","status":"available","price":1000,"currency":"EUR","stock":2}`))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/user/createWithArray", admin, fixture(t, "user_createWithArray.json")))
+	customer := s.login("username1", "string")
+	other := s.login("username2", "string")
+
+	// getInventory
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/store/inventory", customer, ""))
+	res, body := s.call(http.MethodGet, "/store/inventory", staff, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"available":3}`, body)
+	res, body = s.call(http.MethodGet, "/store/inventory?report=value", staff, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"EUR":2000}`, body)
+
+	// placeOrder
+	res, body = s.call(http.MethodPost, "/store/order", customer, fixture(t, "store_order.json"))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var order model.Order
+	assert.NoError(t, json.Unmarshal([]byte(body), &order))
+	assert.Equal(t, int64(1), order.UserID)
+	// pet 0 is single and pending now
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/store/order", other, `{"id":3,"petId":0}`))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/store/order", customer, `{"id":`))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/store/order", customer, `{"id":1,"petId":1,"quantity":5}`))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/store/order", customer, `{"id":2,"petId":9}`))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodPost, "/store/order", "", fixture(t, "store_order.json")))
+	res, body = s.call(http.MethodPost, "/store/order", customer, `{"id":1,"petId":1,"quantity":2,"shipDate":"2019-05-01T00:00:00Z","status":"placed"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, body, `"total":2000`)
+
+	// getOrderById
+	assert.Equal(t, http.StatusOK, s.status(http.MethodGet, "/store/order/0", customer, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/store/order/0", other, ""))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodGet, "/store/order/0", staff, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/store/order/9", staff, ""))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodGet, "/store/order/first", staff, ""))
+
+	// findOrders
+	res, body = s.call(http.MethodGet, "/store/order?status=placed&limit=1", staff, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "2", res.Header.Get("X-Total-Count"))
+	assert.Contains(t, body, `"id":1`)
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/store/order?username=nobody", staff, ""))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodGet, "/store/order?limit=-1", staff, ""))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/store/order", customer, ""))
+
+	// updateOrderStatus
+	form := []string{"Content-Type", "application/x-www-form-urlencoded"}
+	res, _ = s.call(http.MethodPost, "/store/order/1", staff, "status=approved", form...)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, s.status(http.MethodPost, "/store/order/1", staff, "status=placed", append(form, "If-Match", `"1"`)...))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/store/order/1", staff, "status=lost", form...))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/store/order/1", staff, "status=delivered", form...))
+	assert.Equal(t, http.StatusConflict, s.status(http.MethodPost, "/store/order/1", staff, "status=placed", form...))
+	assert.Equal(t, http.StatusPreconditionFailed, s.status(http.MethodPost, "/store/order/9", staff, "status=placed", append(form, "If-Match", `"1"`)...))
+
+	// deleteOrder puts the quantity back into stock, unless delivered
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodDelete, "/store/order/0", other, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/store/order/0", customer, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodDelete, "/store/order/0", customer, ""))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodDelete, "/store/order/first", customer, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/store/order/1", staff, ""))
+	_, body = s.call(http.MethodGet, "/store/inventory", staff, "")
+	assert.JSONEq(t, `{"available":1}`, body)
+
+	// restoreOrder
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/store/order/0/restore", staff, ""))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/store/order/0/restore", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/store/order/0/restore", admin, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/store/order/1?hard=true", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/store/order/1/restore", admin, ""))
+}
+
+func TestUserRoutes(t *testing.T) {
+	s := newIntegrationServer(t)
+	defer s.Close()
+	admin := integrationAdminKey
+	staff := s.login("staff1", "staff")
+
+	// createUser signs up customers only, unless called by an admin
+	res, body := s.call(http.MethodPost, "/user", "", fixture(t, "user_create.json"))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, body, `"username":"string"`)
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/user", "", fixture(t, "user_create.json")))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/user", "", `{"id":`))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/user", "", `{"id":7,"username":"boss","role":"admin"}`))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/user", admin, `{"id":7,"username":"boss","role":"admin"}`))
+
+	// createUsersWithArrayInput and createUsersWithListInput
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/user/createWithArray", staff, fixture(t, "user_createWithArray.json")))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/user/createWithArray", admin, fixture(t, "user_createWithArray.json")))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/user/createWithList", admin, fixture(t, "user_createWithList.json")))
+	res, body = s.call(http.MethodPost, "/user/createWithList", admin, fixture(t, "user_createWithList.json"))
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Contains(t, body, `"items":[{"index":0,"id":4`)
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/user/createWithArray", admin, `{}`))
+
+	// loginUser
+	res, _ = s.call(http.MethodGet, "/user/login?username=username1&password=string", "", "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "100", res.Header.Get("X-Rate-Limit"))
+	_, err := time.Parse(time.RFC3339, res.Header.Get("X-Expires-After"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/user/login", "", "username=username1&password=string",
+		"Content-Type", "application/x-www-form-urlencoded"))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodGet, "/user/login?username=username1&password=wrong", "", ""))
+	res, _ = s.call(http.MethodGet, "/user/login?username=username1&password=string", "", "")
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "1", res.Header.Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/user/username1/unlock", admin, ""))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/user/username1/unlock", staff, ""))
+	customer := s.login("username1", "string")
+
+	// logoutUser
+	other := s.login("username2", "string")
+	assert.Equal(t, http.StatusOK, s.status(http.MethodGet, "/user/logout", other, ""))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodGet, "/user/username2", other, ""))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodGet, "/user/logout", "", ""))
+
+	// getUserByName
+	res, body = s.call(http.MethodGet, "/user/username1", customer, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var user, expected model.User
+	assert.NoError(t, json.Unmarshal([]byte(body), &user))
+	assert.NoError(t, json.Unmarshal([]byte(fixture(t, "username1.json")), &expected))
+	expected.Version = 1
+	assert.Equal(t, expected, user)
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/user/username2", customer, ""))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodGet, "/user/username2", staff, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/user/nobody", staff, ""))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodGet, "/user/username1", "", ""))
+
+	// getUserOrders
+	res, _ = s.call(http.MethodGet, "/user/username1/orders", customer, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "0", res.Header.Get("X-Total-Count"))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/user/username2/orders", customer, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/user/nobody/orders", staff, ""))
+
+	// patchUser
+	mergePatch := []string{"Content-Type", contentTypeMergePatch}
+	res, body = s.call(http.MethodPatch, "/user/username1", customer, `{"phone":"555"}`, mergePatch...)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, body, `"phone":"555"`)
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPatch, "/user/username1", customer, `{"role":"admin"}`, mergePatch...))
+	assert.Equal(t, http.StatusPreconditionFailed, s.status(http.MethodPatch, "/user/username1", customer, `{"phone":"556"}`, append(mergePatch, "If-Match", `"1"`)...))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPatch, "/user/nobody", admin, `{"phone":"556"}`, mergePatch...))
+
+	// updateUser
+	update := `{"username":"username1","firstName":"string1","email":"string1","password":"string1"}`
+	res, _ = s.call(http.MethodPut, "/user/username1", customer, update, "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"3"`, res.Header.Get("ETag"))
+	customer = s.login("username1", "string1")
+	assert.Equal(t, http.StatusPreconditionFailed, s.status(http.MethodPut, "/user/username1", customer, update, "If-Match", `"2"`))
+	// ids and usernames are kept, sessions finding their user by id
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPut, "/user/username1", customer, fixture(t, "user_update.json")))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPut, "/user/username1", customer, `{"id":100,"username":"username1"}`))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPut, "/user/username1", customer, `{"id":1,"username":"username1","role":"admin"}`))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPut, "/user/username1", customer, `{"id":`))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPut, "/user/nobody", admin, fixture(t, "user_update.json")))
+
+	// deleteUser
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodDelete, "/user/username3", customer, ""))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodDelete, "/user/username1?hard=true", customer, ""))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodDelete, "/user/username1?hard=maybe", customer, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/user/username1", customer, ""))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodGet, "/user/username1", customer, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodDelete, "/user/username1", admin, ""))
+
+	// restoreUser
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/user/username1/restore", staff, ""))
+	assert.Equal(t, http.StatusOK, s.status(http.MethodPost, "/user/username1/restore", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/user/username1/restore", admin, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/user/username1?hard=true", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/user/username1/restore", admin, ""))
+}
+
+func TestAuditRoute(t *testing.T) {
+	s := newIntegrationServer(t)
+	defer s.Close()
+
+	res, body := s.call(http.MethodGet, "/admin/audit?entity=pets&entityId=1", integrationAdminKey, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "0", res.Header.Get("X-Total-Count"))
+	assert.JSONEq(t, `[]`, body)
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodGet, "/admin/audit?entity=cats", integrationAdminKey, ""))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/admin/audit", s.login("staff1", "staff"), ""))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodGet, "/admin/audit", "", ""))
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	webhooks := newWebhookStorage()
+	s := newIntegrationServer(t, func(services *Services, storage *memoryStorage) {
+		services.WebhookService = NewWebhookService(log.NewNopLogger(), webhooks)
+	})
+	defer s.Close()
+	admin := integrationAdminKey
+	staff := s.login("staff1", "staff")
+
+	// createWebhook
+	res, body := s.call(http.MethodPost, "/webhooks", admin, `{"url":"http://localhost/hook","events":["pet.created"]}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode, body)
+	var webhook model.Webhook
+	assert.NoError(t, json.Unmarshal([]byte(body), &webhook))
+	assert.Equal(t, int64(1), webhook.ID)
+	assert.NotEmpty(t, webhook.Secret)
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/webhooks", admin, `{"url":"localhost/hook"}`))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/webhooks", admin, `{"url":"http://localhost/hook","events":["pet.eaten"]}`))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodPost, "/webhooks", admin, `{"url":`))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/webhooks", staff, `{"url":"http://localhost/hook"}`))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodPost, "/webhooks", "", `{"url":"http://localhost/hook"}`))
+
+	// findWebhooks, without secrets
+	res, body = s.call(http.MethodGet, "/webhooks", admin, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var found []model.Webhook
+	assert.NoError(t, json.Unmarshal([]byte(body), &found))
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "http://localhost/hook", found[0].URL)
+		assert.Empty(t, found[0].Secret)
+	}
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/webhooks", staff, ""))
+
+	// findDeliveries
+	event := &model.Event{ID: 1, Type: "pet.created"}
+	assert.NoError(t, webhooks.EnqueueDeliveries([]*model.Delivery{
+		model.NewDelivery(&webhook, event, time.Now()),
+		{ID: "1-2", WebhookID: 1, Event: &model.Event{ID: 2, Type: "pet.created"}, Status: model.DeliveryDead, Attempts: 8},
+	}))
+	res, body = s.call(http.MethodGet, "/webhooks/1/deliveries?status=dead", admin, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var deliveries []model.Delivery
+	assert.NoError(t, json.Unmarshal([]byte(body), &deliveries))
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "1-2", deliveries[0].ID)
+	}
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodGet, "/webhooks/1/deliveries?status=lost", admin, ""))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodGet, "/webhooks/one/deliveries", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/webhooks/2/deliveries", admin, ""))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/webhooks/1/deliveries", staff, ""))
+
+	// retryDelivery, only once dead
+	res, body = s.call(http.MethodPost, "/webhooks/1/deliveries/1-2/retry", admin, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode, body)
+	var delivery model.Delivery
+	assert.NoError(t, json.Unmarshal([]byte(body), &delivery))
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.Equal(t, http.StatusConflict, s.status(http.MethodPost, "/webhooks/1/deliveries/1-2/retry", admin, ""))
+	assert.Equal(t, http.StatusConflict, s.status(http.MethodPost, "/webhooks/1/deliveries/1-1/retry", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/webhooks/1/deliveries/1-3/retry", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodPost, "/webhooks/2/deliveries/1-2/retry", admin, ""))
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodPost, "/webhooks/1/deliveries/1-2/retry", staff, ""))
+
+	// deleteWebhook
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodDelete, "/webhooks/1", staff, ""))
+	assert.Equal(t, http.StatusNoContent, s.status(http.MethodDelete, "/webhooks/1", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodDelete, "/webhooks/1", admin, ""))
+	assert.Equal(t, http.StatusBadRequest, s.status(http.MethodDelete, "/webhooks/one", admin, ""))
+	assert.Equal(t, http.StatusNotFound, s.status(http.MethodGet, "/webhooks/1/deliveries", admin, ""))
+	res, body = s.call(http.MethodGet, "/webhooks", admin, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `null`, body)
+}
+
+func TestCacheRoute(t *testing.T) {
+	s := newIntegrationServer(t, func(services *Services, storage *memoryStorage) {
+		cache := model.NewCacheStorage(storage, 10, time.Minute)
+		services.UserService = NewUserService(log.NewNopLogger(), cache, model.DefaultLockoutPolicy, time.Hour)
+		services.Cache = cache
+	})
+	defer s.Close()
+	staff := s.login("staff1", "staff")
+	stats := func() model.CacheStats {
+		res, body := s.call(http.MethodGet, "/admin/cache", integrationAdminKey, "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		var stats model.CacheStats
+		assert.NoError(t, json.Unmarshal([]byte(body), &stats))
+		return stats
+	}
+
+	before := stats()
+	assert.Equal(t, 10, before.Size)
+	assert.Equal(t, http.StatusOK, s.status(http.MethodGet, "/user/staff1", staff, ""))
+	after := stats()
+	assert.True(t, after.Hits > before.Hits)
+	assert.True(t, after.Entries > 0)
+	assert.Equal(t, http.StatusForbidden, s.status(http.MethodGet, "/admin/cache", staff, ""))
+	assert.Equal(t, http.StatusUnauthorized, s.status(http.MethodGet, "/admin/cache", "", ""))
+}
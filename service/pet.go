@@ -7,63 +7,186 @@ import (
 	"github.com/cooljeffrey/petstore/model"
 	"github.com/go-kit/kit/log"
 	"io/ioutil"
+	"net/http"
 	"strings"
 	"time"
 )
 
 type PetService interface {
 	AddPet(ctx context.Context, pet *model.Pet) error
+	AddPets(ctx context.Context, pets []*model.Pet, atomic bool) ([]model.BatchItemResult, error)
 	UpdatePet(ctx context.Context, pet *model.Pet) error
 	FindPetsByStatus(ctx context.Context, statuses []string) ([]*model.Pet, error)
+	StreamPetsByStatus(ctx context.Context, statuses []string, fn func(pet *model.Pet) error) error
+	SearchPets(ctx context.Context, search model.PetSearch) ([]*model.PetSearchResult, int64, error)
 	FindPetByID(ctx context.Context, id int64) (*model.Pet, error)
-	UpdatePetByID(ctx context.Context, id int64, name string, status string) error
+	FindPetsByIDs(ctx context.Context, ids []int64) ([]*model.Pet, error)
+	UpdatePetByID(ctx context.Context, id int64, version int64, name string, status string) error
+	PatchPet(ctx context.Context, id int64, version int64, update *model.Update) (*model.Pet, error)
 	AddImageUrlForPetByID(ctx context.Context, id int64, filename string, file []byte) error
-	DeletePetByID(ctx context.Context, id int64) error
+	DeletePetByID(ctx context.Context, id int64, hard bool) error
+	RestorePetByID(ctx context.Context, id int64) (*model.Pet, error)
 }
 
 type petService struct {
-	logger   log.Logger
-	storage  model.Storage
-	baseUri  string
-	filePath string
+	logger    log.Logger
+	storage   model.Storage
+	publisher Publisher
+	baseUri   string
+	filePath  string
 }
 
-func NewPetService(logger log.Logger, storage model.Storage, baseUri, filePath string) PetService {
+// NewPetService returns the pet service, publishing events of committed changes to publisher unless it is nil.
+func NewPetService(logger log.Logger, storage model.Storage, publisher Publisher, baseUri, filePath string) PetService {
 	return &petService{
-		logger:   logger,
-		storage:  storage,
-		baseUri:  baseUri,
-		filePath: filePath,
+		logger:    logger,
+		storage:   storage,
+		publisher: publisher,
+		baseUri:   baseUri,
+		filePath:  filePath,
 	}
 }
 
 func (s petService) AddPet(ctx context.Context, pet *model.Pet) error {
-	return s.storage.CreatePet(pet)
+	if err := pet.Validate(); err != nil {
+		return err
+	}
+	_, err := changePet(ctx, s.storage, s.publisher, pet.ID, func(tx model.Storage) error {
+		return tx.CreatePet(pet)
+	})
+	return err
+}
+
+// AddPets validates and creates pets, all or none of them when atomic and each on its own otherwise.
+// The results tell what happened to each pet, a *model.BatchError is returned when an atomic batch is refused.
+func (s petService) AddPets(ctx context.Context, pets []*model.Pet, atomic bool) ([]model.BatchItemResult, error) {
+	results := make([]model.BatchItemResult, len(pets))
+	batch := &model.BatchError{}
+	for i, pet := range pets {
+		results[i] = model.BatchItemResult{Index: i, Status: model.BatchItemCreated}
+		if pet == nil {
+			batch.Add(i, 0, "pet is null")
+			continue
+		}
+		results[i].ID = pet.ID
+		if err := pet.Validate(); err != nil {
+			batch.Add(i, pet.ID, err.Error())
+		}
+	}
+
+	if !atomic {
+		failed := map[int]bool{}
+		for _, item := range batch.Items {
+			failed[item.Index] = true
+		}
+		for i, pet := range pets {
+			if failed[i] {
+				continue
+			}
+			_, err := changePet(ctx, s.storage, s.publisher, pet.ID, func(tx model.Storage) error {
+				return tx.CreatePet(pet)
+			})
+			if err != nil {
+				batch.Add(i, pet.ID, err.Error())
+			}
+		}
+		return applyBatchError(results, batch, ""), nil
+	}
+
+	err := batch.Err()
+	if err == nil {
+		err = inTransaction(ctx, s.storage, s.publisher, func(tx model.Storage, emit emitter) error {
+			if err := tx.CreateManyPets(pets); err != nil {
+				return err
+			}
+			for _, pet := range pets {
+				if err := emit(model.NewPetEvent(model.EventPetCreated, pet)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		return results, nil
+	}
+	if e, ok := err.(*model.BatchError); ok {
+		return applyBatchError(results, e, model.BatchItemNotCreated), e
+	}
+	return nil, err
+}
+
+// applyBatchError marks failed items of results, and the others with status unless it is empty.
+func applyBatchError(results []model.BatchItemResult, batch *model.BatchError, status string) []model.BatchItemResult {
+	if status != "" && len(batch.Items) > 0 {
+		for i := range results {
+			results[i].Status = status
+		}
+	}
+	for _, item := range batch.Items {
+		results[item.Index].Status = model.BatchItemFailed
+		results[item.Index].Error = item.Message
+	}
+	return results
 }
 
 func (s petService) UpdatePet(ctx context.Context, pet *model.Pet) error {
-	return s.storage.UpdatePetByID(pet)
+	if err := pet.Validate(); err != nil {
+		return err
+	}
+	_, err := changePet(ctx, s.storage, s.publisher, pet.ID, func(tx model.Storage) error {
+		return tx.UpdatePetByID(pet)
+	})
+	return err
 }
 
 func (s petService) FindPetsByStatus(ctx context.Context, statuses []string) ([]*model.Pet, error) {
 	return s.storage.FindPetsByStatus(statuses)
 }
 
+func (s petService) StreamPetsByStatus(ctx context.Context, statuses []string, fn func(pet *model.Pet) error) error {
+	return s.storage.StreamPetsByStatus(statuses, fn)
+}
+
+func (s petService) SearchPets(ctx context.Context, search model.PetSearch) ([]*model.PetSearchResult, int64, error) {
+	return s.storage.SearchPets(search)
+}
+
 func (s petService) FindPetByID(ctx context.Context, id int64) (*model.Pet, error) {
 	return s.storage.RetrievePetByID(id)
 }
 
-func (s petService) UpdatePetByID(ctx context.Context, id int64, name, status string) error {
-	if name == "" && status != "" {
-		return s.storage.UpdatePetStatusByID(id, status)
-	}
-	if name != "" && status == "" {
-		return s.storage.UpdatePetNameByID(id, name)
+// FindPetsByIDs finds pets of ids, leaving out those not found.
+func (s petService) FindPetsByIDs(ctx context.Context, ids []int64) ([]*model.Pet, error) {
+	return s.storage.RetrievePetsByIDs(ids)
+}
+
+// UpdatePetByID updates name and status of a pet, if it is at version unless version is 0.
+func (s petService) UpdatePetByID(ctx context.Context, id int64, version int64, name, status string) error {
+	var update func(tx model.Storage) error
+	switch {
+	case name == "" && status != "":
+		update = func(tx model.Storage) error { return tx.UpdatePetStatusByID(id, version, status) }
+	case name != "" && status == "":
+		update = func(tx model.Storage) error { return tx.UpdatePetNameByID(id, version, name) }
+	case name != "" && status != "":
+		update = func(tx model.Storage) error { return tx.UpdatePetNameAndStatusByID(id, version, name, status) }
+	default:
+		return errors.New("both name and status are empty")
 	}
-	if name != "" && status != "" {
-		return s.storage.UpdatePetNameAndStatusByID(id, name, status)
+	_, err := changePet(ctx, s.storage, s.publisher, id, update)
+	return err
+}
+
+// PatchPet applies a partial update to a pet, if it is at version unless version is 0.
+func (s petService) PatchPet(ctx context.Context, id int64, version int64, update *model.Update) (*model.Pet, error) {
+	if err := model.ValidatePetUpdate(update); err != nil {
+		return nil, model.NewErrResponse(http.StatusBadRequest, "error", err.Error())
 	}
-	return errors.New("both name and status are empty")
+	return changePet(ctx, s.storage, s.publisher, id, func(tx model.Storage) error {
+		_, err := tx.PatchPetByID(id, version, update)
+		return err
+	})
 }
 
 func (s petService) AddImageUrlForPetByID(ctx context.Context, id int64, filename string, file []byte) error {
@@ -76,10 +199,32 @@ func (s petService) AddImageUrlForPetByID(ctx context.Context, id int64, filenam
 		return err
 	}
 	url := fmt.Sprintf("%s/%s", s.baseUri, newfilename)
-	_, err = s.storage.AddImageUrlByPetID(id, url)
+	_, err = changePet(ctx, s.storage, s.publisher, id, func(tx model.Storage) error {
+		_, err := tx.AddImageUrlByPetID(id, url)
+		return err
+	})
 	return err
 }
 
-func (s petService) DeletePetByID(ctx context.Context, id int64) error {
-	return s.storage.DeletePetByID(id)
+func (s petService) DeletePetByID(ctx context.Context, id int64, hard bool) error {
+	_, err := changePet(ctx, s.storage, s.publisher, id, func(tx model.Storage) error {
+		return tx.DeletePetByID(id, hard)
+	})
+	return err
+}
+
+func (s petService) RestorePetByID(ctx context.Context, id int64) (*model.Pet, error) {
+	var restored *model.Pet
+	err := inTransaction(ctx, s.storage, s.publisher, func(tx model.Storage, emit emitter) error {
+		pet, err := tx.RestorePetByID(id)
+		if err != nil {
+			return err
+		}
+		restored = pet
+		return emit(model.NewPetEvent(model.EventPetRestored, pet))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
 }
@@ -1,3 +1,23 @@
 package service
 
 // This is to test pet service
+
+import (
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApplyBatchError(t *testing.T) {
+	results := []model.BatchItemResult{
+		{Index: 0, ID: 1, Status: model.BatchItemCreated},
+		{Index: 1, ID: 2, Status: model.BatchItemCreated},
+	}
+	batch := &model.BatchError{}
+	batch.Add(1, 2, "name is required")
+
+	results = applyBatchError(results, batch, model.BatchItemNotCreated)
+	assert.Equal(t, model.BatchItemNotCreated, results[0].Status)
+	assert.Equal(t, model.BatchItemFailed, results[1].Status)
+	assert.Equal(t, "name is required", results[1].Error)
+}
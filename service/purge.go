@@ -0,0 +1,58 @@
+package service
+
+import (
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"time"
+)
+
+// Purger deletes for good pets, users and orders deleted longer than the retention period ago.
+type Purger struct {
+	logger    log.Logger
+	storage   model.Storage
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewPurger(logger log.Logger, storage model.Storage, retention time.Duration) *Purger {
+	return &Purger{
+		logger:    logger,
+		storage:   storage,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Purge makes one pass over the collections, and returns how many documents were purged.
+func (p *Purger) Purge() (int64, error) {
+	before := p.now().UTC().Add(-p.retention)
+	var total int64
+	for _, collection := range []string{model.CollectionOrders, model.CollectionPets, model.CollectionUsers} {
+		n, err := p.storage.PurgeDeleted(collection, before)
+		if err != nil {
+			return total, err
+		}
+		if n > 0 {
+			_ = level.Info(p.logger).Log("msg", "purged deleted documents", "collection", collection, "count", n)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Run purges every interval until stop is closed.
+func (p *Purger) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Purge(); err != nil {
+			_ = level.Error(p.logger).Log("msg", "purge deleted documents", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
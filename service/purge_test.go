@@ -0,0 +1,36 @@
+package service
+
+// This is to test purging deleted documents
+
+import (
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// purgeStorage records purges, other Storage methods are not implemented.
+type purgeStorage struct {
+	model.Storage
+	before map[string]time.Time
+}
+
+func (s *purgeStorage) PurgeDeleted(collection string, before time.Time) (int64, error) {
+	s.before[collection] = before
+	return 1, nil
+}
+
+func TestPurger(t *testing.T) {
+	storage := &purgeStorage{before: map[string]time.Time{}}
+	p := NewPurger(log.NewNopLogger(), storage, 24*time.Hour)
+	now := time.Date(2019, 6, 2, 0, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	n, err := p.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	for _, collection := range []string{model.CollectionPets, model.CollectionUsers, model.CollectionOrders} {
+		assert.Equal(t, now.Add(-24*time.Hour), storage.before[collection])
+	}
+}
@@ -4,27 +4,111 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/cooljeffrey/petstore/model"
 	"github.com/go-chi/chi"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Services struct {
 	UserService  UserService
 	PetService   PetService
 	StoreService StoreService
+	AuditService AuditService
+	// Webhook subscriptions, not served when nil
+	WebhookService WebhookService
+	// Events published as changes are committed, /store/events is not served when nil
+	Events *Broker
+	// Storage cache, /admin/cache is not served when nil
+	Cache *model.CacheStorage
 }
 
-func SetupRoutes(services *Services, logger log.Logger) *chi.Mux {
+type Options struct {
+	// Key in api_key header authenticating the caller as admin, disabled when empty
+	AdminAPIKey string
+	// Reject GET /user/login so that credentials never travel in query string
+	DisableQueryLogin bool
+	// Limits of GraphQL queries, DefaultGraphQLMaxDepth and DefaultGraphQLMaxComplexity when 0
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+	// Calls per hour a user is told to stay under by X-Rate-Limit, DefaultRateLimit when 0
+	RateLimit int
+	// Images are served from folder PublicPath under the path of PublicURI, ./public under /images when empty
+	PublicURI  string
+	PublicPath string
+}
+
+// DefaultRateLimit is told to users by X-Rate-Limit unless Options say otherwise.
+const DefaultRateLimit = 100
+
+func SetupRoutes(services *Services, options Options, logger log.Logger) *chi.Mux {
 	r := chi.NewRouter()
+
+	schema, err := newGraphQLSchema(services)
+	if err != nil {
+		panic(err)
+	}
+	limits := graphqlLimits{MaxDepth: options.GraphQLMaxDepth, MaxComplexity: options.GraphQLMaxComplexity}
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultGraphQLMaxDepth
+	}
+	if limits.MaxComplexity <= 0 {
+		limits.MaxComplexity = DefaultGraphQLMaxComplexity
+	}
+
+	rateLimit := options.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = DefaultRateLimit
+	}
+
+	login := func(w http.ResponseWriter, r *http.Request, username, password string) {
+		session, err := services.UserService.Login(r.Context(), username, password)
+		if e, ok := err.(*LoginThrottledError); ok {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64((e.RetryAfter+time.Second-1)/time.Second), 10))
+			encodeError(r.Context(), model.NewErrResponse(http.StatusTooManyRequests, "error", e.Error()), w)
+			return
+		}
+		if err != nil {
+			_ = level.Info(logger).Log("msg", "login failed", "username", username, "err", err)
+			encodeError(r.Context(), model.NewErrResponse(http.StatusUnauthorized, "error", ErrInvalidCredentials.Error()), w)
+			return
+		}
+		w.Header().Set("X-Rate-Limit", strconv.Itoa(rateLimit))
+		w.Header().Set("X-Expires-After", session.ExpiresAt.Format(time.RFC3339))
+
+		err = encodeResponse(r.Context(), w, session.Token)
+		if err != nil {
+			_ = level.Error(logger).Log("err", err, "username", username)
+		}
+	}
+
+	findOrders := func(w http.ResponseWriter, r *http.Request, filter model.OrderFilter) {
+		orders, total, err := services.StoreService.FindOrders(r.Context(), filter)
+		if err != nil {
+			encodeError(r.Context(), model.NewErrResponse(http.StatusInternalServerError, "error", err.Error()), w)
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+		err = encodeResponse(r.Context(), w, orders)
+		if err != nil {
+			_ = level.Error(logger).Log("err", err, "orders", len(orders))
+		}
+	}
+
 	r.Route("/v2", func(r chi.Router) {
+		r.Use(requestID)
+		r.Use(authenticate(services.UserService, options.AdminAPIKey))
+
 		r.Route("/pet", func(r chi.Router) {
 			_ = logger.Log("path", "/pet")
 			r.Get("/findByStatus", func(w http.ResponseWriter, r *http.Request) {
@@ -35,6 +119,7 @@ func SetupRoutes(services *Services, logger log.Logger) *chi.Mux {
 					pets, err := services.PetService.FindPetsByStatus(r.Context(), params)
 					if err != nil {
 						w.WriteHeader(http.StatusBadRequest)
+						return
 					}
 					w.WriteHeader(http.StatusOK)
 					_ = encodeResponse(r.Context(), w, pets)
@@ -43,78 +128,224 @@ func SetupRoutes(services *Services, logger log.Logger) *chi.Mux {
 				}
 			})
 
-			r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			r.Get("/search", func(w http.ResponseWriter, r *http.Request) {
+				search, err := parsePetSearch(r.URL.Query())
+				if err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+					return
+				}
+				results, total, err := services.PetService.SearchPets(r.Context(), search)
+				if err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusInternalServerError, "error", err.Error()), w)
+					return
+				}
+				w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+				err = encodeResponse(r.Context(), w, results)
+				if err != nil {
+					_ = level.Error(logger).Log("err", err, "results", len(results))
+				}
+			})
+
+			r.With(requirePermission(PermissionManagePets)).Post("/", func(w http.ResponseWriter, r *http.Request) {
 				_ = logger.Log("path", "/pet", "method", "post")
 				var pet *model.Pet
-				if e := json.NewDecoder(r.Body).Decode(&pet); e != nil {
+				if e := json.NewDecoder(r.Body).Decode(&pet); e != nil || pet == nil {
 					w.WriteHeader(405)
+					return
 				}
 				if err := services.PetService.AddPet(r.Context(), pet); err != nil {
 					w.WriteHeader(405)
 				}
 			})
-			r.Put("/", func(w http.ResponseWriter, r *http.Request) {
+			r.With(requirePermission(PermissionManagePets)).Post("/batch", func(w http.ResponseWriter, r *http.Request) {
+				_ = logger.Log("path", "/pet/batch", "method", "post")
+				mode := r.URL.Query().Get("mode")
+				if mode != "" && mode != "atomic" && mode != "partial" {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", "mode must be atomic or partial"), w)
+					return
+				}
+				pets, err := decodePets(r)
+				if err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+					return
+				}
+				results, err := services.PetService.AddPets(r.Context(), pets, mode != "partial")
+				if _, ok := err.(*model.BatchError); ok {
+					w.Header().Set("Content-Type", "application/json; charset=utf-8")
+					w.WriteHeader(http.StatusBadRequest)
+				} else if err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusInternalServerError, "error", err.Error()), w)
+					return
+				}
+				err = encodeResponse(r.Context(), w, results)
+				if err != nil {
+					_ = level.Error(logger).Log("err", err, "pets", len(pets))
+				}
+			})
+			r.With(requirePermission(PermissionManagePets)).Put("/", func(w http.ResponseWriter, r *http.Request) {
 				_ = logger.Log("path", "/pet", "method", "put")
 				var pet *model.Pet
-				if e := json.NewDecoder(r.Body).Decode(&pet); e != nil {
+				if e := json.NewDecoder(r.Body).Decode(&pet); e != nil || pet == nil {
 					w.WriteHeader(405)
+					return
+				}
+				version, err := ifMatch(r, func() (int64, error) {
+					p, err := services.PetService.FindPetByID(r.Context(), pet.ID)
+					if err != nil {
+						return 0, err
+					}
+					return p.Version, nil
+				})
+				if err != nil {
+					encodeError(r.Context(), errPreconditionFailed, w)
+					return
 				}
-				if err := services.PetService.UpdatePet(r.Context(), pet); err != nil {
+				pet.Version = version
+				err = services.PetService.UpdatePet(r.Context(), pet)
+				if err == model.ErrVersionMismatch {
+					encodeError(r.Context(), errPreconditionFailed, w)
+					return
+				}
+				if err != nil {
 					w.WriteHeader(http.StatusNotFound)
+					return
 				}
+				w.Header().Set("ETag", etag(pet.Version))
 			})
 
 			r.Route("/{petId}", func(r chi.Router) {
 				_ = logger.Log("path", "/pet/{petId}")
-				r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+				r.With(requirePermission(PermissionManagePets)).Post("/", func(w http.ResponseWriter, r *http.Request) {
 					_ = logger.Log("path", "/pet/{petId}", "method", "post")
 					id, err := strconv.ParseInt(chi.URLParam(r, "petId"), 10, 64)
 					if err != nil {
 						w.WriteHeader(http.StatusMethodNotAllowed)
+						return
 					}
 					err = r.ParseForm()
 					if err != nil {
 						w.WriteHeader(http.StatusMethodNotAllowed)
+						return
+					}
+					version, err := ifMatch(r, func() (int64, error) {
+						p, err := services.PetService.FindPetByID(r.Context(), id)
+						if err != nil {
+							return 0, err
+						}
+						return p.Version, nil
+					})
+					if err != nil {
+						encodeError(r.Context(), errPreconditionFailed, w)
+						return
+					}
+					err = services.PetService.UpdatePetByID(r.Context(), id, version, r.PostForm.Get("name"), r.PostForm.Get("status"))
+					if err == model.ErrVersionMismatch {
+						encodeError(r.Context(), errPreconditionFailed, w)
+						return
 					}
-					err = services.PetService.UpdatePetByID(r.Context(), id, r.PostForm["name"][0], r.PostForm["status"][0])
 					if err != nil {
 						w.WriteHeader(http.StatusMethodNotAllowed)
+						return
 					}
 					w.WriteHeader(http.StatusOK)
 				})
+				r.With(requirePermission(PermissionManagePets)).Patch("/", func(w http.ResponseWriter, r *http.Request) {
+					id, err := strconv.ParseInt(chi.URLParam(r, "petId"), 10, 64)
+					if err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					update, err := decodePatch(r, &model.Pet{})
+					if err != nil {
+						encodeError(r.Context(), err, w)
+						return
+					}
+					version, err := ifMatch(r, func() (int64, error) {
+						p, err := services.PetService.FindPetByID(r.Context(), id)
+						if err != nil {
+							return 0, err
+						}
+						return p.Version, nil
+					})
+					if err != nil {
+						encodeError(r.Context(), errPreconditionFailed, w)
+						return
+					}
+					pet, err := services.PetService.PatchPet(r.Context(), id, version, update)
+					if err != nil {
+						encodePatchError(r.Context(), err, w)
+						return
+					}
+					w.Header().Set("ETag", etag(pet.Version))
+					err = encodeResponse(r.Context(), w, pet)
+					if err != nil {
+						_ = level.Error(logger).Log("err", err, "pet", pet)
+					}
+				})
 				r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 					id, err := strconv.ParseInt(chi.URLParam(r, "petId"), 10, 64)
 					if err != nil {
 						w.WriteHeader(http.StatusBadRequest)
+						return
 					}
 					pet, err := services.PetService.FindPetByID(r.Context(), id)
 					if err != nil || pet == nil {
 						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					if notModified(w, r, pet.Version) {
+						return
 					}
 					err = encodeResponse(r.Context(), w, pet)
 					if err != nil {
 						_ = level.Error(logger).Log("err", err, "resp", pet)
 					}
 				})
-				r.Delete("/", func(w http.ResponseWriter, r *http.Request) {
+				r.With(requirePermission(PermissionManagePets)).Delete("/", func(w http.ResponseWriter, r *http.Request) {
 					id, err := strconv.ParseInt(chi.URLParam(r, "petId"), 10, 64)
 					if err != nil {
 						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					hard, err := hardDelete(r)
+					if err != nil {
+						encodeError(r.Context(), err, w)
+						return
 					}
-					err = services.PetService.DeletePetByID(r.Context(), id)
+					err = services.PetService.DeletePetByID(r.Context(), id, hard)
 					if err != nil {
-						_ = level.Error(logger).Log("err", err, "petId", id)
+						encodeServiceError(r.Context(), err, w)
+						return
 					}
 					w.WriteHeader(http.StatusNoContent)
 				})
-				r.Post("/uploadImage", func(w http.ResponseWriter, r *http.Request) {
+				r.With(requirePermission(PermissionManageDeleted)).Post("/restore", func(w http.ResponseWriter, r *http.Request) {
+					id, err := strconv.ParseInt(chi.URLParam(r, "petId"), 10, 64)
+					if err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					pet, err := services.PetService.RestorePetByID(r.Context(), id)
+					if err != nil {
+						encodeServiceError(r.Context(), err, w)
+						return
+					}
+					w.Header().Set("ETag", etag(pet.Version))
+					err = encodeResponse(r.Context(), w, pet)
+					if err != nil {
+						_ = level.Error(logger).Log("err", err, "pet", pet)
+					}
+				})
+				r.With(requirePermission(PermissionManagePets)).Post("/uploadImage", func(w http.ResponseWriter, r *http.Request) {
 					idstr := chi.URLParam(r, "petId")
 					if idstr == "" {
 						w.WriteHeader(http.StatusNotFound)
+						return
 					}
 					id, err := strconv.ParseInt(idstr, 10, 64)
 					if err != nil {
 						w.WriteHeader(http.StatusNotFound)
+						return
 					}
 					var buf bytes.Buffer
 					file, header, err := r.FormFile("file")
@@ -136,39 +367,188 @@ func SetupRoutes(services *Services, logger log.Logger) *chi.Mux {
 		})
 
 		r.Route("/store", func(r chi.Router) {
-			r.Get("/inventory", func(w http.ResponseWriter, r *http.Request) {
+			r.With(requirePermission(PermissionViewInventory)).Get("/inventory", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("report") == "value" {
+					value, err := services.StoreService.GetStockValue(r.Context())
+					if err != nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusInternalServerError, "error", err.Error()), w)
+						return
+					}
+					err = encodeResponse(r.Context(), w, value)
+					if err != nil {
+						_ = level.Error(logger).Log("err", err, "value", value)
+					}
+					return
+				}
 				inv, err := services.StoreService.GetInventoriesByStatus(r.Context())
 				if err != nil {
 					w.WriteHeader(http.StatusInternalServerError)
+					return
 				}
 				err = encodeResponse(r.Context(), w, inv)
 				if err != nil {
 					_ = level.Error(logger).Log("err", err, "inventory", inv)
 				}
 			})
-			r.Post("/order", func(w http.ResponseWriter, r *http.Request) {
+			r.With(requirePermission(PermissionPlaceOrder)).Post("/order", func(w http.ResponseWriter, r *http.Request) {
 				var order *model.Order
-				if e := json.NewDecoder(r.Body).Decode(&order); e != nil {
+				if e := json.NewDecoder(r.Body).Decode(&order); e != nil || order == nil {
 					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				// staff may place orders on behalf of a user, others always order for themselves
+				p := PrincipalFromContext(r.Context())
+				if order.UserID == 0 || !p.Can(PermissionManageOrders) {
+					order.UserID = p.UserID
 				}
 				o, err := services.StoreService.PlaceOrder(r.Context(), order)
 				if err != nil {
-					w.WriteHeader(http.StatusBadRequest)
+					encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+					return
 				}
 				err = encodeResponse(r.Context(), w, o)
 				if err != nil {
 					_ = level.Error(logger).Log("err", err, "order", o)
 				}
-				w.WriteHeader(http.StatusCreated)
 			})
-			r.Get("/order/{orderId}", func(w http.ResponseWriter, r *http.Request) {
+			if services.Events != nil {
+				r.With(requirePermission(PermissionViewInventory)).Get("/events", func(w http.ResponseWriter, r *http.Request) {
+					flusher, ok := w.(http.Flusher)
+					if !ok {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusInternalServerError, "error", "streaming unsupported"), w)
+						return
+					}
+					id := r.Header.Get("Last-Event-ID")
+					if id == "" {
+						id = r.URL.Query().Get("lastEventId")
+					}
+					var last int64
+					if id != "" {
+						var err error
+						if last, err = strconv.ParseInt(id, 10, 64); err != nil || last < 0 {
+							encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", "invalid Last-Event-ID"), w)
+							return
+						}
+					}
+					// subscribe before replaying, so that no event falls in between
+					events, unsubscribe := services.Events.Subscribe()
+					defer unsubscribe()
+
+					w.Header().Set("Content-Type", "text/event-stream")
+					w.Header().Set("Cache-Control", "no-cache")
+					w.Header().Set("X-Accel-Buffering", "no")
+					w.WriteHeader(http.StatusOK)
+
+					// push sends pet status changes, each event moving the stream past it
+					push := func(e *model.Event) (bool, error) {
+						if e.ID <= last {
+							return false, nil
+						}
+						last = e.ID
+						if e.Type != model.EventPetStatusChanged {
+							return true, nil
+						}
+						return true, writeSSE(w, e.ID, e.Type, e)
+					}
+					// inventory sends counts as of the last event sent
+					inventory := func() error {
+						counts, err := services.StoreService.GetInventoriesByStatus(r.Context())
+						if err != nil {
+							return err
+						}
+						if err = writeSSE(w, last, "inventory", counts); err != nil {
+							return err
+						}
+						flusher.Flush()
+						return nil
+					}
+
+					for replay := last > 0; replay; {
+						missed, err := services.StoreService.FindEventsAfter(r.Context(), last, 100)
+						if err != nil {
+							_ = level.Error(logger).Log("msg", "replay events", "after", last, "err", err)
+							return
+						}
+						for _, e := range missed {
+							if _, err = push(e); err != nil {
+								return
+							}
+						}
+						replay = len(missed) == 100
+					}
+					if err := inventory(); err != nil {
+						return
+					}
+
+					heartbeat := time.NewTicker(15 * time.Second)
+					defer heartbeat.Stop()
+					for {
+						select {
+						case <-r.Context().Done():
+							return
+						case <-heartbeat.C:
+							if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+								return
+							}
+							flusher.Flush()
+						case e, ok := <-events:
+							// closed when not keeping up, the client reconnects with Last-Event-ID
+							if !ok {
+								return
+							}
+							changed, err := push(e)
+							for more := true; more && err == nil; {
+								select {
+								case e, ok := <-events:
+									if !ok {
+										return
+									}
+									var c bool
+									c, err = push(e)
+									changed = changed || c
+								default:
+									more = false
+								}
+							}
+							if err == nil && changed {
+								err = inventory()
+							}
+							if err != nil {
+								return
+							}
+						}
+					}
+				})
+			}
+			r.With(requirePermission(PermissionManageOrders)).Get("/order", func(w http.ResponseWriter, r *http.Request) {
+				filter, err := parseOrderFilter(r.URL.Query())
+				if err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+					return
+				}
+				if username := r.URL.Query().Get("username"); username != "" {
+					user, err := services.UserService.GetUserByUsername(r.Context(), username)
+					if err != nil || user == nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusNotFound, "error", "user not found"), w)
+						return
+					}
+					filter.UserID = user.ID
+				}
+				findOrders(w, r, filter)
+			})
+			r.With(requirePermission(PermissionPlaceOrder)).Get("/order/{orderId}", func(w http.ResponseWriter, r *http.Request) {
 				id, err := strconv.ParseInt(chi.URLParam(r, "orderId"), 10, 64)
 				if err != nil {
 					w.WriteHeader(http.StatusBadRequest)
+					return
 				}
 				order, err := services.StoreService.FindOrderByID(r.Context(), id)
-				if err != nil || order == nil {
+				if err != nil || order == nil || !canAccessOrder(r.Context(), order) {
 					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if notModified(w, r, order.Version) {
+					return
 				}
 				err = encodeResponse(r.Context(), w, order)
 				if err != nil {
@@ -176,56 +556,130 @@ func SetupRoutes(services *Services, logger log.Logger) *chi.Mux {
 				}
 			})
 
-			r.Delete("/order/{orderId}", func(w http.ResponseWriter, r *http.Request) {
+			r.With(requirePermission(PermissionManageOrders)).Post("/order/{orderId}", func(w http.ResponseWriter, r *http.Request) {
 				id, err := strconv.ParseInt(chi.URLParam(r, "orderId"), 10, 64)
 				if err != nil {
 					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if err = r.ParseForm(); err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+					return
+				}
+				version, err := ifMatch(r, func() (int64, error) {
+					o, err := services.StoreService.FindOrderByID(r.Context(), id)
+					if err != nil {
+						return 0, err
+					}
+					return o.Version, nil
+				})
+				if err != nil {
+					encodeError(r.Context(), err, w)
+					return
 				}
-				err = services.StoreService.DeleteOrderByID(r.Context(), id)
+				order, err := services.StoreService.UpdateOrderStatus(r.Context(), id, version, r.PostForm.Get("status"))
 				if err != nil {
-					w.WriteHeader(http.StatusNotFound)
+					encodePatchError(r.Context(), err, w)
+					return
+				}
+				w.Header().Set("ETag", etag(order.Version))
+				err = encodeResponse(r.Context(), w, order)
+				if err != nil {
+					_ = level.Error(logger).Log("err", err, "order", order)
+				}
+			})
+			r.With(requirePermission(PermissionPlaceOrder)).Delete("/order/{orderId}", func(w http.ResponseWriter, r *http.Request) {
+				id, err := strconv.ParseInt(chi.URLParam(r, "orderId"), 10, 64)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				hard, err := hardDelete(r)
+				if err != nil {
+					encodeError(r.Context(), err, w)
+					return
+				}
+				// orders deleted already can still be deleted for good
+				if !hard {
+					order, err := services.StoreService.FindOrderByID(r.Context(), id)
+					if err != nil || order == nil || !canAccessOrder(r.Context(), order) {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+				}
+				err = services.StoreService.DeleteOrderByID(r.Context(), id, hard)
+				if err != nil {
+					encodeServiceError(r.Context(), err, w)
+					return
 				}
 				w.WriteHeader(http.StatusNoContent)
 			})
+			r.With(requirePermission(PermissionManageDeleted)).Post("/order/{orderId}/restore", func(w http.ResponseWriter, r *http.Request) {
+				id, err := strconv.ParseInt(chi.URLParam(r, "orderId"), 10, 64)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				order, err := services.StoreService.RestoreOrderByID(r.Context(), id)
+				if err != nil {
+					encodeServiceError(r.Context(), err, w)
+					return
+				}
+				w.Header().Set("ETag", etag(order.Version))
+				err = encodeResponse(r.Context(), w, order)
+				if err != nil {
+					_ = level.Error(logger).Log("err", err, "order", order)
+				}
+			})
 		})
 
 		r.Route("/user", func(r chi.Router) {
 			r.Post("/", func(w http.ResponseWriter, r *http.Request) {
 				var user *model.User
-				if e := json.NewDecoder(r.Body).Decode(&user); e != nil {
+				if e := json.NewDecoder(r.Body).Decode(&user); e != nil || user == nil {
 					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if e := authorizeRole(r.Context(), user, model.RoleCustomer); e != nil {
+					encodeError(r.Context(), e, w)
+					return
 				}
 				err := services.UserService.CreateUser(r.Context(), user)
 				if err != nil {
 					w.WriteHeader(http.StatusBadRequest)
+					return
 				}
 				err = encodeResponse(r.Context(), w, user)
 				if err != nil {
 					_ = level.Error(logger).Log("err", err, "user", user)
 				}
 			})
-			r.Post("/createWithArray", func(w http.ResponseWriter, r *http.Request) {
+			r.With(requirePermission(PermissionManageUsers)).Post("/createWithArray", func(w http.ResponseWriter, r *http.Request) {
 				var users []*model.User
 				if e := json.NewDecoder(r.Body).Decode(&users); e != nil {
 					w.WriteHeader(http.StatusBadRequest)
+					return
 				}
 				err := services.UserService.CreateUsersWithArray(r.Context(), users)
 				if err != nil {
-					w.WriteHeader(http.StatusBadRequest)
+					encodeBatchError(r.Context(), err, w)
+					return
 				}
 				err = encodeResponse(r.Context(), w, users)
 				if err != nil {
 					_ = level.Error(logger).Log("err", err, "users", users)
 				}
 			})
-			r.Post("/createWithList", func(w http.ResponseWriter, r *http.Request) {
+			r.With(requirePermission(PermissionManageUsers)).Post("/createWithList", func(w http.ResponseWriter, r *http.Request) {
 				var users []*model.User
 				if e := json.NewDecoder(r.Body).Decode(&users); e != nil {
 					w.WriteHeader(http.StatusBadRequest)
+					return
 				}
-				err := services.UserService.CreateUsersWithArray(r.Context(), users)
+				err := services.UserService.CreateUsersWithList(r.Context(), users)
 				if err != nil {
-					w.WriteHeader(http.StatusBadRequest)
+					encodeBatchError(r.Context(), err, w)
+					return
 				}
 				err = encodeResponse(r.Context(), w, users)
 				if err != nil {
@@ -234,76 +688,326 @@ func SetupRoutes(services *Services, logger log.Logger) *chi.Mux {
 			})
 
 			r.Get("/login", func(w http.ResponseWriter, r *http.Request) {
-				username := r.URL.Query().Get("username")
-				password := r.URL.Query().Get("password")
-				err := services.UserService.Login(r.Context(), username, password)
-				if err != nil {
-					w.WriteHeader(http.StatusUnauthorized)
+				if options.DisableQueryLogin {
+					w.Header().Set("Allow", http.MethodPost)
+					encodeError(r.Context(), model.NewErrResponse(
+						http.StatusMethodNotAllowed, "error", "credentials must be posted in request body"), w)
+					return
 				}
-				// TODO the following are hardcoded
-				w.Header().Set("X-Rate-Limit", "100")
-				w.Header().Set("X-Expires-After", "3600")
-
-				w.WriteHeader(http.StatusOK)
+				login(w, r, r.URL.Query().Get("username"), r.URL.Query().Get("password"))
+			})
+			r.Post("/login", func(w http.ResponseWriter, r *http.Request) {
+				var credentials struct {
+					Username string `json:"username"`
+					Password string `json:"password"`
+				}
+				if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+					if e := json.NewDecoder(r.Body).Decode(&credentials); e != nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", e.Error()), w)
+						return
+					}
+				} else {
+					credentials.Username = r.PostFormValue("username")
+					credentials.Password = r.PostFormValue("password")
+				}
+				login(w, r, credentials.Username, credentials.Password)
 			})
 
 			r.Get("/logout", func(w http.ResponseWriter, r *http.Request) {
 				err := services.UserService.Logout(r.Context())
 				if err != nil {
 					w.WriteHeader(http.StatusUnauthorized)
+					return
 				}
 				w.WriteHeader(http.StatusOK)
 			})
 
 			r.Route("/{username}", func(r chi.Router) {
-				r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				r.With(requireSelfOr(PermissionViewUsers)).Get("/", func(w http.ResponseWriter, r *http.Request) {
 					username := chi.URLParam(r, "username")
 					if username == "" {
 						w.WriteHeader(http.StatusBadRequest)
+						return
 					}
 					user, err := services.UserService.GetUserByUsername(r.Context(), username)
 					if err != nil || user == nil {
 						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					if notModified(w, r, user.Version) {
+						return
 					}
 					err = encodeResponse(r.Context(), w, user)
 					if err != nil {
 						_ = level.Error(logger).Log("err", err, "user", user)
 					}
 				})
-				r.Put("/", func(w http.ResponseWriter, r *http.Request) {
+				r.With(requireSelfOr(PermissionManageUsers)).Put("/", func(w http.ResponseWriter, r *http.Request) {
 					username := chi.URLParam(r, "username")
 					if username == "" {
 						w.WriteHeader(http.StatusBadRequest)
+						return
 					}
 					var user *model.User
-					if e := json.NewDecoder(r.Body).Decode(&user); e != nil {
+					if e := json.NewDecoder(r.Body).Decode(&user); e != nil || user == nil {
 						w.WriteHeader(http.StatusBadRequest)
+						return
 					}
-					err := services.UserService.UpdateUserByUsername(r.Context(), username, user)
-					if err != nil || user == nil {
+					existing, err := services.UserService.GetUserByUsername(r.Context(), username)
+					if err != nil || existing == nil {
 						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					if e := model.ValidateUserReplacement(existing, user); e != nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", e.Error()), w)
+						return
+					}
+					if e := authorizeRole(r.Context(), user, existing.Role); e != nil {
+						encodeError(r.Context(), e, w)
+						return
 					}
+					user.Version, err = ifMatch(r, func() (int64, error) { return existing.Version, nil })
+					if err != nil {
+						encodeError(r.Context(), errPreconditionFailed, w)
+						return
+					}
+					err = services.UserService.UpdateUserByUsername(r.Context(), username, user)
+					if err == model.ErrVersionMismatch {
+						encodeError(r.Context(), errPreconditionFailed, w)
+						return
+					}
+					if err != nil {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					w.Header().Set("ETag", etag(user.Version))
 					w.WriteHeader(http.StatusOK)
 				})
-				r.Delete("/", func(w http.ResponseWriter, r *http.Request) {
+				r.With(requireSelfOr(PermissionManageUsers)).Patch("/", func(w http.ResponseWriter, r *http.Request) {
+					username := chi.URLParam(r, "username")
+					update, err := decodePatch(r, &model.User{})
+					if err != nil {
+						encodeError(r.Context(), err, w)
+						return
+					}
+					version, err := ifMatch(r, func() (int64, error) {
+						u, err := services.UserService.GetUserByUsername(r.Context(), username)
+						if err != nil {
+							return 0, err
+						}
+						return u.Version, nil
+					})
+					if err != nil {
+						encodeError(r.Context(), errPreconditionFailed, w)
+						return
+					}
+					user, err := services.UserService.PatchUser(r.Context(), username, version, update)
+					if err != nil {
+						encodePatchError(r.Context(), err, w)
+						return
+					}
+					w.Header().Set("ETag", etag(user.Version))
+					err = encodeResponse(r.Context(), w, user)
+					if err != nil {
+						_ = level.Error(logger).Log("err", err, "user", user)
+					}
+				})
+				r.With(requireSelfOr(PermissionManageUsers)).Delete("/", func(w http.ResponseWriter, r *http.Request) {
 					username := chi.URLParam(r, "username")
 					if username == "" {
 						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					hard, err := hardDelete(r)
+					if err != nil {
+						encodeError(r.Context(), err, w)
+						return
 					}
-					err := services.UserService.DeleteUserByUsername(r.Context(), username)
+					err = services.UserService.DeleteUserByUsername(r.Context(), username, hard)
 					if err != nil {
-						_ = level.Error(logger).Log("err", err, "username", username)
+						encodeServiceError(r.Context(), err, w)
+						return
 					}
 					w.WriteHeader(http.StatusNoContent)
 				})
+				r.With(requirePermission(PermissionManageDeleted)).Post("/restore", func(w http.ResponseWriter, r *http.Request) {
+					username := chi.URLParam(r, "username")
+					user, err := services.UserService.RestoreUser(r.Context(), username)
+					if err != nil {
+						encodeServiceError(r.Context(), err, w)
+						return
+					}
+					w.Header().Set("ETag", etag(user.Version))
+					err = encodeResponse(r.Context(), w, user)
+					if err != nil {
+						_ = level.Error(logger).Log("err", err, "user", user)
+					}
+				})
+				r.With(requireSelfOr(PermissionManageOrders)).Get("/orders", func(w http.ResponseWriter, r *http.Request) {
+					username := chi.URLParam(r, "username")
+					filter, err := parseOrderFilter(r.URL.Query())
+					if err != nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+						return
+					}
+					user, err := services.UserService.GetUserByUsername(r.Context(), username)
+					if err != nil || user == nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusNotFound, "error", "user not found"), w)
+						return
+					}
+					filter.UserID = user.ID
+					findOrders(w, r, filter)
+				})
+				r.With(requirePermission(PermissionManageUsers)).Post("/unlock", func(w http.ResponseWriter, r *http.Request) {
+					username := chi.URLParam(r, "username")
+					err := services.UserService.UnlockUser(r.Context(), username)
+					if err != nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusInternalServerError, "error", err.Error()), w)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+				})
 			})
 		})
+
+		if services.WebhookService != nil {
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Use(requirePermission(PermissionManageWebhooks))
+				r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+					var webhook *model.Webhook
+					if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil || webhook == nil {
+						encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", "invalid webhook"), w)
+						return
+					}
+					webhook, err := services.WebhookService.CreateWebhook(r.Context(), webhook)
+					if err != nil {
+						encodeServiceError(r.Context(), err, w)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json; charset=utf-8")
+					w.WriteHeader(http.StatusCreated)
+					_ = json.NewEncoder(w).Encode(webhook)
+				})
+				r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+					webhooks, err := services.WebhookService.FindWebhooks(r.Context())
+					if err != nil {
+						encodeServiceError(r.Context(), err, w)
+						return
+					}
+					_ = encodeResponse(r.Context(), w, webhooks)
+				})
+				r.Route("/{webhookId}", func(r chi.Router) {
+					webhookID := func(r *http.Request) (int64, error) {
+						id, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+						if err != nil {
+							return 0, model.NewErrResponse(http.StatusBadRequest, "error", "invalid webhook id")
+						}
+						return id, nil
+					}
+					r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+						id, err := webhookID(r)
+						if err != nil {
+							encodeError(r.Context(), err, w)
+							return
+						}
+						webhook, err := services.WebhookService.GetWebhook(r.Context(), id)
+						if err != nil {
+							encodeServiceError(r.Context(), err, w)
+							return
+						}
+						_ = encodeResponse(r.Context(), w, webhook)
+					})
+					r.Delete("/", func(w http.ResponseWriter, r *http.Request) {
+						id, err := webhookID(r)
+						if err != nil {
+							encodeError(r.Context(), err, w)
+							return
+						}
+						if err = services.WebhookService.DeleteWebhook(r.Context(), id); err != nil {
+							encodeServiceError(r.Context(), err, w)
+							return
+						}
+						w.WriteHeader(http.StatusNoContent)
+					})
+					r.Get("/deliveries", func(w http.ResponseWriter, r *http.Request) {
+						id, err := webhookID(r)
+						if err != nil {
+							encodeError(r.Context(), err, w)
+							return
+						}
+						deliveries, err := services.WebhookService.FindDeliveries(r.Context(), id, r.URL.Query().Get("status"))
+						if err != nil {
+							encodeServiceError(r.Context(), err, w)
+							return
+						}
+						_ = encodeResponse(r.Context(), w, deliveries)
+					})
+					r.Post("/deliveries/{deliveryId}/retry", func(w http.ResponseWriter, r *http.Request) {
+						id, err := webhookID(r)
+						if err != nil {
+							encodeError(r.Context(), err, w)
+							return
+						}
+						delivery, err := services.WebhookService.RetryDelivery(r.Context(), id, chi.URLParam(r, "deliveryId"))
+						if err != nil {
+							encodeServiceError(r.Context(), err, w)
+							return
+						}
+						_ = encodeResponse(r.Context(), w, delivery)
+					})
+				})
+			})
+		}
+
+		if services.AuditService != nil {
+			r.With(requirePermission(PermissionViewAudit)).Get("/admin/audit", func(w http.ResponseWriter, r *http.Request) {
+				filter, err := parseAuditFilter(r.URL.Query())
+				if err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+					return
+				}
+				events, total, err := services.AuditService.FindAuditEvents(r.Context(), filter)
+				if err != nil {
+					encodeError(r.Context(), model.NewErrResponse(http.StatusInternalServerError, "error", err.Error()), w)
+					return
+				}
+				w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+				err = encodeResponse(r.Context(), w, events)
+				if err != nil {
+					_ = level.Error(logger).Log("err", err, "events", len(events))
+				}
+			})
+		}
+
+		r.Post("/graphql", func(w http.ResponseWriter, r *http.Request) {
+			var req graphqlRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				encodeError(r.Context(), model.NewErrResponse(http.StatusBadRequest, "error", "invalid request: "+err.Error()), w)
+				return
+			}
+			result := executeGraphQL(r.Context(), schema, services, req, limits)
+			if err := encodeResponse(r.Context(), w, result); err != nil {
+				_ = level.Error(logger).Log("err", err, "path", "/graphql")
+			}
+		})
+
+		if services.Cache != nil {
+			r.With(requirePermission(PermissionViewStats)).Get("/admin/cache", func(w http.ResponseWriter, r *http.Request) {
+				_ = encodeResponse(r.Context(), w, services.Cache.Stats())
+			})
+		}
 	})
 
 	// To serve pet images
-	workDir, _ := os.Getwd()
-	filesDir := filepath.Join(workDir, "public")
-	fileServer(r, "/images", http.Dir(filesDir))
+	imagesPath, filesDir := "/images", options.PublicPath
+	if u, err := url.Parse(options.PublicURI); err == nil && u.Path != "" && u.Path != "/" {
+		imagesPath = strings.TrimSuffix(u.Path, "/")
+	}
+	if filesDir == "" {
+		workDir, _ := os.Getwd()
+		filesDir = filepath.Join(workDir, "public")
+	}
+	fileServer(r, imagesPath, http.Dir(filesDir))
 	return r
 }
 
@@ -325,6 +1029,293 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	_ = json.NewEncoder(w).Encode(err)
 }
 
+// decodePets reads a JSON array of pets, or one pet per line with application/x-ndjson content type.
+func decodePets(r *http.Request) ([]*model.Pet, error) {
+	pets := []*model.Pet{}
+	dec := json.NewDecoder(r.Body)
+	switch strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]) {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		for {
+			var pet *model.Pet
+			err := dec.Decode(&pet)
+			if err == io.EOF {
+				return pets, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", len(pets)+1, err)
+			}
+			pets = append(pets, pet)
+		}
+	default:
+		if err := dec.Decode(&pets); err != nil {
+			return nil, err
+		}
+		return pets, nil
+	}
+}
+
+// parseOrderFilter reads userId, petId, status ( comma separated ), shipDateFrom, shipDateTo ( RFC 3339 ),
+// offset and limit query parameters.
+func parseOrderFilter(query url.Values) (model.OrderFilter, error) {
+	var filter model.OrderFilter
+	var err error
+	for name, dest := range map[string]*int64{
+		"userId": &filter.UserID,
+		"petId":  &filter.PetID,
+		"offset": &filter.Offset,
+		"limit":  &filter.Limit,
+	} {
+		if v := query.Get(name); v != "" {
+			*dest, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || *dest < 0 {
+				return filter, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	for name, dest := range map[string]*time.Time{
+		"shipDateFrom": &filter.ShipDateFrom,
+		"shipDateTo":   &filter.ShipDateTo,
+	} {
+		if v := query.Get(name); v != "" {
+			*dest, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	if v := strings.TrimSpace(query.Get("status")); v != "" {
+		filter.Statuses = strings.Split(v, ",")
+	}
+	return filter, nil
+}
+
+// parsePetSearch reads q ( required ), status ( comma separated ), fuzzy, offset and limit query parameters.
+func parsePetSearch(query url.Values) (model.PetSearch, error) {
+	search := model.PetSearch{Query: strings.TrimSpace(query.Get("q"))}
+	if search.Query == "" {
+		return search, fmt.Errorf("q is required")
+	}
+	var err error
+	for name, dest := range map[string]*int64{
+		"offset": &search.Offset,
+		"limit":  &search.Limit,
+	} {
+		if v := query.Get(name); v != "" {
+			*dest, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || *dest < 0 {
+				return search, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	if v := strings.TrimSpace(query.Get("status")); v != "" {
+		search.Statuses = strings.Split(v, ",")
+		for _, status := range search.Statuses {
+			if !model.ValidPetStatus(status) {
+				return search, fmt.Errorf("invalid status %q", status)
+			}
+		}
+	}
+	if v := query.Get("fuzzy"); v != "" {
+		if search.Fuzzy, err = strconv.ParseBool(v); err != nil {
+			return search, fmt.Errorf("invalid fuzzy %q", v)
+		}
+	}
+	return search, nil
+}
+
+// parseAuditFilter reads entity ( a collection ), entityId, actor ( username ), from, to ( RFC 3339 ), offset and limit
+// query parameters.
+func parseAuditFilter(query url.Values) (model.AuditFilter, error) {
+	filter := model.AuditFilter{
+		Entity: query.Get("entity"),
+		Actor:  query.Get("actor"),
+	}
+	var err error
+	for name, dest := range map[string]*int64{
+		"entityId": &filter.EntityID,
+		"offset":   &filter.Offset,
+		"limit":    &filter.Limit,
+	} {
+		if v := query.Get(name); v != "" {
+			*dest, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || *dest < 0 {
+				return filter, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	for name, dest := range map[string]*time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	} {
+		if v := query.Get(name); v != "" {
+			*dest, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	if filter.Entity != "" {
+		if _, err = model.NewDocument(filter.Entity); err != nil {
+			return filter, fmt.Errorf("invalid entity %q", filter.Entity)
+		}
+	}
+	return filter, nil
+}
+
+var errPreconditionFailed = model.NewErrResponse(
+	http.StatusPreconditionFailed, "error", "resource has been modified, fetch it again")
+
+// etag formats version of a document as its entity tag.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// matchETag tells whether header, a comma separated list of entity tags or *, has the tag of version.
+// Weak tags only match with weak comparison.
+func matchETag(header string, version int64, weak bool) bool {
+	tag := etag(version)
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || t == tag || (weak && t == "W/"+tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header of a document at version and, when If-None-Match has it, answers
+// 304 Not Modified and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	w.Header().Set("ETag", etag(version))
+	if h := r.Header.Get("If-None-Match"); h != "" && matchETag(h, version, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// ifMatch returns the version an update has to be made at, 0 for an unconditional update without If-Match header
+// or with *. The current version of the document is fetched with current, errPreconditionFailed is returned if it
+// does not match or the document cannot be fetched.
+func ifMatch(r *http.Request, current func() (int64, error)) (int64, error) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" {
+		return 0, nil
+	}
+	version, err := current()
+	if err != nil {
+		return 0, errPreconditionFailed
+	}
+	if h == "*" {
+		return 0, nil
+	}
+	if !matchETag(h, version, false) {
+		return 0, errPreconditionFailed
+	}
+	return version, nil
+}
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// decodePatch translates the body of a PATCH request into an update of documents like doc, a JSON Merge Patch
+// or a JSON Patch according to its content type.
+func decodePatch(r *http.Request, doc interface{}) (*model.Update, error) {
+	contentType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+	if contentType != contentTypeMergePatch && contentType != contentTypeJSONPatch {
+		return nil, model.NewErrResponse(http.StatusUnsupportedMediaType, "error",
+			"patch must be "+contentTypeMergePatch+" or "+contentTypeJSONPatch)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, model.NewErrResponse(http.StatusBadRequest, "error", err.Error())
+	}
+	var update *model.Update
+	if contentType == contentTypeMergePatch {
+		update, err = model.MergePatch(doc, body)
+	} else {
+		update, err = model.JSONPatch(doc, body)
+	}
+	if err != nil {
+		return nil, model.NewErrResponse(http.StatusBadRequest, "error", err.Error())
+	}
+	return update, nil
+}
+
+// encodePatchError answers a patch refused by the service or the storage.
+func encodePatchError(ctx context.Context, err error, w http.ResponseWriter) {
+	if _, ok := err.(*model.ErrResponse); ok {
+		encodeError(ctx, err, w)
+		return
+	}
+	switch err {
+	case model.ErrVersionMismatch:
+		encodeError(ctx, errPreconditionFailed, w)
+	case model.ErrPatchTestFailed:
+		encodeError(ctx, model.NewErrResponse(http.StatusConflict, "error", err.Error()), w)
+	case model.ErrNotFound:
+		encodeError(ctx, model.NewErrResponse(http.StatusNotFound, "error", "not found"), w)
+	default:
+		encodeError(ctx, model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+	}
+}
+
+// hardDelete tells whether a delete request asks with hard=true for deleting for good, which only callers
+// managing deleted documents may do.
+func hardDelete(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("hard")
+	if v == "" {
+		return false, nil
+	}
+	hard, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, model.NewErrResponse(http.StatusBadRequest, "error", "invalid hard "+v)
+	}
+	if err = authorizeHard(r.Context(), hard); err != nil {
+		return false, err
+	}
+	return hard, nil
+}
+
+// encodeServiceError answers a failed call of a service, with the status of an ErrResponse, 404 when not found, 409
+// when out of stock and 500 otherwise.
+func encodeServiceError(ctx context.Context, err error, w http.ResponseWriter) {
+	if _, ok := err.(*model.ErrResponse); ok {
+		encodeError(ctx, err, w)
+		return
+	}
+	switch err {
+	case model.ErrNotFound:
+		encodeError(ctx, model.NewErrResponse(http.StatusNotFound, "error", "not found"), w)
+	case model.ErrOutOfStock:
+		encodeError(ctx, model.NewErrResponse(http.StatusConflict, "error", err.Error()), w)
+	default:
+		encodeError(ctx, model.NewErrResponse(http.StatusInternalServerError, "error", err.Error()), w)
+	}
+}
+
+// batchErrResponse is the body of a refused batch with the reason of each failed item.
+type batchErrResponse struct {
+	model.ErrResponse
+	Items []model.BatchItemError `json:"items"`
+}
+
+func encodeBatchError(ctx context.Context, err error, w http.ResponseWriter) {
+	e, ok := err.(*model.BatchError)
+	if !ok {
+		encodeError(ctx, model.NewErrResponse(http.StatusBadRequest, "error", err.Error()), w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(batchErrResponse{
+		ErrResponse: model.ErrResponse{Code: http.StatusBadRequest, Type: "error", Message: e.Error()},
+		Items:       e.Items,
+	})
+}
+
 func fileServer(r chi.Router, path string, root http.FileSystem) {
 	if strings.ContainsAny(path, "{}*") {
 		panic("FileServer does not permit URL parameters.")
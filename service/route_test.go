@@ -1,3 +1,151 @@
 package service
 
 // this is to test routes, decoding and encoding.
+
+import (
+	"context"
+	"errors"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOrderFilter(t *testing.T) {
+	q, _ := url.ParseQuery("userId=1&petId=2&status=placed,approved&shipDateFrom=2019-05-01T00:00:00Z&offset=20&limit=10")
+	filter, err := parseOrderFilter(q)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), filter.UserID)
+	assert.Equal(t, int64(2), filter.PetID)
+	assert.Equal(t, []string{"placed", "approved"}, filter.Statuses)
+	assert.Equal(t, time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), filter.ShipDateFrom)
+	assert.True(t, filter.ShipDateTo.IsZero())
+	assert.Equal(t, int64(20), filter.Offset)
+	assert.Equal(t, int64(10), filter.Limit)
+
+	_, err = parseOrderFilter(url.Values{"limit": {"-1"}})
+	assert.Error(t, err)
+	_, err = parseOrderFilter(url.Values{"shipDateTo": {"yesterday"}})
+	assert.Error(t, err)
+}
+
+func TestParsePetSearch(t *testing.T) {
+	q, _ := url.ParseQuery("q=fluffy+tabby&status=available,sold&fuzzy=true&offset=20&limit=10")
+	search, err := parsePetSearch(q)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PetSearch{
+		Query: "fluffy tabby", Statuses: []string{"available", "sold"}, Fuzzy: true, Offset: 20, Limit: 10,
+	}, search)
+
+	_, err = parsePetSearch(url.Values{"q": {" "}})
+	assert.Error(t, err)
+	_, err = parsePetSearch(url.Values{"q": {"rex"}, "status": {"lost"}})
+	assert.Error(t, err)
+	_, err = parsePetSearch(url.Values{"q": {"rex"}, "fuzzy": {"maybe"}})
+	assert.Error(t, err)
+}
+
+func TestEncodeBatchError(t *testing.T) {
+	batch := &model.BatchError{}
+	batch.Add(1, 2, "duplicate user id exists for 2")
+	w := httptest.NewRecorder()
+	encodeBatchError(context.Background(), batch, w)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"code":400,"type":"error","message":"1 items of batch failed: item 1 ( id 2 ): duplicate user id exists for 2",
+		"items":[{"index":1,"id":2,"message":"duplicate user id exists for 2"}]}`, w.Body.String())
+}
+
+func TestDecodePets(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v2/pet/batch", strings.NewReader(`[{"id":1,"name":"cat1"},{"id":2,"name":"cat2"}]`))
+	r.Header.Set("Content-Type", "application/json")
+	pets, err := decodePets(r)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(pets))
+	assert.Equal(t, "cat2", pets[1].Name)
+
+	r = httptest.NewRequest(http.MethodPost, "/v2/pet/batch", strings.NewReader("{\"id\":1,\"name\":\"cat1\"}\n{\"id\":2,\"name\":\"cat2\"}\n"))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	pets, err = decodePets(r)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(pets))
+	assert.Equal(t, int64(2), pets[1].ID)
+
+	r = httptest.NewRequest(http.MethodPost, "/v2/pet/batch", strings.NewReader("{\"id\":1}\n{\"id\":"))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	_, err = decodePets(r)
+	assert.Error(t, err)
+}
+
+func TestConditionalRequests(t *testing.T) {
+	assert.Equal(t, `"3"`, etag(3))
+	assert.True(t, matchETag(`"2", "3"`, 3, false))
+	assert.True(t, matchETag(`*`, 3, false))
+	assert.True(t, matchETag(`W/"3"`, 3, true))
+	assert.False(t, matchETag(`W/"3"`, 3, false))
+	assert.False(t, matchETag(`"4"`, 3, true))
+
+	r := httptest.NewRequest(http.MethodGet, "/v2/pet/1", nil)
+	r.Header.Set("If-None-Match", `"3"`)
+	w := httptest.NewRecorder()
+	assert.True(t, notModified(w, r, 3))
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	assert.False(t, notModified(w, r, 4))
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+
+	current := func() (int64, error) { return 3, nil }
+	r = httptest.NewRequest(http.MethodPut, "/v2/pet", nil)
+	version, err := ifMatch(r, current)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), version)
+	r.Header.Set("If-Match", `"3"`)
+	version, err = ifMatch(r, current)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+	r.Header.Set("If-Match", `"2"`)
+	_, err = ifMatch(r, current)
+	assert.Equal(t, errPreconditionFailed, err)
+	_, err = ifMatch(r, func() (int64, error) { return 0, errors.New("not found") })
+	assert.Equal(t, errPreconditionFailed, err)
+}
+
+func TestDecodePatch(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/v2/pet/1", strings.NewReader(`{"name":"cat2","tags":null}`))
+	r.Header.Set("Content-Type", "application/merge-patch+json")
+	u, err := decodePatch(r, &model.Pet{})
+	assert.NoError(t, err)
+	assert.Equal(t, "cat2", u.Set["name"])
+	assert.True(t, u.Unset["tags"])
+
+	r = httptest.NewRequest(http.MethodPatch, "/v2/user/u", strings.NewReader(`[{"op":"replace","path":"/email","value":"a@b.c"}]`))
+	r.Header.Set("Content-Type", "application/json-patch+json; charset=utf-8")
+	u, err = decodePatch(r, &model.User{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a@b.c", u.Set["email"])
+
+	r = httptest.NewRequest(http.MethodPatch, "/v2/pet/1", strings.NewReader(`{"name":"cat2"}`))
+	r.Header.Set("Content-Type", "application/json")
+	_, err = decodePatch(r, &model.Pet{})
+	assert.Equal(t, int32(http.StatusUnsupportedMediaType), err.(*model.ErrResponse).Code)
+
+	r = httptest.NewRequest(http.MethodPatch, "/v2/pet/1", strings.NewReader(`{"owner":"me"}`))
+	r.Header.Set("Content-Type", "application/merge-patch+json")
+	_, err = decodePatch(r, &model.Pet{})
+	assert.Equal(t, int32(http.StatusBadRequest), err.(*model.ErrResponse).Code)
+
+	for err, code := range map[error]int{
+		model.ErrVersionMismatch: http.StatusPreconditionFailed,
+		model.ErrPatchTestFailed: http.StatusConflict,
+		model.ErrNotFound:        http.StatusNotFound,
+		errForbidden:             http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		encodePatchError(context.Background(), err, w)
+		assert.Equal(t, code, w.Code)
+	}
+}
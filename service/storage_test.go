@@ -0,0 +1,688 @@
+package service
+
+// This is to keep documents in memory for the route tests, behaving like MongoStorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStorage keeps pets, orders, users, sessions, login attempts and events in maps, deleted documents keeping
+// their deletedAt like in MongoStorage. Transactions are not isolated, changes made before a failure are kept.
+// Import, export, purge and migrations are not implemented.
+type memoryStorage struct {
+	model.Storage
+	mu       sync.Mutex
+	pets     map[int64]model.Pet
+	orders   map[int64]model.Order
+	users    map[int64]model.User
+	sessions map[string]model.Session
+	attempts map[string]model.LoginAttempts
+	events   []*model.Event
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		pets:     map[int64]model.Pet{},
+		orders:   map[int64]model.Order{},
+		users:    map[int64]model.User{},
+		sessions: map[string]model.Session{},
+		attempts: map[string]model.LoginAttempts{},
+	}
+}
+
+// checkVersion fails like MongoStorage when a document is not at version, unless it is 0.
+func checkVersion(current, version int64) error {
+	if version != 0 && version != current {
+		return model.ErrVersionMismatch
+	}
+	return nil
+}
+
+func (m *memoryStorage) WithTransaction(fn func(tx model.Storage) error) error {
+	return fn(m)
+}
+
+func (m *memoryStorage) CreateUser(user *model.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[user.ID]; ok {
+		return fmt.Errorf("duplicate user id exists")
+	}
+	if _, ok := m.userByUsername(user.Username, true); ok {
+		return fmt.Errorf("duplicate username exists")
+	}
+	user.Version = 1
+	m.users[user.ID] = *user
+	return nil
+}
+
+func (m *memoryStorage) CreateManyUsers(users []*model.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batch := &model.BatchError{}
+	for i, u := range users {
+		if u == nil {
+			batch.Add(i, 0, "user is null")
+			continue
+		}
+		if _, ok := m.users[u.ID]; ok {
+			batch.Add(i, u.ID, fmt.Sprintf("duplicate user id exists for %d", u.ID))
+		}
+	}
+	if err := batch.Err(); err != nil {
+		return err
+	}
+	for _, u := range users {
+		u.Version = 1
+		m.users[u.ID] = *u
+	}
+	return nil
+}
+
+// userByUsername finds a user by username, deleted ones too when withDeleted.
+func (m *memoryStorage) userByUsername(username string, withDeleted bool) (model.User, bool) {
+	for _, u := range m.users {
+		if u.Username == username && (withDeleted || u.DeletedAt == nil) {
+			return u, true
+		}
+	}
+	return model.User{}, false
+}
+
+func (m *memoryStorage) RetrieveUserByUsername(username string) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.userByUsername(username, false)
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (m *memoryStorage) RetrieveUserByID(id int64) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok || u.DeletedAt != nil {
+		return nil, model.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (m *memoryStorage) RetrieveUsersByIDs(ids []int64) ([]*model.User, error) {
+	var users []*model.User
+	for _, id := range ids {
+		if u, err := m.RetrieveUserByID(id); err == nil {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (m *memoryStorage) UpdateUserByUsername(username string, user *model.User) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.userByUsername(username, false)
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	if err := checkVersion(existing.Version, user.Version); err != nil {
+		return nil, err
+	}
+	delete(m.users, existing.ID)
+	user.Version = existing.Version + 1
+	m.users[user.ID] = *user
+	return user, nil
+}
+
+func (m *memoryStorage) PatchUserByUsername(username string, version int64, update *model.Update) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.userByUsername(username, false)
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	if err := checkVersion(user.Version, version); err != nil {
+		return nil, err
+	}
+	if err := applyUpdate(&user, update); err != nil {
+		return nil, err
+	}
+	user.Version++
+	m.users[user.ID] = user
+	return &user, nil
+}
+
+func (m *memoryStorage) DeleteUserByUsername(username string, hard bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.userByUsername(username, hard)
+	if !ok {
+		return model.ErrNotFound
+	}
+	if hard {
+		delete(m.users, user.ID)
+		return nil
+	}
+	user.DeletedAt, user.Version = deletedNow(), user.Version+1
+	m.users[user.ID] = user
+	return nil
+}
+
+func (m *memoryStorage) RestoreUserByUsername(username string) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == username && u.DeletedAt != nil {
+			u.DeletedAt, u.Version = nil, u.Version+1
+			m.users[u.ID] = u
+			return &u, nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *memoryStorage) CreatePet(pet *model.Pet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pets[pet.ID]; ok {
+		return fmt.Errorf("duplicate pet id exists")
+	}
+	pet.Version = 1
+	m.pets[pet.ID] = *pet
+	return nil
+}
+
+func (m *memoryStorage) CreateManyPets(pets []*model.Pet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batch := &model.BatchError{}
+	for i, p := range pets {
+		if _, ok := m.pets[p.ID]; ok {
+			batch.Add(i, p.ID, fmt.Sprintf("duplicate pet id exists for %d", p.ID))
+		}
+	}
+	if err := batch.Err(); err != nil {
+		return err
+	}
+	for _, p := range pets {
+		p.Version = 1
+		m.pets[p.ID] = *p
+	}
+	return nil
+}
+
+func (m *memoryStorage) UpdatePetByID(pet *model.Pet) error {
+	return m.updatePet(pet.ID, pet.Version, func(p *model.Pet) error {
+		version := p.Version
+		*p = *pet
+		p.Version = version
+		return nil
+	}, func(p *model.Pet) {
+		pet.Version = p.Version
+	})
+}
+
+// updatePet applies change to pet id at version unless it is 0, increments its version and calls done, unless
+// it is nil, with the updated pet.
+func (m *memoryStorage) updatePet(id int64, version int64, change func(p *model.Pet) error, done func(p *model.Pet)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pet, ok := m.pets[id]
+	if !ok || pet.DeletedAt != nil {
+		return model.ErrNotFound
+	}
+	if err := checkVersion(pet.Version, version); err != nil {
+		return err
+	}
+	if err := change(&pet); err != nil {
+		return err
+	}
+	pet.Version++
+	m.pets[id] = pet
+	if done != nil {
+		done(&pet)
+	}
+	return nil
+}
+
+func (m *memoryStorage) RetrievePetByID(id int64) (*model.Pet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pet, ok := m.pets[id]
+	if !ok || pet.DeletedAt != nil {
+		return nil, model.ErrNotFound
+	}
+	return &pet, nil
+}
+
+func (m *memoryStorage) RetrievePetsByIDs(ids []int64) ([]*model.Pet, error) {
+	var pets []*model.Pet
+	for _, id := range ids {
+		if p, err := m.RetrievePetByID(id); err == nil {
+			pets = append(pets, p)
+		}
+	}
+	return pets, nil
+}
+
+// alivePets returns pets not deleted by id.
+func (m *memoryStorage) alivePets() []*model.Pet {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pets []*model.Pet
+	for _, p := range m.pets {
+		if p.DeletedAt == nil {
+			p := p
+			pets = append(pets, &p)
+		}
+	}
+	sort.Slice(pets, func(i, j int) bool { return pets[i].ID < pets[j].ID })
+	return pets
+}
+
+func (m *memoryStorage) FindPetsByStatus(statuses []string) ([]*model.Pet, error) {
+	pets := []*model.Pet{}
+	for _, p := range m.alivePets() {
+		for _, status := range statuses {
+			if p.Status == status {
+				pets = append(pets, p)
+				break
+			}
+		}
+	}
+	return pets, nil
+}
+
+func (m *memoryStorage) SearchPets(search model.PetSearch) ([]*model.PetSearchResult, int64, error) {
+	results, total := model.SearchPets(m.alivePets(), search)
+	return results, total, nil
+}
+
+func (m *memoryStorage) PatchPetByID(id int64, version int64, update *model.Update) (*model.Pet, error) {
+	var patched *model.Pet
+	err := m.updatePet(id, version, func(p *model.Pet) error {
+		return applyUpdate(p, update)
+	}, func(p *model.Pet) {
+		patched = p
+	})
+	return patched, err
+}
+
+func (m *memoryStorage) UpdatePetNameAndStatusByID(id int64, version int64, name string, status string) error {
+	return m.updatePet(id, version, func(p *model.Pet) error {
+		p.Name, p.Status = name, status
+		return nil
+	}, nil)
+}
+
+func (m *memoryStorage) UpdatePetNameByID(id int64, version int64, name string) error {
+	return m.updatePet(id, version, func(p *model.Pet) error {
+		p.Name = name
+		return nil
+	}, nil)
+}
+
+func (m *memoryStorage) UpdatePetStatusByID(id int64, version int64, status string) error {
+	return m.updatePet(id, version, func(p *model.Pet) error {
+		p.Status = status
+		return nil
+	}, nil)
+}
+
+func (m *memoryStorage) AddImageUrlByPetID(id int64, url string) (*model.Pet, error) {
+	var updated *model.Pet
+	err := m.updatePet(id, 0, func(p *model.Pet) error {
+		p.AddPhotoUrl(url)
+		return nil
+	}, func(p *model.Pet) {
+		updated = p
+	})
+	return updated, err
+}
+
+func (m *memoryStorage) RetrieveStoreInventoriesByStatus() (map[string]int64, error) {
+	inv := map[string]int64{}
+	for _, p := range m.alivePets() {
+		inv[p.Status] += p.Units()
+	}
+	return inv, nil
+}
+
+func (m *memoryStorage) RetrieveStoreStockValue() (model.StockValue, error) {
+	value := model.StockValue{}
+	for _, p := range m.alivePets() {
+		if p.Status != model.PetStatusAvailable || p.Price <= 0 {
+			continue
+		}
+		amount, err := model.MultiplyAmount(p.Price, p.Units())
+		if err != nil {
+			return nil, err
+		}
+		if err = value.Add(p.Currency, amount); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func (m *memoryStorage) ReserveStockByPetID(id int64, quantity int64) (*model.Pet, error) {
+	var reserved *model.Pet
+	err := m.updatePet(id, 0, func(p *model.Pet) error {
+		if p.Stock == nil {
+			if quantity > 1 || p.Status != model.PetStatusAvailable {
+				return model.ErrOutOfStock
+			}
+			p.Status = model.PetStatusPending
+			return nil
+		}
+		if *p.Stock < quantity {
+			return model.ErrOutOfStock
+		}
+		stock := *p.Stock - quantity
+		p.Stock = &stock
+		return nil
+	}, func(p *model.Pet) {
+		reserved = p
+	})
+	return reserved, err
+}
+
+func (m *memoryStorage) ReleaseStockByPetID(id int64, quantity int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pet, ok := m.pets[id]
+	if !ok || (pet.Stock == nil && pet.Status != model.PetStatusPending) {
+		return nil
+	}
+	if pet.Stock == nil {
+		pet.Status = model.PetStatusAvailable
+	} else {
+		stock := *pet.Stock + quantity
+		pet.Stock = &stock
+	}
+	pet.Version++
+	m.pets[id] = pet
+	return nil
+}
+
+func (m *memoryStorage) DeletePetByID(id int64, hard bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pet, ok := m.pets[id]
+	if !ok || (!hard && pet.DeletedAt != nil) {
+		return model.ErrNotFound
+	}
+	if hard {
+		delete(m.pets, id)
+		return nil
+	}
+	pet.DeletedAt, pet.Version = deletedNow(), pet.Version+1
+	m.pets[id] = pet
+	return nil
+}
+
+func (m *memoryStorage) RestorePetByID(id int64) (*model.Pet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pet, ok := m.pets[id]
+	if !ok || pet.DeletedAt == nil {
+		return nil, model.ErrNotFound
+	}
+	pet.DeletedAt, pet.Version = nil, pet.Version+1
+	m.pets[id] = pet
+	return &pet, nil
+}
+
+func (m *memoryStorage) CreateOrder(order *model.Order) (*model.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.orders[order.ID]; ok {
+		return nil, fmt.Errorf("duplicate order id exists")
+	}
+	order.Version = 1
+	m.orders[order.ID] = *order
+	return order, nil
+}
+
+func (m *memoryStorage) RetrieveOrderByID(id int64) (*model.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.orders[id]
+	if !ok || order.DeletedAt != nil {
+		return nil, model.ErrNotFound
+	}
+	return &order, nil
+}
+
+func (m *memoryStorage) DeleteOrderByID(id int64, hard bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.orders[id]
+	if !ok || (!hard && order.DeletedAt != nil) {
+		return model.ErrNotFound
+	}
+	if hard {
+		delete(m.orders, id)
+		return nil
+	}
+	order.DeletedAt, order.Version = deletedNow(), order.Version+1
+	m.orders[id] = order
+	return nil
+}
+
+func (m *memoryStorage) RestoreOrderByID(id int64) (*model.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.orders[id]
+	if !ok || order.DeletedAt == nil {
+		return nil, model.ErrNotFound
+	}
+	order.DeletedAt, order.Version = nil, order.Version+1
+	m.orders[id] = order
+	return &order, nil
+}
+
+func (m *memoryStorage) FindOrders(filter model.OrderFilter) ([]*model.Order, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	orders := []*model.Order{}
+	for _, o := range m.orders {
+		switch {
+		case o.DeletedAt != nil,
+			filter.UserID != 0 && o.UserID != filter.UserID,
+			filter.PetID != 0 && o.PetID != filter.PetID,
+			len(filter.Statuses) > 0 && !containsString(filter.Statuses, o.Status),
+			!filter.ShipDateFrom.IsZero() && o.ShipDate.Before(filter.ShipDateFrom),
+			!filter.ShipDateTo.IsZero() && !o.ShipDate.Before(filter.ShipDateTo):
+			continue
+		}
+		o := o
+		orders = append(orders, &o)
+	}
+	sortOrders(orders)
+	total := int64(len(orders))
+	if filter.Offset >= total {
+		return []*model.Order{}, total, nil
+	}
+	orders = orders[filter.Offset:]
+	if int64(len(orders)) > filter.PageSize() {
+		orders = orders[:filter.PageSize()]
+	}
+	return orders, total, nil
+}
+
+func (m *memoryStorage) FindOrdersByUserIDs(userIDs []int64) ([]*model.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var orders []*model.Order
+	for _, o := range m.orders {
+		for _, id := range userIDs {
+			if o.DeletedAt == nil && o.UserID == id {
+				o := o
+				orders = append(orders, &o)
+			}
+		}
+	}
+	sortOrders(orders)
+	return orders, nil
+}
+
+// sortOrders sorts like MongoStorage, latest ship date first.
+func sortOrders(orders []*model.Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].ShipDate.Equal(orders[j].ShipDate) {
+			return orders[i].ShipDate.After(orders[j].ShipDate)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+}
+
+func (m *memoryStorage) UpdateOrderStatusByID(id int64, version int64, status string) (*model.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.orders[id]
+	if !ok || order.DeletedAt != nil {
+		return nil, model.ErrNotFound
+	}
+	if err := checkVersion(order.Version, version); err != nil {
+		return nil, err
+	}
+	order.Status, order.Version = status, order.Version+1
+	order.Complete = status == model.OrderStatusDelivered
+	m.orders[id] = order
+	return &order, nil
+}
+
+func (m *memoryStorage) AppendEvent(event *model.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memoryStorage) FindEventsAfter(after int64, limit int64) ([]*model.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if after >= int64(len(m.events)) {
+		return nil, nil
+	}
+	events := m.events[after:]
+	if int64(len(events)) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (m *memoryStorage) AppendAuditEvent(event *model.AuditEvent) error {
+	return nil
+}
+
+func (m *memoryStorage) FindAuditEvents(filter model.AuditFilter) ([]*model.AuditEvent, int64, error) {
+	return []*model.AuditEvent{}, 0, nil
+}
+
+func (m *memoryStorage) RetrieveLoginAttemptsByUsername(username string) (*model.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attempts[username]
+	if !ok {
+		a = model.LoginAttempts{Username: username}
+	}
+	return &a, nil
+}
+
+func (m *memoryStorage) ReplaceLoginAttempts(seen *model.LoginAttempts, attempts *model.LoginAttempts) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.attempts[seen.Username]
+	if a.Failures != seen.Failures || !a.LastFailureAt.Equal(seen.LastFailureAt) {
+		return model.ErrVersionMismatch
+	}
+	m.attempts[attempts.Username] = *attempts
+	return nil
+}
+
+func (m *memoryStorage) DeleteLoginAttemptsByUsername(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, username)
+	return nil
+}
+
+func (m *memoryStorage) CreateSession(session *model.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.Token] = *session
+	return nil
+}
+
+func (m *memoryStorage) RetrieveSessionByToken(token string) (*model.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[token]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *memoryStorage) DeleteSessionByToken(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, token)
+	return nil
+}
+
+// applyUpdate applies the top level fields set and unset by update to doc, a pointer to a Pet or User, whose
+// JSON names are those of the stored fields. Nested paths and pushes are not supported.
+func applyUpdate(doc interface{}, update *model.Update) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{}
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for path, value := range update.Set {
+		if strings.Contains(path, ".") {
+			return fmt.Errorf("nested path %s not supported", path)
+		}
+		fields[path] = value
+	}
+	for path := range update.Unset {
+		delete(fields, path)
+	}
+	if len(update.Push) > 0 {
+		return fmt.Errorf("push not supported")
+	}
+	if b, err = json.Marshal(fields); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(doc).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return json.Unmarshal(b, doc)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func deletedNow() *time.Time {
+	t := time.Now().UTC()
+	return &t
+}
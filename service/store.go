@@ -2,26 +2,38 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/cooljeffrey/petstore/model"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"net/http"
 )
 
 type StoreService interface {
 	GetInventoriesByStatus(ctx context.Context) (map[string]int64, error)
+	GetStockValue(ctx context.Context) (model.StockValue, error)
 	PlaceOrder(ctx context.Context, order *model.Order) (*model.Order, error)
 	FindOrderByID(ctx context.Context, id int64) (*model.Order, error)
-	DeleteOrderByID(ctx context.Context, id int64) error
+	DeleteOrderByID(ctx context.Context, id int64, hard bool) error
+	RestoreOrderByID(ctx context.Context, id int64) (*model.Order, error)
+	UpdateOrderStatus(ctx context.Context, id int64, version int64, status string) (*model.Order, error)
+	FindEventsAfter(ctx context.Context, after int64, limit int64) ([]*model.Event, error)
+	FindOrders(ctx context.Context, filter model.OrderFilter) ([]*model.Order, int64, error)
+	FindOrdersByUserIDs(ctx context.Context, userIDs []int64) ([]*model.Order, error)
 }
 
 type storeService struct {
-	logger  log.Logger
-	storage model.Storage
+	logger    log.Logger
+	storage   model.Storage
+	publisher Publisher
 }
 
-func NewStoreService(logger log.Logger, storage model.Storage) StoreService {
+// NewStoreService returns the store service, publishing events of committed changes to publisher unless it is nil.
+func NewStoreService(logger log.Logger, storage model.Storage, publisher Publisher) StoreService {
 	return &storeService{
-		logger:  logger,
-		storage: storage,
+		logger:    logger,
+		storage:   storage,
+		publisher: publisher,
 	}
 }
 
@@ -29,14 +41,195 @@ func (s storeService) GetInventoriesByStatus(ctx context.Context) (map[string]in
 	return s.storage.RetrieveStoreInventoriesByStatus()
 }
 
+func (s storeService) GetStockValue(ctx context.Context) (model.StockValue, error) {
+	return s.storage.RetrieveStoreStockValue()
+}
+
+// PlaceOrder takes the ordered quantity out of stock of the pet and prices the order at the current pet price,
+// both in one transaction where the storage supports it.
 func (s storeService) PlaceOrder(ctx context.Context, order *model.Order) (*model.Order, error) {
-	return s.storage.CreateOrder(order)
+	if order.Quantity < 0 {
+		return nil, errors.New("quantity must not be negative")
+	}
+	if order.Quantity == 0 {
+		order.Quantity = 1
+	}
+	var placed *model.Order
+	err := inTransaction(ctx, s.storage, s.publisher, func(tx model.Storage, emit emitter) error {
+		pet, err := reserveStock(tx, emit, order)
+		if err != nil {
+			return err
+		}
+		order.PetHeld = pet.Stock == nil
+		err = order.ApplyPrice(pet)
+		if err == nil {
+			placed, err = tx.CreateOrder(order)
+			if err == nil {
+				return emit(model.NewOrderEvent(model.EventOrderPlaced, placed))
+			}
+		}
+		// undo the reservation for storages without transactions, harmless when the transaction is aborted anyway
+		if e := releaseStock(tx, emit, order); e != nil {
+			_ = level.Debug(s.logger).Log("msg", "release stock", "petId", order.PetID, "err", e)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return placed, nil
 }
 
 func (s storeService) FindOrderByID(ctx context.Context, id int64) (*model.Order, error) {
 	return s.storage.RetrieveOrderByID(id)
 }
 
-func (s storeService) DeleteOrderByID(ctx context.Context, id int64) error {
-	return s.storage.DeleteOrderByID(id)
+// DeleteOrderByID cancels an order, putting its quantity back into stock, or its single pet back to available if it
+// took it, unless it has been delivered.
+// An order deleted already is only removed for good when hard, as its quantity went back then.
+func (s storeService) DeleteOrderByID(ctx context.Context, id int64, hard bool) error {
+	order, err := s.storage.RetrieveOrderByID(id)
+	if err == model.ErrNotFound && hard {
+		return storageFor(ctx, s.storage).DeleteOrderByID(id, true)
+	}
+	if err != nil {
+		return err
+	}
+	return inTransaction(ctx, s.storage, s.publisher, func(tx model.Storage, emit emitter) error {
+		err := tx.DeleteOrderByID(id, hard)
+		if err == nil {
+			err = emit(model.NewOrderEvent(model.EventOrderDeleted, order))
+		}
+		if err != nil {
+			return err
+		}
+		if order.Complete || order.Status == model.OrderStatusDelivered {
+			return nil
+		}
+		if holds, err := holdsStock(tx, order); err != nil || !holds {
+			return err
+		}
+		return releaseStock(tx, emit, order)
+	})
+}
+
+// RestoreOrderByID undoes the deletion of an order, taking its quantity out of stock again, or its single pet if it
+// took it, unless it has been delivered. The order stays deleted if the pet is out of stock.
+func (s storeService) RestoreOrderByID(ctx context.Context, id int64) (*model.Order, error) {
+	var restored *model.Order
+	err := inTransaction(ctx, s.storage, s.publisher, func(tx model.Storage, emit emitter) error {
+		order, err := tx.RestoreOrderByID(id)
+		if err != nil {
+			return err
+		}
+		restored = order
+		if err = emit(model.NewOrderEvent(model.EventOrderRestored, order)); err != nil {
+			return err
+		}
+		if order.Complete || order.Status == model.OrderStatusDelivered {
+			return nil
+		}
+		if holds, err := holdsStock(tx, order); err != nil || !holds {
+			return err
+		}
+		_, err = reserveStock(tx, emit, order)
+		if err == nil {
+			return nil
+		}
+		// delete the order again for storages without transactions, harmless when the transaction is aborted anyway
+		if e := tx.DeleteOrderByID(id, false); e != nil {
+			_ = level.Debug(s.logger).Log("msg", "delete order", "orderId", id, "err", e)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// holdsStock tells whether order has its quantity out of stock of its pet, or has taken its single pet from available
+// to pending when placed. Orders of single pets which did not, such as imported ones, leave their pet as it is.
+func holdsStock(tx model.Storage, order *model.Order) (bool, error) {
+	pet, err := tx.RetrievePetByID(order.PetID)
+	if err == model.ErrNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return pet.Stock != nil || order.PetHeld, nil
+}
+
+// reserveStock takes the quantity of order out of stock of its pet, or its single pet from available to pending, in
+// transaction tx along with the events of the pet, and returns the pet reserved.
+func reserveStock(tx model.Storage, emit emitter, order *model.Order) (*model.Pet, error) {
+	var reserved *model.Pet
+	_, err := changePetIn(tx, emit, order.PetID, func() error {
+		pet, err := tx.ReserveStockByPetID(order.PetID, int64(order.Quantity))
+		reserved = pet
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reserved, nil
+}
+
+// releaseStock puts the quantity of order back into stock of its pet, or its single pet back to available, in
+// transaction tx along with the events of the pet.
+func releaseStock(tx model.Storage, emit emitter, order *model.Order) error {
+	_, err := changePetIn(tx, emit, order.PetID, func() error {
+		return tx.ReleaseStockByPetID(order.PetID, int64(order.Quantity))
+	})
+	return err
+}
+
+// UpdateOrderStatus moves an order to another status, if it is at version unless version is 0. Delivered orders
+// keep their status, as their quantity is out of stock for good.
+func (s storeService) UpdateOrderStatus(ctx context.Context, id int64, version int64, status string) (*model.Order, error) {
+	switch status {
+	case model.OrderStatusPlaced, model.OrderStatusApproved, model.OrderStatusDelivered:
+	default:
+		return nil, model.NewErrResponse(http.StatusBadRequest, "error", "invalid order status "+status)
+	}
+	var updated *model.Order
+	err := inTransaction(ctx, s.storage, s.publisher, func(tx model.Storage, emit emitter) error {
+		order, err := tx.RetrieveOrderByID(id)
+		if err != nil {
+			return err
+		}
+		if order.Status == status {
+			updated = order
+			return nil
+		}
+		if order.Status == model.OrderStatusDelivered {
+			return model.NewErrResponse(http.StatusConflict, "error", "order has been delivered")
+		}
+		if version == 0 {
+			version = order.Version
+		}
+		updated, err = tx.UpdateOrderStatusByID(id, version, status)
+		if err != nil {
+			return err
+		}
+		return emit(orderStatusEvents(order, updated)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s storeService) FindEventsAfter(ctx context.Context, after int64, limit int64) ([]*model.Event, error) {
+	return s.storage.FindEventsAfter(after, limit)
+}
+
+func (s storeService) FindOrders(ctx context.Context, filter model.OrderFilter) ([]*model.Order, int64, error) {
+	return s.storage.FindOrders(filter)
+}
+
+// FindOrdersByUserIDs finds all orders of users, latest ship date first.
+func (s storeService) FindOrdersByUserIDs(ctx context.Context, userIDs []int64) ([]*model.Order, error) {
+	return s.storage.FindOrdersByUserIDs(userIDs)
 }
@@ -1,3 +1,117 @@
 package service
 
 // this is to test store service
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestPlaceOrderTakesSinglePetOnce(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.pets[1] = model.Pet{ID: 1, Name: "rex", Status: model.PetStatusAvailable, Version: 1}
+	s := NewStoreService(log.NewNopLogger(), storage, nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			_, err := s.PlaceOrder(context.Background(), &model.Order{ID: id, PetID: 1, Status: model.OrderStatusPlaced})
+			errs <- err
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+
+	placed := 0
+	for err := range errs {
+		if err == nil {
+			placed++
+		} else {
+			assert.Equal(t, model.ErrOutOfStock, err)
+		}
+	}
+	assert.Equal(t, 1, placed)
+	assert.Equal(t, model.PetStatusPending, storage.pets[1].Status)
+
+	// cancelling the order makes the pet available again
+	for id, order := range storage.orders {
+		assert.NoError(t, s.DeleteOrderByID(context.Background(), id, false))
+		assert.Equal(t, int64(1), order.PetID)
+	}
+	assert.Equal(t, model.PetStatusAvailable, storage.pets[1].Status)
+
+	storage.pets[2] = model.Pet{ID: 2, Name: "tom", Status: model.PetStatusSold, Version: 1}
+	_, err := s.PlaceOrder(context.Background(), &model.Order{ID: 10, PetID: 2})
+	assert.Equal(t, model.ErrOutOfStock, err)
+}
+
+func TestDeleteOrderKeepsPetItDidNotTake(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.pets[1] = model.Pet{ID: 1, Name: "rex", Status: model.PetStatusPending, Version: 1}
+	storage.orders[1] = model.Order{ID: 1, PetID: 1, Quantity: 1, Status: model.OrderStatusPlaced, Version: 1}
+	s := NewStoreService(log.NewNopLogger(), storage, nil)
+
+	// the pet has been set pending by hand, not by the order
+	assert.NoError(t, s.DeleteOrderByID(context.Background(), 1, false))
+	assert.Equal(t, model.PetStatusPending, storage.pets[1].Status)
+	_, err := s.RestoreOrderByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PetStatusPending, storage.pets[1].Status)
+
+	storage.pets[1] = model.Pet{ID: 1, Name: "rex", Status: model.PetStatusAvailable, Version: 2}
+	placed, err := s.PlaceOrder(context.Background(), &model.Order{ID: 2, PetID: 1})
+	assert.NoError(t, err)
+	assert.True(t, placed.PetHeld)
+	assert.NoError(t, s.DeleteOrderByID(context.Background(), 2, false))
+	assert.Equal(t, model.PetStatusAvailable, storage.pets[1].Status)
+	_, err = s.RestoreOrderByID(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PetStatusPending, storage.pets[1].Status)
+}
+
+func TestOrdersEmitPetStatusChanges(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.pets[1] = model.Pet{ID: 1, Name: "rex", Status: model.PetStatusAvailable, Version: 1}
+	s := NewStoreService(log.NewNopLogger(), storage, nil)
+
+	statusChanges := func() []*model.Event {
+		events, err := storage.FindEventsAfter(0, 100)
+		assert.NoError(t, err)
+		var changes []*model.Event
+		for _, e := range events {
+			if e.Type == model.EventPetStatusChanged {
+				changes = append(changes, e)
+			}
+		}
+		return changes
+	}
+
+	_, err := s.PlaceOrder(context.Background(), &model.Order{ID: 1, PetID: 1})
+	assert.NoError(t, err)
+	changes := statusChanges()
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, model.PetStatusAvailable, changes[0].PreviousStatus)
+		assert.Equal(t, model.PetStatusPending, changes[0].Pet.Status)
+	}
+
+	assert.NoError(t, s.DeleteOrderByID(context.Background(), 1, false))
+	changes = statusChanges()
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, model.PetStatusPending, changes[1].PreviousStatus)
+		assert.Equal(t, model.PetStatusAvailable, changes[1].Pet.Status)
+	}
+
+	_, err = s.RestoreOrderByID(context.Background(), 1)
+	assert.NoError(t, err)
+	changes = statusChanges()
+	if assert.Len(t, changes, 3) {
+		assert.Equal(t, model.PetStatusAvailable, changes[2].PreviousStatus)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Publisher is told about events once the changes they tell about are committed.
+type Publisher interface {
+	Publish(events ...*model.Event)
+}
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is dropped.
+const subscriberBuffer = 256
+
+// Broker fans events out to subscribers within the process. Subscribers which don't keep up are dropped, their
+// channel being closed, and are expected to catch up from the outbox.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan *model.Event]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[chan *model.Event]bool{}}
+}
+
+func (b *Broker) Publish(events ...*model.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		for _, e := range events {
+			select {
+			case ch <- e:
+			default:
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+			if !b.subscribers[ch] {
+				break
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel of events published from now on, and the function to call when done with it.
+func (b *Broker) Subscribe() (<-chan *model.Event, func()) {
+	ch := make(chan *model.Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = true
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.subscribers[ch] {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// RelayEvents publishes events watched in the storage until stop is closed. After watching fails, it waits
+// retry and publishes the events appended meanwhile from the outbox, before watching again.
+func RelayEvents(logger log.Logger, storage model.Storage, watcher model.EventWatcher, publisher Publisher,
+	retry time.Duration, stop <-chan struct{}) {
+	var last int64
+	for {
+		err := watcher.WatchEvents(stop, func(e *model.Event) {
+			last = e.ID
+			publisher.Publish(e)
+		})
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		_ = level.Error(logger).Log("msg", "watch events", "err", err)
+		select {
+		case <-stop:
+			return
+		case <-time.After(retry):
+		}
+		for last > 0 {
+			events, err := storage.FindEventsAfter(last, 100)
+			if err != nil {
+				_ = level.Error(logger).Log("msg", "catch up events", "err", err)
+				break
+			}
+			if len(events) == 0 {
+				break
+			}
+			publisher.Publish(events...)
+			last = events[len(events)-1].ID
+		}
+	}
+}
+
+// writeSSE writes a server-sent event of type event with data as JSON, and id unless it is 0.
+func writeSSE(w io.Writer, id int64, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	if id > 0 {
+		fmt.Fprintf(&sb, "id: %d\n", id)
+	}
+	fmt.Fprintf(&sb, "event: %s\ndata: %s\n\n", event, b)
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,112 @@
+package service
+
+// This is to test publishing events and streaming them to the store dashboard
+
+import (
+	"bufio"
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamStore serves inventory counts and stored events, other StoreService methods are not implemented.
+type streamStore struct {
+	StoreService
+	events []*model.Event
+}
+
+func (s *streamStore) GetInventoriesByStatus(ctx context.Context) (map[string]int64, error) {
+	return map[string]int64{"available": int64(len(s.events))}, nil
+}
+
+func (s *streamStore) FindEventsAfter(ctx context.Context, after int64, limit int64) ([]*model.Event, error) {
+	var events []*model.Event
+	for _, e := range s.events {
+		if e.ID > after {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	events, unsubscribe := b.Subscribe()
+	slow, _ := b.Subscribe()
+
+	e := &model.Event{ID: 1, Type: model.EventPetCreated}
+	b.Publish(e)
+	assert.Equal(t, e, <-events)
+
+	// subscribers not keeping up are dropped
+	for i := 0; i < subscriberBuffer; i++ {
+		b.Publish(e)
+		<-events
+	}
+	_, ok := <-slow
+	for ok {
+		_, ok = <-slow
+	}
+	unsubscribe()
+	_, ok = <-events
+	assert.False(t, ok)
+	b.Publish(e)
+}
+
+func TestStoreEvents(t *testing.T) {
+	store := &streamStore{events: []*model.Event{
+		{ID: 1, Type: model.EventPetCreated},
+		{ID: 2, Type: model.EventPetStatusChanged, PreviousStatus: "available", Pet: &model.Pet{ID: 1, Status: "sold"}},
+		{ID: 3, Type: model.EventOrderPlaced},
+	}}
+	broker := NewBroker()
+	routes := SetupRoutes(&Services{StoreService: store, Events: broker}, Options{AdminAPIKey: "key"}, log.NewNopLogger())
+	server := httptest.NewServer(routes)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v2/store/events", nil)
+	req.Header.Set("api_key", "key")
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(res.Body)
+	next := func() []string {
+		var event []string
+		for lines.Scan() && lines.Text() != "" {
+			event = append(event, lines.Text())
+		}
+		return event
+	}
+
+	// missed events are replayed, then counts as of the last of them
+	status := next()
+	assert.Equal(t, "id: 2", status[0])
+	assert.Equal(t, "event: pet.status_changed", status[1])
+	assert.True(t, strings.Contains(status[2], `"previousStatus":"available"`))
+	assert.Equal(t, []string{"id: 3", "event: inventory", `data: {"available":3}`}, next())
+
+	// published events are pushed, and those sent already are skipped
+	store.events = append(store.events, &model.Event{ID: 4, Type: model.EventPetStatusChanged, Pet: &model.Pet{ID: 1}})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		broker.Publish(store.events[2], store.events[3])
+	}()
+	assert.Equal(t, []string{"id: 4", "event: pet.status_changed"}, next()[:2])
+	assert.Equal(t, []string{"id: 4", "event: inventory", `data: {"available":4}`}, next())
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/v2/store/events?lastEventId=x", nil)
+	req.Header.Set("api_key", "key")
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
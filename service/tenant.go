@@ -0,0 +1,131 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Tenant is one of the stores served by a process, its documents kept in a database of its own.
+type Tenant struct {
+	// Name the tenant is told by in requests
+	Name string `json:"name"`
+	// Database of its documents, defaults to the name
+	Database string `json:"database"`
+	// Key in api_key header authenticating the caller as admin of this tenant only
+	AdminAPIKey string `json:"adminApiKey"`
+	// Settings overriding those of the process when set
+	RateLimit  int    `json:"rateLimit"`
+	PublicURI  string `json:"publicUri"`
+	PublicPath string `json:"publicPath"`
+}
+
+// TenantResolution tells where requests name their tenant.
+type TenantResolution string
+
+const (
+	// X-Tenant-ID header
+	TenantFromHeader TenantResolution = "header"
+	// First label of the host, e.g. downtown.petstore.example.com
+	TenantFromSubdomain TenantResolution = "subdomain"
+	// First segment of the path, e.g. /downtown/v2/pet/1
+	TenantFromPath TenantResolution = "path"
+)
+
+// TenantHeader names the tenant of a request resolved from header.
+const TenantHeader = "X-Tenant-ID"
+
+// tenant names are valid host labels and path segments
+var tenantName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// LoadTenants reads a JSON array of tenants, defaulting their databases to their names. Names, databases and admin
+// keys must be unique so that no two tenants share documents or admins.
+func LoadTenants(r io.Reader) ([]*Tenant, error) {
+	var tenants []*Tenant
+	if err := json.NewDecoder(r).Decode(&tenants); err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants")
+	}
+	names, databases, keys := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, t := range tenants {
+		if t == nil || !tenantName.MatchString(t.Name) {
+			return nil, fmt.Errorf("invalid tenant name, lower case letters, digits and dashes expected")
+		}
+		if t.Database == "" {
+			t.Database = t.Name
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("tenant %s given twice", t.Name)
+		}
+		if databases[t.Database] {
+			return nil, fmt.Errorf("tenant %s: database %s is shared with another tenant", t.Name, t.Database)
+		}
+		if t.AdminAPIKey == "" {
+			return nil, fmt.Errorf("tenant %s has no adminApiKey", t.Name)
+		}
+		if keys[t.AdminAPIKey] {
+			return nil, fmt.Errorf("tenant %s: adminApiKey is shared with another tenant", t.Name)
+		}
+		names[t.Name], databases[t.Database], keys[t.AdminAPIKey] = true, true, true
+	}
+	return tenants, nil
+}
+
+// NewTenantRouter serves each request with the handler of its tenant, by name, resolving the tenant as resolution
+// says. Requests of unknown tenants, or naming none, are not found. Paths are served without the tenant prefix when
+// resolved from path.
+func NewTenantRouter(resolution TenantResolution, handlers map[string]http.Handler) (http.Handler, error) {
+	var resolve func(r *http.Request) (string, *http.Request)
+	switch resolution {
+	case TenantFromHeader:
+		resolve = func(r *http.Request) (string, *http.Request) {
+			return strings.ToLower(r.Header.Get(TenantHeader)), r
+		}
+	case TenantFromSubdomain:
+		resolve = func(r *http.Request) (string, *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			i := strings.Index(host, ".")
+			if i < 0 {
+				return "", r
+			}
+			return strings.ToLower(host[:i]), r
+		}
+	case TenantFromPath:
+		resolve = func(r *http.Request) (string, *http.Request) {
+			path := strings.TrimPrefix(r.URL.Path, "/")
+			i := strings.Index(path, "/")
+			if i < 0 {
+				return "", r
+			}
+			name := path[:i]
+			u := *r.URL
+			u.Path = path[i:]
+			u.RawPath = ""
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = &u
+			return name, r2
+		}
+	default:
+		return nil, fmt.Errorf("unknown tenant resolution %s, header, subdomain or path expected", resolution)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, r := resolve(r)
+		h, ok := handlers[name]
+		if !ok || name == "" {
+			encodeError(r.Context(), model.NewErrResponse(http.StatusNotFound, "error", "unknown tenant"), w)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}), nil
+}
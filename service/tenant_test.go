@@ -0,0 +1,156 @@
+package service
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTenants(t *testing.T) {
+	tenants, err := LoadTenants(strings.NewReader(
+		`[{"name":"downtown","adminApiKey":"downtown-key"},
+		  {"name":"uptown","database":"petstore-uptown","adminApiKey":"uptown-key","rateLimit":10}]`))
+	assert.NoError(t, err)
+	if assert.Len(t, tenants, 2) {
+		assert.Equal(t, "downtown", tenants[0].Database)
+		assert.Equal(t, "petstore-uptown", tenants[1].Database)
+		assert.Equal(t, 10, tenants[1].RateLimit)
+	}
+
+	for _, invalid := range []string{
+		`[]`,
+		`[{"database":"downtown","adminApiKey":"a"}]`,
+		`[{"name":"Down Town","adminApiKey":"a"}]`,
+		`[{"name":"downtown","adminApiKey":"a"},{"name":"downtown","database":"other","adminApiKey":"b"}]`,
+		`[{"name":"downtown","database":"shared","adminApiKey":"a"},{"name":"uptown","database":"shared","adminApiKey":"b"}]`,
+		`[{"name":"downtown","adminApiKey":"a"},{"name":"uptown","database":"downtown","adminApiKey":"b"}]`,
+		`[{"name":"downtown"}]`,
+		`[{"name":"downtown","adminApiKey":"key"},{"name":"uptown","adminApiKey":"key"}]`,
+	} {
+		_, err = LoadTenants(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestTenantRouter(t *testing.T) {
+	images, err := ioutil.TempDir("", "petstore-images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(images)
+	newHandlers := func() map[string]http.Handler {
+		return map[string]http.Handler{
+			"downtown": SetupRoutes(newMemoryServices(newMemoryStorage(), images),
+				Options{AdminAPIKey: "downtown-key"}, log.NewNopLogger()),
+			"uptown": SetupRoutes(newMemoryServices(newMemoryStorage(), images),
+				Options{AdminAPIKey: "uptown-key", RateLimit: 10}, log.NewNopLogger()),
+		}
+	}
+	_, err = NewTenantRouter("cookie", newHandlers())
+	assert.Error(t, err)
+
+	for _, resolution := range []TenantResolution{TenantFromHeader, TenantFromSubdomain, TenantFromPath} {
+		router, err := NewTenantRouter(resolution, newHandlers())
+		if !assert.NoError(t, err) {
+			continue
+		}
+		call := func(tenant, method, path, apiKey, body string) *httptest.ResponseRecorder {
+			target := "http://petstore.test" + path
+			switch resolution {
+			case TenantFromSubdomain:
+				target = "http://" + tenant + ".petstore.test:8080" + path
+			case TenantFromPath:
+				target = "http://petstore.test/" + tenant + path
+			}
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			if resolution == TenantFromHeader && tenant != "" {
+				req.Header.Set(TenantHeader, tenant)
+			}
+			if body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if apiKey != "" {
+				req.Header.Set("api_key", apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// documents of a tenant are not seen by others
+		w := call("downtown", http.MethodPost, "/v2/pet", "downtown-key", `{"id":1,"name":"kitten","status":"available"}`)
+		assert.Equal(t, http.StatusOK, w.Code, resolution)
+		assert.Equal(t, http.StatusOK, call("downtown", http.MethodGet, "/v2/pet/1", "", "").Code, resolution)
+		assert.Equal(t, http.StatusNotFound, call("uptown", http.MethodGet, "/v2/pet/1", "", "").Code, resolution)
+
+		// neither are admin keys and sessions
+		assert.Equal(t, http.StatusUnauthorized,
+			call("uptown", http.MethodPost, "/v2/pet", "downtown-key", `{"id":2,"name":"puppy"}`).Code, resolution)
+		w = call("downtown", http.MethodPost, "/v2/user", "", `{"id":1,"username":"alice","password":"secret"}`)
+		assert.Equal(t, http.StatusOK, w.Code, resolution)
+		w = call("downtown", http.MethodPost, "/v2/user/login", "", `{"username":"alice","password":"secret"}`)
+		assert.Equal(t, http.StatusOK, w.Code, resolution)
+		assert.Equal(t, "100", w.Header().Get("X-Rate-Limit"))
+		token := strings.Trim(strings.TrimSpace(w.Body.String()), `"`)
+		assert.Equal(t, http.StatusOK, call("downtown", http.MethodGet, "/v2/user/alice", token, "").Code, resolution)
+		assert.Equal(t, http.StatusUnauthorized, call("uptown", http.MethodGet, "/v2/user/alice", token, "").Code, resolution)
+
+		// settings are the tenant's
+		w = call("uptown", http.MethodPost, "/v2/user", "", `{"id":1,"username":"alice","password":"other"}`)
+		assert.Equal(t, http.StatusOK, w.Code, resolution)
+		w = call("uptown", http.MethodPost, "/v2/user/login", "", `{"username":"alice","password":"other"}`)
+		assert.Equal(t, http.StatusOK, w.Code, resolution)
+		assert.Equal(t, "10", w.Header().Get("X-Rate-Limit"))
+
+		// requests of no or unknown tenants are not found
+		assert.Equal(t, http.StatusNotFound, call("", http.MethodGet, "/v2/pet/1", "", "").Code, resolution)
+		w = call("midtown", http.MethodGet, "/v2/pet/1", "", "")
+		assert.Equal(t, http.StatusNotFound, w.Code, resolution)
+		assert.Contains(t, w.Body.String(), "unknown tenant")
+	}
+}
+
+func TestTenantImages(t *testing.T) {
+	public, err := ioutil.TempDir("", "petstore-public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(public)
+	handlers := map[string]http.Handler{}
+	for _, name := range []string{"downtown", "uptown"} {
+		images := filepath.Join(public, name)
+		if err = os.MkdirAll(images, 0755); err != nil {
+			t.Fatal(err)
+		}
+		handlers[name] = SetupRoutes(newMemoryServices(newMemoryStorage(), images),
+			Options{AdminAPIKey: name + "-key", PublicURI: "/images/" + name, PublicPath: images}, log.NewNopLogger())
+	}
+	if err = ioutil.WriteFile(filepath.Join(public, "uptown", "cat.jpg"), []byte("cat"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewTenantRouter(TenantFromHeader, handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(tenant, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://petstore.test"+path, nil)
+		req.Header.Set(TenantHeader, tenant)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("uptown", "/images/uptown/cat.jpg")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "cat", w.Body.String())
+	assert.Equal(t, http.StatusNotFound, get("downtown", "/images/uptown/cat.jpg").Code)
+	assert.Equal(t, http.StatusNotFound, get("downtown", "/images/downtown/cat.jpg").Code)
+	assert.Equal(t, http.StatusNotFound, get("downtown", "/images/downtown/../uptown/cat.jpg").Code)
+	assert.Equal(t, http.StatusNotFound, get("downtown", "/images/downtown/%2e%2e/uptown/cat.jpg").Code)
+}
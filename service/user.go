@@ -2,76 +2,204 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/cooljeffrey/petstore/model"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"net/http"
+	"time"
 )
 
+var (
+	ErrInvalidCredentials = errors.New("invalid username and password combination")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+)
+
+// LoginThrottledError is returned by Login when the username has to wait before trying again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed login attempts, account locked for %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter)
+}
+
 type UserService interface {
 	CreateUser(ctx context.Context, user *model.User) error
 	CreateUsersWithArray(ctx context.Context, array []*model.User) error
 	CreateUsersWithList(ctx context.Context, list []*model.User) error
-	Login(ctx context.Context, username, password string) error
+	Login(ctx context.Context, username, password string) (*model.Session, error)
 	Logout(ctx context.Context) error
+	Authenticate(ctx context.Context, token string) (*Principal, error)
 	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	GetUsersByIDs(ctx context.Context, ids []int64) ([]*model.User, error)
 	UpdateUserByUsername(ctx context.Context, username string, user *model.User) error
-	DeleteUserByUsername(ctx context.Context, username string) error
+	PatchUser(ctx context.Context, username string, version int64, update *model.Update) (*model.User, error)
+	DeleteUserByUsername(ctx context.Context, username string, hard bool) error
+	RestoreUser(ctx context.Context, username string) (*model.User, error)
+	UnlockUser(ctx context.Context, username string) error
 }
 
 type userService struct {
-	logger  log.Logger
-	storage model.Storage
+	logger     log.Logger
+	storage    model.Storage
+	policy     model.LockoutPolicy
+	sessionTTL time.Duration
+	now        func() time.Time
 }
 
-func NewUserService(logger log.Logger, storage model.Storage) UserService {
+func NewUserService(logger log.Logger, storage model.Storage, policy model.LockoutPolicy, sessionTTL time.Duration) UserService {
 	return &userService{
-		logger:  logger,
-		storage: storage,
+		logger:     logger,
+		storage:    storage,
+		policy:     policy,
+		sessionTTL: sessionTTL,
+		now:        time.Now,
 	}
 }
 
 func (s userService) CreateUser(ctx context.Context, user *model.User) error {
-	return s.storage.CreateUser(user)
+	return storageFor(ctx, s.storage).CreateUser(user)
 }
 
 func (s userService) CreateUsersWithArray(ctx context.Context, array []*model.User) error {
-	return s.storage.CreateManyUsers(array)
+	return storageFor(ctx, s.storage).CreateManyUsers(array)
 }
 
 func (s userService) CreateUsersWithList(ctx context.Context, list []*model.User) error {
-	return s.storage.CreateManyUsers(list)
+	return storageFor(ctx, s.storage).CreateManyUsers(list)
 }
 
-func (s userService) Login(ctx context.Context, username, password string) error {
-	user, err := s.storage.RetrieveUserByUsername(username)
+// Login counts the attempt as failed before checking the password, so that concurrent attempts are throttled as if
+// made one after another, and forgets failed attempts once the password matches.
+func (s userService) Login(ctx context.Context, username, password string) (*model.Session, error) {
+	now := s.now().UTC()
+	attempts, err := s.reserveLoginAttempt(username, now)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if user.Password == password {
-		// TODO implement token authentiation and authorization ?
-		return nil
-	} else {
-		return errors.New("invalid username and password combination")
+
+	user, err := s.storage.RetrieveUserByUsername(username)
+	if err != nil || password == "" || user.Password != password {
+		if s.policy.Locked(attempts, now) {
+			_ = level.Warn(s.logger).Log("msg", "login locked", "username", username, "failures", attempts.Failures)
+		}
+		return nil, ErrInvalidCredentials
+	}
+	if err = s.storage.DeleteLoginAttemptsByUsername(username); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return nil, err
+	}
+	session := model.NewSession(hex.EncodeToString(b), user, now.Add(s.sessionTTL))
+	if err = s.storage.CreateSession(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// reserveLoginAttempt records one more failed attempt of username unless it has to wait, starting over once a past
+// lockout is over, and returns the attempts recorded. It tries again when another attempt was recorded meanwhile.
+func (s userService) reserveLoginAttempt(username string, now time.Time) (*model.LoginAttempts, error) {
+	for {
+		seen, err := s.storage.RetrieveLoginAttemptsByUsername(username)
+		if err != nil {
+			return nil, err
+		}
+		attempts := &model.LoginAttempts{Username: username, Failures: seen.Failures + 1, LastFailureAt: now}
+		if s.policy.Expired(seen, now) {
+			attempts.Failures = 1
+		} else if wait := s.policy.RetryAfter(seen, now); wait > 0 {
+			return nil, &LoginThrottledError{RetryAfter: wait, Locked: s.policy.Locked(seen, now)}
+		}
+		switch err = s.storage.ReplaceLoginAttempts(seen, attempts); err {
+		case nil:
+			return attempts, nil
+		case model.ErrVersionMismatch:
+			continue
+		default:
+			return nil, err
+		}
 	}
 }
 
 func (s userService) Logout(ctx context.Context) error {
-	// TODO implement token authentiation and authorization ?
-	return nil
+	p := PrincipalFromContext(ctx)
+	if p == nil || p.Token == "" {
+		return nil
+	}
+	return s.storage.DeleteSessionByToken(p.Token)
+}
+
+func (s userService) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	session, err := s.storage.RetrieveSessionByToken(token)
+	if err != nil || session == nil {
+		return nil, ErrInvalidToken
+	}
+	if session.Expired(s.now().UTC()) {
+		_ = s.storage.DeleteSessionByToken(token)
+		return nil, ErrInvalidToken
+	}
+	// roles and usernames may have changed since login, deleted users lose access
+	user, err := s.storage.RetrieveUserByID(session.UserID)
+	if err != nil || user == nil {
+		return nil, ErrInvalidToken
+	}
+	return &Principal{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.RoleOrDefault(),
+		Token:    session.Token,
+	}, nil
 }
 
 func (s userService) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
 	return s.storage.RetrieveUserByUsername(username)
 }
 
+// GetUsersByIDs finds users of ids, leaving out those not found.
+func (s userService) GetUsersByIDs(ctx context.Context, ids []int64) ([]*model.User, error) {
+	return s.storage.RetrieveUsersByIDs(ids)
+}
+
 func (s userService) UpdateUserByUsername(ctx context.Context, username string, user *model.User) error {
-	_, err := s.storage.UpdateUserByUsername(username, user)
+	_, err := storageFor(ctx, s.storage).UpdateUserByUsername(username, user)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s userService) DeleteUserByUsername(ctx context.Context, username string) error {
-	return s.storage.DeleteUserByUsername(username)
+// PatchUser applies a partial update to a user, if it is at version unless version is 0.
+// Only callers managing users can change roles.
+func (s userService) PatchUser(ctx context.Context, username string, version int64, update *model.Update) (*model.User, error) {
+	if err := model.ValidateUserUpdate(update); err != nil {
+		return nil, model.NewErrResponse(http.StatusBadRequest, "error", err.Error())
+	}
+	if update.Touches("role") && !PrincipalFromContext(ctx).Can(PermissionManageUsers) {
+		return nil, errForbidden
+	}
+	return storageFor(ctx, s.storage).PatchUserByUsername(username, version, update)
+}
+
+func (s userService) DeleteUserByUsername(ctx context.Context, username string, hard bool) error {
+	return storageFor(ctx, s.storage).DeleteUserByUsername(username, hard)
+}
+
+func (s userService) RestoreUser(ctx context.Context, username string) (*model.User, error) {
+	return storageFor(ctx, s.storage).RestoreUserByUsername(username)
+}
+
+func (s userService) UnlockUser(ctx context.Context, username string) error {
+	return s.storage.DeleteLoginAttemptsByUsername(username)
 }
@@ -1,3 +1,70 @@
 package service
 
 // this is to test user service
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottlesConcurrentAttempts(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.users[1] = model.User{ID: 1, Username: "alice", Password: "secret", Version: 1}
+	s := NewUserService(log.NewNopLogger(), storage, model.DefaultLockoutPolicy, time.Hour)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Login(context.Background(), "alice", "guess")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// only one guess is checked, the others wait for it
+	checked := 0
+	for err := range errs {
+		if err == ErrInvalidCredentials {
+			checked++
+		} else {
+			assert.IsType(t, &LoginThrottledError{}, err)
+		}
+	}
+	assert.Equal(t, 1, checked)
+	assert.Equal(t, int32(1), storage.attempts["alice"].Failures)
+
+	_, err := s.Login(context.Background(), "alice", "secret")
+	assert.IsType(t, &LoginThrottledError{}, err)
+}
+
+func TestLoginForgetsFailuresOnSuccess(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.users[1] = model.User{ID: 1, Username: "alice", Password: "secret", Version: 1}
+	s := NewUserService(log.NewNopLogger(), storage, model.LockoutPolicy{MaxFailures: 2, LockoutDuration: time.Minute}, time.Hour)
+
+	_, err := s.Login(context.Background(), "alice", "guess")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	session, err := s.Login(context.Background(), "alice", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), session.UserID)
+	_, ok := storage.attempts["alice"]
+	assert.False(t, ok)
+
+	_, err = s.Login(context.Background(), "alice", "guess")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.Login(context.Background(), "alice", "guess")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = s.Login(context.Background(), "alice", "secret")
+	if assert.IsType(t, &LoginThrottledError{}, err) {
+		assert.True(t, err.(*LoginThrottledError).Locked)
+	}
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error)
+	FindWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	FindDeliveries(ctx context.Context, webhookID int64, status string) ([]*model.Delivery, error)
+	RetryDelivery(ctx context.Context, webhookID int64, deliveryID string) (*model.Delivery, error)
+}
+
+// maxDeliveries bounds the deliveries listed at once.
+const maxDeliveries int64 = 100
+
+type webhookService struct {
+	logger   log.Logger
+	webhooks model.WebhookStore
+	now      func() time.Time
+}
+
+func NewWebhookService(logger log.Logger, webhooks model.WebhookStore) WebhookService {
+	return &webhookService{
+		logger:   logger,
+		webhooks: webhooks,
+		now:      time.Now,
+	}
+}
+
+// CreateWebhook subscribes a webhook to events from now on, with a random secret unless one is given.
+func (s webhookService) CreateWebhook(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error) {
+	if err := webhook.Validate(); err != nil {
+		return nil, model.NewErrResponse(http.StatusBadRequest, "error", err.Error())
+	}
+	if webhook.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		webhook.Secret = hex.EncodeToString(b)
+	}
+	webhook.CreatedAt = s.now().UTC()
+	if err := s.webhooks.CreateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// FindWebhooks returns all webhooks, without their secrets.
+func (s webhookService) FindWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	webhooks, err := s.webhooks.FindWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+	return webhooks, nil
+}
+
+// GetWebhook returns a webhook, without its secret.
+func (s webhookService) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	webhook, err := s.webhooks.RetrieveWebhookByID(id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s webhookService) DeleteWebhook(ctx context.Context, id int64) error {
+	return s.webhooks.DeleteWebhookByID(id)
+}
+
+func (s webhookService) FindDeliveries(ctx context.Context, webhookID int64, status string) ([]*model.Delivery, error) {
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		return nil, model.NewErrResponse(http.StatusBadRequest, "error", "invalid delivery status "+status)
+	}
+	if _, err := s.webhooks.RetrieveWebhookByID(webhookID); err != nil {
+		return nil, err
+	}
+	return s.webhooks.FindDeliveries(webhookID, status, maxDeliveries)
+}
+
+// RetryDelivery takes a dead delivery off the dead-letter list, to be attempted again right away.
+func (s webhookService) RetryDelivery(ctx context.Context, webhookID int64, deliveryID string) (*model.Delivery, error) {
+	delivery, err := s.webhooks.RetrieveDeliveryByID(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookID != webhookID {
+		return nil, model.ErrNotFound
+	}
+	if delivery.Status != model.DeliveryDead {
+		return nil, model.NewErrResponse(http.StatusConflict, "error", "only dead deliveries can be retried")
+	}
+	delivery.Status = model.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = s.now().UTC()
+	if err = s.webhooks.UpdateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+const (
+	// Type of the event delivered
+	HeaderWebhookEvent = "X-Petstore-Event"
+	// Id of the delivery, the same for all attempts
+	HeaderWebhookDelivery = "X-Petstore-Delivery"
+	// Signature of the delivery, t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>" keyed by the secret>
+	HeaderWebhookSignature = "X-Petstore-Signature"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// SignWebhook returns the signature header of body delivered at unix time timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, webhookMAC(secret, timestamp, body))
+}
+
+// VerifyWebhook checks the signature header of body, and that it has been signed no longer than tolerance before
+// now, so that receivers can refuse forged and replayed deliveries.
+func VerifyWebhook(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var mac string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return ErrInvalidSignature
+		}
+		switch kv[0] {
+		case "t":
+			t, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			timestamp = t
+		case "v1":
+			mac = kv[1]
+		}
+	}
+	if timestamp == 0 || mac == "" {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(timestamp, 0)); d > tolerance || d < -tolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(mac), []byte(webhookMAC(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func webhookMAC(secret string, timestamp int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(h, "%d.", timestamp)
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DeliveryPolicy tells how often deliveries are attempted. Attempts after a failure wait BaseDelay, doubled on each
+// failure up to MaxDelay, and deliveries failing MaxAttempts times are dead.
+type DeliveryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// How long a delivery attempt is claimed by a dispatcher, before others can make it
+	Lease time.Duration
+	// How long events are left before being dispatched, for concurrent changes to append the earlier ones on servers
+	// without transactions, where ids are not taken in the order changes commit
+	Settle time.Duration
+}
+
+var DefaultDeliveryPolicy = DeliveryPolicy{
+	MaxAttempts: 8,
+	BaseDelay:   10 * time.Second,
+	MaxDelay:    time.Hour,
+	Lease:       time.Minute,
+	Settle:      2 * time.Second,
+}
+
+// Delay returns how long to wait after attempt number attempts has failed.
+func (p DeliveryPolicy) Delay(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Name of the position of the dispatcher in the outbox
+const dispatcherCursor = "webhooks"
+
+// dispatchBatch bounds the events and deliveries handled at once.
+const dispatchBatch int64 = 100
+
+// Dispatcher delivers events of the outbox to the webhooks subscribed to them. Several dispatchers can run against
+// a database : deliveries are queued once whichever queues them, and each attempt is claimed by one dispatcher.
+type Dispatcher struct {
+	logger   log.Logger
+	storage  model.Storage
+	webhooks model.WebhookStore
+	policy   DeliveryPolicy
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewDispatcher(logger log.Logger, storage model.Storage, webhooks model.WebhookStore, policy DeliveryPolicy) *Dispatcher {
+	return &Dispatcher{
+		logger:   logger,
+		storage:  storage,
+		webhooks: webhooks,
+		policy:   policy,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// Dispatch makes one pass, queueing deliveries of new events and attempting those due.
+func (d *Dispatcher) Dispatch() error {
+	if err := d.enqueue(); err != nil {
+		return err
+	}
+	return d.deliver()
+}
+
+// enqueue queues deliveries of events appended since the last pass, to the webhooks subscribed to them when
+// they were appended.
+func (d *Dispatcher) enqueue() error {
+	cursor, err := d.webhooks.RetrieveEventCursor(dispatcherCursor)
+	if err != nil {
+		return err
+	}
+	webhooks, err := d.webhooks.FindWebhooks()
+	if err != nil {
+		return err
+	}
+	now := d.now().UTC()
+	for {
+		events, err := d.storage.FindEventsAfter(cursor, dispatchBatch)
+		if err != nil {
+			return err
+		}
+		var deliveries []*model.Delivery
+		last := cursor
+		for _, e := range events {
+			if e.At.After(now.Add(-d.policy.Settle)) {
+				break
+			}
+			for _, w := range webhooks {
+				if w.Subscribes(e.Type) && !w.CreatedAt.After(e.At) {
+					deliveries = append(deliveries, model.NewDelivery(w, e, now))
+				}
+			}
+			last = e.ID
+		}
+		if last == cursor {
+			return nil
+		}
+		if err = d.webhooks.EnqueueDeliveries(deliveries); err != nil {
+			return err
+		}
+		if err = d.webhooks.UpdateEventCursor(dispatcherCursor, last); err != nil {
+			return err
+		}
+		cursor = last
+		if int64(len(events)) < dispatchBatch {
+			return nil
+		}
+	}
+}
+
+// deliver attempts deliveries due, once claimed, rescheduling failed ones or moving them to the dead-letter list.
+func (d *Dispatcher) deliver() error {
+	deliveries, err := d.webhooks.FindDueDeliveries(d.now().UTC(), dispatchBatch)
+	if err != nil {
+		return err
+	}
+	webhooks := map[int64]*model.Webhook{}
+	for _, due := range deliveries {
+		now := d.now().UTC()
+		delivery, err := d.webhooks.ClaimDelivery(due.ID, now, now.Add(d.policy.Lease))
+		if err == model.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		w, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			w, err = d.webhooks.RetrieveWebhookByID(delivery.WebhookID)
+			if err == model.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			webhooks[delivery.WebhookID] = w
+		}
+
+		delivery.Attempts++
+		now = d.now().UTC()
+		if err := d.post(w, delivery); err != nil {
+			delivery.LastError = err.Error()
+			delivery.NextAttemptAt = now.Add(d.policy.Delay(delivery.Attempts))
+			if delivery.Attempts >= d.policy.MaxAttempts {
+				delivery.Status = model.DeliveryDead
+			}
+			_ = level.Info(d.logger).Log("msg", "webhook delivery failed", "webhookId", w.ID, "delivery", delivery.ID,
+				"attempts", delivery.Attempts, "status", delivery.Status, "err", err)
+		} else {
+			delivery.Status = model.DeliveryDelivered
+			delivery.LastError = ""
+			delivery.DeliveredAt = &now
+		}
+		if err = d.webhooks.UpdateDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) post(webhook *model.Webhook, delivery *model.Delivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(HeaderWebhookEvent, delivery.Event.Type)
+	req.Header.Set(HeaderWebhookDelivery, delivery.ID)
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(webhook.Secret, d.now().Unix(), body))
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return nil
+}
+
+// Run dispatches every interval until stop is closed.
+func (d *Dispatcher) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.Dispatch(); err != nil {
+			_ = level.Error(d.logger).Log("msg", "dispatch webhooks", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package service
+
+// This is to test events and their delivery to webhooks, against local receivers
+
+import (
+	"context"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookStorage keeps events, webhooks and deliveries in memory, other Storage methods are not implemented.
+type webhookStorage struct {
+	model.Storage
+	events     []*model.Event
+	webhooks   map[int64]*model.Webhook
+	deliveries map[string]*model.Delivery
+	cursors    map[string]int64
+}
+
+func newWebhookStorage() *webhookStorage {
+	return &webhookStorage{
+		webhooks:   map[int64]*model.Webhook{},
+		deliveries: map[string]*model.Delivery{},
+		cursors:    map[string]int64{},
+	}
+}
+
+func (s *webhookStorage) AppendEvent(event *model.Event) error {
+	event.ID = int64(len(s.events) + 1)
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *webhookStorage) FindEventsAfter(after int64, limit int64) ([]*model.Event, error) {
+	var events []*model.Event
+	for _, e := range s.events {
+		if e.ID > after && int64(len(events)) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *webhookStorage) CreateWebhook(webhook *model.Webhook) error {
+	webhook.ID = int64(len(s.webhooks) + 1)
+	w := *webhook
+	s.webhooks[w.ID] = &w
+	return nil
+}
+
+func (s *webhookStorage) RetrieveWebhookByID(id int64) (*model.Webhook, error) {
+	w, ok := s.webhooks[id]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	webhook := *w
+	return &webhook, nil
+}
+
+func (s *webhookStorage) FindWebhooks() ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	for _, w := range s.webhooks {
+		webhook := *w
+		webhooks = append(webhooks, &webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (s *webhookStorage) DeleteWebhookByID(id int64) error {
+	if _, ok := s.webhooks[id]; !ok {
+		return model.ErrNotFound
+	}
+	delete(s.webhooks, id)
+	for key, d := range s.deliveries {
+		if d.WebhookID == id {
+			delete(s.deliveries, key)
+		}
+	}
+	return nil
+}
+
+func (s *webhookStorage) EnqueueDeliveries(deliveries []*model.Delivery) error {
+	for _, d := range deliveries {
+		if _, ok := s.deliveries[d.ID]; !ok {
+			delivery := *d
+			s.deliveries[d.ID] = &delivery
+		}
+	}
+	return nil
+}
+
+func (s *webhookStorage) FindDueDeliveries(now time.Time, limit int64) ([]*model.Delivery, error) {
+	var deliveries []*model.Delivery
+	for _, d := range s.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) {
+			delivery := *d
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *webhookStorage) ClaimDelivery(id string, now time.Time, until time.Time) (*model.Delivery, error) {
+	d, ok := s.deliveries[id]
+	if !ok || d.Status != model.DeliveryPending || d.NextAttemptAt.After(now) {
+		return nil, model.ErrNotFound
+	}
+	d.NextAttemptAt = until
+	delivery := *d
+	return &delivery, nil
+}
+
+func (s *webhookStorage) FindDeliveries(webhookID int64, status string, limit int64) ([]*model.Delivery, error) {
+	var deliveries []*model.Delivery
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			delivery := *d
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *webhookStorage) RetrieveDeliveryByID(id string) (*model.Delivery, error) {
+	d, ok := s.deliveries[id]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	delivery := *d
+	return &delivery, nil
+}
+
+func (s *webhookStorage) UpdateDelivery(delivery *model.Delivery) error {
+	d := *delivery
+	s.deliveries[d.ID] = &d
+	return nil
+}
+
+func (s *webhookStorage) RetrieveEventCursor(name string) (int64, error) {
+	return s.cursors[name], nil
+}
+
+func (s *webhookStorage) UpdateEventCursor(name string, position int64) error {
+	s.cursors[name] = position
+	return nil
+}
+
+// receiver records deliveries with a valid signature, and answers status to all of them.
+type receiver struct {
+	sync.Mutex
+	secret string
+	status int
+	events []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.Lock()
+	defer rc.Unlock()
+	if VerifyWebhook(rc.secret, r.Header.Get(HeaderWebhookSignature), body, time.Hour, time.Now()) == nil {
+		rc.events = append(rc.events, r.Header.Get(HeaderWebhookEvent))
+	}
+	w.WriteHeader(rc.status)
+}
+
+func TestDispatcher(t *testing.T) {
+	storage := newWebhookStorage()
+	now := time.Now().UTC()
+	webhooks := NewWebhookService(log.NewNopLogger(), storage).(*webhookService)
+	webhooks.now = func() time.Time { return now.Add(-time.Minute) }
+
+	ok := &receiver{secret: "s3cret", status: http.StatusNoContent}
+	okServer := httptest.NewServer(ok)
+	defer okServer.Close()
+	failing := &receiver{secret: "other", status: http.StatusInternalServerError}
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+
+	sold, err := webhooks.CreateWebhook(context.Background(), &model.Webhook{
+		URL: okServer.URL, Events: []string{model.EventPetStatusChanged}, Secret: "s3cret"})
+	assert.NoError(t, err)
+	all, err := webhooks.CreateWebhook(context.Background(), &model.Webhook{URL: failingServer.URL, Secret: "other"})
+	assert.NoError(t, err)
+	_, err = webhooks.CreateWebhook(context.Background(), &model.Webhook{URL: "ftp://example.com"})
+	assert.Error(t, err)
+
+	pet := &model.Pet{ID: 1, Name: "rex", Status: "available"}
+	assert.NoError(t, appendEvents(storage, petEvents(nil, pet)...))
+	assert.NoError(t, appendEvents(storage, petEvents(pet, &model.Pet{ID: 1, Name: "rex", Status: "sold"})...))
+	for _, e := range storage.events {
+		e.At = now.Add(-time.Second * 10)
+	}
+	assert.Len(t, storage.events, 3)
+
+	policy := DeliveryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, Lease: time.Second, Settle: time.Second}
+	d := NewDispatcher(log.NewNopLogger(), storage, storage, policy)
+	d.now = func() time.Time { return now }
+
+	assert.NoError(t, d.Dispatch())
+	assert.Equal(t, []string{model.EventPetStatusChanged}, ok.events)
+	assert.Len(t, failing.events, 3)
+	assert.Equal(t, int64(3), storage.cursors[dispatcherCursor])
+	assert.Len(t, storage.deliveries, 4)
+
+	// failed deliveries are retried after exponential delays, then dead
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Minute)
+		assert.NoError(t, d.Dispatch())
+	}
+	assert.Len(t, failing.events, 9)
+	dead, err := webhooks.FindDeliveries(context.Background(), all.ID, model.DeliveryDead)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 3)
+	for _, delivery := range dead {
+		assert.Equal(t, 3, delivery.Attempts)
+		assert.Equal(t, "webhook answered 500 Internal Server Error", delivery.LastError)
+	}
+	delivered, err := webhooks.FindDeliveries(context.Background(), sold.ID, model.DeliveryDelivered)
+	assert.NoError(t, err)
+	assert.Len(t, delivered, 1)
+
+	// dead deliveries can be retried
+	failing.status = http.StatusOK
+	_, err = webhooks.RetryDelivery(context.Background(), all.ID, delivered[0].ID)
+	assert.Error(t, err)
+	retried, err := webhooks.RetryDelivery(context.Background(), all.ID, dead[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeliveryPending, retried.Status)
+	webhooks.now = func() time.Time { return now }
+	assert.NoError(t, d.Dispatch())
+	assert.Len(t, failing.events, 10)
+	delivery, err := storage.RetrieveDeliveryByID(dead[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeliveryDelivered, delivery.Status)
+}
+
+func TestDispatcherClaimsDeliveries(t *testing.T) {
+	storage := newWebhookStorage()
+	now := time.Now().UTC()
+	ok := &receiver{secret: "s3cret", status: http.StatusNoContent}
+	server := httptest.NewServer(ok)
+	defer server.Close()
+	webhook := &model.Webhook{URL: server.URL, Secret: "s3cret", CreatedAt: now.Add(-time.Hour)}
+	assert.NoError(t, storage.CreateWebhook(webhook))
+	assert.NoError(t, appendEvents(storage, model.NewOrderEvent(model.EventOrderPlaced, &model.Order{ID: 1})))
+	assert.NoError(t, storage.EnqueueDeliveries([]*model.Delivery{model.NewDelivery(webhook, storage.events[0], now)}))
+
+	// another dispatcher attempting the delivery holds it for the lease
+	_, err := storage.ClaimDelivery("1-1", now, now.Add(DefaultDeliveryPolicy.Lease))
+	assert.NoError(t, err)
+	_, err = storage.ClaimDelivery("1-1", now, now.Add(DefaultDeliveryPolicy.Lease))
+	assert.Equal(t, model.ErrNotFound, err)
+	d := NewDispatcher(log.NewNopLogger(), storage, storage, DefaultDeliveryPolicy)
+	d.now = func() time.Time { return now }
+	assert.NoError(t, d.deliver())
+	assert.Empty(t, ok.events)
+
+	// and loses it once the lease is over, if it did not record the attempt
+	d.now = func() time.Time { return now.Add(DefaultDeliveryPolicy.Lease) }
+	assert.NoError(t, d.deliver())
+	assert.Equal(t, []string{model.EventOrderPlaced}, ok.events)
+	assert.NoError(t, d.deliver())
+	assert.Len(t, ok.events, 1)
+}
+
+func TestDispatcherSettle(t *testing.T) {
+	storage := newWebhookStorage()
+	now := time.Now().UTC()
+	assert.NoError(t, storage.CreateWebhook(&model.Webhook{URL: "http://localhost", CreatedAt: now.Add(-time.Hour)}))
+	assert.NoError(t, appendEvents(storage, model.NewOrderEvent(model.EventOrderPlaced, &model.Order{ID: 1})))
+	storage.events[0].At = now
+
+	d := NewDispatcher(log.NewNopLogger(), storage, storage, DefaultDeliveryPolicy)
+	d.now = func() time.Time { return now }
+	assert.NoError(t, d.enqueue())
+	assert.Empty(t, storage.deliveries)
+
+	d.now = func() time.Time { return now.Add(DefaultDeliveryPolicy.Settle) }
+	assert.NoError(t, d.enqueue())
+	assert.Len(t, storage.deliveries, 1)
+	assert.Equal(t, int64(1), storage.cursors[dispatcherCursor])
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Unix(1559433600, 0)
+	body := []byte(`{"id":1}`)
+	header := SignWebhook("secret", now.Unix(), body)
+
+	assert.NoError(t, VerifyWebhook("secret", header, body, time.Minute, now.Add(time.Second)))
+	assert.Equal(t, ErrInvalidSignature, VerifyWebhook("other", header, body, time.Minute, now))
+	assert.Equal(t, ErrInvalidSignature, VerifyWebhook("secret", header, []byte(`{"id":2}`), time.Minute, now))
+	assert.Equal(t, ErrInvalidSignature, VerifyWebhook("secret", header, body, time.Minute, now.Add(time.Hour)))
+	assert.Equal(t, ErrInvalidSignature, VerifyWebhook("secret", "v1=abc", body, time.Minute, now))
+}
+
+func TestDeliveryPolicyDelay(t *testing.T) {
+	p := DeliveryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 2*time.Second, p.Delay(2))
+	assert.Equal(t, 4*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Second, p.Delay(4))
+	assert.Equal(t, 5*time.Second, p.Delay(40))
+}
+
+func TestOrderStatusEvents(t *testing.T) {
+	placed := &model.Order{ID: 1, Status: model.OrderStatusPlaced}
+	assert.Empty(t, orderStatusEvents(placed, placed))
+
+	events := orderStatusEvents(placed, &model.Order{ID: 1, Status: model.OrderStatusDelivered})
+	assert.Len(t, events, 2)
+	assert.Equal(t, model.EventOrderStatusChanged, events[0].Type)
+	assert.Equal(t, model.OrderStatusPlaced, events[0].PreviousStatus)
+	assert.Equal(t, model.EventOrderDelivered, events[1].Type)
+	assert.Equal(t, model.CollectionOrders, events[1].Entity)
+}
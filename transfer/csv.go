@@ -0,0 +1,234 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV columns of each collection. Lists are joined by "|" and tags written as "id:name".
+var csvColumns = map[string][]string{
+	model.CollectionPets:       {"id", "name", "status", "categoryId", "categoryName", "photoUrls", "tags", "price", "currency", "stock"},
+	model.CollectionUsers:      {"id", "username", "firstName", "lastName", "email", "phone", "userStatus", "role"},
+	model.CollectionOrders:     {"id", "petId", "userId", "quantity", "shipDate", "status", "complete", "unitPrice", "currency", "total", "petHeld"},
+	model.CollectionCategories: {"id", "name"},
+	model.CollectionTags:       {"id", "name"},
+}
+
+const csvListSeparator = "|"
+
+type csvEncoder struct {
+	collection string
+	w          *csv.Writer
+}
+
+func newCSVEncoder(collection string, w io.Writer) (*csvEncoder, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns[collection]); err != nil {
+		return nil, err
+	}
+	return &csvEncoder{collection: collection, w: cw}, nil
+}
+
+func (e *csvEncoder) Encode(doc interface{}) error {
+	record, err := toRecord(doc)
+	if err != nil {
+		return err
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type csvDecoder struct {
+	collection string
+	r          *csv.Reader
+	header     map[string]int
+	// records read so far, the header included
+	records int
+}
+
+func newCSVDecoder(collection string, r io.Reader) (*csvDecoder, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	names, err := cr.Read()
+	if err == io.EOF {
+		return &csvDecoder{collection: collection, r: cr}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]int{}
+	for i, name := range names {
+		header[strings.TrimSpace(name)] = i
+	}
+	if _, ok := header["id"]; !ok {
+		return nil, fmt.Errorf("csv header of %s has no id column", collection)
+	}
+	return &csvDecoder{collection: collection, r: cr, header: header, records: 1}, nil
+}
+
+func (d *csvDecoder) Decode() (interface{}, error) {
+	if d.header == nil {
+		return nil, io.EOF
+	}
+	record, err := d.r.Read()
+	if err != nil {
+		if e, ok := err.(*csv.ParseError); ok {
+			d.records++
+			return nil, &DocumentError{Line: e.Line, Err: e.Err}
+		}
+		return nil, err
+	}
+	d.records++
+	line := d.records
+	field := func(name string) string {
+		if i, ok := d.header[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	doc, err := fromRecord(d.collection, field)
+	if err != nil {
+		return nil, &DocumentError{Line: line, Err: err}
+	}
+	return doc, nil
+}
+
+func toRecord(doc interface{}) ([]string, error) {
+	i64 := func(v int64) string { return strconv.FormatInt(v, 10) }
+	optional := func(v int64) string {
+		if v == 0 {
+			return ""
+		}
+		return i64(v)
+	}
+	switch d := doc.(type) {
+	case *model.Pet:
+		var categoryID, categoryName, stock string
+		if d.Category != nil {
+			categoryID, categoryName = i64(d.Category.ID), d.Category.Name
+		}
+		if d.Stock != nil {
+			stock = i64(*d.Stock)
+		}
+		var tags []string
+		for _, t := range d.Tags {
+			tags = append(tags, fmt.Sprintf("%d:%s", t.ID, t.Name))
+		}
+		return []string{i64(d.ID), d.Name, d.Status, categoryID, categoryName,
+			strings.Join(d.PhotoUrls, csvListSeparator), strings.Join(tags, csvListSeparator),
+			optional(d.Price), d.Currency, stock}, nil
+	case *model.User:
+		return []string{i64(d.ID), d.Username, d.Firstname, d.Lastname, d.Email, d.Phone,
+			strconv.FormatInt(int64(d.UserStatus), 10), d.Role}, nil
+	case *model.Order:
+		return []string{i64(d.ID), i64(d.PetID), optional(d.UserID), strconv.FormatInt(int64(d.Quantity), 10),
+			d.ShipDate.Format(time.RFC3339Nano), d.Status, strconv.FormatBool(d.Complete),
+			optional(d.UnitPrice), d.Currency, optional(d.Total), strconv.FormatBool(d.PetHeld)}, nil
+	case *model.Category:
+		return []string{i64(d.ID), d.Name}, nil
+	case *model.Tag:
+		return []string{i64(d.ID), d.Name}, nil
+	}
+	return nil, fmt.Errorf("unknown document type %T", doc)
+}
+
+func fromRecord(collection string, field func(name string) string) (interface{}, error) {
+	var err error
+	i64 := func(name string) int64 {
+		v := strings.TrimSpace(field(name))
+		if v == "" || err != nil {
+			return 0
+		}
+		n, e := strconv.ParseInt(v, 10, 64)
+		if e != nil {
+			err = fmt.Errorf("invalid %s %q", name, v)
+		}
+		return n
+	}
+	list := func(name string) []string {
+		if v := field(name); v != "" {
+			return strings.Split(v, csvListSeparator)
+		}
+		return []string{}
+	}
+
+	var doc interface{}
+	switch collection {
+	case model.CollectionPets:
+		pet := &model.Pet{
+			ID:        i64("id"),
+			Name:      field("name"),
+			Status:    field("status"),
+			PhotoUrls: list("photoUrls"),
+			Price:     i64("price"),
+			Currency:  field("currency"),
+		}
+		if field("categoryId") != "" || field("categoryName") != "" {
+			pet.Category = model.NewCategory(i64("categoryId"), field("categoryName"))
+		}
+		if strings.TrimSpace(field("stock")) != "" {
+			stock := i64("stock")
+			pet.Stock = &stock
+		}
+		for _, t := range list("tags") {
+			parts := strings.SplitN(t, ":", 2)
+			id, e := strconv.ParseInt(parts[0], 10, 64)
+			if e != nil || len(parts) != 2 {
+				return nil, fmt.Errorf("invalid tag %q", t)
+			}
+			pet.AddTag(model.NewTag(id, parts[1]))
+		}
+		doc = pet
+	case model.CollectionUsers:
+		doc = &model.User{
+			ID:         i64("id"),
+			Username:   field("username"),
+			Firstname:  field("firstName"),
+			Lastname:   field("lastName"),
+			Email:      field("email"),
+			Phone:      field("phone"),
+			UserStatus: int32(i64("userStatus")),
+			Role:       field("role"),
+		}
+	case model.CollectionOrders:
+		order := &model.Order{
+			ID:        i64("id"),
+			PetID:     i64("petId"),
+			UserID:    i64("userId"),
+			Quantity:  int32(i64("quantity")),
+			Status:    field("status"),
+			Complete:  field("complete") == "true",
+			UnitPrice: i64("unitPrice"),
+			Currency:  field("currency"),
+			Total:     i64("total"),
+			PetHeld:   field("petHeld") == "true",
+		}
+		if v := field("shipDate"); v != "" {
+			shipDate, e := time.Parse(time.RFC3339Nano, v)
+			if e != nil {
+				return nil, fmt.Errorf("invalid shipDate %q", v)
+			}
+			order.ShipDate = shipDate
+		}
+		doc = order
+	case model.CollectionCategories:
+		doc = model.NewCategory(i64("id"), field("name"))
+	case model.CollectionTags:
+		doc = model.NewTag(i64("id"), field("name"))
+	default:
+		return nil, fmt.Errorf("unknown collection %s", collection)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
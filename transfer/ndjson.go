@@ -0,0 +1,61 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/cooljeffrey/petstore/model"
+	"io"
+	"strings"
+)
+
+// longest line accepted when reading NDJSON
+const maxLineSize = 16 * 1024 * 1024
+
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	bw := bufio.NewWriter(w)
+	return &ndjsonEncoder{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (e *ndjsonEncoder) Encode(doc interface{}) error {
+	return e.enc.Encode(doc)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type ndjsonDecoder struct {
+	collection string
+	scanner    *bufio.Scanner
+	line       int
+}
+
+func newNDJSONDecoder(collection string, r io.Reader) *ndjsonDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &ndjsonDecoder{collection: collection, scanner: scanner}
+}
+
+func (d *ndjsonDecoder) Decode() (interface{}, error) {
+	for d.scanner.Scan() {
+		d.line++
+		line := strings.TrimSpace(d.scanner.Text())
+		if line == "" {
+			continue
+		}
+		doc, _ := model.NewDocument(d.collection)
+		if err := json.Unmarshal([]byte(line), doc); err != nil {
+			return nil, &DocumentError{Line: d.line, Err: err}
+		}
+		return doc, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
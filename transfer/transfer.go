@@ -0,0 +1,150 @@
+// Package transfer moves the documents of storage collections to and from NDJSON or CSV streams,
+// one document at a time.
+package transfer
+
+import (
+	"fmt"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"io"
+)
+
+type Format string
+
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// Collections which can be exported and imported, in the order to import them.
+var Collections = []string{
+	model.CollectionCategories,
+	model.CollectionTags,
+	model.CollectionPets,
+	model.CollectionUsers,
+	model.CollectionOrders,
+}
+
+// Stats counts what an import did, or would do in a dry run.
+type Stats struct {
+	Read     int64 `json:"read"`
+	Inserted int64 `json:"inserted"`
+	Replaced int64 `json:"replaced"`
+	Skipped  int64 `json:"skipped"`
+	Failed   int64 `json:"failed"`
+}
+
+// Encoder writes documents of one collection.
+type Encoder interface {
+	Encode(doc interface{}) error
+	// Flush writes buffered documents out
+	Flush() error
+}
+
+// Decoder reads documents of one collection, returning io.EOF after the last one.
+// Errors of a single document are returned as *DocumentError and reading can go on after them.
+type Decoder interface {
+	Decode() (interface{}, error)
+}
+
+// DocumentError tells which document of the stream could not be decoded.
+type DocumentError struct {
+	Line int
+	Err  error
+}
+
+func (e *DocumentError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func NewEncoder(collection string, format Format, w io.Writer) (Encoder, error) {
+	if _, err := model.NewDocument(collection); err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatNDJSON:
+		return newNDJSONEncoder(w), nil
+	case FormatCSV:
+		return newCSVEncoder(collection, w)
+	}
+	return nil, fmt.Errorf("unknown format %s", format)
+}
+
+func NewDecoder(collection string, format Format, r io.Reader) (Decoder, error) {
+	if _, err := model.NewDocument(collection); err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatNDJSON:
+		return newNDJSONDecoder(collection, r), nil
+	case FormatCSV:
+		return newCSVDecoder(collection, r)
+	}
+	return nil, fmt.Errorf("unknown format %s", format)
+}
+
+// Export writes all documents of collection to w and returns how many were written.
+// Passwords of users are never exported.
+func Export(storage model.Storage, collection string, format Format, w io.Writer) (int64, error) {
+	enc, err := NewEncoder(collection, format, w)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = storage.ExportCollection(collection, func(doc interface{}) error {
+		if u, ok := doc.(*model.User); ok {
+			u.Password = ""
+		}
+		n++
+		return enc.Encode(doc)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, enc.Flush()
+}
+
+// Import stores all documents of collection read from r. Documents which fail are logged and counted,
+// the import goes on with the next one.
+func Import(logger log.Logger, storage model.Storage, collection string, format Format, r io.Reader,
+	mode model.ConflictMode, dryRun bool) (Stats, error) {
+	var stats Stats
+	if mode != model.ConflictUpsert && mode != model.ConflictSkip {
+		return stats, fmt.Errorf("unknown conflict mode %s", mode)
+	}
+	dec, err := NewDecoder(collection, format, r)
+	if err != nil {
+		return stats, err
+	}
+	for {
+		doc, err := dec.Decode()
+		if err == io.EOF {
+			return stats, nil
+		}
+		stats.Read++
+		if e, ok := err.(*DocumentError); ok {
+			stats.Failed++
+			_ = level.Warn(logger).Log("collection", collection, "err", e)
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		outcome, err := storage.ImportDocument(collection, doc, mode, dryRun)
+		if err != nil {
+			stats.Failed++
+			id, _ := model.DocumentID(doc)
+			_ = level.Warn(logger).Log("collection", collection, "id", id, "err", err)
+			continue
+		}
+		switch outcome {
+		case model.ImportInserted:
+			stats.Inserted++
+		case model.ImportReplaced:
+			stats.Replaced++
+		case model.ImportSkipped:
+			stats.Skipped++
+		}
+	}
+}
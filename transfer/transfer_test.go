@@ -0,0 +1,173 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/cooljeffrey/petstore/model"
+	"github.com/cooljeffrey/petstore/service"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStorage keeps documents of one collection in memory, other Storage methods are not implemented.
+type fakeStorage struct {
+	model.Storage
+	docs map[int64]interface{}
+}
+
+func (f *fakeStorage) ExportCollection(collection string, fn func(doc interface{}) error) error {
+	for id := int64(0); id < 100; id++ {
+		if doc, ok := f.docs[id]; ok {
+			if err := fn(doc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeStorage) ImportDocument(collection string, doc interface{}, mode model.ConflictMode, dryRun bool) (model.ImportOutcome, error) {
+	id, _ := model.DocumentID(doc)
+	if id < 0 {
+		return "", errors.New("negative id")
+	}
+	outcome := model.ImportInserted
+	if _, ok := f.docs[id]; ok {
+		if mode == model.ConflictSkip {
+			return model.ImportSkipped, nil
+		}
+		outcome = model.ImportReplaced
+	}
+	if !dryRun {
+		f.docs[id] = doc
+	}
+	return outcome, nil
+}
+
+func TestExportNeverExportsPasswords(t *testing.T) {
+	storage := &fakeStorage{docs: map[int64]interface{}{
+		1: model.NewUser(1, "username1", "first", "last", "email@email.com", "secret", "123", 1),
+	}}
+	for _, format := range []Format{FormatNDJSON, FormatCSV} {
+		var buf bytes.Buffer
+		n, err := Export(storage, model.CollectionUsers, format, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.Contains(t, buf.String(), "username1")
+		assert.NotContains(t, buf.String(), "secret")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	stock := int64(4)
+	ts := time.Date(2019, 4, 27, 3, 43, 14, 876000000, time.UTC)
+	docs := map[string][]interface{}{
+		model.CollectionPets: {
+			model.NewPet(1, model.NewCategory(1, "cat"), "kitten", []string{"/images/1.jpg", "/images/2.jpg"},
+				[]*model.Tag{model.NewTag(1, "cute"), model.NewTag(2, "small")}, model.PetStatusAvailable),
+			&model.Pet{ID: 2, Name: "goldfish", Status: model.PetStatusAvailable, PhotoUrls: []string{}, Price: 250, Currency: "USD", Stock: &stock},
+		},
+		model.CollectionUsers: {
+			&model.User{ID: 1, Username: "username1", Firstname: "first", Email: "email@email.com", UserStatus: 1, Role: model.RoleStaff},
+		},
+		model.CollectionOrders: {
+			&model.Order{ID: 1, PetID: 2, UserID: 1, Quantity: 2, ShipDate: ts, Status: model.OrderStatusPlaced, UnitPrice: 250, Currency: "USD", Total: 500},
+		},
+		model.CollectionCategories: {model.NewCategory(1, "cat")},
+		model.CollectionTags:       {model.NewTag(1, "cute, small")},
+	}
+	for _, format := range []Format{FormatNDJSON, FormatCSV} {
+		for collection, expected := range docs {
+			var buf bytes.Buffer
+			enc, err := NewEncoder(collection, format, &buf)
+			assert.NoError(t, err)
+			for _, doc := range expected {
+				assert.NoError(t, enc.Encode(doc))
+			}
+			assert.NoError(t, enc.Flush())
+
+			dec, err := NewDecoder(collection, format, &buf)
+			assert.NoError(t, err)
+			for _, doc := range expected {
+				decoded, err := dec.Decode()
+				assert.NoError(t, err, "%s %s", format, collection)
+				assert.Equal(t, doc, decoded, "%s %s", format, collection)
+			}
+		}
+	}
+}
+
+func TestImportOfExportKeepsPasswords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "petstore-transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storage, err := model.NewBoltStorage(filepath.Join(dir, "petstore.db"), log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, storage.CreateUser(model.NewUser(1, "admin", "first", "last", "email@email.com", "secret", "123", 1)))
+	users := service.NewUserService(log.NewNopLogger(), storage, model.LockoutPolicy{}, time.Hour)
+
+	for _, format := range []Format{FormatNDJSON, FormatCSV} {
+		var buf bytes.Buffer
+		_, err = Export(storage, model.CollectionUsers, format, &buf)
+		assert.NoError(t, err)
+		stats, err := Import(log.NewNopLogger(), storage, model.CollectionUsers, format, &buf, model.ConflictUpsert, false)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Replaced)
+
+		_, err = users.Login(context.Background(), "admin", "secret")
+		assert.NoError(t, err)
+		_, err = users.Login(context.Background(), "admin", "")
+		assert.Equal(t, service.ErrInvalidCredentials, err)
+	}
+
+	// users without password cannot be created by imports
+	stats, err := Import(log.NewNopLogger(), storage, model.CollectionUsers, FormatNDJSON,
+		strings.NewReader(`{"id":2,"username":"bob"}`+"\n"), model.ConflictUpsert, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Failed)
+	_, err = storage.RetrieveUserByUsername("bob")
+	assert.Equal(t, model.ErrNotFound, err)
+}
+
+func TestImport(t *testing.T) {
+	storage := &fakeStorage{docs: map[int64]interface{}{1: model.NewTag(1, "old")}}
+	input := "{\"id\":1,\"name\":\"new\"}\n{\"id\":2,\"name\":\"two\"}\nnot json\n{\"id\":-1,\"name\":\"bad\"}\n"
+
+	stats, err := Import(log.NewNopLogger(), storage, model.CollectionTags, FormatNDJSON, strings.NewReader(input), model.ConflictSkip, true)
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Read: 4, Inserted: 1, Skipped: 1, Failed: 2}, stats)
+	assert.Equal(t, 1, len(storage.docs))
+
+	stats, err = Import(log.NewNopLogger(), storage, model.CollectionTags, FormatNDJSON, strings.NewReader(input), model.ConflictUpsert, false)
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Read: 4, Inserted: 1, Replaced: 1, Failed: 2}, stats)
+	assert.Equal(t, "new", storage.docs[1].(*model.Tag).Name)
+
+	_, err = Import(log.NewNopLogger(), storage, model.CollectionTags, FormatNDJSON, strings.NewReader(input), "merge", false)
+	assert.Error(t, err)
+}
+
+func TestCSVDecodeErrors(t *testing.T) {
+	dec, err := NewDecoder(model.CollectionOrders, FormatCSV, strings.NewReader("id,petId,shipDate\n1,x,\n2,1,2019-01-01T00:00:00Z\n"))
+	assert.NoError(t, err)
+	_, err = dec.Decode()
+	assert.IsType(t, &DocumentError{}, err)
+	assert.Equal(t, 2, err.(*DocumentError).Line)
+	doc, err := dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), doc.(*model.Order).ID)
+
+	_, err = NewDecoder(model.CollectionOrders, FormatCSV, strings.NewReader("petId\n1\n"))
+	assert.Error(t, err)
+}